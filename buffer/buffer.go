@@ -1,6 +1,7 @@
 package buffer
 
 import (
+	"encoding/base64"
 	"io"
 	"strconv"
 	"time"
@@ -89,42 +90,69 @@ func (b *Bytes) Bytes() []byte {
 
 // AppendInt appends an integer to the underlying buffer (assuming base 10).
 func (b *Bytes) AppendInt(i int64) {
-	s := strconv.FormatInt(i, 10)
-	b.AppendString(s)
+	b.commit(strconv.AppendInt(b.buf[:b.index], i, 10))
 }
 
 // AppendTime appends the time formatted using the specified layout.
 func (b *Bytes) AppendTime(t time.Time, layout string) {
-	s := t.Format(layout)
-	b.AppendString(s)
+	b.commit(t.AppendFormat(b.buf[:b.index], layout))
 }
 
 // AppendUint appends an unsigned integer to the underlying buffer (assuming
 // base 10).
 func (b *Bytes) AppendUint(i uint64) {
-	s := strconv.FormatUint(i, 10)
-	b.AppendString(s)
+	b.commit(strconv.AppendUint(b.buf[:b.index], i, 10))
 }
 
 // AppendBool appends a bool to the underlying buffer.
 func (b *Bytes) AppendBool(v bool) {
-	s := strconv.FormatBool(v)
-	b.AppendString(s)
+	b.commit(strconv.AppendBool(b.buf[:b.index], v))
 }
 
 // AppendFloat appends a float to the underlying buffer.
 func (b *Bytes) AppendFloat(f float64, bitSize int) {
-	s := strconv.FormatFloat(f, 'f', -1, bitSize)
-	b.AppendString(s)
+	b.commit(strconv.AppendFloat(b.buf[:b.index], f, 'f', -1, bitSize))
+}
+
+//commit adopts result of strconv or time append function called with the used part of the buffer
+func (b *Bytes) commit(result []byte) {
+	b.index = len(result)
+	if len(result) > len(b.buf) || cap(result) != cap(b.buf) {
+		b.buf = result[:cap(result)]
+	}
+}
+
+// AppendBase64 appends standard base64 encoded bytes
+func (b *Bytes) AppendBase64(bs []byte) {
+	size := base64.StdEncoding.EncodedLen(len(bs))
+	if size == 0 {
+		return
+	}
+	if size+b.index >= len(b.buf) {
+		grow := expandSize
+		if grow < size {
+			grow = size
+		}
+		b.buf = append(b.buf, make([]byte, grow)...)
+	}
+	base64.StdEncoding.Encode(b.buf[b.index:], bs)
+	b.index += size
 }
 
 // Trim trims any final character from the buffer
 func (b *Bytes) Trim(ch byte) {
-	if b.buf[b.index-1] == ch && b.index > 0 {
+	if b.index > 0 && b.buf[b.index-1] == ch {
 		b.index--
 	}
 }
 
+//Truncate discards all but the first size bytes
+func (b *Bytes) Truncate(size int) {
+	if size >= 0 && size < b.index {
+		b.index = size
+	}
+}
+
 //Reset reset index
 func (b *Bytes) Reset() {
 	b.index = 0
@@ -3,6 +3,7 @@ package buffer
 import (
 	"github.com/stretchr/testify/assert"
 	"testing"
+	"time"
 )
 
 func TestNewBytes(t *testing.T) {
@@ -36,7 +37,7 @@ func TestNewBytes(t *testing.T) {
 	}
 
 	for _, useCase := range useCases {
-		bs := Pool.Borrow()
+		bs := NewBytes(1024)
 		for _, item := range useCase.items {
 			switch v := item.(type) {
 			case string:
@@ -54,7 +55,33 @@ func TestNewBytes(t *testing.T) {
 			}
 		}
 		assert.EqualValues(t, useCase.expect, string(bs.Bytes()))
-		Pool.Put(bs)
 	}
 
 }
+
+func TestBytes_AppendTime(t *testing.T) {
+	bs := NewBytes(4)
+	ts := time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC)
+	bs.AppendInt(-1234567890)
+	bs.AppendTime(ts, time.RFC3339)
+	bs.AppendByte(',')
+	bs.AppendFloat(1.25, 32)
+	assert.EqualValues(t, "-12345678902021-01-02T03:04:05Z,1.25", string(bs.Bytes()))
+	bs.Reset()
+	bs.AppendUint(1 << 63)
+	assert.EqualValues(t, "9223372036854775808", string(bs.Bytes()))
+}
+
+func TestBytes_Append_Allocs(t *testing.T) {
+	bs := NewBytes(1024)
+	ts := time.Now()
+	allocs := testing.AllocsPerRun(100, func() {
+		bs.Reset()
+		bs.AppendInt(-1 << 40)
+		bs.AppendUint(1 << 63)
+		bs.AppendFloat(3.14159, 64)
+		bs.AppendBool(true)
+		bs.AppendTime(ts, time.RFC3339Nano)
+	})
+	assert.EqualValues(t, 0, allocs)
+}
@@ -0,0 +1,65 @@
+package buffer
+
+import "unicode/utf8"
+
+const hexDigits = "0123456789abcdef"
+
+//AppendJSONString appends RFC 8259 quoted and escaped string, invalid UTF-8 is replaced with U+FFFD,
+//with escapeHTML <, > and & are escaped as \u003c, \u003e and \u0026
+func (b *Bytes) AppendJSONString(s string, escapeHTML bool) {
+	b.AppendByte('"')
+	b.AppendJSONEscaped(s, escapeHTML)
+	b.AppendByte('"')
+}
+
+//AppendJSONEscaped appends RFC 8259 escaped string without surrounding quotes
+func (b *Bytes) AppendJSONEscaped(s string, escapeHTML bool) {
+	start := 0
+	for i := 0; i < len(s); {
+		c := s[i]
+		if c < utf8.RuneSelf {
+			if c >= 0x20 && c != '"' && c != '\\' && (!escapeHTML || (c != '<' && c != '>' && c != '&')) {
+				i++
+				continue
+			}
+			b.AppendString(s[start:i])
+			switch c {
+			case '"', '\\':
+				b.AppendByte('\\')
+				b.AppendByte(c)
+			case '\n':
+				b.AppendString(`\n`)
+			case '\r':
+				b.AppendString(`\r`)
+			case '\t':
+				b.AppendString(`\t`)
+			default:
+				b.AppendString(`\u00`)
+				b.AppendByte(hexDigits[c>>4])
+				b.AppendByte(hexDigits[c&0xF])
+			}
+			i++
+			start = i
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && size == 1 {
+			b.AppendString(s[start:i])
+			b.AppendString(`\ufffd`)
+			i += size
+			start = i
+			continue
+		}
+		//U+2028 and U+2029 are valid JSON, but break JavaScript parsers
+		if r == '\u2028' || r == '\u2029' {
+			b.AppendString(s[start:i])
+			b.AppendString(`\u202`)
+			b.AppendByte(hexDigits[r&0xF])
+			i += size
+			start = i
+			continue
+		}
+		i += size
+	}
+	b.AppendString(s[start:])
+}
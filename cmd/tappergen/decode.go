@@ -0,0 +1,232 @@
+package main
+
+import (
+	"fmt"
+	"go/types"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//writeDecoder writes struct Decode method, decoder unmarshals JSON message into auxiliary struct with pointer fields,
+//so only present keys are assigned
+func (g *generator) writeDecoder(w *writer, aStruct *structType) {
+	name := aStruct.named.Obj().Name()
+	g.imports["encoding/json"] = "json"
+	var decoded []*field
+	var auxTypes []string
+	var skipped []string
+	for _, item := range aStruct.fields {
+		auxType, ok := g.auxType(item)
+		if !ok {
+			skipped = append(skipped, item.name)
+			continue
+		}
+		decoded = append(decoded, item)
+		auxTypes = append(auxTypes, auxType)
+	}
+	w.line("")
+	if len(skipped) > 0 {
+		w.line("// Decode decodes %v fields from JSON message, %v fields can not be decoded", name, strings.Join(skipped, ", "))
+	} else {
+		w.line("// Decode decodes %v fields from JSON message", name)
+	}
+	w.line("func (v *%v) Decode(data []byte) error {", name)
+	w.line("var aux struct {")
+	for i, item := range decoded {
+		w.line("F%v *%v `json:%v`", i, auxTypes[i], strconv.Quote(item.name))
+	}
+	w.line("}")
+	w.line("if err := json.Unmarshal(data, &aux); err != nil {")
+	w.line("return err")
+	w.line("}")
+	for i, item := range decoded {
+		w.line("if aux.F%v != nil {", i)
+		for _, holder := range item.holders() {
+			w.line("if %v == nil {", holder)
+			w.line("%v = new(%v)", holder, g.typeName(holderType(item, holder)))
+			w.line("}")
+		}
+		g.assign(w, item, fmt.Sprintf("*aux.F%v", i))
+		w.line("}")
+	}
+	w.line("return nil")
+	w.line("}")
+}
+
+//holderType returns embedded struct type of the holder expression
+func holderType(item *field, holder string) types.Type {
+	for i := range item.path {
+		if (&field{path: item.path[:i+1]}).expr() == holder {
+			return item.path[i].Type().(*types.Pointer).Elem()
+		}
+	}
+	return nil
+}
+
+//auxType returns auxiliary JSON field type or false if value can not be decoded
+func (g *generator) auxType(item *field) (string, bool) {
+	val := item.value
+	switch val.kind {
+	case kindInt, kindInt64, kindUint, kindFloat64, kindFloat32, kindBool, kindDuration:
+		if item.tag.AsString {
+			return "string", true
+		}
+		if val.kind == kindDuration {
+			return "int64", true
+		}
+		return g.typeName(val.goType.Underlying()), true
+	case kindString, kindTime:
+		return "string", true
+	case kindBytes:
+		return "[]byte", true
+	case kindSlice:
+		return "[]" + g.typeName(val.elem.goType.Underlying()), true
+	case kindObject:
+		if !val.generated && !hasMethod(types.NewPointer(val.goType), true, "Decode", []string{"[]byte"}, []string{"error"}) {
+			return "", false
+		}
+		return "json.RawMessage", true
+	case kindObjects:
+		return "[]json.RawMessage", true
+	case kindMap:
+		if val.elem != nil {
+			switch val.elem.kind {
+			case kindObject, kindStream, kindText, kindStringer:
+				return "", false
+			}
+		}
+		return g.typeName(item.path[len(item.path)-1].Type()), true
+	case kindText:
+		if !hasMethod(types.NewPointer(val.goType), true, "UnmarshalText", []string{"[]byte"}, []string{"error"}) {
+			return "", false
+		}
+		return "string", true
+	}
+	return "", false
+}
+
+//assign writes statements assigning auxiliary value to the field
+func (g *generator) assign(w *writer, item *field, src string) {
+	target := item.expr()
+	val := item.value
+	fieldType := item.path[len(item.path)-1].Type()
+	switch val.kind {
+	case kindInt, kindInt64, kindUint, kindFloat64, kindFloat32, kindBool, kindDuration:
+		if !item.tag.AsString {
+			w.line("%v = %v", target, g.convert(src, val.goType.Underlying(), val.goType))
+			break
+		}
+		w.line("parsed, err := %v", g.parse(src, val))
+		g.returnError(w, item.name)
+		w.line("%v = %v", target, g.convert("parsed", g.parsedType(val), val.goType))
+	case kindString, kindBytes:
+		w.line("%v = %v", target, g.convert(src, val.goType.Underlying(), val.goType))
+	case kindMap:
+		w.line("%v = %v", target, src)
+	case kindTime:
+		layout := item.format
+		if layout == "" { //JSON message native time layout
+			layout = time.RFC3339Nano
+		}
+		g.imports["time"] = "time"
+		w.line("parsed, err := time.Parse(%v, %v)", g.layout(layout), src)
+		g.returnError(w, item.name)
+		if val.pointer {
+			w.line("%v = &parsed", target)
+		} else {
+			w.line("%v = parsed", target)
+		}
+	case kindSlice:
+		if types.Identical(val.elem.goType, val.elem.goType.Underlying()) {
+			w.line("%v = %v", target, g.convert(src, types.NewSlice(val.elem.goType), fieldType))
+			break
+		}
+		w.line("%v = make(%v, len(%v))", target, g.typeName(fieldType), src)
+		w.line("for i, item := range %v {", src)
+		w.line("%v[i] = %v", target, g.convert("item", val.elem.goType.Underlying(), val.elem.goType))
+		w.line("}")
+	case kindObject:
+		if val.pointer {
+			w.line("%v = new(%v)", target, g.typeName(val.goType))
+		}
+		w.line("if err := %v.Decode(%v); err != nil {", target, src)
+		w.line("return err")
+		w.line("}")
+	case kindObjects:
+		w.line("%v = make(%v, len(%v))", target, g.typeName(fieldType), src)
+		w.line("for i := range %v {", src)
+		if val.elem.pointer {
+			w.line("%v[i] = new(%v)", target, g.typeName(val.elem.goType))
+		}
+		w.line("if err := %v[i].Decode((%v)[i]); err != nil {", target, src)
+		w.line("return err")
+		w.line("}")
+		w.line("}")
+	case kindText:
+		if val.pointer {
+			w.line("%v = new(%v)", target, g.typeName(val.goType))
+		}
+		w.line("err := %v.UnmarshalText([]byte(%v))", target, src)
+		g.returnError(w, item.name)
+	}
+}
+
+//parse returns string option parsing expression
+func (g *generator) parse(src string, val *value) string {
+	if val.kind == kindDuration {
+		g.imports["time"] = "time"
+		return "time.ParseDuration(" + src + ")"
+	}
+	g.imports["strconv"] = "strconv"
+	basic := val.goType.Underlying().(*types.Basic)
+	switch val.kind {
+	case kindInt, kindInt64:
+		return fmt.Sprintf("strconv.ParseInt(%v, 10, %v)", src, bitSize(basic))
+	case kindUint:
+		return fmt.Sprintf("strconv.ParseUint(%v, 10, %v)", src, bitSize(basic))
+	case kindFloat32:
+		return fmt.Sprintf("strconv.ParseFloat(%v, 32)", src)
+	case kindFloat64:
+		return fmt.Sprintf("strconv.ParseFloat(%v, 64)", src)
+	}
+	return "strconv.ParseBool(" + src + ")"
+}
+
+//parsedType returns string option parsing result type
+func (g *generator) parsedType(val *value) types.Type {
+	switch val.kind {
+	case kindDuration:
+		return val.goType
+	case kindInt, kindInt64:
+		return types.Typ[types.Int64]
+	case kindUint:
+		return types.Typ[types.Uint64]
+	case kindFloat32, kindFloat64:
+		return types.Typ[types.Float64]
+	}
+	return types.Typ[types.Bool]
+}
+
+//returnError writes decoding error check
+func (g *generator) returnError(w *writer, name string) {
+	g.imports["fmt"] = "fmt"
+	w.line("if err != nil {")
+	w.line("return fmt.Errorf(%v, err)", strconv.Quote("invalid "+name+": %w"))
+	w.line("}")
+}
+
+//bitSize returns strconv bit size of integer type, 0 for int and uint
+func bitSize(basic *types.Basic) int {
+	switch basic.Kind() {
+	case types.Int8, types.Uint8:
+		return 8
+	case types.Int16, types.Uint16:
+		return 16
+	case types.Int32, types.Uint32:
+		return 32
+	case types.Int64, types.Uint64:
+		return 64
+	}
+	return 0
+}
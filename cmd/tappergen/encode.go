@@ -0,0 +1,321 @@
+package main
+
+import (
+	"go/types"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//putOptions represents value encoding options
+type putOptions struct {
+	omitEmpty bool
+	asString  bool
+	format    string
+	mapValue  bool //map value is not addressable and empty string is not skipped
+	visit     bool //recursive struct values are encoded with the visit of the encoded struct
+	helper    string
+}
+
+//writeEncoder writes struct Encode method
+func (g *generator) writeEncoder(w *writer, aStruct *structType) {
+	name := aStruct.named.Obj().Name()
+	g.imports[ioPath] = "io"
+	w.line("")
+	w.line("// Encode encodes %v fields", name)
+	w.line("func (v *%v) Encode(stream io.Stream) {", name)
+	if aStruct.recursive {
+		g.imports[encoderPath] = "encoder"
+		w.line("visit := encoder.NewVisit(v)")
+		w.line("v.EncodeVisit(stream, visit)")
+		w.line("visit.Release()")
+		w.line("}")
+		w.line("")
+		w.line("// EncodeVisit encodes %v fields, nested values visited by the ancestors are skipped", name)
+		w.line("func (v *%v) EncodeVisit(stream io.Stream, visit *encoder.Visit) {", name)
+	}
+	for _, item := range aStruct.fields {
+		holders := item.holders()
+		for i := range holders {
+			holders[i] += " != nil"
+		}
+		g.open(w, holders)
+		options := &putOptions{omitEmpty: item.tag.OmitEmpty, asString: item.tag.AsString, format: item.format, visit: aStruct.recursive}
+		if item.kind == kindMap && item.elem != nil {
+			options.helper = item.helper()
+		}
+		g.put(w, strconv.Quote(item.name), item.expr(), item.value, options)
+		g.close(w, holders)
+	}
+	w.line("}")
+}
+
+//writeMapEncoder writes map field encoder type
+func (g *generator) writeMapEncoder(w *writer, item *field) {
+	mapType := item.path[len(item.path)-1].Type().Underlying().(*types.Map)
+	helper := item.helper()
+	g.imports[encoderPath] = "encoder"
+	w.line("")
+	w.line("// %v encodes %v.%v entries in key order", helper, item.owner.named.Obj().Name(), item.path[len(item.path)-1].Name())
+	w.line("type %v %v", helper, g.typeName(mapType))
+	w.line("")
+	w.line("// Encode encodes map entries")
+	w.line("func (m %v) Encode(stream io.Stream) {", helper)
+	w.line("scratch := encoder.Borrow()")
+	if item.elem.kind == kindObject && !item.elem.pointer {
+		w.line("var value %v //map values are not addressable, the holder is shared by the entries", g.typeName(item.elem.goType))
+	}
+	w.line("for key := range m {")
+	w.line("scratch.Keys = append(scratch.Keys, %v)", g.convert("key", mapType.Key(), types.Typ[types.String]))
+	w.line("}")
+	w.line("scratch.SortKeys()")
+	w.line("for _, key := range scratch.Keys {")
+	g.put(w, "key", "m["+g.convert("key", types.Typ[types.String], mapType.Key())+"]", item.elem, &putOptions{format: time.RFC3339, mapValue: true})
+	w.line("}")
+	w.line("scratch.Release()")
+	w.line("}")
+}
+
+//put writes value encoding statements
+func (g *generator) put(w *writer, key, expr string, val *value, options *putOptions) {
+	var conditions []string
+	if val.pointer {
+		conditions = append(conditions, expr+" != nil")
+	}
+	switch val.kind {
+	case kindInt, kindInt64, kindUint, kindFloat64, kindFloat32, kindDuration:
+		if options.omitEmpty {
+			conditions = append(conditions, expr+" != 0")
+		}
+		g.open(w, conditions)
+		switch {
+		case options.asString:
+			w.line("stream.PutString(%v, %v)", key, g.formatNumber(expr, val))
+		case val.kind == kindDuration:
+			w.line("stream.PutDuration(%v, %v)", key, expr)
+		default:
+			basic := numberTypes[val.kind]
+			w.line("stream.%v(%v, %v)", numberPuts[val.kind], key, g.convert(expr, val.goType, types.Typ[basic]))
+		}
+	case kindString:
+		g.open(w, conditions)
+		put := "PutNonEmptyString"
+		if options.mapValue {
+			put = "PutString"
+		}
+		w.line("stream.%v(%v, %v)", put, key, g.convert(expr, val.goType, types.Typ[types.String]))
+	case kindBool:
+		if options.omitEmpty {
+			conditions = append(conditions, expr)
+		}
+		g.open(w, conditions)
+		value := g.convert(expr, val.goType, types.Typ[types.Bool])
+		if options.asString {
+			g.imports["strconv"] = "strconv"
+			w.line("stream.PutString(%v, strconv.FormatBool(%v))", key, value)
+		} else {
+			w.line("stream.PutBool(%v, %v)", key, value)
+		}
+	case kindTime:
+		if options.omitEmpty {
+			conditions = append(conditions, "!"+expr+".IsZero()")
+		}
+		g.open(w, conditions)
+		value := expr
+		if val.pointer {
+			value = "*" + expr
+		}
+		w.line("stream.PutTime(%v, %v, %v)", key, value, g.layout(options.format))
+	case kindBytes:
+		if options.omitEmpty {
+			conditions = append(conditions, "len("+expr+") > 0")
+		}
+		g.open(w, conditions)
+		w.line("stream.PutB64EncodedBytes(%v, %v)", key, expr)
+	case kindSlice:
+		if options.omitEmpty {
+			conditions = append(conditions, "len("+expr+") > 0")
+		}
+		g.open(w, conditions)
+		basic := types.Typ[sliceTypes[val.elem.kind]]
+		if types.Identical(val.elem.goType, basic) {
+			w.line("stream.%v(%v, %v)", slicePuts[val.elem.kind], key, expr)
+			break
+		}
+		if len(conditions) == 0 {
+			w.line("{")
+		}
+		values := "scratch." + sliceScratch[val.elem.kind]
+		g.imports[encoderPath] = "encoder"
+		w.line("scratch := encoder.Borrow()")
+		w.line("for _, item := range %v {", expr)
+		w.line("%v = append(%v, %v)", values, values, g.convert("item", val.elem.goType, basic))
+		w.line("}")
+		w.line("stream.%v(%v, %v)", slicePuts[val.elem.kind], key, values)
+		w.line("scratch.Release()")
+		if len(conditions) == 0 {
+			w.line("}")
+		}
+	case kindObject:
+		g.open(w, conditions)
+		object := "&" + expr
+		if val.pointer {
+			object = expr
+		}
+		switch {
+		case options.visit && g.isRecursive(val):
+			w.line("if child := visit.Visit(%v); child != nil {", object)
+			w.line("stream.PutObject(%v, child)", key)
+			w.line("child.Release()")
+			w.line("}")
+		case options.mapValue && !val.pointer:
+			w.line("value = %v", expr)
+			w.line("stream.PutObject(%v, &value)", key)
+		default:
+			w.line("stream.PutObject(%v, %v)", key, object)
+		}
+	case kindObjects:
+		if options.omitEmpty {
+			conditions = append(conditions, "len("+expr+") > 0")
+		}
+		g.open(w, conditions)
+		if len(conditions) == 0 {
+			w.line("{")
+		}
+		g.imports[encoderPath] = "encoder"
+		object := "&" + expr + "[i]"
+		if val.elem.pointer {
+			object = expr + "[i]"
+		}
+		w.line("scratch := encoder.Borrow()")
+		w.line("for i := range %v {", expr)
+		if val.elem.pointer {
+			w.line("if %v != nil {", object)
+		}
+		if options.visit && g.isRecursive(val.elem) {
+			w.line("if child := visit.Visit(%v); child != nil {", object)
+			w.line("scratch.Objects = append(scratch.Objects, child)")
+			w.line("}")
+		} else {
+			w.line("scratch.Objects = append(scratch.Objects, %v)", object)
+		}
+		if val.elem.pointer {
+			w.line("}")
+		}
+		w.line("}")
+		w.line("stream.PutObjects(%v, scratch.Objects)", key)
+		w.line("scratch.Release()")
+		if len(conditions) == 0 {
+			w.line("}")
+		}
+	case kindMap:
+		if options.omitEmpty {
+			conditions = append(conditions, "len("+expr+") > 0")
+		} else {
+			conditions = append(conditions, expr+" != nil")
+		}
+		g.open(w, conditions)
+		if val.elem == nil {
+			g.imports[encoderPath] = "encoder"
+			w.line("stream.PutObject(%v, encoder.Map(%v))", key, expr)
+		} else {
+			w.line("stream.PutObject(%v, %v(%v))", key, options.helper, expr)
+		}
+	case kindStream:
+		g.open(w, conditions)
+		w.line("%v.MarshalStream(%v, stream)", expr, key)
+	case kindText:
+		g.open(w, conditions)
+		w.line("if text, err := %v.MarshalText(); err != nil {", expr)
+		w.line("stream.PutNull(%v)", key)
+		if options.omitEmpty {
+			w.line("} else if len(text) > 0 {")
+		} else {
+			w.line("} else {")
+		}
+		w.line("stream.PutString(%v, string(text))", key)
+		w.line("}")
+	case kindStringer:
+		g.open(w, conditions)
+		if options.omitEmpty {
+			w.line("if text := %v.String(); text != \"\" {", expr)
+			w.line("stream.PutString(%v, text)", key)
+			w.line("}")
+		} else {
+			w.line("stream.PutString(%v, %v.String())", key, expr)
+		}
+	}
+	g.close(w, conditions)
+}
+
+//isRecursive returns true if the value is generated recursive struct
+func (g *generator) isRecursive(val *value) bool {
+	nested := g.nested(val)
+	return nested != nil && nested.recursive
+}
+
+//formatNumber returns number or duration string expression
+func (g *generator) formatNumber(expr string, val *value) string {
+	if val.kind == kindDuration {
+		return expr + ".String()"
+	}
+	g.imports["strconv"] = "strconv"
+	value := g.convert(expr, val.goType, types.Typ[numberTypes[val.kind]])
+	switch val.kind {
+	case kindInt:
+		return "strconv.Itoa(" + value + ")"
+	case kindInt64:
+		return "strconv.FormatInt(" + value + ", 10)"
+	case kindUint:
+		return "strconv.FormatUint(" + value + ", 10)"
+	case kindFloat32:
+		return "strconv.FormatFloat(float64(" + value + "), 'g', -1, 32)"
+	}
+	return "strconv.FormatFloat(" + value + ", 'g', -1, 64)"
+}
+
+//layout returns time layout expression
+func (g *generator) layout(layout string) string {
+	switch layout {
+	case time.RFC3339:
+		g.imports["time"] = "time"
+		return "time.RFC3339"
+	case time.RFC3339Nano:
+		g.imports["time"] = "time"
+		return "time.RFC3339Nano"
+	}
+	return strconv.Quote(layout)
+}
+
+//convert returns conversion expression if types are not identical
+func (g *generator) convert(expr string, from, to types.Type) string {
+	if types.Identical(from, to) {
+		return expr
+	}
+	return g.typeName(to) + "(" + expr + ")"
+}
+
+//open writes conditional block if conditions are not empty
+func (g *generator) open(w *writer, conditions []string) {
+	if len(conditions) > 0 {
+		w.line("if %v {", strings.Join(conditions, " && "))
+	}
+}
+
+//close closes conditional block opened with open
+func (g *generator) close(w *writer, conditions []string) {
+	if len(conditions) > 0 {
+		w.line("}")
+	}
+}
+
+const (
+	ioPath      = "github.com/viant/tapper/io"
+	encoderPath = "github.com/viant/tapper/io/encoder"
+)
+
+var numberTypes = map[kind]types.BasicKind{kindInt: types.Int, kindInt64: types.Int64, kindUint: types.Uint64, kindFloat64: types.Float64, kindFloat32: types.Float32}
+var numberPuts = map[kind]string{kindInt: "PutInt", kindInt64: "PutInt64", kindUint: "PutUint64", kindFloat64: "PutFloat", kindFloat32: "PutFloat32"}
+var sliceTypes = map[kind]types.BasicKind{kindInt: types.Int, kindInt64: types.Int, kindUint: types.Uint64, kindFloat64: types.Float64, kindFloat32: types.Float64, kindBool: types.Bool, kindString: types.String}
+var sliceScratch = map[kind]string{kindInt: "Ints", kindInt64: "Ints", kindUint: "UInts", kindFloat64: "Floats", kindFloat32: "Floats", kindBool: "Bools", kindString: "Strings"}
+var slicePuts = map[kind]string{kindInt: "PutInts", kindInt64: "PutInts", kindUint: "PutUInts", kindFloat64: "PutFloats", kindFloat32: "PutFloats", kindBool: "PutBools", kindString: "PutStrings"}
@@ -0,0 +1,344 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/pkg/errors"
+	"github.com/viant/tapper/io/encoder"
+	"go/ast"
+	"go/build"
+	"go/format"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+//Options represents generator options
+type Options struct {
+	Dir    string   //package directory
+	Types  []string //struct type names
+	Output string   //generated file name, it is excluded from the package sources
+	Decode bool     //generates JSON message decoders
+}
+
+//generator generates io.Encoder and io.Decoder implementations
+type generator struct {
+	*Options
+	pkg     *types.Package
+	structs []*structType //generated structs, nested struct types are included after requested types
+	index   map[*types.Named]*structType
+	imports map[string]string //package path to name
+	helpers []*field          //fields with generated map encoder type
+}
+
+//structType represents generated struct
+type structType struct {
+	named     *types.Named
+	fields    []*field
+	recursive bool //struct references itself through nested struct fields, cyclic values are skipped
+}
+
+//field represents generated struct field, embedded struct fields are flattened as in encoder.Provider
+type field struct {
+	*value
+	name   string       //encoded key
+	path   []*types.Var //embedded struct fields followed by the field
+	tag    *encoder.Tag
+	format string //time layout
+	owner  *structType
+}
+
+//expr returns field selector expression
+func (f *field) expr() string {
+	names := make([]string, 0, len(f.path))
+	for _, item := range f.path {
+		names = append(names, item.Name())
+	}
+	return "v." + strings.Join(names, ".")
+}
+
+//holders returns embedded struct pointer selector expressions
+func (f *field) holders() []string {
+	var result []string
+	for i, item := range f.path[:len(f.path)-1] {
+		if _, ok := item.Type().(*types.Pointer); ok {
+			result = append(result, (&field{path: f.path[:i+1]}).expr())
+		}
+	}
+	return result
+}
+
+//helper returns generated map encoder type name
+func (f *field) helper() string {
+	name := f.owner.named.Obj().Name()
+	return strings.ToLower(name[:1]) + name[1:] + f.path[len(f.path)-1].Name() + "Map"
+}
+
+//Generate returns generated source of the package struct types
+func Generate(options *Options) ([]byte, error) {
+	g := &generator{Options: options, index: map[*types.Named]*structType{}, imports: map[string]string{}}
+	if err := g.load(); err != nil {
+		return nil, err
+	}
+	for _, name := range options.Types {
+		object := g.pkg.Scope().Lookup(name)
+		if object == nil {
+			return nil, errors.Errorf("type %v was not found in %v package", name, g.pkg.Name())
+		}
+		named, ok := object.Type().(*types.Named)
+		if !ok || !g.isLocalStruct(named) {
+			return nil, errors.Errorf("type %v is not a struct", name)
+		}
+		g.include(named)
+	}
+	for i := 0; i < len(g.structs); i++ { //nested struct types are appended while adding fields
+		if err := g.addFields(g.structs[i]); err != nil {
+			return nil, err
+		}
+	}
+	if err := g.markRecursive(); err != nil {
+		return nil, err
+	}
+	return g.source()
+}
+
+//load parses and type checks package sources, test files and generated output are excluded
+func (g *generator) load() error {
+	info, err := build.ImportDir(g.Dir, 0)
+	if err != nil {
+		return errors.Wrapf(err, "failed to load package %v", g.Dir)
+	}
+	fileSet := token.NewFileSet()
+	var files []*ast.File
+	for _, name := range info.GoFiles {
+		if name == g.Output {
+			continue
+		}
+		file, err := parser.ParseFile(fileSet, filepath.Join(g.Dir, name), nil, 0)
+		if err != nil {
+			return err
+		}
+		files = append(files, file)
+	}
+	config := &types.Config{Importer: importer.ForCompiler(fileSet, "source", nil)}
+	if g.pkg, err = config.Check(info.ImportPath, fileSet, files, nil); err != nil {
+		return errors.Wrapf(err, "failed to check package %v", g.Dir)
+	}
+	return nil
+}
+
+//include adds struct type to generated structs
+func (g *generator) include(named *types.Named) {
+	if _, ok := g.index[named]; ok {
+		return
+	}
+	result := &structType{named: named}
+	g.index[named] = result
+	g.structs = append(g.structs, result)
+}
+
+//addFields adds struct fields, embedded struct fields without tag name are flattened
+func (g *generator) addFields(owner *structType) error {
+	for _, method := range []string{"Encode", "Decode"} {
+		if method == "Decode" && !g.Decode {
+			continue
+		}
+		if object, index, _ := types.LookupFieldOrMethod(types.NewPointer(owner.named), true, g.pkg, method); object != nil && len(index) == 1 { //promoted methods are shadowed
+			return errors.Errorf("type %v already has %v field or method", owner.named.Obj().Name(), method)
+		}
+	}
+	if err := g.appendFields(owner, owner.named.Underlying().(*types.Struct), nil, map[types.Type]bool{owner.named: true}); err != nil {
+		return errors.Wrapf(err, "failed to generate %v", owner.named.Obj().Name())
+	}
+	owner.fields = dedupe(owner.fields)
+	for _, item := range owner.fields {
+		if item.kind == kindMap && item.elem != nil {
+			g.helpers = append(g.helpers, item)
+		}
+	}
+	return nil
+}
+
+func (g *generator) appendFields(owner *structType, aStruct *types.Struct, path []*types.Var, embedded map[types.Type]bool) error {
+	for i := 0; i < aStruct.NumFields(); i++ {
+		fieldVar := aStruct.Field(i)
+		if fieldVar.Pkg() != g.pkg && !fieldVar.Exported() { //not accessible
+			continue
+		}
+		tag := encoder.ParseTag(reflect.StructTag(aStruct.Tag(i)).Get(encoder.TagName))
+		if tag.Skip {
+			continue
+		}
+		fieldPath := append(append([]*types.Var{}, path...), fieldVar)
+		if embeddedType := structOf(fieldVar.Type()); fieldVar.Anonymous() && tag.Name == "" && embeddedType != nil {
+			if embedded[embeddedType] {
+				return errors.Errorf("recursive embedded struct: %v", types.TypeString(embeddedType, (*types.Package).Name))
+			}
+			embedded[embeddedType] = true
+			if err := g.appendFields(owner, embeddedType.Underlying().(*types.Struct), fieldPath, embedded); err != nil {
+				return err
+			}
+			delete(embedded, embeddedType)
+			continue
+		}
+		result := &field{name: fieldVar.Name(), path: fieldPath, tag: tag, format: time.RFC3339, owner: owner}
+		if tag.Name != "" {
+			result.name = tag.Name
+		}
+		var err error
+		if result.value, err = g.classify(fieldVar.Type(), true); err != nil {
+			return errors.Wrapf(err, "invalid field %v", fieldVar.Name())
+		}
+		if tag.HasFormat {
+			if result.kind != kindTime {
+				return errors.Errorf("format option is only supported by time fields: %v", fieldVar.Name())
+			}
+			result.format = tag.Format
+		}
+		owner.fields = append(owner.fields, result)
+	}
+	return nil
+}
+
+//markRecursive marks struct types referencing themselves through nested struct fields, map values can not be recursive
+func (g *generator) markRecursive() error {
+	for _, aStruct := range g.structs {
+		aStruct.recursive = g.reaches(aStruct, aStruct, map[*structType]bool{})
+	}
+	for _, aStruct := range g.structs {
+		for _, item := range aStruct.fields {
+			if nested := g.nested(item.value); item.kind == kindMap && nested != nil && nested.recursive {
+				return errors.Errorf("failed to generate %v: invalid field %v: not yet supported recursive map value type: %v",
+					aStruct.named.Obj().Name(), item.path[len(item.path)-1].Name(), types.TypeString(item.path[len(item.path)-1].Type(), (*types.Package).Name))
+			}
+		}
+	}
+	return nil
+}
+
+//reaches returns true if struct fields reference target struct directly or through nested structs
+func (g *generator) reaches(from, target *structType, visited map[*structType]bool) bool {
+	for _, item := range from.fields {
+		nested := g.nested(item.value)
+		if nested == nil || visited[nested] {
+			continue
+		}
+		if nested == target {
+			return true
+		}
+		visited[nested] = true
+		if g.reaches(nested, target, visited) {
+			return true
+		}
+	}
+	return false
+}
+
+//nested returns generated struct of nested object, struct slice item or map value
+func (g *generator) nested(val *value) *structType {
+	if (val.kind == kindObjects || val.kind == kindMap) && val.elem != nil {
+		val = val.elem
+	}
+	if val.kind != kindObject || !val.generated {
+		return nil
+	}
+	return g.index[val.goType.(*types.Named)]
+}
+
+//structOf returns struct or struct pointer named type, time is not considered a struct
+func structOf(aType types.Type) types.Type {
+	if ptr, ok := aType.(*types.Pointer); ok {
+		aType = ptr.Elem()
+	}
+	if _, ok := aType.Underlying().(*types.Struct); !ok || isNamed(aType, "time", "Time") {
+		return nil
+	}
+	if _, ok := aType.(*types.Named); !ok {
+		return nil
+	}
+	return aType
+}
+
+//dedupe removes fields with duplicated name, the least nested field wins, then the first declared one
+func dedupe(fields []*field) []*field {
+	var result = make([]*field, 0, len(fields))
+	index := map[string]int{}
+	for _, item := range fields {
+		i, ok := index[item.name]
+		if !ok {
+			index[item.name] = len(result)
+			result = append(result, item)
+			continue
+		}
+		if len(item.path) < len(result[i].path) {
+			result[i] = item
+		}
+	}
+	return result
+}
+
+//typeName returns type expression, other package imports are recorded
+func (g *generator) typeName(aType types.Type) string {
+	return types.TypeString(aType, func(pkg *types.Package) string {
+		if pkg == g.pkg {
+			return ""
+		}
+		g.imports[pkg.Path()] = pkg.Name()
+		return pkg.Name()
+	})
+}
+
+//source returns formatted generated source
+func (g *generator) source() ([]byte, error) {
+	body := &writer{}
+	for _, aStruct := range g.structs {
+		g.writeEncoder(body, aStruct)
+		if g.Decode {
+			g.writeDecoder(body, aStruct)
+		}
+	}
+	for _, item := range g.helpers {
+		g.writeMapEncoder(body, item)
+	}
+	var paths []string
+	for path := range g.imports {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	result := &writer{}
+	result.line("// Code generated by tappergen. DO NOT EDIT.")
+	result.line("")
+	result.line("package %v", g.pkg.Name())
+	result.line("")
+	result.line("import (")
+	for _, path := range paths {
+		result.line("%q", path)
+	}
+	result.line(")")
+	result.Write(body.Bytes())
+	formatted, err := format.Source(result.Bytes())
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to format generated source:\n%s", result.Bytes())
+	}
+	return formatted, nil
+}
+
+//writer represents generated source writer
+type writer struct {
+	bytes.Buffer
+}
+
+func (w *writer) line(format string, args ...interface{}) {
+	if len(args) == 0 {
+		w.WriteString(format)
+	} else {
+		w.WriteString(fmt.Sprintf(format, args...))
+	}
+	w.WriteByte('\n')
+}
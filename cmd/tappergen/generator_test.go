@@ -0,0 +1,109 @@
+package main
+
+import (
+	"flag"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "updates golden files")
+
+func TestGenerate(t *testing.T) {
+	var testCases = []struct {
+		description string
+		options     *Options
+		golden      string
+	}{
+		{
+			description: "encoders and decoders",
+			options:     &Options{Dir: "internal/golden", Types: []string{"Event"}, Output: "event_tapper.go", Decode: true},
+			golden:      "internal/golden/event_tapper.go",
+		},
+		{
+			description: "encoders only",
+			options:     &Options{Dir: "internal/golden", Types: []string{"Address", "Item"}, Output: "event_tapper.go"},
+			golden:      "testdata/encoder.golden",
+		},
+	}
+	for _, testCase := range testCases {
+		actual, err := Generate(testCase.options)
+		if !assert.Nil(t, err, testCase.description) {
+			continue
+		}
+		if *update {
+			assert.Nil(t, ioutil.WriteFile(testCase.golden, actual, 0644), testCase.description)
+			continue
+		}
+		expect, err := ioutil.ReadFile(testCase.golden)
+		if !assert.Nil(t, err, testCase.description) {
+			continue
+		}
+		assert.EqualValues(t, string(expect), string(actual), testCase.description)
+	}
+}
+
+func TestGenerate_Error(t *testing.T) {
+	var testCases = []struct {
+		description string
+		source      string
+		expect      string
+	}{
+		{
+			description: "missing type",
+			source:      "type Foo struct{}",
+			expect:      "type Event was not found in sample package",
+		},
+		{
+			description: "not a struct",
+			source:      "type Event int",
+			expect:      "type Event is not a struct",
+		},
+		{
+			description: "unsupported field",
+			source:      "type Event struct{ C chan int }",
+			expect:      "failed to generate Event: invalid field C: not yet supported type: chan int",
+		},
+		{
+			description: "format on non time field",
+			source:      "type Event struct{ ID int `tapper:\"id,format=RFC3339\"` }",
+			expect:      "failed to generate Event: format option is only supported by time fields: ID",
+		},
+		{
+			description: "map key",
+			source:      "type Event struct{ M map[int]string }",
+			expect:      "failed to generate Event: invalid field M: not yet supported map key type: map[int]string",
+		},
+		{
+			description: "other package struct",
+			source:      "import \"sync\"\ntype Event struct{ L sync.Mutex }",
+			expect:      "failed to generate Event: invalid field L: nested struct type has to be named and declared in sample package: sync.Mutex",
+		},
+		{
+			description: "declared method",
+			source:      "type Event struct{}\nfunc (e *Event) Encode() {}",
+			expect:      "type Event already has Encode field or method",
+		},
+		{
+			description: "recursive embedded struct",
+			source:      "type Event struct{ *Event }",
+			expect:      "failed to generate Event: recursive embedded struct: sample.Event",
+		},
+		{
+			description: "recursive map value",
+			source:      "type Event struct{ M map[string]Node }\ntype Node struct{ Next *Node }",
+			expect:      "failed to generate Event: invalid field M: not yet supported recursive map value type: map[string]sample.Node",
+		},
+	}
+	for _, testCase := range testCases {
+		dir := t.TempDir()
+		if !assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "sample.go"), []byte("package sample\n"+testCase.source+"\n"), 0644)) {
+			continue
+		}
+		_, err := Generate(&Options{Dir: dir, Types: []string{"Event"}, Output: "event_tapper.go", Decode: true})
+		if assert.NotNil(t, err, testCase.description) {
+			assert.EqualValues(t, testCase.expect, err.Error(), testCase.description)
+		}
+	}
+}
@@ -0,0 +1,524 @@
+// Code generated by tappergen. DO NOT EDIT.
+
+package golden
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/viant/tapper/io"
+	"github.com/viant/tapper/io/encoder"
+	"strconv"
+	"time"
+)
+
+// Encode encodes Event fields
+func (v *Event) Encode(stream io.Stream) {
+	stream.PutNonEmptyString("createdBy", v.audit.CreatedBy)
+	if v.Meta != nil {
+		stream.PutNonEmptyString("source", v.Meta.Source)
+	}
+	stream.PutInt("id", v.ID)
+	if v.Small != 0 {
+		stream.PutInt("small", int(v.Small))
+	}
+	stream.PutInt64("big", v.Big)
+	stream.PutUint64("count", uint64(v.Count))
+	stream.PutString("total", strconv.FormatUint(v.Total, 10))
+	stream.PutFloat("score", v.Score)
+	if v.Ratio != 0 {
+		stream.PutFloat32("ratio", v.Ratio)
+	}
+	stream.PutNonEmptyString("name", v.Name)
+	stream.PutBool("active", v.Active)
+	stream.PutInt("level", int(v.Level))
+	stream.PutDuration("elapsed", v.Elapsed)
+	stream.PutString("timeout", v.Timeout.String())
+	stream.PutTime("created", v.Created, time.RFC3339)
+	if !v.Day.IsZero() {
+		stream.PutTime("day", v.Day, "2006-01-02")
+	}
+	if v.Updated != nil {
+		stream.PutTime("updated", *v.Updated, time.RFC3339Nano)
+	}
+	stream.PutB64EncodedBytes("data", v.Data)
+	stream.PutInts("ids", v.IDs)
+	if len(v.Codes) > 0 {
+		scratch := encoder.Borrow()
+		for _, item := range v.Codes {
+			scratch.Ints = append(scratch.Ints, int(item))
+		}
+		stream.PutInts("codes", scratch.Ints)
+		scratch.Release()
+	}
+	stream.PutFloats("scores", v.Scores)
+	stream.PutBools("flags", v.Flags)
+	stream.PutStrings("tags", v.Tags)
+	if len(v.Levels) > 0 {
+		scratch := encoder.Borrow()
+		for _, item := range v.Levels {
+			scratch.Ints = append(scratch.Ints, int(item))
+		}
+		stream.PutInts("levels", scratch.Ints)
+		scratch.Release()
+	}
+	stream.PutString("status", v.Status.String())
+	v.Price.MarshalStream("price", stream)
+	if text, err := v.IP.MarshalText(); err != nil {
+		stream.PutNull("ip")
+	} else if len(text) > 0 {
+		stream.PutString("ip", string(text))
+	}
+	if text, err := v.Gateway.MarshalText(); err != nil {
+		stream.PutNull("gateway")
+	} else {
+		stream.PutString("gateway", string(text))
+	}
+	if text, err := v.Invalid.MarshalText(); err != nil {
+		stream.PutNull("invalid")
+	} else {
+		stream.PutString("invalid", string(text))
+	}
+	stream.PutObject("version", &v.Version)
+	stream.PutObject("address", &v.Address)
+	if v.Billing != nil {
+		stream.PutObject("billing", v.Billing)
+	}
+	{
+		scratch := encoder.Borrow()
+		for i := range v.Items {
+			scratch.Objects = append(scratch.Objects, &v.Items[i])
+		}
+		stream.PutObjects("items", scratch.Objects)
+		scratch.Release()
+	}
+	if len(v.Refs) > 0 {
+		scratch := encoder.Borrow()
+		for i := range v.Refs {
+			if v.Refs[i] != nil {
+				scratch.Objects = append(scratch.Objects, v.Refs[i])
+			}
+		}
+		stream.PutObjects("refs", scratch.Objects)
+		scratch.Release()
+	}
+	if v.Labels != nil {
+		stream.PutObject("labels", eventLabelsMap(v.Labels))
+	}
+	if len(v.Counts) > 0 {
+		stream.PutObject("counts", eventCountsMap(v.Counts))
+	}
+	if len(v.Attrs) > 0 {
+		stream.PutObject("attrs", encoder.Map(v.Attrs))
+	}
+	if len(v.Statuses) > 0 {
+		stream.PutObject("statuses", eventStatusesMap(v.Statuses))
+	}
+	if len(v.Places) > 0 {
+		stream.PutObject("places", eventPlacesMap(v.Places))
+	}
+	if v.Root != nil {
+		stream.PutObject("root", v.Root)
+	}
+}
+
+// Decode decodes Event fields from JSON message, status, price, invalid, statuses, places fields can not be decoded
+func (v *Event) Decode(data []byte) error {
+	var aux struct {
+		F0  *string                 `json:"createdBy"`
+		F1  *string                 `json:"source"`
+		F2  *int                    `json:"id"`
+		F3  *int16                  `json:"small"`
+		F4  *int64                  `json:"big"`
+		F5  *uint32                 `json:"count"`
+		F6  *string                 `json:"total"`
+		F7  *float64                `json:"score"`
+		F8  *float32                `json:"ratio"`
+		F9  *string                 `json:"name"`
+		F10 *bool                   `json:"active"`
+		F11 *int8                   `json:"level"`
+		F12 *int64                  `json:"elapsed"`
+		F13 *string                 `json:"timeout"`
+		F14 *string                 `json:"created"`
+		F15 *string                 `json:"day"`
+		F16 *string                 `json:"updated"`
+		F17 *[]byte                 `json:"data"`
+		F18 *[]int                  `json:"ids"`
+		F19 *[]int32                `json:"codes"`
+		F20 *[]float64              `json:"scores"`
+		F21 *[]bool                 `json:"flags"`
+		F22 *[]string               `json:"tags"`
+		F23 *[]int8                 `json:"levels"`
+		F24 *string                 `json:"ip"`
+		F25 *string                 `json:"gateway"`
+		F26 *json.RawMessage        `json:"version"`
+		F27 *json.RawMessage        `json:"address"`
+		F28 *json.RawMessage        `json:"billing"`
+		F29 *[]json.RawMessage      `json:"items"`
+		F30 *[]json.RawMessage      `json:"refs"`
+		F31 *map[string]string      `json:"labels"`
+		F32 *map[string]int         `json:"counts"`
+		F33 *map[string]interface{} `json:"attrs"`
+		F34 *json.RawMessage        `json:"root"`
+	}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	if aux.F0 != nil {
+		v.audit.CreatedBy = *aux.F0
+	}
+	if aux.F1 != nil {
+		if v.Meta == nil {
+			v.Meta = new(Meta)
+		}
+		v.Meta.Source = *aux.F1
+	}
+	if aux.F2 != nil {
+		v.ID = *aux.F2
+	}
+	if aux.F3 != nil {
+		v.Small = *aux.F3
+	}
+	if aux.F4 != nil {
+		v.Big = *aux.F4
+	}
+	if aux.F5 != nil {
+		v.Count = *aux.F5
+	}
+	if aux.F6 != nil {
+		parsed, err := strconv.ParseUint(*aux.F6, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid total: %w", err)
+		}
+		v.Total = parsed
+	}
+	if aux.F7 != nil {
+		v.Score = *aux.F7
+	}
+	if aux.F8 != nil {
+		v.Ratio = *aux.F8
+	}
+	if aux.F9 != nil {
+		v.Name = *aux.F9
+	}
+	if aux.F10 != nil {
+		v.Active = *aux.F10
+	}
+	if aux.F11 != nil {
+		v.Level = Level(*aux.F11)
+	}
+	if aux.F12 != nil {
+		v.Elapsed = time.Duration(*aux.F12)
+	}
+	if aux.F13 != nil {
+		parsed, err := time.ParseDuration(*aux.F13)
+		if err != nil {
+			return fmt.Errorf("invalid timeout: %w", err)
+		}
+		v.Timeout = parsed
+	}
+	if aux.F14 != nil {
+		parsed, err := time.Parse(time.RFC3339, *aux.F14)
+		if err != nil {
+			return fmt.Errorf("invalid created: %w", err)
+		}
+		v.Created = parsed
+	}
+	if aux.F15 != nil {
+		parsed, err := time.Parse("2006-01-02", *aux.F15)
+		if err != nil {
+			return fmt.Errorf("invalid day: %w", err)
+		}
+		v.Day = parsed
+	}
+	if aux.F16 != nil {
+		parsed, err := time.Parse(time.RFC3339Nano, *aux.F16)
+		if err != nil {
+			return fmt.Errorf("invalid updated: %w", err)
+		}
+		v.Updated = &parsed
+	}
+	if aux.F17 != nil {
+		v.Data = *aux.F17
+	}
+	if aux.F18 != nil {
+		v.IDs = *aux.F18
+	}
+	if aux.F19 != nil {
+		v.Codes = *aux.F19
+	}
+	if aux.F20 != nil {
+		v.Scores = *aux.F20
+	}
+	if aux.F21 != nil {
+		v.Flags = *aux.F21
+	}
+	if aux.F22 != nil {
+		v.Tags = *aux.F22
+	}
+	if aux.F23 != nil {
+		v.Levels = make([]Level, len(*aux.F23))
+		for i, item := range *aux.F23 {
+			v.Levels[i] = Level(item)
+		}
+	}
+	if aux.F24 != nil {
+		err := v.IP.UnmarshalText([]byte(*aux.F24))
+		if err != nil {
+			return fmt.Errorf("invalid ip: %w", err)
+		}
+	}
+	if aux.F25 != nil {
+		err := v.Gateway.UnmarshalText([]byte(*aux.F25))
+		if err != nil {
+			return fmt.Errorf("invalid gateway: %w", err)
+		}
+	}
+	if aux.F26 != nil {
+		if err := v.Version.Decode(*aux.F26); err != nil {
+			return err
+		}
+	}
+	if aux.F27 != nil {
+		if err := v.Address.Decode(*aux.F27); err != nil {
+			return err
+		}
+	}
+	if aux.F28 != nil {
+		v.Billing = new(Address)
+		if err := v.Billing.Decode(*aux.F28); err != nil {
+			return err
+		}
+	}
+	if aux.F29 != nil {
+		v.Items = make([]Item, len(*aux.F29))
+		for i := range *aux.F29 {
+			if err := v.Items[i].Decode((*aux.F29)[i]); err != nil {
+				return err
+			}
+		}
+	}
+	if aux.F30 != nil {
+		v.Refs = make([]*Item, len(*aux.F30))
+		for i := range *aux.F30 {
+			v.Refs[i] = new(Item)
+			if err := v.Refs[i].Decode((*aux.F30)[i]); err != nil {
+				return err
+			}
+		}
+	}
+	if aux.F31 != nil {
+		v.Labels = *aux.F31
+	}
+	if aux.F32 != nil {
+		v.Counts = *aux.F32
+	}
+	if aux.F33 != nil {
+		v.Attrs = *aux.F33
+	}
+	if aux.F34 != nil {
+		v.Root = new(Node)
+		if err := v.Root.Decode(*aux.F34); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Encode encodes Version fields
+func (v *Version) Encode(stream io.Stream) {
+	stream.PutInt("major", v.Major)
+	stream.PutInt("minor", v.Minor)
+}
+
+// Decode decodes Version fields from JSON message
+func (v *Version) Decode(data []byte) error {
+	var aux struct {
+		F0 *int `json:"major"`
+		F1 *int `json:"minor"`
+	}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	if aux.F0 != nil {
+		v.Major = *aux.F0
+	}
+	if aux.F1 != nil {
+		v.Minor = *aux.F1
+	}
+	return nil
+}
+
+// Encode encodes Address fields
+func (v *Address) Encode(stream io.Stream) {
+	stream.PutNonEmptyString("city", v.City)
+	stream.PutNonEmptyString("zip", v.Zip)
+}
+
+// Decode decodes Address fields from JSON message
+func (v *Address) Decode(data []byte) error {
+	var aux struct {
+		F0 *string `json:"city"`
+		F1 *string `json:"zip"`
+	}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	if aux.F0 != nil {
+		v.City = *aux.F0
+	}
+	if aux.F1 != nil {
+		v.Zip = *aux.F1
+	}
+	return nil
+}
+
+// Encode encodes Item fields
+func (v *Item) Encode(stream io.Stream) {
+	stream.PutNonEmptyString("sku", v.SKU)
+	stream.PutInt("qty", v.Qty)
+}
+
+// Decode decodes Item fields from JSON message
+func (v *Item) Decode(data []byte) error {
+	var aux struct {
+		F0 *string `json:"sku"`
+		F1 *int    `json:"qty"`
+	}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	if aux.F0 != nil {
+		v.SKU = *aux.F0
+	}
+	if aux.F1 != nil {
+		v.Qty = *aux.F1
+	}
+	return nil
+}
+
+// Encode encodes Node fields
+func (v *Node) Encode(stream io.Stream) {
+	visit := encoder.NewVisit(v)
+	v.EncodeVisit(stream, visit)
+	visit.Release()
+}
+
+// EncodeVisit encodes Node fields, nested values visited by the ancestors are skipped
+func (v *Node) EncodeVisit(stream io.Stream, visit *encoder.Visit) {
+	stream.PutNonEmptyString("name", v.Name)
+	if v.Next != nil {
+		if child := visit.Visit(v.Next); child != nil {
+			stream.PutObject("next", child)
+			child.Release()
+		}
+	}
+	if len(v.Children) > 0 {
+		scratch := encoder.Borrow()
+		for i := range v.Children {
+			if v.Children[i] != nil {
+				if child := visit.Visit(v.Children[i]); child != nil {
+					scratch.Objects = append(scratch.Objects, child)
+				}
+			}
+		}
+		stream.PutObjects("children", scratch.Objects)
+		scratch.Release()
+	}
+}
+
+// Decode decodes Node fields from JSON message
+func (v *Node) Decode(data []byte) error {
+	var aux struct {
+		F0 *string            `json:"name"`
+		F1 *json.RawMessage   `json:"next"`
+		F2 *[]json.RawMessage `json:"children"`
+	}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	if aux.F0 != nil {
+		v.Name = *aux.F0
+	}
+	if aux.F1 != nil {
+		v.Next = new(Node)
+		if err := v.Next.Decode(*aux.F1); err != nil {
+			return err
+		}
+	}
+	if aux.F2 != nil {
+		v.Children = make([]*Node, len(*aux.F2))
+		for i := range *aux.F2 {
+			v.Children[i] = new(Node)
+			if err := v.Children[i].Decode((*aux.F2)[i]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// eventLabelsMap encodes Event.Labels entries in key order
+type eventLabelsMap map[string]string
+
+// Encode encodes map entries
+func (m eventLabelsMap) Encode(stream io.Stream) {
+	scratch := encoder.Borrow()
+	for key := range m {
+		scratch.Keys = append(scratch.Keys, key)
+	}
+	scratch.SortKeys()
+	for _, key := range scratch.Keys {
+		stream.PutString(key, m[key])
+	}
+	scratch.Release()
+}
+
+// eventCountsMap encodes Event.Counts entries in key order
+type eventCountsMap map[string]int
+
+// Encode encodes map entries
+func (m eventCountsMap) Encode(stream io.Stream) {
+	scratch := encoder.Borrow()
+	for key := range m {
+		scratch.Keys = append(scratch.Keys, key)
+	}
+	scratch.SortKeys()
+	for _, key := range scratch.Keys {
+		stream.PutInt(key, m[key])
+	}
+	scratch.Release()
+}
+
+// eventStatusesMap encodes Event.Statuses entries in key order
+type eventStatusesMap map[string]Status
+
+// Encode encodes map entries
+func (m eventStatusesMap) Encode(stream io.Stream) {
+	scratch := encoder.Borrow()
+	for key := range m {
+		scratch.Keys = append(scratch.Keys, key)
+	}
+	scratch.SortKeys()
+	for _, key := range scratch.Keys {
+		stream.PutString(key, m[key].String())
+	}
+	scratch.Release()
+}
+
+// eventPlacesMap encodes Event.Places entries in key order
+type eventPlacesMap map[string]Address
+
+// Encode encodes map entries
+func (m eventPlacesMap) Encode(stream io.Stream) {
+	scratch := encoder.Borrow()
+	var value Address //map values are not addressable, the holder is shared by the entries
+	for key := range m {
+		scratch.Keys = append(scratch.Keys, key)
+	}
+	scratch.SortKeys()
+	for _, key := range scratch.Keys {
+		value = m[key]
+		stream.PutObject(key, &value)
+	}
+	scratch.Release()
+}
@@ -0,0 +1,132 @@
+//Package golden defines tappergen golden file types, event_tapper.go is generated and compared with generator output
+package golden
+
+import (
+	"errors"
+	"fmt"
+	"github.com/viant/tapper/io"
+	"net"
+	"time"
+)
+
+//go:generate go run github.com/viant/tapper/cmd/tappergen -type Event .
+
+//Event represents all supported field kinds
+type Event struct {
+	audit
+	*Meta
+	ID       int                    `tapper:"id"`
+	Small    int16                  `tapper:"small,omitempty"`
+	Big      int64                  `tapper:"big"`
+	Count    uint32                 `tapper:"count"`
+	Total    uint64                 `tapper:"total,string"`
+	Score    float64                `tapper:"score"`
+	Ratio    float32                `tapper:"ratio,omitempty"`
+	Name     string                 `tapper:"name"`
+	Active   bool                   `tapper:"active"`
+	Secret   string                 `tapper:"-"`
+	Level    Level                  `tapper:"level"`
+	Elapsed  time.Duration          `tapper:"elapsed"`
+	Timeout  time.Duration          `tapper:"timeout,string"`
+	Created  time.Time              `tapper:"created"`
+	Day      time.Time              `tapper:"day,omitempty,format=DateOnly"`
+	Updated  *time.Time             `tapper:"updated,format=RFC3339Nano"`
+	Data     []byte                 `tapper:"data"`
+	IDs      []int                  `tapper:"ids"`
+	Codes    []int32                `tapper:"codes,omitempty"`
+	Scores   []float64              `tapper:"scores"`
+	Flags    []bool                 `tapper:"flags"`
+	Tags     []string               `tapper:"tags"`
+	Levels   []Level                `tapper:"levels,omitempty"`
+	Status   Status                 `tapper:"status"`
+	Price    Money                  `tapper:"price"`
+	IP       net.IP                 `tapper:"ip,omitempty"`
+	Gateway  net.IP                 `tapper:"gateway"`
+	Invalid  Invalid                `tapper:"invalid"`
+	Version  Version                `tapper:"version"`
+	Address  Address                `tapper:"address"`
+	Billing  *Address               `tapper:"billing"`
+	Items    []Item                 `tapper:"items"`
+	Refs     []*Item                `tapper:"refs,omitempty"`
+	Labels   map[string]string      `tapper:"labels"`
+	Counts   map[string]int         `tapper:"counts,omitempty"`
+	Attrs    map[string]interface{} `tapper:"attrs,omitempty"`
+	Statuses map[string]Status      `tapper:"statuses,omitempty"`
+	Places   map[string]Address     `tapper:"places,omitempty"`
+	Root     *Node                  `tapper:"root"`
+}
+
+type audit struct {
+	CreatedBy string `tapper:"createdBy"`
+}
+
+//Meta represents embedded pointer struct
+type Meta struct {
+	Source string `tapper:"source"`
+	ID     string `tapper:"id"` //shadowed by Event.ID
+}
+
+//Level represents named integer
+type Level int8
+
+//Status represents enum encoded with fmt.Stringer
+type Status int
+
+//String returns status name
+func (s Status) String() string {
+	if s == 1 {
+		return "active"
+	}
+	return "unknown"
+}
+
+//Money represents cents amount encoded as float
+type Money int64
+
+//MarshalStream puts money as float
+func (m Money) MarshalStream(key string, stream io.Stream) {
+	stream.PutFloat(key, float64(m)/100)
+}
+
+//Invalid represents text marshaler failing to marshal
+type Invalid string
+
+var errInvalid = errors.New("invalid value")
+
+//MarshalText returns an error for non empty value
+func (i Invalid) MarshalText() ([]byte, error) {
+	if i != "" {
+		return nil, errInvalid
+	}
+	return nil, nil
+}
+
+//Version represents struct with fmt.Stringer encoded by its fields
+type Version struct {
+	Major int `tapper:"major"`
+	Minor int `tapper:"minor"`
+}
+
+//String returns version text
+func (v Version) String() string {
+	return fmt.Sprintf("%v.%v", v.Major, v.Minor)
+}
+
+//Address represents nested struct
+type Address struct {
+	City string `tapper:"city"`
+	Zip  string `tapper:"zip,omitempty"`
+}
+
+//Item represents struct slice item
+type Item struct {
+	SKU string `tapper:"sku"`
+	Qty int    `tapper:"qty"`
+}
+
+//Node represents recursive struct
+type Node struct {
+	Name     string  `tapper:"name"`
+	Next     *Node   `tapper:"next"`
+	Children []*Node `tapper:"children,omitempty"`
+}
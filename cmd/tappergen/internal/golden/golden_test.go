@@ -0,0 +1,209 @@
+package golden_test
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"github.com/viant/tapper/cmd/tappergen/internal/golden"
+	"github.com/viant/tapper/io"
+	"github.com/viant/tapper/io/encoder"
+	"github.com/viant/tapper/msg"
+	"github.com/viant/tapper/msg/json"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEvent_Encode(t *testing.T) {
+	cyclic := &golden.Node{Name: "cyclic"}
+	cyclic.Next = cyclic
+	cyclic.Children = []*golden.Node{{Name: "child", Next: cyclic}, cyclic}
+	var testCases = []struct {
+		description string
+		value       *golden.Event
+		expect      string
+	}{
+		{
+			description: "empty event",
+			value:       &golden.Event{},
+		},
+		{
+			description: "all field kinds",
+			value:       newEvent(),
+		},
+		{
+			description: "failed text marshaler and struct stringer",
+			value:       &golden.Event{Invalid: "x", Version: golden.Version{Major: 1, Minor: 2}},
+			expect:      `{"createdBy":"bob","id":0,"big":0,"count":0,"total":"0","score":0,"active":false,"level":0,"elapsed":0,"timeout":"0s","created":"0001-01-01T00:00:00Z","data":"","ids":[],"scores":[],"flags":[],"tags":[],"status":"unknown","price":0,"gateway":"","invalid":null,"version":{"major":1,"minor":2},"address":{},"items":[]}`,
+		},
+		{
+			description: "cyclic value",
+			value:       &golden.Event{Root: cyclic},
+			expect:      `{"createdBy":"bob","id":0,"big":0,"count":0,"total":"0","score":0,"active":false,"level":0,"elapsed":0,"timeout":"0s","created":"0001-01-01T00:00:00Z","data":"","ids":[],"scores":[],"flags":[],"tags":[],"status":"unknown","price":0,"gateway":"","invalid":"","version":{"major":0,"minor":0},"address":{},"items":[],"root":{"name":"cyclic","children":[{"name":"child"}]}}`,
+		},
+	}
+	for _, testCase := range testCases {
+		testCase.value.CreatedBy = "bob"
+		provider, err := encoder.New(testCase.value)
+		if !assert.Nil(t, err, testCase.description) {
+			continue
+		}
+		expect := encodeJSON(t, provider.New(testCase.value))
+		if testCase.expect != "" {
+			assert.EqualValues(t, testCase.expect, expect, testCase.description)
+		}
+		assert.EqualValues(t, expect, encodeJSON(t, testCase.value), testCase.description)
+	}
+}
+
+func TestEvent_Encode_Allocs(t *testing.T) {
+	value := newEvent()
+	message := msg.NewProvider(1024, 1, json.New).NewMessage()
+	value.Encode(message)
+	allocs := testing.AllocsPerRun(100, func() {
+		message.GetByteBuffer().Reset()
+		value.Encode(message)
+	})
+	//text marshalers (ip, gateway), string option formatting (timeout, total), struct map value holder (places)
+	//and JSON base64 bytes (data) allocate once each, the remaining fields including slices, maps and nested structs do not allocate
+	assert.EqualValues(t, 6, allocs)
+}
+
+func TestEvent_Decode(t *testing.T) {
+	updated := time.Date(2021, 3, 4, 5, 6, 7, 800, time.UTC)
+	value := &golden.Event{ //Status, Price and Statuses can not be decoded
+		Meta:    &golden.Meta{Source: "web"},
+		ID:      1,
+		Small:   -2,
+		Big:     1 << 40,
+		Count:   3,
+		Total:   1<<64 - 1,
+		Score:   1.5,
+		Ratio:   0.25,
+		Name:    "test \"event\"",
+		Active:  true,
+		Level:   -1,
+		Elapsed: time.Second,
+		Timeout: time.Minute,
+		Created: time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC),
+		Day:     time.Date(2021, 3, 4, 0, 0, 0, 0, time.UTC),
+		Updated: &updated,
+		Data:    []byte("data"),
+		IDs:     []int{1, 2},
+		Codes:   []int32{3},
+		Scores:  []float64{0.5},
+		Flags:   []bool{true, false},
+		Tags:    []string{"a", "b"},
+		Levels:  []golden.Level{4, 5},
+		IP:      net.IPv4(10, 0, 0, 1),
+		Address: golden.Address{City: "NY", Zip: "10001"},
+		Billing: &golden.Address{City: "LA"},
+		Items:   []golden.Item{{SKU: "a", Qty: 1}},
+		Refs:    []*golden.Item{{SKU: "b", Qty: 2}},
+		Labels:  map[string]string{"b": "2", "a": ""},
+		Counts:  map[string]int{"x": 1},
+		Attrs:   map[string]interface{}{"flag": true, "name": "n"},
+		Root:    &golden.Node{Name: "root", Next: &golden.Node{Name: "next"}, Children: []*golden.Node{{Name: "child"}}},
+	}
+	value.CreatedBy = "bob"
+	data := encodeJSON(t, value)
+	decoded := &golden.Event{}
+	var decoder io.Decoder = decoded
+	if !assert.Nil(t, decoder.Decode([]byte(data))) {
+		return
+	}
+	assert.EqualValues(t, data, encodeJSON(t, decoded))
+	assert.EqualValues(t, value.Created, decoded.Created)
+	assert.EqualValues(t, value.Updated.UnixNano(), decoded.Updated.UnixNano())
+}
+
+func TestEvent_Decode_Error(t *testing.T) {
+	var testCases = []struct {
+		description string
+		data        string
+		expect      string
+	}{
+		{
+			description: "invalid JSON",
+			data:        `{"id":`,
+			expect:      "unexpected end of JSON input",
+		},
+		{
+			description: "invalid duration",
+			data:        `{"timeout":"1x"}`,
+			expect:      `invalid timeout: time: unknown unit "x" in duration "1x"`,
+		},
+		{
+			description: "invalid time",
+			data:        `{"day":"03/04/2021"}`,
+			expect:      `invalid day: parsing time "03/04/2021" as "2006-01-02": cannot parse "03/04/2021" as "2006"`,
+		},
+		{
+			description: "invalid nested value",
+			data:        `{"items":[{"qty":"1"}]}`,
+			expect:      "json: cannot unmarshal string into Go struct field .qty of type int",
+		},
+	}
+	for _, testCase := range testCases {
+		err := (&golden.Event{}).Decode([]byte(testCase.data))
+		if assert.NotNil(t, err, testCase.description) {
+			assert.EqualValues(t, testCase.expect, err.Error(), testCase.description)
+		}
+	}
+}
+
+func newEvent() *golden.Event {
+	updated := time.Date(2021, 3, 4, 5, 6, 7, 800, time.UTC)
+	return &golden.Event{
+		Meta:     &golden.Meta{Source: "web", ID: "x"},
+		ID:       1,
+		Small:    -2,
+		Big:      1 << 40,
+		Count:    3,
+		Total:    1<<64 - 1,
+		Score:    1.5,
+		Ratio:    0.25,
+		Name:     "test \"event\"",
+		Active:   true,
+		Secret:   "secret",
+		Level:    -1,
+		Elapsed:  time.Second,
+		Timeout:  time.Minute,
+		Created:  time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC),
+		Day:      time.Date(2021, 3, 4, 0, 0, 0, 0, time.UTC),
+		Updated:  &updated,
+		Data:     []byte("data"),
+		IDs:      []int{1, 2},
+		Codes:    []int32{3},
+		Scores:   []float64{0.5},
+		Flags:    []bool{true, false},
+		Tags:     []string{"a", "b"},
+		Levels:   []golden.Level{4, 5},
+		Status:   1,
+		Price:    1250,
+		IP:       net.IPv4(10, 0, 0, 1),
+		Gateway:  net.IPv4(10, 0, 0, 254),
+		Invalid:  "x",
+		Version:  golden.Version{Major: 1, Minor: 2},
+		Address:  golden.Address{City: "NY", Zip: "10001"},
+		Billing:  &golden.Address{City: "LA"},
+		Items:    []golden.Item{{SKU: "a", Qty: 1}},
+		Refs:     []*golden.Item{nil, {SKU: "b", Qty: 2}},
+		Labels:   map[string]string{"b": "2", "a": ""},
+		Counts:   map[string]int{"x": 1},
+		Attrs:    map[string]interface{}{"flag": true, "name": "n"},
+		Statuses: map[string]golden.Status{"s": 1},
+		Places:   map[string]golden.Address{"home": {City: "SF"}, "work": {City: "NY"}},
+		Root:     &golden.Node{Name: "root", Next: &golden.Node{Name: "next"}, Children: []*golden.Node{{Name: "child"}}},
+	}
+}
+
+func encodeJSON(t *testing.T, value io.Encoder) string {
+	message := msg.NewProvider(1024, 1, json.New).NewMessage()
+	value.Encode(message)
+	buf := new(bytes.Buffer)
+	_, err := message.WriteTo(buf)
+	assert.Nil(t, err)
+	message.Free()
+	return strings.TrimSpace(buf.String())
+}
@@ -0,0 +1,44 @@
+//Command tappergen generates allocation free io.Encoder and JSON io.Decoder implementations for struct types,
+//generated code honours tapper tags as encoder.Provider does, i.e.
+//
+//	//go:generate tappergen -type Event,Order
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func main() {
+	typeNames := flag.String("type", "", "comma separated struct type names, required")
+	output := flag.String("output", "", "output file name, default <type>_tapper.go")
+	decode := flag.Bool("decode", true, "generates JSON message decoders")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: tappergen -type T [-output file] [-decode=false] [directory]\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if *typeNames == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	options := &Options{Dir: ".", Types: strings.Split(*typeNames, ","), Output: *output, Decode: *decode}
+	if flag.NArg() > 0 {
+		options.Dir = flag.Arg(0)
+	}
+	if options.Output == "" {
+		options.Output = strings.ToLower(options.Types[0]) + "_tapper.go"
+	}
+	source, err := Generate(options)
+	if err != nil {
+		log.Fatalf("tappergen: %v", err)
+	}
+	if err = ioutil.WriteFile(filepath.Join(options.Dir, options.Output), source, 0644); err != nil {
+		log.Fatalf("tappergen: %v", err)
+	}
+}
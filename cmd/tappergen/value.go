@@ -0,0 +1,220 @@
+package main
+
+import (
+	"github.com/pkg/errors"
+	"go/types"
+)
+
+//kind represents generated value encoding
+type kind int
+
+const (
+	kindInt      kind = iota //int, int8, int16 and int32 encoded with PutInt
+	kindInt64                //int64 encoded with PutInt64
+	kindDuration             //time.Duration encoded with PutDuration
+	kindUint                 //unsigned integers encoded with PutUint64
+	kindFloat64              //float64 encoded with PutFloat
+	kindFloat32              //float32 encoded with PutFloat32
+	kindString               //string encoded with PutNonEmptyString, map values with PutString
+	kindBool                 //bool encoded with PutBool
+	kindTime                 //time.Time encoded with PutTime
+	kindBytes                //byte slice encoded with PutB64EncodedBytes
+	kindSlice                //primitive slice encoded with PutInts, PutUInts, PutFloats, PutBools or PutStrings
+	kindObject               //nested struct or io.Encoder encoded with PutObject
+	kindObjects              //struct slice encoded with PutObjects
+	kindMap                  //map with string keys encoded as nested object in key order
+	kindStream               //io.StreamMarshaler
+	kindText                 //encoding.TextMarshaler encoded as string
+	kindStringer             //fmt.Stringer encoded as string
+)
+
+//value represents encoded value type
+type value struct {
+	kind      kind
+	goType    types.Type //value type, pointer element type for pointer value
+	pointer   bool       //nil pointer is skipped
+	generated bool       //nested struct encoder and decoder are generated
+	elem      *value     //slice and map element, nil for dynamic map[string]interface{}
+}
+
+//classify returns value encoding of the type, marshalers take precedence over the type kind as in encoder.Provider,
+//addressable type can use marshaler methods with pointer receiver
+func (g *generator) classify(aType types.Type, addressable bool) (*value, error) {
+	result := &value{goType: aType}
+	if ptr, ok := aType.(*types.Pointer); ok {
+		result.pointer, result.goType = true, ptr.Elem()
+		addressable = true
+	}
+	switch {
+	case isNamed(result.goType, "time", "Time"):
+		result.kind = kindTime
+		return result, nil
+	case isNamed(result.goType, "time", "Duration"):
+		if result.pointer {
+			return nil, errors.Errorf("not yet supported type: %v", types.TypeString(aType, (*types.Package).Name))
+		}
+		result.kind = kindDuration
+		return result, nil
+	}
+	if _, ok := result.goType.Underlying().(*types.Interface); ok {
+		return nil, errors.Errorf("not yet supported type: %v", types.TypeString(aType, (*types.Package).Name))
+	}
+	if marshaler, ok := marshaling(result.goType, addressable); ok {
+		result.kind = marshaler
+		return result, nil
+	}
+	if result.pointer {
+		if !g.isLocalStruct(result.goType) {
+			return nil, errors.Errorf("not yet supported type: %v", types.TypeString(aType, (*types.Package).Name))
+		}
+		result.kind, result.generated = kindObject, true
+		g.include(result.goType.(*types.Named))
+		return result, nil
+	}
+	switch actual := aType.Underlying().(type) {
+	case *types.Basic:
+		if result.kind = basicKind(actual); result.kind == -1 {
+			return nil, errors.Errorf("not yet supported type: %v", types.TypeString(aType, (*types.Package).Name))
+		}
+		return result, nil
+	case *types.Slice:
+		elemType := actual.Elem()
+		if basic, ok := elemType.(*types.Basic); ok && basic.Kind() == types.Uint8 {
+			result.kind = kindBytes
+			return result, nil
+		}
+		if basic, ok := elemType.Underlying().(*types.Basic); ok && basicKind(basic) != -1 {
+			result.kind, result.elem = kindSlice, &value{kind: basicKind(basic), goType: elemType}
+			return result, nil
+		}
+		structType := elemType
+		if ptr, ok := elemType.(*types.Pointer); ok {
+			structType = ptr.Elem()
+		}
+		if !g.isLocalStruct(structType) {
+			return nil, errors.Errorf("not yet supported slice type: %v", types.TypeString(aType, (*types.Package).Name))
+		}
+		elem, err := g.classify(elemType, true)
+		if err != nil {
+			return nil, err
+		}
+		result.kind, result.elem = kindObjects, elem
+		return result, nil
+	case *types.Map:
+		if key, ok := actual.Key().Underlying().(*types.Basic); !ok || key.Kind() != types.String {
+			return nil, errors.Errorf("not yet supported map key type: %v", types.TypeString(aType, (*types.Package).Name))
+		}
+		result.kind = kindMap
+		if isInterface(actual.Elem()) {
+			if !types.Identical(actual, mapOfInterface) {
+				return nil, errors.Errorf("not yet supported map type: %v", types.TypeString(aType, (*types.Package).Name))
+			}
+			return result, nil
+		}
+		elem, err := g.classify(actual.Elem(), false)
+		if err != nil {
+			return nil, errors.Wrapf(err, "not yet supported map value type: %v", types.TypeString(aType, (*types.Package).Name))
+		}
+		if elem.kind == kindMap || elem.kind == kindObjects {
+			return nil, errors.Errorf("not yet supported map value type: %v", types.TypeString(aType, (*types.Package).Name))
+		}
+		result.elem = elem
+		return result, nil
+	case *types.Struct:
+		if !g.isLocalStruct(aType) {
+			return nil, errors.Errorf("nested struct type has to be named and declared in %v package: %v", g.pkg.Name(), types.TypeString(aType, (*types.Package).Name))
+		}
+		result.kind, result.generated = kindObject, true
+		g.include(aType.(*types.Named))
+		return result, nil
+	}
+	return nil, errors.Errorf("not yet supported type: %v", types.TypeString(aType, (*types.Package).Name))
+}
+
+//basicKind returns basic type encoding or -1
+func basicKind(basic *types.Basic) kind {
+	switch basic.Kind() {
+	case types.Int, types.Int8, types.Int16, types.Int32:
+		return kindInt
+	case types.Int64:
+		return kindInt64
+	case types.Uint, types.Uint8, types.Uint16, types.Uint32, types.Uint64:
+		return kindUint
+	case types.Float64:
+		return kindFloat64
+	case types.Float32:
+		return kindFloat32
+	case types.String:
+		return kindString
+	case types.Bool:
+		return kindBool
+	}
+	return -1
+}
+
+//marshaling returns custom encoding implemented by the type in encoder.Provider precedence order, fmt.Stringer struct is encoded by its fields
+func marshaling(aType types.Type, addressable bool) (kind, bool) {
+	switch {
+	case hasMethod(aType, addressable, "MarshalStream", []string{"string", streamType}, nil):
+		return kindStream, true
+	case hasMethod(aType, addressable, "Encode", []string{streamType}, nil):
+		return kindObject, true
+	case hasMethod(aType, addressable, "MarshalText", nil, []string{"[]byte", "error"}):
+		return kindText, true
+	case hasMethod(aType, addressable, "String", nil, []string{"string"}) && !isStruct(aType):
+		return kindStringer, true
+	}
+	return 0, false
+}
+
+//hasMethod returns true if type has method with parameter and result types
+func hasMethod(aType types.Type, addressable bool, name string, params, results []string) bool {
+	object, _, _ := types.LookupFieldOrMethod(aType, addressable, nil, name)
+	method, ok := object.(*types.Func)
+	if !ok {
+		return false
+	}
+	signature := method.Type().(*types.Signature)
+	return matches(signature.Params(), params) && matches(signature.Results(), results)
+}
+
+func matches(tuple *types.Tuple, expect []string) bool {
+	if tuple.Len() != len(expect) {
+		return false
+	}
+	for i, typeName := range expect {
+		if types.TypeString(tuple.At(i).Type(), nil) != typeName {
+			return false
+		}
+	}
+	return true
+}
+
+//isLocalStruct returns true for named struct type declared in generated package
+func (g *generator) isLocalStruct(aType types.Type) bool {
+	named, ok := aType.(*types.Named)
+	if !ok || named.Obj().Pkg() != g.pkg {
+		return false
+	}
+	_, ok = named.Underlying().(*types.Struct)
+	return ok
+}
+
+func isNamed(aType types.Type, pkgPath, name string) bool {
+	named, ok := aType.(*types.Named)
+	return ok && named.Obj().Pkg() != nil && named.Obj().Pkg().Path() == pkgPath && named.Obj().Name() == name
+}
+
+func isStruct(aType types.Type) bool {
+	_, ok := aType.Underlying().(*types.Struct)
+	return ok
+}
+
+func isInterface(aType types.Type) bool {
+	actual, ok := aType.Underlying().(*types.Interface)
+	return ok && actual.NumMethods() == 0
+}
+
+const streamType = "github.com/viant/tapper/io.Stream"
+
+var mapOfInterface = types.NewMap(types.Typ[types.String], types.NewInterfaceType(nil, nil).Complete())
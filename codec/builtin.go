@@ -0,0 +1,77 @@
+package codec
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+)
+
+const (
+	//Gzip gzip codec name
+	Gzip = "gzip"
+	//Zlib zlib codec name
+	Zlib = "zlib"
+	//Deflate raw deflate codec name
+	Deflate = "deflate"
+	//None no-op codec name
+	None = "none"
+)
+
+type gzipCodec struct{}
+
+func (c gzipCodec) Suffix() string {
+	return ".gz"
+}
+
+func (c gzipCodec) NewWriter(writer io.Writer, level int) (Writer, error) {
+	return gzip.NewWriterLevel(writer, level)
+}
+
+type zlibCodec struct{}
+
+func (c zlibCodec) Suffix() string {
+	return ".zz"
+}
+
+func (c zlibCodec) NewWriter(writer io.Writer, level int) (Writer, error) {
+	return zlib.NewWriterLevel(writer, level)
+}
+
+type deflateCodec struct{}
+
+func (c deflateCodec) Suffix() string {
+	return ".deflate"
+}
+
+func (c deflateCodec) NewWriter(writer io.Writer, level int) (Writer, error) {
+	return flate.NewWriter(writer, level)
+}
+
+//noneCodec buffers without compression
+type noneCodec struct{}
+
+func (c noneCodec) Suffix() string {
+	return ""
+}
+
+func (c noneCodec) NewWriter(writer io.Writer, level int) (Writer, error) {
+	return &bufferedWriter{Writer: bufio.NewWriter(writer)}, nil
+}
+
+type bufferedWriter struct {
+	*bufio.Writer
+}
+
+//Close flushes buffered data, underlying writer is not closed
+func (w *bufferedWriter) Close() error {
+	return w.Flush()
+}
+
+func init() {
+	Register(Gzip, gzipCodec{})
+	Register(Zlib, zlibCodec{})
+	Register(Deflate, deflateCodec{})
+	Register(None, noneCodec{})
+}
@@ -0,0 +1,84 @@
+package codec
+
+import (
+	"github.com/pkg/errors"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+//DefaultLevel selects codec default compression level
+const DefaultLevel = -1
+
+//Writer represents compressing writer
+type Writer interface {
+	io.WriteCloser
+	Flush() error
+}
+
+//Codec represents compression codec
+type Codec interface {
+	//Suffix returns file name suffix, i.e. .gz
+	Suffix() string
+	//NewWriter returns writer compressing to the supplied writer with level, DefaultLevel selects codec default
+	NewWriter(writer io.Writer, level int) (Writer, error)
+}
+
+var registry = struct {
+	mux    sync.RWMutex
+	codecs map[string]Codec
+}{codecs: map[string]Codec{}}
+
+//Register registers codec under case insensitive name
+func Register(name string, codec Codec) {
+	registry.mux.Lock()
+	defer registry.mux.Unlock()
+	registry.codecs[strings.ToLower(name)] = codec
+}
+
+//Lookup returns registered codec
+func Lookup(name string) (Codec, bool) {
+	registry.mux.RLock()
+	defer registry.mux.RUnlock()
+	codec, ok := registry.codecs[strings.ToLower(name)]
+	return codec, ok
+}
+
+//Spec represents codec with level
+type Spec struct {
+	Name  string
+	Level int
+	Codec
+}
+
+//IsCompressed returns true if codec produces compressed data
+func (s *Spec) IsCompressed() bool {
+	return s != nil && s.Name != None
+}
+
+//NewWriter returns compressing writer
+func (s *Spec) NewWriter(writer io.Writer) (Writer, error) {
+	return s.Codec.NewWriter(writer, s.Level)
+}
+
+//Parse parses codec expression: <name> or <name>:<level>, i.e. gzip:9, empty expression returns nil
+func Parse(expr string) (*Spec, error) {
+	if expr == "" {
+		return nil, nil
+	}
+	name, level := strings.ToLower(expr), DefaultLevel
+	if index := strings.Index(expr, ":"); index != -1 {
+		name = strings.ToLower(expr[:index])
+		value, err := strconv.Atoi(expr[index+1:])
+		if err != nil {
+			return nil, errors.Errorf("invalid codec level: %v", expr)
+		}
+		level = value
+	}
+	codec, ok := Lookup(name)
+	if !ok {
+		return nil, errors.Errorf("unsupported codec: %v", name)
+	}
+	return &Spec{Name: name, Level: level, Codec: codec}, nil
+}
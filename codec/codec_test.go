@@ -0,0 +1,131 @@
+package codec
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"io/ioutil"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	var useCases = []struct {
+		description  string
+		expr         string
+		expectName   string
+		expectLevel  int
+		expectSuffix string
+		expectNil    bool
+		expectError  bool
+	}{
+		{description: "empty", expr: "", expectNil: true},
+		{description: "gzip", expr: "gzip", expectName: Gzip, expectLevel: DefaultLevel, expectSuffix: ".gz"},
+		{description: "gzip with level", expr: "GZIP:9", expectName: Gzip, expectLevel: 9, expectSuffix: ".gz"},
+		{description: "zlib", expr: "zlib:1", expectName: Zlib, expectLevel: 1, expectSuffix: ".zz"},
+		{description: "deflate", expr: "deflate", expectName: Deflate, expectLevel: DefaultLevel, expectSuffix: ".deflate"},
+		{description: "none", expr: "none", expectName: None, expectLevel: DefaultLevel},
+		{description: "unsupported", expr: "zstd", expectError: true},
+		{description: "invalid level", expr: "gzip:x", expectError: true},
+	}
+	for _, useCase := range useCases {
+		spec, err := Parse(useCase.expr)
+		if useCase.expectError {
+			assert.NotNil(t, err, useCase.description)
+			continue
+		}
+		if !assert.Nil(t, err, useCase.description) {
+			continue
+		}
+		if useCase.expectNil {
+			assert.Nil(t, spec, useCase.description)
+			assert.False(t, spec.IsCompressed(), useCase.description)
+			continue
+		}
+		assert.EqualValues(t, useCase.expectName, spec.Name, useCase.description)
+		assert.EqualValues(t, useCase.expectLevel, spec.Level, useCase.description)
+		assert.EqualValues(t, useCase.expectSuffix, spec.Suffix(), useCase.description)
+		assert.EqualValues(t, useCase.expectName != None, spec.IsCompressed(), useCase.description)
+	}
+}
+
+func TestSpec_NewWriter(t *testing.T) {
+	var useCases = []struct {
+		description string
+		expr        string
+		newReader   func(reader io.Reader) (io.Reader, error)
+	}{
+		{
+			description: "gzip",
+			expr:        "gzip:9",
+			newReader: func(reader io.Reader) (io.Reader, error) {
+				return gzip.NewReader(reader)
+			},
+		},
+		{
+			description: "zlib",
+			expr:        "zlib",
+			newReader: func(reader io.Reader) (io.Reader, error) {
+				return zlib.NewReader(reader)
+			},
+		},
+		{
+			description: "deflate",
+			expr:        "deflate:1",
+			newReader: func(reader io.Reader) (io.Reader, error) {
+				return flate.NewReader(reader), nil
+			},
+		},
+		{
+			description: "none",
+			expr:        "none",
+			newReader: func(reader io.Reader) (io.Reader, error) {
+				return reader, nil
+			},
+		},
+	}
+	data := bytes.Repeat([]byte("{\"id\":1,\"name\":\"tapper\"}\n"), 100)
+	for _, useCase := range useCases {
+		spec, err := Parse(useCase.expr)
+		if !assert.Nil(t, err, useCase.description) {
+			continue
+		}
+		buffer := new(bytes.Buffer)
+		writer, err := spec.NewWriter(buffer)
+		if !assert.Nil(t, err, useCase.description) {
+			continue
+		}
+		_, err = writer.Write(data)
+		assert.Nil(t, err, useCase.description)
+		assert.Nil(t, writer.Flush(), useCase.description)
+		assert.Nil(t, writer.Close(), useCase.description)
+		reader, err := useCase.newReader(buffer)
+		if !assert.Nil(t, err, useCase.description) {
+			continue
+		}
+		actual, err := ioutil.ReadAll(reader)
+		assert.Nil(t, err, useCase.description)
+		assert.EqualValues(t, data, actual, useCase.description)
+	}
+}
+
+type customCodec struct {
+	noneCodec
+}
+
+func (c customCodec) Suffix() string {
+	return ".custom"
+}
+
+func TestRegister(t *testing.T) {
+	Register("Custom", customCodec{})
+	spec, err := Parse("custom:3")
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.EqualValues(t, ".custom", spec.Suffix())
+	assert.EqualValues(t, 3, spec.Level)
+	assert.True(t, spec.IsCompressed())
+}
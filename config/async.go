@@ -0,0 +1,45 @@
+package config
+
+import (
+	"github.com/pkg/errors"
+)
+
+const (
+	//PolicyBlock blocks caller until queue has room
+	PolicyBlock = "block"
+	//PolicyDropNewest discards incoming record when queue is full
+	PolicyDropNewest = "dropNewest"
+	//PolicyDropOldest discards the oldest queued record when queue is full
+	PolicyDropOldest = "dropOldest"
+	//PolicySpill writes incoming record to spill file when queue is full
+	PolicySpill = "spill"
+
+	//DefaultQueueSize default async queue size
+	DefaultQueueSize = 1024
+)
+
+//Async represents asynchronous logging config
+type Async struct {
+	QueueSize int    //max number of queued records
+	Policy    string //full queue policy: block, dropNewest, dropOldest or spill
+	SpillPath string //optional local spill file path, used with spill policy
+}
+
+//Init initialises async config
+func (a *Async) Init() error {
+	if a.QueueSize == 0 {
+		a.QueueSize = DefaultQueueSize
+	}
+	if a.Policy == "" {
+		a.Policy = PolicyBlock
+	}
+	switch a.Policy {
+	case PolicyBlock, PolicyDropNewest, PolicyDropOldest, PolicySpill:
+	default:
+		return errors.Errorf("unsupported async policy: %v", a.Policy)
+	}
+	if a.QueueSize < 0 {
+		return errors.Errorf("invalid async queue size: %v", a.QueueSize)
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package config
+
+import (
+	"github.com/pkg/errors"
+)
+
+const (
+	//AvroCodecDeflate compresses every block with raw deflate
+	AvroCodecDeflate = "deflate"
+	//AvroCodecNull writes uncompressed blocks
+	AvroCodecNull = "null"
+
+	//DefaultAvroBlockSize default max uncompressed block size
+	DefaultAvroBlockSize = 64 * 1024
+	//DefaultAvroBlockRecords default max number of records per block
+	DefaultAvroBlockRecords = 1000
+)
+
+//Avro represents Avro Object Container File output, every file starts with a header holding the schema and sync marker
+type Avro struct {
+	Schema       string //Avro JSON schema i.e. avro.NewSchema(provider).String()
+	Codec        string //block codec: deflate (default) or null
+	BlockSize    int    //max uncompressed block size in bytes
+	BlockRecords int    //max number of records per block
+}
+
+//Init initialises avro config
+func (a *Avro) Init() error {
+	if a.Schema == "" {
+		return errors.New("avro schema was empty")
+	}
+	if a.Codec == "" {
+		a.Codec = AvroCodecDeflate
+	}
+	switch a.Codec {
+	case AvroCodecDeflate, AvroCodecNull:
+	default:
+		return errors.Errorf("unsupported avro codec: %v", a.Codec)
+	}
+	if a.BlockSize == 0 {
+		a.BlockSize = DefaultAvroBlockSize
+	}
+	if a.BlockRecords == 0 {
+		a.BlockRecords = DefaultAvroBlockRecords
+	}
+	if a.BlockSize < 0 || a.BlockRecords < 0 {
+		return errors.Errorf("invalid avro block size: %v, records: %v", a.BlockSize, a.BlockRecords)
+	}
+	return nil
+}
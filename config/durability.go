@@ -0,0 +1,58 @@
+package config
+
+import (
+	"github.com/pkg/errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	//DurabilityNone relies on buffered writer flushes and rotation
+	DurabilityNone = "none"
+	//DurabilityAlways flushes and syncs after every record
+	DurabilityAlways = "always"
+	//DurabilityEvery flushes and syncs after every N records, i.e. every:100
+	DurabilityEvery = "every"
+	//DurabilityInterval flushes and syncs at most every N ms, i.e. interval:500
+	DurabilityInterval = "interval"
+)
+
+//Durability represents parsed stream durability policy
+type Durability struct {
+	Policy   string
+	Every    int
+	Interval time.Duration
+}
+
+//ParseDurability parses durability expression: none, always, every:<records> or interval:<ms>
+func ParseDurability(expr string) (*Durability, error) {
+	result := &Durability{Policy: DurabilityNone}
+	if expr == "" {
+		return result, nil
+	}
+	policy, value := expr, ""
+	if index := strings.Index(expr, ":"); index != -1 {
+		policy, value = expr[:index], expr[index+1:]
+	}
+	result.Policy = policy
+	switch policy {
+	case DurabilityNone, DurabilityAlways:
+		if value != "" {
+			return nil, errors.Errorf("invalid durability: %v, %v does not take a value", expr, policy)
+		}
+		return result, nil
+	case DurabilityEvery, DurabilityInterval:
+		number, err := strconv.Atoi(value)
+		if err != nil || number <= 0 {
+			return nil, errors.Errorf("invalid durability: %v, expected positive number after %v:", expr, policy)
+		}
+		if policy == DurabilityEvery {
+			result.Every = number
+		} else {
+			result.Interval = time.Duration(number) * time.Millisecond
+		}
+		return result, nil
+	}
+	return nil, errors.Errorf("unsupported durability: %v", expr)
+}
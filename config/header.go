@@ -0,0 +1,6 @@
+package config
+
+//Header represents header record written at the beginning of every file
+type Header struct {
+	Columns []string //optional header columns i.e. encoder.Provider Columns(), by default columns of the first logged message
+}
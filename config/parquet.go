@@ -0,0 +1,46 @@
+package config
+
+import (
+	"github.com/pkg/errors"
+	"github.com/viant/tapper/parquet"
+)
+
+//ParquetSuffix rotated parquet file suffix
+const ParquetSuffix = ".parquet"
+
+//Parquet represents rotated file conversion to Parquet, closed NDJSON or CSV file is replaced with a parquet file
+type Parquet struct {
+	Columns        []*parquet.Column //optional schema i.e. parquet.NewSchema(provider), by default columns are inferred from rotated records
+	Format         string            //rotated file format: json (default) or csv
+	Delimiter      string            //CSV field delimiter, "," by default
+	SliceDelimiter string            //CSV slice item delimiter, ":" by default
+	RowGroupSize   int               //max number of rows per row group
+	Compression    string            //page compression: snappy (default), gzip or none
+	options        *parquet.Options
+}
+
+//Options returns conversion options
+func (p *Parquet) Options() *parquet.Options {
+	return p.options
+}
+
+//Init initialises parquet config
+func (p *Parquet) Init() error {
+	p.options = &parquet.Options{
+		Format:         p.Format,
+		Delimiter:      p.Delimiter,
+		SliceDelimiter: p.SliceDelimiter,
+		RowGroupSize:   p.RowGroupSize,
+		Compression:    p.Compression,
+	}
+	return p.options.Init()
+}
+
+//initHeader sets whether rotated CSV records start with header record, CSV conversion needs either header or columns
+func (p *Parquet) initHeader(header bool) error {
+	p.options.Header = header
+	if p.options.Format == parquet.FormatCSV && !header && len(p.Columns) == 0 {
+		return errors.New("parquet csv conversion requires header record or parquet columns")
+	}
+	return nil
+}
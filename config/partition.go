@@ -0,0 +1,83 @@
+package config
+
+import (
+	"github.com/pkg/errors"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+//DefaultMaxOpenPartitions default max number of concurrently open partitions
+const DefaultMaxOpenPartitions = 64
+
+//Partition represents partitioned output config
+type Partition struct {
+	Keys    []string //partition keys, referenced in stream and rotation URL as {key}
+	MaxOpen int      //max number of concurrently open partitions, least recently used partition is closed first
+	IdleMs  int      //optional idle time after which partition is closed
+}
+
+//Init initialises partition config
+func (p *Partition) Init() error {
+	if len(p.Keys) == 0 {
+		return errors.New("partition keys were empty")
+	}
+	if p.MaxOpen == 0 {
+		p.MaxOpen = DefaultMaxOpenPartitions
+	}
+	return nil
+}
+
+//validateURL checks that URL references every partition key, so that partitions do not share files
+func (p *Partition) validateURL(name, URL string) error {
+	for _, key := range p.Keys {
+		if !strings.Contains(URL, "{"+key+"}") {
+			return errors.Errorf("partition key {%v} was missing in %v: %v", key, name, URL)
+		}
+	}
+	return nil
+}
+
+//ExpandURL expands URL partition keys placeholders with supplied values
+func (p *Partition) ExpandURL(URL string, values []string) string {
+	for i, key := range p.Keys {
+		URL = strings.Replace(URL, "{"+key+"}", values[i], -1)
+	}
+	return URL
+}
+
+//GlobURL returns URL with partition keys placeholders replaced with glob wildcard, i.e. to find files of all partitions
+func (p *Partition) GlobURL(URL string) string {
+	for _, key := range p.Keys {
+		URL = strings.Replace(URL, "{"+key+"}", "*", -1)
+	}
+	return URL
+}
+
+//MatchURL returns partition values of URL expanded from supplied URL pattern, it returns false if URL does not match
+func (p *Partition) MatchURL(pattern, URL string) ([]string, bool) {
+	expr := regexp.QuoteMeta(pattern)
+	for i, key := range p.Keys {
+		placeholder := regexp.QuoteMeta("{" + key + "}")
+		expr = strings.Replace(expr, placeholder, "(?P<k"+strconv.Itoa(i)+">[^/]+)", 1)
+		expr = strings.Replace(expr, placeholder, "[^/]+", -1)
+	}
+	matcher, err := regexp.Compile("^" + expr + "$")
+	if err != nil {
+		return nil, false
+	}
+	matched := matcher.FindStringSubmatch(URL)
+	if matched == nil {
+		return nil, false
+	}
+	values := make([]string, len(p.Keys))
+	for i, name := range matcher.SubexpNames() {
+		if index, err := strconv.Atoi(strings.TrimPrefix(name, "k")); err == nil && name != "" {
+			values[index] = matched[i]
+		}
+	}
+	if p.ExpandURL(pattern, values) != URL { //repeated placeholder with other value
+		return nil, false
+	}
+	return values, true
+}
@@ -0,0 +1,91 @@
+package config
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/viant/tapper/parquet"
+	"testing"
+)
+
+func TestStream_Init_Partition(t *testing.T) {
+	var useCases = []struct {
+		description string
+		URL         string
+		rotationURL string
+		hasError    bool
+	}{
+		{
+			description: "all keys referenced",
+			URL:         "/tmp/active/{country}/{event}/log.json",
+			rotationURL: "/tmp/data/{country}/{event}/log-%v.json",
+		},
+		{
+			description: "key missing in URL",
+			URL:         "/tmp/active/{country}/log.json",
+			rotationURL: "/tmp/data/{country}/{event}/log-%v.json",
+			hasError:    true,
+		},
+		{
+			description: "key missing in rotation URL",
+			URL:         "/tmp/active/{country}/{event}/log.json",
+			rotationURL: "/tmp/data/{event}/log-%v.json",
+			hasError:    true,
+		},
+	}
+	for _, useCase := range useCases {
+		stream := &Stream{
+			URL:       useCase.URL,
+			Partition: &Partition{Keys: []string{"country", "event"}},
+			Rotation:  &Rotation{EveryMs: 1000, URL: useCase.rotationURL},
+		}
+		err := stream.Init()
+		assert.EqualValues(t, useCase.hasError, err != nil, useCase.description)
+	}
+}
+
+func TestRotation_Init_Partition(t *testing.T) {
+	stream := &Stream{
+		URL:       "/tmp/active/{country}/log.csv",
+		Header:    &Header{},
+		Partition: &Partition{Keys: []string{"country"}},
+		Rotation:  &Rotation{EveryMs: 1000, URL: "/tmp/data/{country}/log-%v.csv", Parquet: &Parquet{Format: parquet.FormatCSV, Columns: []*parquet.Column{{Name: "id"}}}},
+	}
+	if !assert.Nil(t, stream.Init()) {
+		return
+	}
+	partitionRotation := *stream.Rotation
+	partitionRotation.URL = stream.Partition.ExpandURL(stream.Rotation.URL, []string{"US"})
+	assert.Nil(t, partitionRotation.Init())
+	assert.True(t, partitionRotation.Parquet.Options().Header, "partition rotation keeps header option")
+	assert.True(t, stream.Rotation.Parquet.Options().Header)
+}
+
+func TestPartition_MatchURL(t *testing.T) {
+	partition := &Partition{Keys: []string{"country", "event"}}
+	var useCases = []struct {
+		description string
+		URL         string
+		expect      []string
+		matched     bool
+	}{
+		{
+			description: "matched",
+			URL:         "/tmp/event={click}/country=US/click.json",
+			expect:      []string{"US", "click"},
+			matched:     true,
+		},
+		{
+			description: "repeated key mismatch",
+			URL:         "/tmp/event={click}/country=US/view.json",
+		},
+		{
+			description: "not matched",
+			URL:         "/tmp/other/country=US/click.json",
+		},
+	}
+	for _, useCase := range useCases {
+		values, ok := partition.MatchURL("/tmp/event={{event}}/country={country}/{event}.json", useCase.URL)
+		assert.EqualValues(t, useCase.matched, ok, useCase.description)
+		assert.EqualValues(t, useCase.expect, values, useCase.description)
+	}
+	assert.EqualValues(t, "/tmp/*/*.json", partition.GlobURL("/tmp/{country}/{event}.json"))
+}
@@ -2,44 +2,158 @@ package config
 
 import (
 	"fmt"
+	"github.com/pkg/errors"
+	"github.com/viant/tapper/codec"
 	"strings"
 	"sync/atomic"
 	"time"
 )
 
-
-
+const (
+	//AlignMinute aligns rotation to wall-clock minute
+	AlignMinute = "minute"
+	//AlignHour aligns rotation to wall-clock hour
+	AlignHour = "hour"
+	//AlignDay aligns rotation to wall-clock day
+	AlignDay = "day"
+)
 
 //Rotation rotation rotation config
 type Rotation struct {
-	EveryMs        int
-	MaxEntries     int
+	EveryMs            int
+	MaxEntries         int
+	MaxBytes           int64  //max uncompressed bytes written per file
+	MaxCompressedBytes int64  //max bytes reaching underlying file (after stream codec), approximate as codec output is counted once flushed
+	AlignTo            string //optional wall-clock alignment: minute, hour or day
+	TimeZone           string //optional alignment and URL expansion time zone (UTC, Local or IANA name), Local by default
 	Format
-	URL string
-	Codec          string
-	Emit           *Event
-	rawURL            string
-	hasSeq         bool
-
-	sequence       int32
+	URL         string
+	Codec       string
+	Emit        *Event
+	Parquet     *Parquet //optional rotated file conversion to Parquet
+	compression *codec.Spec
+	rawURL      string
+	hasSeq      bool
+	location    *time.Location
+	sequence    int32
 }
 
-//IsGzip returns true if gzip codec specified
+//IsGzip returns true if gzip codec with any level specified, deprecated: use Compression instead
 func (r *Rotation) IsGzip() bool {
-	return strings.ToLower(r.Codec) == "gzip"
+	compression := r.compression
+	if compression == nil { //not initialised yet
+		compression, _ = codec.Parse(r.Codec)
+	}
+	return compression != nil && compression.Name == codec.Gzip
+}
+
+//Compression returns parsed rotation codec, nil if codec was not specified
+func (r *Rotation) Compression() *codec.Spec {
+	return r.compression
+}
+
+//IsCompressed returns true if rotated files are compressed
+func (r *Rotation) IsCompressed() bool {
+	return r.compression.IsCompressed()
+}
+
+//Suffix returns rotated file codec or parquet suffix, i.e. .gz
+func (r *Rotation) Suffix() string {
+	if r.Parquet != nil {
+		return ParquetSuffix
+	}
+	if !r.IsCompressed() {
+		return ""
+	}
+	return r.compression.Suffix()
 }
 
 //Init initialises rotation
-func (r *Rotation) Init() {
+func (r *Rotation) Init() error {
+	var err error
+	if r.compression, err = codec.Parse(r.Codec); err != nil {
+		return err
+	}
 	r.Format.Init(r.URL)
 	r.hasSeq = strings.Contains(r.URL, "%")
 	if r.Emit != nil {
 		r.Emit.Init()
 	}
+	if r.Parquet != nil {
+		if r.Parquet.options == nil { //partition rotation copies share initialised options completed by stream Init, i.e. header
+			if err = r.Parquet.Init(); err != nil {
+				return err
+			}
+		}
+		if r.IsCompressed() {
+			return errors.New("parquet rotation output can not be used with rotation codec")
+		}
+	}
+	switch strings.ToLower(r.AlignTo) {
+	case "", AlignMinute, AlignHour, AlignDay:
+	default:
+		return errors.Errorf("unsupported rotation alignment: %v", r.AlignTo)
+	}
+	location, err := time.LoadLocation(r.TimeZone)
+	if err != nil {
+		return errors.Wrapf(err, "invalid rotation time zone: %v", r.TimeZone)
+	}
+	if r.TimeZone == "" {
+		location = time.Local
+	}
+	r.location = location
+	return nil
+}
+
+//IsAligned returns true if rotation is aligned to wall-clock boundaries
+func (r *Rotation) IsAligned() bool {
+	return r.AlignTo != ""
+}
+
+//In returns time in rotation time zone
+func (r *Rotation) In(t time.Time) time.Time {
+	if r.location == nil {
+		return t
+	}
+	return t.In(r.location)
+}
+
+//PeriodStart returns wall-clock aligned period start for supplied time
+func (r *Rotation) PeriodStart(t time.Time) time.Time {
+	t = r.In(t)
+	switch strings.ToLower(r.AlignTo) {
+	case AlignMinute:
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), 0, 0, t.Location())
+	case AlignHour:
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, t.Location())
+	case AlignDay:
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	}
+	return t
+}
+
+func (r *Rotation) nextBoundary(t time.Time) time.Time {
+	start := r.PeriodStart(t)
+	switch strings.ToLower(r.AlignTo) {
+	case AlignMinute:
+		return start.Add(time.Minute)
+	case AlignHour:
+		return start.Add(time.Hour)
+	}
+	return start.AddDate(0, 0, 1)
+}
+
+//HasSequence returns true if rotation URL has %v placeholder expanded with ID and sequence
+func (r *Rotation) HasSequence() bool {
+	return r.hasSeq
 }
 
 //ExpiryTime returns expiry time
 func (r Rotation) ExpiryTime(created time.Time) *time.Time {
+	if r.IsAligned() {
+		expiry := r.nextBoundary(created)
+		return &expiry
+	}
 	if r.EveryMs == 0 {
 		return nil
 	}
@@ -49,7 +163,7 @@ func (r Rotation) ExpiryTime(created time.Time) *time.Time {
 
 //ExpandURL expand rotation Format with log sequence,  time and ID
 func (r *Rotation) ExpandURL(t time.Time, ID string) string {
-	URL := r.Format.ExpandURL(t, r.URL)
+	URL := r.Format.ExpandURL(r.In(t), r.URL)
 	if !r.hasSeq {
 		return URL
 	}
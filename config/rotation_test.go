@@ -0,0 +1,130 @@
+package config
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+	_ "time/tzdata"
+)
+
+func TestRotation_ExpiryTime(t *testing.T) {
+
+	var useCases = []struct {
+		description string
+		rotation    *Rotation
+		created     time.Time
+		expect      time.Time
+	}{
+		{
+			description: "not aligned",
+			rotation:    &Rotation{EveryMs: 3600000, TimeZone: "UTC"},
+			created:     time.Date(2021, 3, 4, 10, 25, 13, 0, time.UTC),
+			expect:      time.Date(2021, 3, 4, 11, 25, 13, 0, time.UTC),
+		},
+		{
+			description: "aligned to minute",
+			rotation:    &Rotation{AlignTo: AlignMinute, TimeZone: "UTC"},
+			created:     time.Date(2021, 3, 4, 10, 25, 13, 0, time.UTC),
+			expect:      time.Date(2021, 3, 4, 10, 26, 0, 0, time.UTC),
+		},
+		{
+			description: "aligned to hour",
+			rotation:    &Rotation{AlignTo: AlignHour, TimeZone: "UTC"},
+			created:     time.Date(2021, 3, 4, 10, 25, 13, 0, time.UTC),
+			expect:      time.Date(2021, 3, 4, 11, 0, 0, 0, time.UTC),
+		},
+		{
+			description: "aligned to day",
+			rotation:    &Rotation{AlignTo: AlignDay, TimeZone: "UTC"},
+			created:     time.Date(2021, 3, 4, 23, 59, 59, 0, time.UTC),
+			expect:      time.Date(2021, 3, 5, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			description: "aligned to hour with half hour offset zone",
+			rotation:    &Rotation{AlignTo: AlignHour, TimeZone: "Asia/Kolkata"},
+			created:     time.Date(2021, 3, 4, 10, 25, 13, 0, time.UTC),
+			expect:      time.Date(2021, 3, 4, 10, 30, 0, 0, time.UTC),
+		},
+		{
+			description: "aligned to day in local zone",
+			rotation:    &Rotation{AlignTo: AlignDay, TimeZone: "America/New_York"},
+			created:     time.Date(2021, 3, 4, 3, 0, 0, 0, time.UTC),
+			expect:      time.Date(2021, 3, 4, 5, 0, 0, 0, time.UTC),
+		},
+	}
+
+	for _, useCase := range useCases {
+		if !assert.Nil(t, useCase.rotation.Init(), useCase.description) {
+			continue
+		}
+		actual := useCase.rotation.ExpiryTime(useCase.created)
+		if !assert.NotNil(t, actual, useCase.description) {
+			continue
+		}
+		assert.True(t, useCase.expect.Equal(*actual), useCase.description+": "+actual.String())
+	}
+}
+
+func TestRotation_ExpandURL(t *testing.T) {
+
+	var useCases = []struct {
+		description string
+		rotation    *Rotation
+		ts          time.Time
+		expect      string
+	}{
+		{
+			description: "UTC expansion",
+			rotation:    &Rotation{URL: "/tmp/log.[yyyyMMdd_HH]-%v", TimeZone: "UTC"},
+			ts:          time.Date(2021, 3, 4, 23, 15, 0, 0, time.UTC),
+			expect:      "/tmp/log.20210304_23-id-0",
+		},
+		{
+			description: "zone expansion",
+			rotation:    &Rotation{URL: "/tmp/log.[yyyyMMdd_HH]-%v", TimeZone: "Asia/Tokyo"},
+			ts:          time.Date(2021, 3, 4, 23, 15, 0, 0, time.UTC),
+			expect:      "/tmp/log.20210305_08-id-0",
+		},
+	}
+
+	for _, useCase := range useCases {
+		if !assert.Nil(t, useCase.rotation.Init(), useCase.description) {
+			continue
+		}
+		assert.EqualValues(t, useCase.expect, useCase.rotation.ExpandURL(useCase.ts, "id"), useCase.description)
+	}
+}
+
+func TestRotation_Init(t *testing.T) {
+	assert.NotNil(t, (&Rotation{AlignTo: "week"}).Init())
+	assert.NotNil(t, (&Rotation{TimeZone: "Mars/Olympus"}).Init())
+	assert.Nil(t, (&Rotation{AlignTo: "Hour", TimeZone: "Local"}).Init())
+	assert.Nil(t, (&Rotation{Parquet: &Parquet{}}).Init())
+	assert.NotNil(t, (&Rotation{Parquet: &Parquet{Compression: "lzo"}}).Init())
+	assert.NotNil(t, (&Rotation{Codec: "gzip", Parquet: &Parquet{}}).Init())
+}
+
+func TestRotation_IsGzip(t *testing.T) {
+	var testCases = []struct {
+		description string
+		codec       string
+		init        bool
+		expect      bool
+	}{
+		{description: "gzip", codec: "gzip", expect: true},
+		{description: "gzip with level", codec: "gzip:9", init: true, expect: true},
+		{description: "uninitialised gzip with level", codec: "GZIP:1", expect: true},
+		{description: "zlib", codec: "zlib", init: true},
+		{description: "none", codec: ""},
+	}
+	for _, testCase := range testCases {
+		rotation := &Rotation{Codec: testCase.codec}
+		stream := &Stream{Codec: testCase.codec}
+		if testCase.init {
+			assert.Nil(t, rotation.Init(), testCase.description)
+			assert.Nil(t, stream.Init(), testCase.description)
+		}
+		assert.EqualValues(t, testCase.expect, rotation.IsGzip(), testCase.description)
+		assert.EqualValues(t, testCase.expect, stream.IsGzip(), testCase.description)
+	}
+}
@@ -1,23 +1,42 @@
 package config
 
 import (
+	"github.com/pkg/errors"
+	"github.com/viant/tapper/codec"
 	"math/rand"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 )
 
+const (
+	//ShardByRoundRobin distributes messages across shards in round-robin order
+	ShardByRoundRobin = "roundRobin"
+	//ShardByAffinity keeps messages logged from the same processor on the same shard
+	ShardByAffinity = "affinity"
+)
+
 //Stream represents log stream
 type Stream struct {
 	//Rotation represents optional log stream rotation
 	Rotation     *Rotation
-	FlushMod     int    //flush module to be set only for local testing
+	FlushMod     int    //deprecated: use Durability instead, FlushMod N is mapped to every:N durability
+	Durability   string //optional durability policy: none (default), always, every:<records> or interval:<ms>
+	durability   *Durability
 	URL          string //destination URL
 	timeLayout   string
-	Codec        string //compression codec
-	StreamUpload bool   //streams controls progressive upload to s3, g3 (skip checkup)
+	Codec        string //compression codec: gzip, zlib, deflate, none or registered codec, with optional level i.e. gzip:9
+	compression  *codec.Spec
+	StreamUpload bool //streams controls progressive upload to s3, g3 (skip checkup)
 	format       *Format
-	SamplePct    *float64 //sample pct (0..100)
+	SamplePct    *float64   //sample pct (0..100)
+	Async        *Async     //optional asynchronous logging
+	Shards       int        //optional number of independent writers, 1 by default
+	ShardBy      string     //shard selection: roundRobin (default) or affinity
+	Partition    *Partition //optional partitioned output
+	Header       *Header    //optional header record (CSV) written at the beginning of every file
+	Avro         *Avro      //optional Avro Object Container File output
 	mux          sync.Mutex
 	sampler      *rand.Rand
 }
@@ -36,21 +55,115 @@ func (s *Stream) CanSample() bool {
 	return (target * 100) < *s.SamplePct
 }
 
-//IsGzip returns true if gzip codec specified
+//DurabilityPolicy returns parsed durability policy
+func (s *Stream) DurabilityPolicy() *Durability {
+	return s.durability
+}
+
+//HasAffinity returns true if shards are selected by processor affinity
+func (s *Stream) HasAffinity() bool {
+	return s.Shards > 1 && s.ShardBy == ShardByAffinity
+}
+
+//IsGzip returns true if gzip codec with any level specified, deprecated: use Compression instead
 func (s *Stream) IsGzip() bool {
-	return strings.ToLower(s.Codec) == "gzip"
+	compression := s.compression
+	if compression == nil { //not initialised yet
+		compression, _ = codec.Parse(s.Codec)
+	}
+	return compression != nil && compression.Name == codec.Gzip
+}
+
+//Compression returns parsed stream codec, nil if codec was not specified
+func (s *Stream) Compression() *codec.Spec {
+	return s.compression
 }
 
-//Init initialises log stream
-func (s *Stream) Init() {
+//Init initialises log stream, it returns an error for invalid stream or rotation settings (prior versions returned nothing)
+func (s *Stream) Init() error {
+	var err error
 	if s.Rotation != nil {
-		s.Rotation.Init()
+		if err = s.Rotation.Init(); err != nil {
+			return err
+		}
 	} else if s.format == nil {
 		s.format = &Format{}
 		s.format.Init(s.URL)
 		s.URL = s.format.ExpandURL(time.Now(), s.URL)
 	}
-	if s.IsGzip() && !strings.HasSuffix(s.URL, ".gz") {
-		s.URL += ".gz"
+	if s.compression, err = codec.Parse(s.Codec); err != nil {
+		return err
+	}
+	if s.FlushMod > 0 {
+		if s.Durability != "" {
+			return errors.New("deprecated FlushMod can not be used with Durability")
+		}
+		s.Durability = DurabilityEvery + ":" + strconv.Itoa(s.FlushMod)
+		s.FlushMod = 0
+	}
+	durability, err := ParseDurability(s.Durability)
+	if err != nil {
+		return err
+	}
+	s.durability = durability
+	if s.Shards == 0 {
+		s.Shards = 1
+	}
+	switch s.ShardBy {
+	case "", ShardByRoundRobin, ShardByAffinity:
+	default:
+		return errors.Errorf("unsupported shard selection: %v", s.ShardBy)
+	}
+	if s.Async != nil {
+		if err := s.Async.Init(); err != nil {
+			return err
+		}
+		if durability.Policy != DurabilityNone { //asynchronous record is acknowledged once queued, before it is synced
+			return errors.New("async mode can not be used with durability policy")
+		}
+	}
+	if s.Partition != nil {
+		if err := s.Partition.Init(); err != nil {
+			return err
+		}
+		if s.Shards > 1 || s.Async != nil {
+			return errors.New("partitioned output can not be used with shards or async mode")
+		}
+		if s.Rotation == nil {
+			return errors.New("partitioned output requires rotation")
+		}
+		if err := s.Partition.validateURL("URL", s.URL); err != nil {
+			return err
+		}
+		if err := s.Partition.validateURL("rotation URL", s.Rotation.URL); err != nil {
+			return err
+		}
+	}
+	if s.Avro != nil {
+		if err := s.Avro.Init(); err != nil {
+			return err
+		}
+		if s.compression.IsCompressed() || s.Header != nil { //none codec writes uncompressed stream
+			return errors.New("avro output can not be used with stream codec or header record")
+		}
+	}
+	if s.Rotation != nil && s.Rotation.Parquet != nil {
+		if s.compression.IsCompressed() || s.Avro != nil {
+			return errors.New("parquet rotation output can not be used with stream codec or avro output")
+		}
+		if err := s.Rotation.Parquet.initHeader(s.Header != nil); err != nil {
+			return err
+		}
+	}
+	if suffix := s.suffix(); suffix != "" && !strings.HasSuffix(s.URL, suffix) {
+		s.URL += suffix
+	}
+	return nil
+}
+
+func (s *Stream) suffix() string {
+	if s.compression == nil {
+		return ""
 	}
+	return s.compression.Suffix()
 }
@@ -0,0 +1,29 @@
+package config
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/viant/tapper/parquet"
+	"testing"
+)
+
+func TestStream_Init(t *testing.T) {
+	schema := `{"type":"record","name":"Event","fields":[{"name":"id","type":"long"}]}`
+	var testCases = []struct {
+		description string
+		stream      *Stream
+		hasError    bool
+	}{
+		{description: "avro", stream: &Stream{URL: "/tmp/log.avro", Avro: &Avro{Schema: schema}}},
+		{description: "avro with none codec", stream: &Stream{URL: "/tmp/log.avro", Codec: "none", Avro: &Avro{Schema: schema}}},
+		{description: "avro with gzip codec", stream: &Stream{URL: "/tmp/log.avro", Codec: "gzip", Avro: &Avro{Schema: schema}}, hasError: true},
+		{description: "parquet", stream: &Stream{URL: "/tmp/log.json", Rotation: &Rotation{URL: "/tmp/log-%v", Parquet: &Parquet{}}}},
+		{description: "parquet with none codec", stream: &Stream{URL: "/tmp/log.json", Codec: "none", Rotation: &Rotation{URL: "/tmp/log-%v", Parquet: &Parquet{}}}},
+		{description: "parquet with gzip codec", stream: &Stream{URL: "/tmp/log.json", Codec: "gzip", Rotation: &Rotation{URL: "/tmp/log-%v", Parquet: &Parquet{}}}, hasError: true},
+		{description: "parquet csv without header", stream: &Stream{URL: "/tmp/log.csv", Rotation: &Rotation{URL: "/tmp/log-%v", Parquet: &Parquet{Format: "csv"}}}, hasError: true},
+		{description: "parquet csv with columns", stream: &Stream{URL: "/tmp/log.csv", Rotation: &Rotation{URL: "/tmp/log-%v", Parquet: &Parquet{Format: "csv", Columns: []*parquet.Column{{Name: "id", Type: "int64"}}}}}},
+	}
+	for _, testCase := range testCases {
+		err := testCase.stream.Init()
+		assert.EqualValues(t, testCase.hasError, err != nil, testCase.description)
+	}
+}
@@ -11,8 +11,10 @@ type Event struct {
 	Config  *config.Event
 	Created time.Time
 	URL     string
-	attempt int
-	nextRun *time.Time
+	//OnSuccess is called once event was emitted, including scheduled retries
+	OnSuccess func()
+	attempt   int
+	nextRun   *time.Time
 }
 
 //SetNextRun set next run
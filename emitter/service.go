@@ -11,7 +11,9 @@ import (
 	"io/ioutil"
 	"net/http"
 	u "net/url"
+	"os"
 	"os/exec"
+	"path"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -20,25 +22,35 @@ import (
 
 const (
 	scheduleLoopSleepTime = 100 * time.Millisecond
+	markerSuffix          = ".tapper"
+	shutdownAttempts      = 3 //pending event emit attempts on shutdown, events left in place are emitted on the next start
 )
 
 //Service represents emitter service
 type Service struct {
 	_pending map[string]*Event
+	dropped  []string //URLs of events dropped after max retries
 	mux      sync.Mutex
 	closed   int32
+	stop     chan struct{}
+	stopped  chan struct{}
 	fs       afs.Service
 }
 
 //Close closes service
 func (s *Service) Close() error {
-	atomic.StoreInt32(&s.closed, 1)
+	if atomic.CompareAndSwapInt32(&s.closed, 0, 1) {
+		close(s.stop)
+	}
 	return nil
 }
 
 //Schedule schedules an event
 func (s *Service) Schedule(event *Event) error {
 	if event.attempt > event.Config.MaxRetries && event.Config.MaxRetries > 0 {
+		s.mux.Lock()
+		s.dropped = append(s.dropped, event.URL)
+		s.mux.Unlock()
 		return errors.Errorf("max retries reached: %v", event.Config.MaxRetries)
 	}
 	event.SetNextRun(time.Now())
@@ -54,8 +66,78 @@ func (s *Service) Emit(event *Event) error {
 	err := s.emit(event)
 	if err != nil {
 		s.Schedule(event)
+		return err
+	}
+	if event.OnSuccess != nil {
+		event.OnSuccess()
+	}
+	return nil
+}
+
+//Shutdown stops scheduled retries and retries pending events up to shutdownAttempts times or until context is done,
+//it returns an error with the number and URLs of events that were not emitted, including events dropped after max retries
+func (s *Service) Shutdown(ctx context.Context) error {
+	s.Close()
+	select { //scheduled events are emitted by this goroutine only once scheduler loop exits
+	case <-s.stopped:
+	case <-ctx.Done():
+		return s.unemitted()
+	}
+	for attempt := 1; ; attempt++ {
+		failed := 0
+		for _, event := range s.takePending() {
+			if err := s.Emit(event); err != nil {
+				failed++
+			}
+		}
+		if failed == 0 || attempt >= shutdownAttempts || ctx.Err() != nil {
+			return s.unemitted()
+		}
+		select {
+		case <-ctx.Done():
+		case <-time.After(scheduleLoopSleepTime):
+		}
+	}
+}
+
+//unemitted returns an error listing dropped and pending events or nil
+func (s *Service) unemitted() error {
+	failed := append(s.takeDropped(), s.pendingURLs()...)
+	if len(failed) == 0 {
+		return nil
 	}
-	return err
+	return errors.Errorf("failed to emit %v events: %v", len(failed), strings.Join(failed, ", "))
+}
+
+//takeDropped returns and resets URLs of events dropped after max retries
+func (s *Service) takeDropped() []string {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	result := s.dropped
+	s.dropped = nil
+	return result
+}
+
+//pendingURLs returns URLs of events scheduled for retry
+func (s *Service) pendingURLs() []string {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	var result = make([]string, 0, len(s._pending))
+	for URL := range s._pending {
+		result = append(result, URL)
+	}
+	return result
+}
+
+func (s *Service) takePending() []*Event {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	var result = make([]*Event, 0, len(s._pending))
+	for k, v := range s._pending {
+		result = append(result, v)
+		delete(s._pending, k)
+	}
+	return result
 }
 
 func (s *Service) emit(event *Event) error {
@@ -107,8 +189,7 @@ func (s *Service) pending() []*Event {
 	defer s.mux.Unlock()
 	now := time.Now()
 	for k, v := range s._pending {
-		if v.nextRun.After(now) {
-			v.attempt++
+		if !v.nextRun.After(now) {
 			result = append(result, s._pending[k])
 		}
 	}
@@ -116,6 +197,7 @@ func (s *Service) pending() []*Event {
 }
 
 func (s *Service) handleScheduled() {
+	defer close(s.stopped)
 	for atomic.LoadInt32(&s.closed) == 0 {
 		pending := s.pending()
 		if len(pending) > 0 {
@@ -126,14 +208,19 @@ func (s *Service) handleScheduled() {
 				_ = s.Emit(pending[i])
 			}
 		}
-		time.Sleep(scheduleLoopSleepTime)
+		select {
+		case <-s.stop:
+		case <-time.After(scheduleLoopSleepTime):
+		}
 	}
 }
 
-func (s *Service) loadPending(stream *config.Stream) error {
+//loadPending returns events of rotated files left by a previous process,
+//files with recovery marker are skipped as logger recovery finalises and emits them
+func (s *Service) loadPending(stream *config.Stream) ([]*Event, error) {
 
 	if stream.Rotation == nil || stream.Rotation.Emit == nil {
-		return nil
+		return nil, nil
 	}
 	parent, name := url.Split(stream.Rotation.URL, file.Scheme)
 	index := strings.Index(name, "[")
@@ -146,30 +233,61 @@ func (s *Service) loadPending(stream *config.Stream) error {
 	}
 	objects, err := s.fs.List(context.Background(), parent)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	location := os.TempDir() //remote rotated files are finalised from temp directory
+	if url.Scheme(parent, file.Scheme) == file.Scheme {
+		location = url.Path(parent)
 	}
+	suffix := stream.Rotation.Suffix()
+	var result []*Event
 	for _, object := range objects {
-		if object.IsDir() {
+		if object.IsDir() || strings.HasPrefix(object.Name(), ".") {
 			continue
 		}
 		if strings.HasPrefix(object.Name(), rotationPrefix) {
-			event := &Event{
+			if isRecovered(location, object.Name(), suffix) {
+				continue
+			}
+			result = append(result, &Event{
 				Config:  stream.Rotation.Emit,
 				Created: object.ModTime(),
 				URL:     object.URL(),
-			}
-			_ = s.Emit(event)
+			})
 		}
 	}
-	return nil
+	return result, nil
+}
+
+//isRecovered returns true if rotated file, or its uncompressed original, has recovery marker
+func isRecovered(location, name, suffix string) bool {
+	names := []string{name}
+	if suffix != "" && strings.HasSuffix(name, suffix) {
+		names = append(names, strings.TrimSuffix(name, suffix))
+	}
+	for _, candidate := range names {
+		if _, err := os.Stat(MarkerPath(path.Join(location, candidate))); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+//MarkerPath returns hidden recovery marker path of supplied rotation path
+func MarkerPath(rotationPath string) string {
+	parent, name := path.Split(rotationPath)
+	return path.Join(parent, "."+name+markerSuffix)
 }
 
-//New creates new service
+//New creates new service, events of rotated files left by a previous process are scheduled for emit
 func New(stream *config.Stream) (*Service, error) {
-	result := &Service{_pending: make(map[string]*Event), fs: afs.New()}
-	go func() {
-		result.loadPending(stream)
-		result.handleScheduled()
-	}()
+	result := &Service{_pending: make(map[string]*Event), fs: afs.New(), stop: make(chan struct{}), stopped: make(chan struct{})}
+	pending, _ := result.loadPending(stream) //list before the logger opens its first writer
+	now := time.Now()
+	for _, event := range pending {
+		event.nextRun = &now
+		result._pending[event.URL] = event
+	}
+	go result.handleScheduled()
 	return result, nil
 }
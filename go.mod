@@ -3,7 +3,10 @@ module github.com/viant/tapper
 go 1.15
 
 require (
+	github.com/fxamacker/cbor v1.5.1
 	github.com/go-errors/errors v1.1.1 // indirect
+	github.com/golang/snappy v0.0.4
+	github.com/hamba/avro v1.6.6
 	github.com/klauspost/cpuid v1.3.1 // indirect
 	github.com/lunixbochs/vtclean v1.0.0 // indirect
 	github.com/pkg/errors v0.9.1
@@ -12,9 +15,11 @@ require (
 	github.com/viant/assertly v0.5.4 // indirect
 	github.com/viant/toolbox v0.33.0
 	github.com/viant/xunsafe v0.8.0
+	github.com/vmihailenco/msgpack/v5 v5.3.5
 	golang.org/x/crypto v0.0.0-20200820211705-5c72a883971a // indirect
 	golang.org/x/net v0.0.0-20200822124328-c89045814202 // indirect
 	golang.org/x/oauth2 v0.0.0-20200107190931-bf48bf16ab8d // indirect
 	golang.org/x/sync v0.0.0-20200625203802-6e8e738ad208 // indirect
+	google.golang.org/protobuf v1.27.1
 	gopkg.in/yaml.v2 v2.3.0
 )
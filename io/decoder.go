@@ -0,0 +1,7 @@
+package io
+
+//Decoder defines message decoder, reader side counterpart of Encoder
+type Decoder interface {
+	//Decode decodes JSON message produced by Encoder, i.e. a line of JSON log
+	Decode(data []byte) error
+}
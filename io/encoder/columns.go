@@ -0,0 +1,58 @@
+package encoder
+
+import (
+	"github.com/viant/tapper/io"
+	"time"
+)
+
+//columns represents a stream collecting keys of put fields, nested object keys are dotted the way CSV message names its columns
+type columns struct {
+	prefix string
+	keys   []string
+}
+
+func (c *columns) add(key string) {
+	if c.prefix != "" {
+		key = c.prefix + "." + key
+	}
+	c.keys = append(c.keys, key)
+}
+
+//Put does not add a column
+func (c *columns) Put(bs []byte) {}
+
+//PutByte does not add a column
+func (c *columns) PutByte(b byte) {}
+
+//PutObject adds dotted columns of object fields
+func (c *columns) PutObject(key string, object io.Encoder) {
+	prefix := c.prefix
+	c.prefix = key
+	if prefix != "" {
+		c.prefix = prefix + "." + key
+	}
+	object.Encode(c)
+	c.prefix = prefix
+}
+
+//PutObjects adds a single column, as objects are written as JSON cell by default
+func (c *columns) PutObjects(key string, objects []io.Encoder) { c.add(key) }
+
+func (c *columns) PutString(key, value string)                   { c.add(key) }
+func (c *columns) PutNonEmptyString(key, value string)           { c.add(key) }
+func (c *columns) PutB64EncodedBytes(key string, bytes []byte)   { c.add(key) }
+func (c *columns) PutStrings(key string, values []string)        { c.add(key) }
+func (c *columns) PutInts(key string, values []int)              { c.add(key) }
+func (c *columns) PutUInts(key string, values []uint64)          { c.add(key) }
+func (c *columns) PutInt(key string, value int)                  { c.add(key) }
+func (c *columns) PutFloat(key string, value float64)            { c.add(key) }
+func (c *columns) PutFloats(key string, values []float64)        { c.add(key) }
+func (c *columns) PutBool(key string, value bool)                { c.add(key) }
+func (c *columns) PutBools(key string, value []bool)             { c.add(key) }
+func (c *columns) PutInt64(key string, value int64)              { c.add(key) }
+func (c *columns) PutUint64(key string, value uint64)            { c.add(key) }
+func (c *columns) PutFloat32(key string, value float32)          { c.add(key) }
+func (c *columns) PutTime(key string, value time.Time, _ string) { c.add(key) }
+func (c *columns) PutDuration(key string, value time.Duration)   { c.add(key) }
+func (c *columns) PutNull(key string)                            { c.add(key) }
+func (c *columns) PutRaw(key string, value []byte)               { c.add(key) }
@@ -0,0 +1,316 @@
+package encoder
+
+import (
+	"encoding"
+	"fmt"
+	"github.com/viant/tapper/io"
+	"reflect"
+	"sort"
+	"time"
+	"unsafe"
+)
+
+//Map represents dynamic map encoder, entries are encoded in key order
+type Map map[string]interface{}
+
+//Encode encodes map entries
+func (m Map) Encode(stream io.Stream) {
+	scratch := Borrow()
+	for key := range m {
+		scratch.Keys = append(scratch.Keys, key)
+	}
+	scratch.SortKeys()
+	for _, key := range scratch.Keys {
+		putValue(stream, key, m[key], time.RFC3339)
+	}
+	scratch.Release()
+}
+
+//mapping represents map field keys collection and entry encoding functions
+type mapping struct {
+	collect func(m *mapEncoder, keys []string) []string
+	put     func(m *mapEncoder, key string, stream io.Stream)
+}
+
+//mapEncoder encodes map with string keys as nested object, entries are encoded in key order
+type mapEncoder struct {
+	*mapping
+	field  *Field
+	owner  *Struct
+	ptr    unsafe.Pointer //map pointer
+	keys   []string
+	child  Struct        //reusable nested struct encoder
+	holder reflect.Value //reusable addressable struct map value
+}
+
+//Encode encodes map entries
+func (m *mapEncoder) Encode(stream io.Stream) {
+	for _, key := range m.keys {
+		m.put(m, key, stream)
+	}
+}
+
+//putObject puts nested struct map value, cyclic value is skipped
+func (m *mapEncoder) putObject(key string, ptr unsafe.Pointer, stream io.Stream) {
+	nested := m.field.Nested
+	if nested.recursive && m.owner.visiting(nested, ptr) {
+		return
+	}
+	m.child.Provider, m.child.ptr, m.child.parent = nested, ptr, m.owner
+	stream.PutObject(key, &m.child)
+}
+
+//mapEncoder returns encoding function of map with string keys, nil map is skipped
+func (b *builder) mapEncoder(f *Field) (func(e *Struct, ptr unsafe.Pointer, stream io.Stream), error) {
+	if f.Key().Kind() != reflect.String {
+		return nil, fmt.Errorf("not yet supported map key type: %v", f.Type.String())
+	}
+	entries, err := b.mapping(f)
+	if err != nil {
+		return nil, err
+	}
+	return func(e *Struct, ptr unsafe.Pointer, stream io.Stream) {
+		mapPtr := f.Pointer(ptr)
+		if *(*unsafe.Pointer)(mapPtr) == nil {
+			return
+		}
+		state := e.state(f)
+		if state.entries == nil {
+			state.entries = &mapEncoder{mapping: entries, field: f}
+		}
+		m := state.entries
+		m.owner, m.ptr = e, mapPtr
+		m.keys = m.collect(m, m.keys[:0])
+		if f.OmitEmpty && len(m.keys) == 0 {
+			return
+		}
+		sort.Strings(m.keys)
+		stream.PutObject(f.Name, m)
+	}, nil
+}
+
+//mapping returns map keys collection and entry encoding, common map types do not use reflection
+func (b *builder) mapping(f *Field) (*mapping, error) {
+	if f.Key() == stringType {
+		switch f.Elem() {
+		case stringType:
+			return &mapping{
+				collect: func(m *mapEncoder, keys []string) []string {
+					for key := range *(*map[string]string)(m.ptr) {
+						keys = append(keys, key)
+					}
+					return keys
+				},
+				put: func(m *mapEncoder, key string, stream io.Stream) {
+					stream.PutString(key, (*(*map[string]string)(m.ptr))[key])
+				},
+			}, nil
+		case intType:
+			return &mapping{
+				collect: func(m *mapEncoder, keys []string) []string {
+					for key := range *(*map[string]int)(m.ptr) {
+						keys = append(keys, key)
+					}
+					return keys
+				},
+				put: func(m *mapEncoder, key string, stream io.Stream) {
+					stream.PutInt(key, (*(*map[string]int)(m.ptr))[key])
+				},
+			}, nil
+		case float64Type:
+			return &mapping{
+				collect: func(m *mapEncoder, keys []string) []string {
+					for key := range *(*map[string]float64)(m.ptr) {
+						keys = append(keys, key)
+					}
+					return keys
+				},
+				put: func(m *mapEncoder, key string, stream io.Stream) {
+					stream.PutFloat(key, (*(*map[string]float64)(m.ptr))[key])
+				},
+			}, nil
+		case boolType:
+			return &mapping{
+				collect: func(m *mapEncoder, keys []string) []string {
+					for key := range *(*map[string]bool)(m.ptr) {
+						keys = append(keys, key)
+					}
+					return keys
+				},
+				put: func(m *mapEncoder, key string, stream io.Stream) {
+					stream.PutBool(key, (*(*map[string]bool)(m.ptr))[key])
+				},
+			}, nil
+		case interfaceType:
+			return &mapping{
+				collect: func(m *mapEncoder, keys []string) []string {
+					for key := range *(*map[string]interface{})(m.ptr) {
+						keys = append(keys, key)
+					}
+					return keys
+				},
+				put: func(m *mapEncoder, key string, stream io.Stream) {
+					putValue(stream, key, (*(*map[string]interface{})(m.ptr))[key], f.Format)
+				},
+			}, nil
+		}
+	}
+	return b.reflectMapping(f)
+}
+
+//reflectMapping returns map keys collection and entry encoding using reflection
+func (b *builder) reflectMapping(f *Field) (*mapping, error) {
+	elemType := f.Elem()
+	nestedType, pointer := structType(elemType)
+	custom := marshaling(elemType) != MarshalNone //map values are not addressable, only value receiver marshaler is used
+	switch {
+	case custom:
+	case nestedType != nil:
+		nested, err := b.provider(nestedType)
+		if err != nil {
+			return nil, err
+		}
+		f.Nested = nested
+	case elemType == timeType:
+	default:
+		switch elemType.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+			reflect.Float32, reflect.Float64, reflect.String, reflect.Bool, reflect.Interface, reflect.Slice, reflect.Map:
+		default:
+			return nil, fmt.Errorf("not yet supported map value type: %v", f.Type.String())
+		}
+	}
+	keyType := f.Key()
+	return &mapping{
+		collect: func(m *mapEncoder, keys []string) []string {
+			iterator := reflect.NewAt(f.Type, m.ptr).Elem().MapRange()
+			for iterator.Next() {
+				keys = append(keys, iterator.Key().String())
+			}
+			return keys
+		},
+		put: func(m *mapEncoder, key string, stream io.Stream) {
+			value := reflect.NewAt(f.Type, m.ptr).Elem().MapIndex(reflect.ValueOf(key).Convert(keyType))
+			switch {
+			case custom:
+				if value.Kind() != reflect.Ptr || !value.IsNil() {
+					putValue(stream, key, value.Interface(), f.Format)
+				}
+				return
+			case f.Nested != nil && pointer:
+				if !value.IsNil() {
+					m.putObject(key, unsafe.Pointer(value.Pointer()), stream)
+				}
+				return
+			case f.Nested != nil:
+				if !m.holder.IsValid() {
+					m.holder = reflect.New(elemType).Elem()
+				}
+				m.holder.Set(value)
+				m.putObject(key, unsafe.Pointer(m.holder.UnsafeAddr()), stream)
+				return
+			}
+			switch value.Kind() {
+			case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32:
+				stream.PutInt(key, int(value.Int()))
+			case reflect.Int64:
+				if elemType == durationType {
+					stream.PutDuration(key, time.Duration(value.Int()))
+					return
+				}
+				stream.PutInt64(key, value.Int())
+			case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+				stream.PutUint64(key, value.Uint())
+			case reflect.Float32:
+				stream.PutFloat32(key, float32(value.Float()))
+			case reflect.Float64:
+				stream.PutFloat(key, value.Float())
+			case reflect.String:
+				stream.PutString(key, value.String())
+			case reflect.Bool:
+				stream.PutBool(key, value.Bool())
+			default:
+				putValue(stream, key, value.Interface(), f.Format)
+			}
+		},
+	}, nil
+}
+
+//putValue puts dynamic value, custom marshalers are used before fmt.Sprint text fallback
+func putValue(stream io.Stream, key string, value interface{}, layout string) {
+	switch actual := value.(type) {
+	case nil:
+		stream.PutNull(key)
+	case string:
+		stream.PutString(key, actual)
+	case bool:
+		stream.PutBool(key, actual)
+	case int:
+		stream.PutInt(key, actual)
+	case int8:
+		stream.PutInt(key, int(actual))
+	case int16:
+		stream.PutInt(key, int(actual))
+	case int32:
+		stream.PutInt(key, int(actual))
+	case int64:
+		stream.PutInt64(key, actual)
+	case uint:
+		stream.PutUint64(key, uint64(actual))
+	case uint8:
+		stream.PutUint64(key, uint64(actual))
+	case uint16:
+		stream.PutUint64(key, uint64(actual))
+	case uint32:
+		stream.PutUint64(key, uint64(actual))
+	case uint64:
+		stream.PutUint64(key, actual)
+	case float32:
+		stream.PutFloat32(key, actual)
+	case float64:
+		stream.PutFloat(key, actual)
+	case time.Time:
+		stream.PutTime(key, actual, layout)
+	case *time.Time:
+		if actual != nil {
+			stream.PutTime(key, *actual, layout)
+		}
+	case time.Duration:
+		stream.PutDuration(key, actual)
+	case []byte:
+		stream.PutB64EncodedBytes(key, actual)
+	case []string:
+		stream.PutStrings(key, actual)
+	case []int:
+		stream.PutInts(key, actual)
+	case []uint64:
+		stream.PutUInts(key, actual)
+	case []float64:
+		stream.PutFloats(key, actual)
+	case []bool:
+		stream.PutBools(key, actual)
+	case io.StreamMarshaler:
+		actual.MarshalStream(key, stream)
+	case io.Encoder:
+		stream.PutObject(key, actual)
+	case map[string]interface{}:
+		stream.PutObject(key, Map(actual))
+	case encoding.TextMarshaler:
+		if text, err := actual.MarshalText(); err == nil {
+			stream.PutString(key, string(text))
+		} else {
+			stream.PutNull(key)
+		}
+	case fmt.Stringer:
+		stream.PutString(key, actual.String())
+	default:
+		stream.PutString(key, fmt.Sprint(actual))
+	}
+}
+
+var stringType = reflect.TypeOf("")
+var intType = reflect.TypeOf(0)
+var float64Type = reflect.TypeOf(0.0)
+var boolType = reflect.TypeOf(true)
+var interfaceType = reflect.TypeOf((*interface{})(nil)).Elem()
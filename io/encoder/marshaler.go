@@ -0,0 +1,132 @@
+package encoder
+
+import (
+	"encoding"
+	"fmt"
+	"github.com/viant/tapper/io"
+	"reflect"
+	"unsafe"
+)
+
+//Marshaling represents field custom encoding
+type Marshaling int
+
+const (
+	//MarshalNone field is encoded by its kind
+	MarshalNone = Marshaling(iota)
+	//MarshalStream field is encoded by io.StreamMarshaler
+	MarshalStream
+	//MarshalObject field is encoded by io.Encoder as nested object
+	MarshalObject
+	//MarshalText field is encoded by encoding.TextMarshaler as string, marshaling error is encoded as null
+	MarshalText
+	//MarshalString field of non struct type is encoded by fmt.Stringer as string
+	MarshalString
+)
+
+//marshaling returns custom encoding implemented by the type, time and duration are encoded natively,
+//fmt.Stringer is not used for struct types, as String is usually a debug representation of struct fields
+func marshaling(aType reflect.Type) Marshaling {
+	native := aType
+	if native.Kind() == reflect.Ptr {
+		native = native.Elem()
+	}
+	if native == timeType || native == durationType {
+		return MarshalNone
+	}
+	switch {
+	case aType.Implements(streamMarshalerType):
+		return MarshalStream
+	case aType.Implements(encoderType):
+		return MarshalObject
+	case aType.Implements(textMarshalerType):
+		return MarshalText
+	case aType.Implements(stringerType) && native.Kind() != reflect.Struct:
+		return MarshalString
+	}
+	return MarshalNone
+}
+
+//marshalerEncoder returns encoding function delegating to the field type marshaler or nil, nil pointer is skipped,
+//field of non pointer type uses its address, so marshaler methods can have pointer receiver, text marshaler error is encoded as null,
+//so the failure is not mistaken for empty text while CSV columns stay aligned
+func marshalerEncoder(f *Field) func(e *Struct, ptr unsafe.Pointer, stream io.Stream) {
+	pointer := f.Kind() == reflect.Ptr
+	addrType := f.Type
+	if !pointer {
+		addrType = reflect.PtrTo(f.Type)
+	}
+	if f.Marshaling = marshaling(addrType); f.Marshaling == MarshalNone {
+		return nil
+	}
+	typ := typeOf(reflect.New(f.Type).Elem().Interface())
+	valueOf := func(ptr unsafe.Pointer) interface{} {
+		if !pointer {
+			return f.Addr(ptr)
+		}
+		fieldPtr := *(*unsafe.Pointer)(f.Pointer(ptr))
+		if fieldPtr == nil {
+			return nil
+		}
+		var result interface{}
+		*(*emptyInterface)(unsafe.Pointer(&result)) = emptyInterface{typ: typ, word: fieldPtr}
+		return result
+	}
+	switch f.Marshaling {
+	case MarshalStream:
+		return func(e *Struct, ptr unsafe.Pointer, stream io.Stream) {
+			if value := valueOf(ptr); value != nil {
+				value.(io.StreamMarshaler).MarshalStream(f.Name, stream)
+			}
+		}
+	case MarshalObject:
+		return func(e *Struct, ptr unsafe.Pointer, stream io.Stream) {
+			if value := valueOf(ptr); value != nil {
+				stream.PutObject(f.Name, value.(io.Encoder))
+			}
+		}
+	case MarshalText:
+		return func(e *Struct, ptr unsafe.Pointer, stream io.Stream) {
+			value := valueOf(ptr)
+			if value == nil {
+				return
+			}
+			text, err := value.(encoding.TextMarshaler).MarshalText()
+			if err != nil {
+				stream.PutNull(f.Name)
+				return
+			}
+			if f.OmitEmpty && len(text) == 0 {
+				return
+			}
+			stream.PutString(f.Name, string(text))
+		}
+	}
+	return func(e *Struct, ptr unsafe.Pointer, stream io.Stream) {
+		value := valueOf(ptr)
+		if value == nil {
+			return
+		}
+		text := value.(fmt.Stringer).String()
+		if f.OmitEmpty && text == "" {
+			return
+		}
+		stream.PutString(f.Name, text)
+	}
+}
+
+//emptyInterface represents interface{} runtime representation
+type emptyInterface struct {
+	typ  unsafe.Pointer
+	word unsafe.Pointer
+}
+
+//typeOf returns runtime type of the value, pointer value interface uses the pointer as data word, so it can be built without allocation
+func typeOf(value interface{}) unsafe.Pointer {
+	return (*emptyInterface)(unsafe.Pointer(&value)).typ
+}
+
+var streamMarshalerType = reflect.TypeOf((*io.StreamMarshaler)(nil)).Elem()
+var encoderType = reflect.TypeOf((*io.Encoder)(nil)).Elem()
+var textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+var stringerType = reflect.TypeOf((*fmt.Stringer)(nil)).Elem()
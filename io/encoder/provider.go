@@ -5,24 +5,51 @@ import (
 	"github.com/viant/tapper/io"
 	"github.com/viant/xunsafe"
 	"reflect"
+	"sync"
 	"time"
+	"unsafe"
 )
 
-const (
-	intMaks     = uint16(1) << 1
-	float64Mask = uint16(1) << 2
-	stringMask  = uint16(1) << 3
-	boolMask    = uint16(1) << 4
-	timePtrMask = uint16(1) << 5
-	float32Mask = uint16(1) << 6
-	timeMask    = uint16(1) << 7
-	stringsMask = uint16(1) << 8
-	intsMask    = uint16(1) << 9
-)
+//Field represents encoded struct field, field encoding function is resolved by the provider, so encoding does not use reflection
+type Field struct {
+	*xunsafe.Field
+	Name       string     //encoded key, tapper tag name or struct field name
+	OmitEmpty  bool       //skips zero value
+	AsString   bool       //encodes numbers, booleans and durations as strings
+	Format     string     //time layout, time.RFC3339 by default, empty layout uses message native time type
+	Nested     *Provider  //struct, struct pointer or struct slice field provider
+	Marshaling Marshaling //custom encoding implemented by the field type
+	index      int
+	path       []embedding
+	encode     func(e *Struct, ptr unsafe.Pointer, stream io.Stream)
+}
+
+//embedding represents embedded struct of flattened field
+type embedding struct {
+	offset  uintptr
+	pointer bool
+}
+
+//holder returns pointer to the struct declaring the field or nil if embedded struct pointer is nil
+func (f *Field) holder(ptr unsafe.Pointer) unsafe.Pointer {
+	for _, embedded := range f.path {
+		ptr = unsafe.Pointer(uintptr(ptr) + embedded.offset)
+		if embedded.pointer {
+			if ptr = *(*unsafe.Pointer)(ptr); ptr == nil {
+				return nil
+			}
+		}
+	}
+	return ptr
+}
 
 //Provider represents a struct encoder provider
 type Provider struct {
 	reflect.Type
+	Fields    []*Field //encoded fields in declaration order, embedded struct fields are flattened
+	recursive bool     //provider type is referenced by its own nested fields
+
+	//deprecated: use Fields instead, fields grouped by kind are kept for backward compatibility only
 	Int     []*xunsafe.Field
 	Float64 []*xunsafe.Field
 	String  []*xunsafe.Field
@@ -32,10 +59,9 @@ type Provider struct {
 	Time    []*xunsafe.Field
 	Strings []*xunsafe.Field
 	Ints    []*xunsafe.Field
-	mask    uint16
 }
 
-//New creates encoder for a struct value
+//New creates encoder for a struct value, encoder reuses nested struct encoders, so it is not safe for concurrent use
 func (p *Provider) New(value interface{}) io.Encoder {
 	return &Struct{
 		Provider: p,
@@ -44,7 +70,38 @@ func (p *Provider) New(value interface{}) io.Encoder {
 	}
 }
 
-//New creates struct encoder provider
+//ColumnsOf returns columns CSV message writes for the value, unlike Columns, map entries and io.Encoder field keys use dotted names,
+//while nil pointers, nil maps and omitted empty fields have no columns
+func (p *Provider) ColumnsOf(value interface{}) []string {
+	result := &columns{}
+	p.New(value).Encode(result)
+	return result.keys
+}
+
+//Columns returns field names in encoding order, nested struct fields use dotted names, i.e. user.id, map and io.Encoder fields
+//use field name, as their columns depend on encoded value, use ColumnsOf for values with such fields
+func (p *Provider) Columns() []string {
+	return p.columns("", map[*Provider]bool{}, make([]string, 0, len(p.Fields)))
+}
+
+func (p *Provider) columns(prefix string, visited map[*Provider]bool, result []string) []string {
+	visited[p] = true
+	for _, field := range p.Fields {
+		if kind := field.Kind(); field.Nested != nil && (kind == reflect.Struct || kind == reflect.Ptr) {
+			if !visited[field.Nested] {
+				result = field.Nested.columns(prefix+field.Name+".", visited, result)
+			}
+			continue
+		}
+		result = append(result, prefix+field.Name)
+	}
+	delete(visited, p)
+	return result
+}
+
+var providers = &sync.Map{}
+
+//New creates struct encoder provider, providers are cached per struct type
 func New(value interface{}) (*Provider, error) {
 	var sType reflect.Type
 	switch actual := value.(type) {
@@ -52,64 +109,171 @@ func New(value interface{}) (*Provider, error) {
 		sType = actual
 	default:
 		sType = reflect.TypeOf(value)
-		if sType.Kind() == reflect.Ptr {
-			sType = sType.Elem()
-		}
+	}
+	if sType.Kind() == reflect.Ptr {
+		sType = sType.Elem()
+	}
+	if sType.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("unsupported type: %v, expected struct", sType.String())
+	}
+	if cached, ok := providers.Load(sType); ok {
+		return cached.(*Provider), nil
+	}
+	builder := &builder{pending: map[reflect.Type]*Provider{}}
+	result, err := builder.provider(sType)
+	if err != nil {
+		return nil, err
+	}
+	for pendingType, provider := range builder.pending {
+		providers.LoadOrStore(pendingType, provider)
+	}
+	return result, nil
+}
+
+//builder builds providers of a struct type and its nested struct types
+type builder struct {
+	pending map[reflect.Type]*Provider
+}
+
+func (b *builder) provider(sType reflect.Type) (*Provider, error) {
+	if result, ok := b.pending[sType]; ok {
+		result.recursive = true //provider is still being built, recursive type shares the provider
+		return result, nil
+	}
+	if cached, ok := providers.Load(sType); ok {
+		return cached.(*Provider), nil
 	}
 	result := &Provider{Type: sType}
-	xStruct := xunsafe.NewStruct(sType)
-	for i := range xStruct.Fields {
-		field := &xStruct.Fields[i]
-		if tapperTag, ok := field.Tag.Lookup("tapper"); ok {
-			if tapperTag == "-" {
-				continue
-			}
+	b.pending[sType] = result
+	if err := b.addFields(result, sType, nil, map[reflect.Type]bool{sType: true}); err != nil {
+		return nil, err
+	}
+	result.Fields = dedupe(result.Fields)
+	for i, field := range result.Fields {
+		field.index = i
+		if len(field.path) == 0 { //promoted field offset is relative to its embedded struct
+			result.group(field.Field)
 		}
+	}
+	return result, nil
+}
 
-		switch field.Kind() {
+//group adds field to deprecated fields grouped by kind, promoted embedded fields are not grouped
+func (p *Provider) group(field *xunsafe.Field) {
+	switch field.Kind() {
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint64:
+		p.Int = append(p.Int, field)
+	case reflect.Float64:
+		p.Float64 = append(p.Float64, field)
+	case reflect.String:
+		p.String = append(p.String, field)
+	case reflect.Bool:
+		p.Bool = append(p.Bool, field)
+	case reflect.Float32:
+		p.Float32 = append(p.Float32, field)
+	case reflect.Slice:
+		switch field.Elem().Kind() {
 		case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint64:
-			result.mask |= intMaks
-			result.Int = append(result.Int, field)
-		case reflect.Float64:
-			result.mask |= float64Mask
-			result.Float64 = append(result.Float64, field)
+			p.Ints = append(p.Ints, field)
 		case reflect.String:
-			result.mask |= stringMask
-			result.String = append(result.String, field)
-		case reflect.Bool:
-			result.mask |= boolMask
-			result.Bool = append(result.Bool, field)
-		case reflect.Float32:
-			result.mask |= float32Mask
-			result.Float32 = append(result.Float32, field)
-		case reflect.Slice:
-			switch field.Elem().Kind() {
-			case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint64:
-				result.mask |= intsMask
-				result.Ints = append(result.Ints, field)
-			case reflect.String:
-				result.mask |= stringsMask
-				result.Strings = append(result.Strings, field)
-			default:
-				return nil, fmt.Errorf("not yet supported type: %v", field.Type.String())
+			p.Strings = append(p.Strings, field)
+		}
+	default:
+		if field.Type.AssignableTo(timeType) {
+			p.Time = append(p.Time, field)
+		} else if field.Type.AssignableTo(timePtrType) {
+			p.TimePtr = append(p.TimePtr, field)
+		}
+	}
+}
+
+//addFields adds struct fields, embedded struct fields without tag name are flattened
+func (b *builder) addFields(provider *Provider, sType reflect.Type, path []embedding, embedded map[reflect.Type]bool) error {
+	xStruct := xunsafe.NewStruct(sType)
+	for i := range xStruct.Fields {
+		xField := &xStruct.Fields[i]
+		tag := ParseTag(xField.Tag.Get(TagName))
+		if tag.Skip {
+			continue
+		}
+		if embeddedType, pointer := structType(xField.Type); xField.Anonymous && tag.Name == "" && embeddedType != nil {
+			if embedded[embeddedType] {
+				return fmt.Errorf("recursive embedded struct: %v", embeddedType.String())
 			}
-		default:
-			if field.Type.AssignableTo(timeType) {
-				result.mask |= timeMask
-				result.Time = append(result.Time, field)
-				continue
+			embedded[embeddedType] = true
+			fieldPath := append(append([]embedding{}, path...), embedding{offset: xField.Offset, pointer: pointer})
+			if err := b.addFields(provider, embeddedType, fieldPath, embedded); err != nil {
+				return err
 			}
-			if field.Type.AssignableTo(timePtrType) {
-				result.mask |= timePtrMask
-				result.TimePtr = append(result.TimePtr, field)
-				continue
+			delete(embedded, embeddedType)
+			continue
+		}
+		field := &Field{Field: xField, Name: xField.Name, OmitEmpty: tag.OmitEmpty, AsString: tag.AsString, Format: time.RFC3339, path: path}
+		if tag.Name != "" {
+			field.Name = tag.Name
+		}
+		if tag.HasFormat {
+			if !isTime(xField.Type) {
+				return fmt.Errorf("format option is only supported by time fields: %v", xField.Name)
 			}
-			return nil, fmt.Errorf("not yet supported type: %v", field.Type.String())
+			field.Format = tag.Format
 		}
+		encode, err := b.encoder(field)
+		if err != nil {
+			return err
+		}
+		if len(path) > 0 {
+			encode = flattened(field, encode)
+		}
+		field.encode = encode
+		provider.Fields = append(provider.Fields, field)
 	}
+	return nil
+}
 
-	return result, nil
+//flattened returns embedded struct field encoder
+func flattened(field *Field, encode func(e *Struct, ptr unsafe.Pointer, stream io.Stream)) func(e *Struct, ptr unsafe.Pointer, stream io.Stream) {
+	return func(e *Struct, ptr unsafe.Pointer, stream io.Stream) {
+		if holder := field.holder(ptr); holder != nil {
+			encode(e, holder, stream)
+		}
+	}
+}
+
+//dedupe removes fields with duplicated name, the least nested field wins, then the first declared one
+func dedupe(fields []*Field) []*Field {
+	var result = make([]*Field, 0, len(fields))
+	index := map[string]int{}
+	for _, field := range fields {
+		i, ok := index[field.Name]
+		if !ok {
+			index[field.Name] = len(result)
+			result = append(result, field)
+			continue
+		}
+		if len(field.path) < len(result[i].path) {
+			result[i] = field
+		}
+	}
+	return result
+}
+
+//structType returns struct type of struct or struct pointer type, time is not considered a struct
+func structType(fieldType reflect.Type) (reflect.Type, bool) {
+	pointer := fieldType.Kind() == reflect.Ptr
+	if pointer {
+		fieldType = fieldType.Elem()
+	}
+	if fieldType.Kind() != reflect.Struct || fieldType == timeType {
+		return nil, false
+	}
+	return fieldType, pointer
+}
+
+func isTime(fieldType reflect.Type) bool {
+	return fieldType.AssignableTo(timeType) || fieldType.AssignableTo(timePtrType)
 }
 
 var timeType = reflect.TypeOf(time.Time{})
 var timePtrType = reflect.TypeOf(&time.Time{})
+var durationType = reflect.TypeOf(time.Duration(0))
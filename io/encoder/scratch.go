@@ -0,0 +1,56 @@
+package encoder
+
+import (
+	"github.com/viant/tapper/io"
+	"sort"
+	"sync"
+)
+
+//Scratch represents pooled slices reused by generated encoders for struct slices, converted primitive slices and map keys
+type Scratch struct {
+	Objects []io.Encoder
+	Ints    []int
+	UInts   []uint64
+	Floats  []float64
+	Bools   []bool
+	Strings []string
+	Keys    []string
+}
+
+var scratches = sync.Pool{New: func() interface{} { return &Scratch{} }}
+
+//Borrow returns pooled scratch with empty slices, it has to be released once the slices are encoded
+func Borrow() *Scratch {
+	return scratches.Get().(*Scratch)
+}
+
+//SortKeys sorts keys without converting the slice to sort.Interface
+func (s *Scratch) SortKeys() {
+	sort.Sort((*keys)(s))
+}
+
+//Release empties the slices and returns scratch to the pool, visits collected in Objects are released too
+func (s *Scratch) Release() {
+	for i, object := range s.Objects {
+		if visit, ok := object.(*Visit); ok {
+			visit.Release()
+		}
+		s.Objects[i] = nil
+	}
+	for i := range s.Strings {
+		s.Strings[i] = ""
+	}
+	for i := range s.Keys {
+		s.Keys[i] = ""
+	}
+	s.Objects, s.Ints, s.UInts, s.Floats = s.Objects[:0], s.Ints[:0], s.UInts[:0], s.Floats[:0]
+	s.Bools, s.Strings, s.Keys = s.Bools[:0], s.Strings[:0], s.Keys[:0]
+	scratches.Put(s)
+}
+
+//keys implements sort.Interface for scratch keys
+type keys Scratch
+
+func (k *keys) Len() int           { return len(k.Keys) }
+func (k *keys) Less(i, j int) bool { return k.Keys[i] < k.Keys[j] }
+func (k *keys) Swap(i, j int)      { k.Keys[i], k.Keys[j] = k.Keys[j], k.Keys[i] }
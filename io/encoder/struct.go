@@ -1,7 +1,10 @@
 package encoder
 
 import (
+	"fmt"
 	"github.com/viant/tapper/io"
+	"reflect"
+	"strconv"
 	"time"
 	"unsafe"
 )
@@ -9,115 +12,405 @@ import (
 //Struct reprsents basic struct encoder
 type Struct struct {
 	*Provider
-	ptr   unsafe.Pointer
-	value interface{}
+	ptr    unsafe.Pointer
+	value  interface{}
+	parent *Struct
+	states []fieldState //reusable field encoding state
 }
 
-//Encode encodes a stream
+//fieldState represents reusable nested encoders and converted slices of a field
+type fieldState struct {
+	structs []Struct
+	objects []io.Encoder
+	ints    []int
+	uints   []uint64
+	floats  []float64
+	entries *mapEncoder
+}
+
+//Encode encodes a stream, fields are encoded in declaration order
 func (e *Struct) Encode(stream io.Stream) {
-	e.encodeInt(stream)
-	e.encodeFloat64(stream)
-	e.encodeString(stream)
-	e.encodeBool(stream)
-	e.encodeTimePtr(stream)
-	if e.mask < float32Mask {
-		return
+	for _, f := range e.Fields {
+		f.encode(e, e.ptr, stream)
 	}
-	e.encodeFloat32(stream)
-	e.encodeTime(stream)
-	e.encodeInts(stream)
-	e.encodeStrings(stream)
 }
 
-func (e *Struct) encodeInt(stream io.Stream) {
-	if len(e.Int) == 0 {
-		return
-	}
-	for _, f := range e.Int {
-		v := f.Int(e.ptr)
-		stream.PutInt(f.Name, v)
+//state returns field encoding state
+func (e *Struct) state(f *Field) *fieldState {
+	if e.states == nil {
+		e.states = make([]fieldState, len(e.Fields))
 	}
+	return &e.states[f.index]
 }
 
-func (e *Struct) encodeInts(stream io.Stream) {
-	if len(e.Ints) == 0 {
-		return
-	}
-	for _, f := range e.Ints {
-		v := f.Addr(e.ptr).(*[]int)
-		stream.PutInts(f.Name, *v)
+//nested returns reusable nested struct encoders of the field
+func (e *Struct) nested(f *Field, size int) []Struct {
+	state := e.state(f)
+	if cap(state.structs) < size {
+		state.structs = make([]Struct, size)
 	}
+	return state.structs[:size]
 }
 
-func (e *Struct) encodeStrings(stream io.Stream) {
-	if len(e.Strings) == 0 {
-		return
-	}
-	for _, f := range e.Strings {
-		v := f.Addr(e.ptr).(*[]string)
-		stream.PutStrings(f.Name, *v)
+//visiting returns true if the struct is being encoded by the encoder or its parents, it detects cyclic values of recursive types
+func (e *Struct) visiting(provider *Provider, ptr unsafe.Pointer) bool {
+	for parent := e; parent != nil; parent = parent.parent {
+		if parent.Provider == provider && parent.ptr == ptr {
+			return true
+		}
 	}
+	return false
 }
 
-func (e *Struct) encodeTimePtr(stream io.Stream) {
-	if len(e.TimePtr) == 0 {
+//putObject puts nested struct, cyclic value is skipped
+func (e *Struct) putObject(f *Field, ptr unsafe.Pointer, stream io.Stream) {
+	if f.Nested.recursive && e.visiting(f.Nested, ptr) {
 		return
 	}
-	for _, f := range e.TimePtr {
-		v := f.TimePtr(e.ptr)
-		if v == nil {
+	child := &e.nested(f, 1)[0]
+	child.Provider, child.ptr, child.parent = f.Nested, ptr, e
+	stream.PutObject(f.Name, child)
+}
+
+//putObjects puts struct or struct pointer slice, nil and cyclic items are skipped
+func (e *Struct) putObjects(f *Field, slice *sliceHeader, size uintptr, pointer bool, stream io.Stream) {
+	children := e.nested(f, slice.len)
+	state := e.state(f)
+	objects := state.objects[:0]
+	for i := 0; i < slice.len; i++ {
+		item := unsafe.Pointer(uintptr(slice.data) + uintptr(i)*size)
+		if pointer {
+			if item = *(*unsafe.Pointer)(item); item == nil {
+				continue
+			}
+		}
+		if f.Nested.recursive && e.visiting(f.Nested, item) {
 			continue
 		}
-		stream.PutString(f.Name, v.Format(time.RFC3339))
+		child := &children[i]
+		child.Provider, child.ptr, child.parent = f.Nested, item, e
+		objects = append(objects, child)
 	}
+	state.objects = objects
+	stream.PutObjects(f.Name, objects)
 }
 
-func (e *Struct) encodeTime(stream io.Stream) {
-	if len(e.Time) == 0 {
-		return
-	}
-	for _, f := range e.Time {
-		v := f.Time(e.ptr)
-		stream.PutString(f.Name, v.Format(time.RFC3339))
-	}
+//sliceHeader represents slice runtime representation
+type sliceHeader struct {
+	data unsafe.Pointer
+	len  int
+	cap  int
 }
 
-func (e *Struct) encodeString(stream io.Stream) {
-	if len(e.String) == 0 {
-		return
+//encoder returns field encoding function, custom marshaler takes precedence over field kind
+func (b *builder) encoder(f *Field) (func(e *Struct, ptr unsafe.Pointer, stream io.Stream), error) {
+	if encode := marshalerEncoder(f); encode != nil {
+		return encode, nil
 	}
-	for _, f := range e.String {
-		v := f.String(e.ptr)
-		stream.PutNonEmptyString(f.Name, v)
+	switch f.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32:
+		kind := f.Kind()
+		return func(e *Struct, ptr unsafe.Pointer, stream io.Stream) {
+			v := int(signed(kind, f.Pointer(ptr)))
+			switch {
+			case f.OmitEmpty && v == 0:
+			case f.AsString:
+				stream.PutString(f.Name, strconv.Itoa(v))
+			default:
+				stream.PutInt(f.Name, v)
+			}
+		}, nil
+	case reflect.Int64:
+		if f.Type == durationType {
+			return func(e *Struct, ptr unsafe.Pointer, stream io.Stream) {
+				v := time.Duration(f.Int64(ptr))
+				switch {
+				case f.OmitEmpty && v == 0:
+				case f.AsString:
+					stream.PutString(f.Name, v.String())
+				default:
+					stream.PutDuration(f.Name, v)
+				}
+			}, nil
+		}
+		return func(e *Struct, ptr unsafe.Pointer, stream io.Stream) {
+			v := f.Int64(ptr)
+			switch {
+			case f.OmitEmpty && v == 0:
+			case f.AsString:
+				stream.PutString(f.Name, strconv.FormatInt(v, 10))
+			default:
+				stream.PutInt64(f.Name, v)
+			}
+		}, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		kind := f.Kind()
+		return func(e *Struct, ptr unsafe.Pointer, stream io.Stream) {
+			v := unsigned(kind, f.Pointer(ptr))
+			switch {
+			case f.OmitEmpty && v == 0:
+			case f.AsString:
+				stream.PutString(f.Name, strconv.FormatUint(v, 10))
+			default:
+				stream.PutUint64(f.Name, v)
+			}
+		}, nil
+	case reflect.Float64:
+		return func(e *Struct, ptr unsafe.Pointer, stream io.Stream) {
+			v := f.Float64(ptr)
+			switch {
+			case f.OmitEmpty && v == 0:
+			case f.AsString:
+				stream.PutString(f.Name, strconv.FormatFloat(v, 'g', -1, 64))
+			default:
+				stream.PutFloat(f.Name, v)
+			}
+		}, nil
+	case reflect.Float32:
+		return func(e *Struct, ptr unsafe.Pointer, stream io.Stream) {
+			v := f.Float32(ptr)
+			switch {
+			case f.OmitEmpty && v == 0:
+			case f.AsString:
+				stream.PutString(f.Name, strconv.FormatFloat(float64(v), 'g', -1, 32))
+			default:
+				stream.PutFloat32(f.Name, v)
+			}
+		}, nil
+	case reflect.String:
+		return func(e *Struct, ptr unsafe.Pointer, stream io.Stream) {
+			v := f.String(ptr)
+			if f.OmitEmpty && v == "" {
+				return
+			}
+			stream.PutNonEmptyString(f.Name, v)
+		}, nil
+	case reflect.Bool:
+		return func(e *Struct, ptr unsafe.Pointer, stream io.Stream) {
+			v := f.Bool(ptr)
+			switch {
+			case f.OmitEmpty && !v:
+			case f.AsString:
+				stream.PutString(f.Name, strconv.FormatBool(v))
+			default:
+				stream.PutBool(f.Name, v)
+			}
+		}, nil
+	case reflect.Slice:
+		if encode := sliceEncoder(f); encode != nil {
+			return encode, nil
+		}
+		if elemType, pointer := structType(f.Elem()); elemType != nil {
+			nested, err := b.provider(elemType)
+			if err != nil {
+				return nil, err
+			}
+			f.Nested = nested
+			size := f.Elem().Size()
+			return func(e *Struct, ptr unsafe.Pointer, stream io.Stream) {
+				slice := (*sliceHeader)(f.Pointer(ptr))
+				if f.OmitEmpty && slice.len == 0 {
+					return
+				}
+				e.putObjects(f, slice, size, pointer, stream)
+			}, nil
+		}
+	case reflect.Map:
+		return b.mapEncoder(f)
+	default:
+		if nestedType, pointer := structType(f.Type); nestedType != nil {
+			nested, err := b.provider(nestedType)
+			if err != nil {
+				return nil, err
+			}
+			f.Nested = nested
+			if pointer {
+				return func(e *Struct, ptr unsafe.Pointer, stream io.Stream) {
+					if value := *(*unsafe.Pointer)(f.Pointer(ptr)); value != nil {
+						e.putObject(f, value, stream)
+					}
+				}, nil
+			}
+			return func(e *Struct, ptr unsafe.Pointer, stream io.Stream) {
+				e.putObject(f, f.Pointer(ptr), stream)
+			}, nil
+		}
+		if f.Type.AssignableTo(timeType) {
+			return func(e *Struct, ptr unsafe.Pointer, stream io.Stream) {
+				v := f.Time(ptr)
+				if f.OmitEmpty && v.IsZero() {
+					return
+				}
+				stream.PutTime(f.Name, v, f.Format)
+			}, nil
+		}
+		if f.Type.AssignableTo(timePtrType) {
+			return func(e *Struct, ptr unsafe.Pointer, stream io.Stream) {
+				v := f.TimePtr(ptr)
+				if v == nil || (f.OmitEmpty && v.IsZero()) {
+					return
+				}
+				stream.PutTime(f.Name, *v, f.Format)
+			}, nil
+		}
 	}
+	return nil, fmt.Errorf("not yet supported type: %v", f.Type.String())
 }
 
-func (e *Struct) encodeBool(stream io.Stream) {
-	if len(e.Bool) == 0 {
-		return
-	}
-	for _, f := range e.Bool {
-		v := f.Bool(e.ptr)
-		stream.PutBool(f.Name, v)
+//sliceEncoder returns primitive slice encoding function or nil, integer slices not sharing int or uint64 layout and float32 slices are converted with reusable slice
+func sliceEncoder(f *Field) func(e *Struct, ptr unsafe.Pointer, stream io.Stream) {
+	switch kind := f.Elem().Kind(); kind {
+	case reflect.Int:
+		return func(e *Struct, ptr unsafe.Pointer, stream io.Stream) {
+			v := *(*[]int)(f.Pointer(ptr))
+			if f.OmitEmpty && len(v) == 0 {
+				return
+			}
+			stream.PutInts(f.Name, v)
+		}
+	case reflect.Int64:
+		if strconv.IntSize == 64 { //int64 and int slices share memory layout
+			return func(e *Struct, ptr unsafe.Pointer, stream io.Stream) {
+				v := *(*[]int)(f.Pointer(ptr))
+				if f.OmitEmpty && len(v) == 0 {
+					return
+				}
+				stream.PutInts(f.Name, v)
+			}
+		}
+		return intsEncoder(f, kind)
+	case reflect.Int8, reflect.Int16, reflect.Int32:
+		return intsEncoder(f, kind)
+	case reflect.Uint64:
+		return func(e *Struct, ptr unsafe.Pointer, stream io.Stream) {
+			v := *(*[]uint64)(f.Pointer(ptr))
+			if f.OmitEmpty && len(v) == 0 {
+				return
+			}
+			stream.PutUInts(f.Name, v)
+		}
+	case reflect.Uint:
+		if strconv.IntSize == 64 { //uint and uint64 slices share memory layout
+			return func(e *Struct, ptr unsafe.Pointer, stream io.Stream) {
+				v := *(*[]uint64)(f.Pointer(ptr))
+				if f.OmitEmpty && len(v) == 0 {
+					return
+				}
+				stream.PutUInts(f.Name, v)
+			}
+		}
+		return uintsEncoder(f, kind)
+	case reflect.Uint8:
+		return func(e *Struct, ptr unsafe.Pointer, stream io.Stream) {
+			v := *(*[]byte)(f.Pointer(ptr))
+			if f.OmitEmpty && len(v) == 0 {
+				return
+			}
+			stream.PutB64EncodedBytes(f.Name, v)
+		}
+	case reflect.Uint16, reflect.Uint32:
+		return uintsEncoder(f, kind)
+	case reflect.Float64:
+		return func(e *Struct, ptr unsafe.Pointer, stream io.Stream) {
+			v := *(*[]float64)(f.Pointer(ptr))
+			if f.OmitEmpty && len(v) == 0 {
+				return
+			}
+			stream.PutFloats(f.Name, v)
+		}
+	case reflect.Float32:
+		return func(e *Struct, ptr unsafe.Pointer, stream io.Stream) {
+			v := *(*[]float32)(f.Pointer(ptr))
+			if f.OmitEmpty && len(v) == 0 {
+				return
+			}
+			state := e.state(f)
+			state.floats = state.floats[:0]
+			for _, item := range v {
+				state.floats = append(state.floats, float64(item))
+			}
+			stream.PutFloats(f.Name, state.floats)
+		}
+	case reflect.Bool:
+		return func(e *Struct, ptr unsafe.Pointer, stream io.Stream) {
+			v := *(*[]bool)(f.Pointer(ptr))
+			if f.OmitEmpty && len(v) == 0 {
+				return
+			}
+			stream.PutBools(f.Name, v)
+		}
+	case reflect.String:
+		return func(e *Struct, ptr unsafe.Pointer, stream io.Stream) {
+			v := *(*[]string)(f.Pointer(ptr))
+			if f.OmitEmpty && len(v) == 0 {
+				return
+			}
+			stream.PutStrings(f.Name, v)
+		}
 	}
+	return nil
 }
 
-func (e *Struct) encodeFloat64(stream io.Stream) {
-	if len(e.Float64) == 0 {
-		return
+//intsEncoder returns integer slice encoding function, items are converted with reusable slice
+func intsEncoder(f *Field, kind reflect.Kind) func(e *Struct, ptr unsafe.Pointer, stream io.Stream) {
+	size := f.Elem().Size()
+	return func(e *Struct, ptr unsafe.Pointer, stream io.Stream) {
+		slice := (*sliceHeader)(f.Pointer(ptr))
+		if f.OmitEmpty && slice.len == 0 {
+			return
+		}
+		state := e.state(f)
+		state.ints = state.ints[:0]
+		for i := 0; i < slice.len; i++ {
+			state.ints = append(state.ints, int(signed(kind, unsafe.Pointer(uintptr(slice.data)+uintptr(i)*size))))
+		}
+		stream.PutInts(f.Name, state.ints)
 	}
-	for _, f := range e.Float64 {
-		v := f.Float64(e.ptr)
-		stream.PutFloat(f.Name, v)
+}
+
+//uintsEncoder returns unsigned integer slice encoding function, items are converted with reusable slice
+func uintsEncoder(f *Field, kind reflect.Kind) func(e *Struct, ptr unsafe.Pointer, stream io.Stream) {
+	size := f.Elem().Size()
+	return func(e *Struct, ptr unsafe.Pointer, stream io.Stream) {
+		slice := (*sliceHeader)(f.Pointer(ptr))
+		if f.OmitEmpty && slice.len == 0 {
+			return
+		}
+		state := e.state(f)
+		state.uints = state.uints[:0]
+		for i := 0; i < slice.len; i++ {
+			state.uints = append(state.uints, unsigned(kind, unsafe.Pointer(uintptr(slice.data)+uintptr(i)*size)))
+		}
+		stream.PutUInts(f.Name, state.uints)
 	}
 }
 
-func (e *Struct) encodeFloat32(stream io.Stream) {
-	if len(e.Float32) == 0 {
-		return
+//signed returns signed integer of kind stored at pointer
+func signed(kind reflect.Kind, ptr unsafe.Pointer) int64 {
+	switch kind {
+	case reflect.Int8:
+		return int64(*(*int8)(ptr))
+	case reflect.Int16:
+		return int64(*(*int16)(ptr))
+	case reflect.Int32:
+		return int64(*(*int32)(ptr))
+	case reflect.Int:
+		return int64(*(*int)(ptr))
 	}
-	for _, f := range e.Float32 {
-		v := f.Float32(e.ptr)
-		stream.PutFloat(f.Name, float64(v))
+	return *(*int64)(ptr)
+}
+
+//unsigned returns unsigned integer of kind stored at pointer
+func unsigned(kind reflect.Kind, ptr unsafe.Pointer) uint64 {
+	switch kind {
+	case reflect.Uint8:
+		return uint64(*(*uint8)(ptr))
+	case reflect.Uint16:
+		return uint64(*(*uint16)(ptr))
+	case reflect.Uint32:
+		return uint64(*(*uint32)(ptr))
+	case reflect.Uint:
+		return uint64(*(*uint)(ptr))
 	}
+	return *(*uint64)(ptr)
 }
@@ -4,10 +4,20 @@ import (
 	"bytes"
 	"fmt"
 	"github.com/stretchr/testify/assert"
+	"github.com/viant/tapper/buffer"
+	"github.com/viant/tapper/io"
 	"github.com/viant/tapper/io/encoder"
 	"github.com/viant/tapper/msg"
+	"github.com/viant/tapper/msg/csv"
+	"github.com/viant/tapper/msg/json"
+	"github.com/viant/xunsafe"
+	"math"
+	"net"
+	"reflect"
 	"strings"
 	"testing"
+	"time"
+	"unsafe"
 )
 
 func TestStruct_Encode(t *testing.T) {
@@ -26,6 +36,29 @@ func TestStruct_Encode(t *testing.T) {
 		V    []int
 	}
 
+	type Tagged struct {
+		ID      int           `tapper:"id"`
+		Secret  string        `tapper:"-"`
+		Dash    string        `tapper:"-,"`
+		Name    string        `tapper:"name,omitempty"`
+		Count   int           `tapper:"count,omitempty"`
+		Code    int64         `tapper:"code,string"`
+		Elapsed time.Duration `tapper:"elapsed,string"`
+		Enabled bool          `tapper:",string"`
+		At      time.Time     `tapper:"at,format=2006-01-02"`
+		Updated time.Time     `tapper:"updated,format=RFC1123"`
+		Created time.Time     `tapper:"created,omitempty,format=RFC3339Nano"`
+		Tags    []string      `tapper:"tags,omitempty"`
+	}
+
+	type Baz struct {
+		Size    uint64
+		Elapsed time.Duration
+		Ratio   float32
+		At      time.Time
+		U       []uint64
+	}
+
 	var testCases = []struct {
 		description string
 		value       interface{}
@@ -39,11 +72,26 @@ func TestStruct_Encode(t *testing.T) {
 		{
 			description: "type with basic repeated",
 			value:       &Bar{ID: 2, Name: "Bob", F: 1.3, B: true, V: []int{1, 2}},
-			expect:      `{"ID":2,"F":1.3,"Name":"Bob","B":true,"V":[1,2]}`,
+			expect:      `{"ID":2,"Name":"Bob","F":1.3,"B":true,"V":[1,2]}`,
+		},
+		{
+			description: "typed primitives",
+			value:       &Baz{Size: math.MaxUint64, Elapsed: time.Second, Ratio: 0.1, At: time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC), U: []uint64{math.MaxUint64}},
+			expect:      `{"Size":18446744073709551615,"Elapsed":1000000000,"Ratio":0.1,"At":"2021-03-04T05:06:07Z","U":[18446744073709551615]}`,
+		},
+		{
+			description: "tag names and options",
+			value:       &Tagged{ID: 1, Secret: "s", Dash: "d", Code: 7, Elapsed: time.Second, Enabled: true, At: time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC), Updated: time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC)},
+			expect:      `{"id":1,"-":"d","code":"7","elapsed":"1s","Enabled":"true","at":"2021-03-04","updated":"Thu, 04 Mar 2021 05:06:07 UTC"}`,
+		},
+		{
+			description: "omitempty with values",
+			value:       &Tagged{Name: "n", Count: 2, Created: time.Date(2021, 3, 4, 5, 6, 7, 8, time.UTC), Tags: []string{"a"}},
+			expect:      `{"id":0,"name":"n","count":2,"code":"0","elapsed":"0s","Enabled":"false","at":"0001-01-01","updated":"Mon, 01 Jan 0001 00:00:00 UTC","created":"2021-03-04T05:06:07.000000008Z","tags":["a"]}`,
 		},
 	}
 
-	provider := msg.NewProvider(1024, 1)
+	provider := msg.NewProvider(1024, 1, json.New)
 	for _, testCase := range testCases {
 		msg := provider.NewMessage()
 		provider, _ := encoder.New(testCase.value)
@@ -59,3 +107,467 @@ func TestStruct_Encode(t *testing.T) {
 	}
 
 }
+
+type address struct {
+	City string `tapper:"city"`
+	Zip  string `tapper:"zip,omitempty"`
+}
+
+type audit struct {
+	CreatedBy string `tapper:"createdBy"`
+}
+
+type item struct {
+	SKU string `tapper:"sku"`
+	Qty int    `tapper:"qty"`
+}
+
+type order struct {
+	audit
+	*Meta
+	ID       int      `tapper:"id"`
+	Address  address  `tapper:"address"`
+	Billing  *address `tapper:"billing"`
+	Items    []item   `tapper:"items"`
+	Refs     []*item  `tapper:"refs,omitempty"`
+	Shipping address  //non embedded struct field keeps its name
+}
+
+//Meta represents embedded pointer struct
+type Meta struct {
+	Source string `tapper:"source"`
+	ID     string `tapper:"id"` //shadowed by order.ID
+}
+
+type node struct {
+	Name     string  `tapper:"name"`
+	Next     *node   `tapper:"next"`
+	Children []*node `tapper:"children,omitempty"`
+}
+
+func encodeJSON(t *testing.T, value interface{}) string {
+	provider, err := encoder.New(value)
+	if !assert.Nil(t, err) {
+		return ""
+	}
+	message := msg.NewProvider(1024, 1, json.New).NewMessage()
+	provider.New(value).Encode(message)
+	buf := new(bytes.Buffer)
+	_, err = message.WriteTo(buf)
+	assert.Nil(t, err)
+	message.Free()
+	return strings.TrimSpace(buf.String())
+}
+
+func TestStruct_Encode_Nested(t *testing.T) {
+	cyclic := &node{Name: "a"}
+	cyclic.Next = &node{Name: "b", Next: cyclic}
+	cyclic.Children = []*node{cyclic, {Name: "c"}}
+
+	var testCases = []struct {
+		description string
+		value       interface{}
+		expect      string
+	}{
+		{
+			description: "nested, embedded and slices",
+			value: &order{
+				audit:   audit{CreatedBy: "bob"},
+				Meta:    &Meta{Source: "web", ID: "x"},
+				ID:      1,
+				Address: address{City: "NY", Zip: "10001"},
+				Billing: &address{City: "LA"},
+				Items:   []item{{SKU: "a", Qty: 1}, {SKU: "b", Qty: 2}},
+				Refs:    []*item{nil, {SKU: "c"}},
+			},
+			expect: `{"createdBy":"bob","source":"web","id":1,"address":{"city":"NY","zip":"10001"},"billing":{"city":"LA"},"items":[{"sku":"a","qty":1},{"sku":"b","qty":2}],"refs":[{"sku":"c","qty":0}],"Shipping":{}}`,
+		},
+		{
+			description: "nil pointers and empty slices",
+			value:       &order{ID: 2},
+			expect:      `{"id":2,"address":{},"items":[],"Shipping":{}}`,
+		},
+		{
+			description: "cyclic value",
+			value:       cyclic,
+			expect:      `{"name":"a","next":{"name":"b"},"children":[{"name":"c"}]}`,
+		},
+	}
+	for _, testCase := range testCases {
+		assert.EqualValues(t, testCase.expect, encodeJSON(t, testCase.value), testCase.description)
+	}
+}
+
+func TestProvider_Nested(t *testing.T) {
+	provider, err := encoder.New(&order{})
+	if !assert.Nil(t, err) {
+		return
+	}
+	cached, err := encoder.New(reflect.TypeOf(order{}))
+	assert.Nil(t, err)
+	assert.True(t, provider == cached, "providers are cached per type")
+	assert.EqualValues(t, []string{"createdBy", "source", "id", "address.city", "address.zip", "billing.city", "billing.zip", "items", "refs", "Shipping.city", "Shipping.zip"}, provider.Columns())
+
+	recursive, err := encoder.New(&node{})
+	assert.Nil(t, err)
+	assert.EqualValues(t, []string{"name", "children"}, recursive.Columns())
+
+	type embedded struct {
+		*embedded
+	}
+	_, err = encoder.New(&embedded{})
+	assert.NotNil(t, err, "recursive embedded struct")
+}
+
+func TestStruct_Encode_Nested_Allocs(t *testing.T) {
+	value := &order{ID: 1, Billing: &address{City: "LA"}, Items: []item{{SKU: "a"}, {SKU: "b"}}, Refs: []*item{{SKU: "c"}}}
+	provider, err := encoder.New(value)
+	if !assert.Nil(t, err) {
+		return
+	}
+	message := msg.NewProvider(1024, 1, json.New).NewMessage()
+	structEncoder := provider.New(value)
+	structEncoder.Encode(message)
+	allocs := testing.AllocsPerRun(100, func() {
+		message.GetByteBuffer().Reset()
+		structEncoder.Encode(message)
+	})
+	assert.EqualValues(t, 0, allocs)
+}
+
+func TestStruct_Encode_CSV(t *testing.T) {
+	type Event struct {
+		Name   string `tapper:"name"`
+		ID     int    `tapper:"id"`
+		Active bool   `tapper:"active"`
+		Score  float64
+		Tags   []string          `tapper:"tags"`
+		Home   address           `tapper:"home"`
+		Labels map[string]string `tapper:"labels"`
+	}
+	provider, err := encoder.New(&Event{})
+	if !assert.Nil(t, err) {
+		return
+	}
+	message := msg.NewProvider(1024, 1, csv.New).NewMessage()
+	event := &Event{Name: "a", ID: 1, Active: true, Score: 1.5, Tags: []string{"x", "y"}, Home: address{City: "NY", Zip: "1"}}
+	provider.New(event).Encode(message)
+	assert.EqualValues(t, provider.Columns()[:7], message.(msg.Header).Columns())
+	assert.EqualValues(t, provider.ColumnsOf(event), message.(msg.Header).Columns())
+	buf := new(bytes.Buffer)
+	_, err = message.WriteTo(buf)
+	assert.Nil(t, err)
+	assert.EqualValues(t, "a,1,true,1.5,x:y,NY,1\n", buf.String())
+
+	message = msg.NewProvider(1024, 1, csv.New).NewMessage()
+	event.Labels = map[string]string{"b": "2", "a": "1"}
+	provider.New(event).Encode(message)
+	assert.EqualValues(t, []string{"name", "id", "active", "Score", "tags", "home.city", "home.zip", "labels.a", "labels.b"}, provider.ColumnsOf(event))
+	assert.EqualValues(t, provider.ColumnsOf(event), message.(msg.Header).Columns())
+}
+
+type kinds struct {
+	I8      int8                   `tapper:"i8"`
+	I16     int16                  `tapper:"i16"`
+	I32     int32                  `tapper:"i32"`
+	U8      uint8                  `tapper:"u8"`
+	U16     uint16                 `tapper:"u16"`
+	U32     uint32                 `tapper:"u32"`
+	U64     uint64                 `tapper:"u64"`
+	Floats  []float64              `tapper:"floats"`
+	F32s    []float32              `tapper:"f32s"`
+	Bools   []bool                 `tapper:"bools"`
+	Uints   []uint64               `tapper:"uints"`
+	I32s    []int32                `tapper:"i32s"`
+	I64s    []int64                `tapper:"i64s"`
+	Us      []uint                 `tapper:"us"`
+	Data    []byte                 `tapper:"data"`
+	Labels  map[string]string      `tapper:"labels"`
+	Counts  map[string]int         `tapper:"counts,omitempty"`
+	Attrs   map[string]interface{} `tapper:"attrs"`
+	Weights map[string]int32       `tapper:"weights"`
+	Refs    map[string]*item       `tapper:"refs"`
+}
+
+func TestStruct_Encode_Kinds(t *testing.T) {
+	value := &kinds{
+		I8: -8, I16: -16, I32: -32, U8: 8, U16: 16, U32: 32, U64: math.MaxUint64,
+		Floats:  []float64{1.5, 2},
+		F32s:    []float32{0.5},
+		Bools:   []bool{true, false},
+		Uints:   []uint64{1, math.MaxUint64},
+		I32s:    []int32{-1, 2},
+		I64s:    []int64{-3, 4},
+		Us:      []uint{5},
+		Data:    []byte("hi"),
+		Labels:  map[string]string{"z": "last", "a": "first"},
+		Counts:  map[string]int{},
+		Attrs:   map[string]interface{}{"b": true, "a": 1.5, "c": nil, "d": map[string]interface{}{"y": "x"}},
+		Weights: map[string]int32{"w": 3},
+		Refs:    map[string]*item{"r": {SKU: "s", Qty: 1}, "nil": nil},
+	}
+	var testCases = []struct {
+		description string
+		value       interface{}
+		newMessage  func(provider *msg.Provider, bytes *buffer.Bytes) msg.Message
+		expect      string
+	}{
+		{
+			description: "json",
+			value:       value,
+			newMessage:  json.New,
+			expect:      `{"i8":-8,"i16":-16,"i32":-32,"u8":8,"u16":16,"u32":32,"u64":18446744073709551615,"floats":[1.5,2],"f32s":[0.5],"bools":[true,false],"uints":[1,18446744073709551615],"i32s":[-1,2],"i64s":[-3,4],"us":[5],"data":"aGk=","labels":{"a":"first","z":"last"},"attrs":{"a":1.5,"b":true,"c":null,"d":{"y":"x"}},"weights":{"w":3},"refs":{"r":{"sku":"s","qty":1}}}`,
+		},
+		{
+			description: "json nil maps",
+			value:       &kinds{},
+			newMessage:  json.New,
+			expect:      `{"i8":0,"i16":0,"i32":0,"u8":0,"u16":0,"u32":0,"u64":0,"floats":[],"f32s":[],"bools":[],"uints":[],"i32s":[],"i64s":[],"us":[],"data":""}`,
+		},
+		{
+			description: "csv",
+			value:       value,
+			newMessage:  csv.New,
+			expect:      `-8,-16,-32,8,16,32,18446744073709551615,1.5:2,0.5,true:false,1:18446744073709551615,-1:2,-3:4,5,aGk=,first,last,1.5,true,,x,3,s,1`,
+		},
+	}
+	for _, testCase := range testCases {
+		provider, err := encoder.New(testCase.value)
+		if !assert.Nil(t, err, testCase.description) {
+			continue
+		}
+		message := msg.NewProvider(1024, 1, testCase.newMessage).NewMessage()
+		provider.New(testCase.value).Encode(message)
+		buf := new(bytes.Buffer)
+		_, err = message.WriteTo(buf)
+		assert.Nil(t, err, testCase.description)
+		assert.EqualValues(t, testCase.expect, strings.TrimSpace(buf.String()), testCase.description)
+		message.Free()
+	}
+}
+
+func TestStruct_Encode_Kinds_Allocs(t *testing.T) {
+	type fast struct {
+		I16    int16             `tapper:"i16"`
+		U64    uint64            `tapper:"u64"`
+		F32s   []float32         `tapper:"f32s"`
+		I32s   []int32           `tapper:"i32s"`
+		Labels map[string]string `tapper:"labels"`
+		Counts map[string]int    `tapper:"counts"`
+	}
+	value := &fast{I16: 1, U64: 2, F32s: []float32{1}, I32s: []int32{1, 2}, Labels: map[string]string{"b": "2", "a": "1"}, Counts: map[string]int{"c": 1}}
+	provider, err := encoder.New(value)
+	if !assert.Nil(t, err) {
+		return
+	}
+	message := msg.NewProvider(1024, 1, json.New).NewMessage()
+	structEncoder := provider.New(value)
+	structEncoder.Encode(message)
+	allocs := testing.AllocsPerRun(100, func() {
+		message.GetByteBuffer().Reset()
+		structEncoder.Encode(message)
+	})
+	assert.EqualValues(t, 0, allocs)
+}
+
+type money int64
+
+func (m money) MarshalStream(key string, stream io.Stream) {
+	stream.PutFloat(key, float64(m)/100)
+}
+
+type status int
+
+func (s status) String() string {
+	if s == 1 {
+		return "active"
+	}
+	return "unknown"
+}
+
+type point struct {
+	X, Y int
+}
+
+func (p *point) Encode(stream io.Stream) {
+	stream.PutInt("x", p.X)
+	stream.PutInt("y", p.Y)
+}
+
+type invalid struct{}
+
+type version struct {
+	Major int `tapper:"major"`
+	Minor int `tapper:"minor"`
+}
+
+func (v version) String() string {
+	return fmt.Sprintf("%v.%v", v.Major, v.Minor)
+}
+
+var errInvalid = fmt.Errorf("invalid")
+
+func (i invalid) MarshalText() ([]byte, error) {
+	return nil, errInvalid
+}
+
+type account struct {
+	Price    money             `tapper:"price"`
+	Status   status            `tapper:"status"`
+	Previous *status           `tapper:"previous"`
+	IP       net.IP            `tapper:"ip,omitempty"`
+	Location point             `tapper:"location"`
+	Origin   *point            `tapper:"origin"`
+	Invalid  invalid           `tapper:"invalid"`
+	Statuses map[string]status `tapper:"statuses,omitempty"`
+	Elapsed  time.Duration     `tapper:"elapsed"`
+	Version  version           `tapper:"version"`
+}
+
+func TestStruct_Encode_Marshaler(t *testing.T) {
+	previous := status(2)
+	var testCases = []struct {
+		description string
+		value       interface{}
+		expect      string
+	}{
+		{
+			description: "custom marshalers",
+			value: &account{
+				Price:    1250,
+				Status:   1,
+				Previous: &previous,
+				IP:       net.IPv4(10, 0, 0, 1),
+				Location: point{X: 1, Y: 2},
+				Origin:   &point{},
+				Statuses: map[string]status{"b": 1, "a": 0},
+				Elapsed:  time.Second,
+				Version:  version{Major: 1, Minor: 2},
+			},
+			expect: `{"price":12.5,"status":"active","previous":"unknown","ip":"10.0.0.1","location":{"x":1,"y":2},"origin":{"x":0,"y":0},"invalid":null,"statuses":{"a":"unknown","b":"active"},"elapsed":1000000000,"version":{"major":1,"minor":2}}`,
+		},
+		{
+			description: "nil pointers, empty and failed values",
+			value:       &account{},
+			expect:      `{"price":0,"status":"unknown","location":{"x":0,"y":0},"invalid":null,"elapsed":0,"version":{"major":0,"minor":0}}`,
+		},
+	}
+	provider, err := encoder.New(&account{})
+	if !assert.Nil(t, err) {
+		return
+	}
+	for _, testCase := range testCases {
+		assert.EqualValues(t, testCase.expect, encodeJSON(t, testCase.value), testCase.description)
+		message := msg.NewProvider(1024, 1, csv.New).NewMessage()
+		provider.New(testCase.value).Encode(message)
+		assert.EqualValues(t, provider.ColumnsOf(testCase.value), message.(msg.Header).Columns(), testCase.description)
+		message.Free()
+	}
+	var marshaling []encoder.Marshaling
+	for _, field := range provider.Fields {
+		marshaling = append(marshaling, field.Marshaling)
+	}
+	assert.EqualValues(t, []encoder.Marshaling{encoder.MarshalStream, encoder.MarshalString, encoder.MarshalString, encoder.MarshalText,
+		encoder.MarshalObject, encoder.MarshalObject, encoder.MarshalText, encoder.MarshalNone, encoder.MarshalNone, encoder.MarshalNone}, marshaling)
+}
+
+func TestStruct_Encode_Marshaler_Allocs(t *testing.T) {
+	type event struct { //net.IP text marshaler allocates
+		Price    money   `tapper:"price"`
+		Status   status  `tapper:"status"`
+		Previous *status `tapper:"previous"`
+		Location point   `tapper:"location"`
+		Origin   *point  `tapper:"origin"`
+		Invalid  invalid `tapper:"invalid"`
+	}
+	value := &event{Price: 1, Status: 1, Location: point{X: 1}, Origin: &point{}}
+	provider, err := encoder.New(value)
+	if !assert.Nil(t, err) {
+		return
+	}
+	message := msg.NewProvider(1024, 1, json.New).NewMessage()
+	structEncoder := provider.New(value)
+	structEncoder.Encode(message)
+	allocs := testing.AllocsPerRun(100, func() {
+		message.GetByteBuffer().Reset()
+		structEncoder.Encode(message)
+	})
+	assert.EqualValues(t, 0, allocs)
+}
+
+func TestProvider_Columns(t *testing.T) {
+	type Bar struct {
+		ID      int
+		Name    string `tapper:"name,omitempty"`
+		F       float64
+		Skipped string `tapper:"-"`
+		B       bool
+		V       []int `tapper:"values"`
+	}
+	provider, err := encoder.New(&Bar{})
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.EqualValues(t, []string{"ID", "name", "F", "B", "values"}, provider.Columns())
+	for _, fields := range [][]*xunsafe.Field{provider.Int, provider.String, provider.Float64, provider.Bool, provider.Ints} {
+		assert.EqualValues(t, 1, len(fields), "deprecated fields grouped by kind")
+	}
+}
+
+func TestProvider_Group_Embedded(t *testing.T) {
+	type Base struct {
+		Name string
+		Seq  int
+	}
+	type Bar struct {
+		ID int
+		*Base
+		Active bool
+	}
+	provider, err := encoder.New(&Bar{})
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.EqualValues(t, []string{"ID", "Name", "Seq", "Active"}, provider.Columns())
+	bar := &Bar{ID: 7, Base: &Base{Name: "base", Seq: 3}, Active: true}
+	if assert.EqualValues(t, 1, len(provider.Int), "promoted fields are not grouped") {
+		assert.EqualValues(t, 7, provider.Int[0].Int(unsafe.Pointer(bar)))
+	}
+	assert.EqualValues(t, 0, len(provider.String), "promoted fields are not grouped")
+	assert.EqualValues(t, 1, len(provider.Bool))
+}
+
+func TestNew_Error(t *testing.T) {
+	type Format struct {
+		ID int `tapper:"id,format=RFC3339"`
+	}
+	type Unsupported struct {
+		Values map[string]chan int
+	}
+	type Key struct {
+		Values map[int]string
+	}
+	for _, value := range []interface{}{&Format{}, &Unsupported{}, &Key{}} {
+		_, err := encoder.New(value)
+		assert.NotNil(t, err, fmt.Sprintf("%T", value))
+	}
+}
+
+func TestParseTag(t *testing.T) {
+	var useCases = []struct {
+		tag    string
+		expect *encoder.Tag
+	}{
+		{tag: "", expect: &encoder.Tag{}},
+		{tag: "-", expect: &encoder.Tag{Skip: true}},
+		{tag: "-,", expect: &encoder.Tag{Name: "-"}},
+		{tag: "id", expect: &encoder.Tag{Name: "id"}},
+		{tag: ",omitempty,string", expect: &encoder.Tag{OmitEmpty: true, AsString: true}},
+		{tag: "at,format=Mon, 02 Jan 2006", expect: &encoder.Tag{Name: "at", Format: "Mon, 02 Jan 2006", HasFormat: true}},
+		{tag: "at,omitempty,format=RFC3339Nano", expect: &encoder.Tag{Name: "at", OmitEmpty: true, Format: time.RFC3339Nano, HasFormat: true}},
+		{tag: "at,format=", expect: &encoder.Tag{Name: "at", HasFormat: true}},
+	}
+	for _, useCase := range useCases {
+		assert.EqualValues(t, useCase.expect, encoder.ParseTag(useCase.tag), useCase.tag)
+	}
+}
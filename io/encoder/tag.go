@@ -0,0 +1,80 @@
+package encoder
+
+import (
+	"strings"
+	"time"
+)
+
+//TagName struct field tag name
+const TagName = "tapper"
+
+//Tag represents tapper struct field tag, i.e. tapper:"name,omitempty,string,format=RFC3339Nano"
+type Tag struct {
+	Name      string //encoded key, struct field name by default
+	Skip      bool   //tapper:"-" skips the field
+	OmitEmpty bool   //omitempty skips zero value, i.e. 0, false, "", nil, empty slice or zero time
+	AsString  bool   //string encodes numbers, booleans and durations as strings
+	Format    string //format time layout or layout name (i.e. RFC3339Nano), empty layout uses message native time type
+	HasFormat bool   //format option was specified
+}
+
+//layouts maps time layout names to layouts
+var layouts = map[string]string{
+	"ANSIC":       time.ANSIC,
+	"UnixDate":    time.UnixDate,
+	"RubyDate":    time.RubyDate,
+	"RFC822":      time.RFC822,
+	"RFC822Z":     time.RFC822Z,
+	"RFC850":      time.RFC850,
+	"RFC1123":     time.RFC1123,
+	"RFC1123Z":    time.RFC1123Z,
+	"RFC3339":     time.RFC3339,
+	"RFC3339Nano": time.RFC3339Nano,
+	"Kitchen":     time.Kitchen,
+	"Stamp":       time.Stamp,
+	"StampMilli":  time.StampMilli,
+	"StampMicro":  time.StampMicro,
+	"StampNano":   time.StampNano,
+	"DateTime":    "2006-01-02 15:04:05",
+	"DateOnly":    "2006-01-02",
+	"TimeOnly":    "15:04:05",
+}
+
+//ParseTag parses tapper tag, format option has to be the last one since time layout can contain comma
+func ParseTag(tag string) *Tag {
+	result := &Tag{}
+	if tag == "-" {
+		result.Skip = true
+		return result
+	}
+	index := strings.Index(tag, ",")
+	if index == -1 {
+		result.Name = tag
+		return result
+	}
+	result.Name = tag[:index]
+	options := tag[index+1:]
+	for options != "" {
+		option := options
+		if strings.HasPrefix(option, "format=") {
+			result.HasFormat = true
+			result.Format = strings.TrimPrefix(option, "format=")
+			if layout, ok := layouts[result.Format]; ok {
+				result.Format = layout
+			}
+			break
+		}
+		if index = strings.Index(options, ","); index != -1 {
+			option, options = options[:index], options[index+1:]
+		} else {
+			options = ""
+		}
+		switch strings.TrimSpace(option) {
+		case "omitempty":
+			result.OmitEmpty = true
+		case "string":
+			result.AsString = true
+		}
+	}
+	return result
+}
@@ -0,0 +1,48 @@
+package encoder
+
+import (
+	"github.com/viant/tapper/io"
+	"sync"
+)
+
+//Visitor represents generated encoder of recursive struct
+type Visitor interface {
+	EncodeVisit(stream io.Stream, visit *Visit)
+}
+
+//Visit represents recursive struct value encoded by generated code, it links the value with its ancestors to skip cyclic values
+type Visit struct {
+	value  Visitor
+	parent *Visit
+}
+
+var visits = sync.Pool{New: func() interface{} { return &Visit{} }}
+
+//NewVisit returns pooled visit of encoded root value
+func NewVisit(value Visitor) *Visit {
+	var root *Visit
+	return root.Visit(value)
+}
+
+//Visit returns pooled visit of nested value, nil is returned if the value is already visited by the visit or its ancestors
+func (v *Visit) Visit(value Visitor) *Visit {
+	for ancestor := v; ancestor != nil; ancestor = ancestor.parent {
+		if ancestor.value == value {
+			return nil
+		}
+	}
+	result := visits.Get().(*Visit)
+	result.value, result.parent = value, v
+	return result
+}
+
+//Encode encodes visited value
+func (v *Visit) Encode(stream io.Stream) {
+	v.value.EncodeVisit(stream, v)
+}
+
+//Release returns visit to the pool
+func (v *Visit) Release() {
+	v.value, v.parent = nil, nil
+	visits.Put(v)
+}
@@ -0,0 +1,7 @@
+package io
+
+//StreamMarshaler defines value that puts itself into a stream, i.e. money as a float or enum as a string
+type StreamMarshaler interface {
+	//MarshalStream puts value with the key
+	MarshalStream(key string, stream Stream)
+}
@@ -1,5 +1,7 @@
 package io
 
+import "time"
+
 //Stream represents a message stream
 type Stream interface {
 	//Put puts  bytes
@@ -32,4 +34,18 @@ type Stream interface {
 	PutBool(key string, value bool)
 	//PutBools puts bool
 	PutBools(key string, value []bool)
+	//PutInt64 puts int64
+	PutInt64(key string, value int64)
+	//PutUint64 puts uint64
+	PutUint64(key string, value uint64)
+	//PutFloat32 puts float32
+	PutFloat32(key string, value float32)
+	//PutTime puts time formatted with layout, i.e. time.RFC3339
+	PutTime(key string, value time.Time, layout string)
+	//PutDuration puts duration as int64 nanoseconds
+	PutDuration(key string, value time.Duration)
+	//PutNull puts null value
+	PutNull(key string)
+	//PutRaw puts value already encoded in the message format
+	PutRaw(key string, value []byte)
 }
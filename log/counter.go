@@ -0,0 +1,24 @@
+package log
+
+import (
+	"io"
+	"sync/atomic"
+)
+
+//counter represents a writer counting bytes passed to the underlying writer
+type counter struct {
+	io.Writer
+	size int64
+}
+
+//Write writes data and tracks written size
+func (c *counter) Write(bs []byte) (int, error) {
+	n, err := c.Writer.Write(bs)
+	atomic.AddInt64(&c.size, int64(n))
+	return n, err
+}
+
+//Size returns number of bytes written
+func (c *counter) Size() int64 {
+	return atomic.LoadInt64(&c.size)
+}
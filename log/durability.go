@@ -0,0 +1,112 @@
+package log
+
+import (
+	"github.com/viant/tapper/config"
+	"sync/atomic"
+	"time"
+)
+
+// syncer represents a file that can commit its content to stable storage
+type syncer interface {
+	Sync() error
+}
+
+// SyncStats represents fsync metrics
+type SyncStats struct {
+	Count uint64        //number of syncs
+	Total time.Duration //total sync latency
+	Max   time.Duration //max sync latency
+}
+
+// Avg returns average sync latency
+func (s SyncStats) Avg() time.Duration {
+	if s.Count == 0 {
+		return 0
+	}
+	return s.Total / time.Duration(s.Count)
+}
+
+type syncStats struct {
+	count   uint64
+	totalNs int64
+	maxNs   int64
+}
+
+func (s *syncStats) record(elapsed time.Duration) {
+	atomic.AddUint64(&s.count, 1)
+	atomic.AddInt64(&s.totalNs, int64(elapsed))
+	for {
+		max := atomic.LoadInt64(&s.maxNs)
+		if int64(elapsed) <= max || atomic.CompareAndSwapInt64(&s.maxNs, max, int64(elapsed)) {
+			return
+		}
+	}
+}
+
+// SyncStats returns fsync latency metrics
+func (l *Logger) SyncStats() SyncStats {
+	return SyncStats{
+		Count: atomic.LoadUint64(&l.syncStats.count),
+		Total: time.Duration(atomic.LoadInt64(&l.syncStats.totalNs)),
+		Max:   time.Duration(atomic.LoadInt64(&l.syncStats.maxNs)),
+	}
+}
+
+// sync flushes buffered data and commits file content to stable storage
+func (w *writer) sync(stats *syncStats) error {
+	if err := w.flusher.Flush(); err != nil {
+		return err
+	}
+	w.unsynced = 0
+	w.synced = time.Now()
+	if file, ok := w.closer.(syncer); ok {
+		err := file.Sync()
+		stats.record(time.Since(w.synced))
+		return err
+	}
+	return nil
+}
+
+// syncIfNeeded applies stream durability policy after a record was written
+func (s *shard) syncIfNeeded(writer *writer, now time.Time) error {
+	durability := s.logger.config.DurabilityPolicy()
+	writer.unsynced++
+	switch durability.Policy {
+	case config.DurabilityAlways:
+		return writer.sync(&s.logger.syncStats)
+	case config.DurabilityEvery:
+		if writer.unsynced >= durability.Every {
+			return writer.sync(&s.logger.syncStats)
+		}
+	case config.DurabilityInterval:
+		if now.Sub(writer.synced) >= durability.Interval {
+			return writer.sync(&s.logger.syncStats)
+		}
+	}
+	return nil
+}
+
+// syncPending syncs records written since the last sync
+func (s *shard) syncPending() error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	writer := s.getWriter()
+	if s.closed || writer == nil || writer.unsynced == 0 {
+		return nil
+	}
+	return writer.sync(&s.logger.syncStats)
+}
+
+// syncPeriodically syncs idle shards with interval durability policy
+func (l *Logger) syncPeriodically(interval time.Duration) {
+	for atomic.LoadInt32(&l.closed) == 0 {
+		time.Sleep(interval)
+		shards := l.shards
+		if l.partitions != nil {
+			shards = l.partitions.shards()
+		}
+		for _, shard := range shards {
+			_ = shard.syncPending()
+		}
+	}
+}
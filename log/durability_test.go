@@ -0,0 +1,178 @@
+package log_test
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/viant/afs"
+	"github.com/viant/tapper/config"
+	"github.com/viant/tapper/log"
+	"github.com/viant/tapper/msg"
+	"github.com/viant/tapper/msg/json"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+const durabilityChildEnv = "TAPPER_DURABILITY_CHILD_URL"
+
+//TestDurabilityChild logs records one by one, acknowledging each on stdout, until killed by TestLogger_Log_Durability
+func TestDurabilityChild(t *testing.T) {
+	URL := os.Getenv(durabilityChildEnv)
+	if URL == "" {
+		t.Skip("durability child process helper")
+	}
+	logger, err := log.New(&config.Stream{URL: URL, Durability: config.DurabilityAlways}, "child", afs.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	provider := msg.NewProvider(128, 1, json.New)
+	next := bufio.NewReader(os.Stdin)
+	for i := 0; ; i++ {
+		message := provider.NewMessage()
+		message.PutInt("id", i)
+		message.PutString("data", strings.Repeat("x", 100))
+		if err = logger.Log(message); err != nil {
+			t.Fatal(err)
+		}
+		message.Free()
+		fmt.Printf("ack %v\n", i)
+		if _, err = next.ReadString('\n'); err != nil {
+			return
+		}
+	}
+}
+
+func TestLogger_Log_Durability(t *testing.T) {
+	URL := "/tmp/tapper-durability.json"
+	_ = os.Remove(URL)
+	cmd := exec.Command(os.Args[0], "-test.run=^TestDurabilityChild$", "-test.v")
+	cmd.Env = append(os.Environ(), durabilityChildEnv+"="+URL)
+	stdout, err := cmd.StdoutPipe()
+	if !assert.Nil(t, err) {
+		return
+	}
+	stdin, err := cmd.StdinPipe()
+	if !assert.Nil(t, err) {
+		return
+	}
+	if !assert.Nil(t, cmd.Start()) {
+		return
+	}
+	acknowledged := -1
+	scanner := bufio.NewScanner(stdout)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "ack ") {
+			continue
+		}
+		acknowledged, _ = strconv.Atoi(line[4:])
+		if acknowledged >= 500 {
+			break
+		}
+		_, _ = stdin.Write([]byte("next\n"))
+	}
+	assert.Nil(t, cmd.Process.Kill())
+	_ = cmd.Wait()
+	if !assert.True(t, acknowledged >= 500, "child process acknowledged records") {
+		return
+	}
+
+	data, err := ioutil.ReadFile(URL)
+	if !assert.Nil(t, err) {
+		return
+	}
+	logged := map[int]bool{}
+	for _, line := range strings.Split(string(data), "\n") {
+		if strings.HasPrefix(line, `{"id":`) {
+			id, _ := strconv.Atoi(line[6:strings.Index(line, ",")])
+			logged[id] = true
+		}
+	}
+	for i := 0; i <= acknowledged; i++ {
+		if !assert.True(t, logged[i], "acknowledged record %v was lost", i) {
+			break
+		}
+	}
+}
+
+func TestLogger_SyncStats(t *testing.T) {
+	var useCases = []struct {
+		description string
+		durability  string
+		records     int
+		wait        time.Duration
+		expectMin   uint64
+		expectMax   uint64
+	}{
+		{description: "none", durability: config.DurabilityNone, records: 10, expectMin: 0, expectMax: 0},
+		{description: "always", durability: config.DurabilityAlways, records: 10, expectMin: 10, expectMax: 10},
+		{description: "every", durability: "every:5", records: 12, expectMin: 2, expectMax: 2},
+		{description: "interval", durability: "interval:50", records: 10, wait: 200 * time.Millisecond, expectMin: 1, expectMax: 5},
+	}
+	for _, useCase := range useCases {
+		cfg := &config.Stream{URL: "/tmp/tapper-sync-stats.json", Durability: useCase.durability}
+		logger, err := log.New(cfg, "xx", afs.New())
+		if !assert.Nil(t, err, useCase.description) {
+			continue
+		}
+		provider := msg.NewProvider(128, 1, json.New)
+		for i := 0; i < useCase.records; i++ {
+			message := provider.NewMessage()
+			message.PutInt("id", i)
+			assert.Nil(t, logger.Log(message), useCase.description)
+			message.Free()
+		}
+		time.Sleep(useCase.wait)
+		stats := logger.SyncStats()
+		assert.True(t, stats.Count >= useCase.expectMin && stats.Count <= useCase.expectMax, fmt.Sprintf("%v: %v", useCase.description, stats.Count))
+		assert.True(t, stats.Max >= stats.Avg(), useCase.description)
+		assert.Nil(t, logger.Close(), useCase.description)
+	}
+	_, err := log.New(&config.Stream{URL: "/tmp/tapper-sync-stats.json", Durability: "every:x"}, "xx", afs.New())
+	assert.NotNil(t, err)
+}
+
+func TestStream_Init_Durability(t *testing.T) {
+	var useCases = []struct {
+		description string
+		config      *config.Stream
+		expect      *config.Durability
+		hasError    bool
+	}{
+		{
+			description: "deprecated flush mod",
+			config:      &config.Stream{URL: "/tmp/tapper-durability.json", FlushMod: 10},
+			expect:      &config.Durability{Policy: config.DurabilityEvery, Every: 10},
+		},
+		{
+			description: "flush mod with durability",
+			config:      &config.Stream{URL: "/tmp/tapper-durability.json", FlushMod: 10, Durability: config.DurabilityAlways},
+			hasError:    true,
+		},
+		{
+			description: "async with durability",
+			config:      &config.Stream{URL: "/tmp/tapper-durability.json", Durability: config.DurabilityAlways, Async: &config.Async{}},
+			hasError:    true,
+		},
+		{
+			description: "async without durability",
+			config:      &config.Stream{URL: "/tmp/tapper-durability.json", Durability: config.DurabilityNone, Async: &config.Async{}},
+			expect:      &config.Durability{Policy: config.DurabilityNone},
+		},
+	}
+	for _, useCase := range useCases {
+		err := useCase.config.Init()
+		if useCase.hasError {
+			assert.NotNil(t, err, useCase.description)
+			continue
+		}
+		if assert.Nil(t, err, useCase.description) {
+			assert.EqualValues(t, useCase.expect, useCase.config.DurabilityPolicy(), useCase.description)
+		}
+	}
+}
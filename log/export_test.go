@@ -0,0 +1,12 @@
+package log
+
+import (
+	"github.com/viant/afs"
+	"github.com/viant/tapper/config"
+	"time"
+)
+
+//NewWithClock creates a transaction logger using supplied clock, so tests can cross rotation periods
+func NewWithClock(config *config.Stream, ID string, fs afs.Service, clock func() time.Time) (*Logger, error) {
+	return newLogger(config, ID, fs, clock)
+}
@@ -0,0 +1,86 @@
+package log
+
+import (
+	"github.com/pkg/errors"
+	"github.com/viant/tapper/buffer"
+	"github.com/viant/tapper/config"
+	"github.com/viant/tapper/msg"
+	"sync"
+	"sync/atomic"
+)
+
+// header represents header record written at the beginning of every file
+type header struct {
+	config *config.Header
+	mux    sync.Mutex
+	record atomic.Value
+}
+
+type headerRecord struct {
+	columns []string
+	data    []byte
+}
+
+// check initialises header record with the first message and verifies message columns match the header
+func (h *header) check(message msg.Message) error {
+	source, ok := message.(msg.Header)
+	if !ok {
+		return errors.Errorf("header record is not supported by %T", message)
+	}
+	record := h.load()
+	if record == nil {
+		record = h.init(source)
+	}
+	columns := source.Columns()
+	if len(columns) != len(record.columns) {
+		return errors.Errorf("inconsistent columns: expected %v, but had %v", record.columns, columns)
+	}
+	for i := range columns {
+		if columns[i] != record.columns[i] {
+			return errors.Errorf("inconsistent columns: expected %v, but had %v", record.columns, columns)
+		}
+	}
+	return nil
+}
+
+func (h *header) init(source msg.Header) *headerRecord {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+	if record := h.load(); record != nil {
+		return record
+	}
+	columns := h.config.Columns
+	if len(columns) == 0 {
+		columns = append([]string{}, source.Columns()...)
+	}
+	bs := buffer.NewBytes(256)
+	source.AppendHeader(bs, columns)
+	record := &headerRecord{columns: columns, data: append([]byte{}, bs.Bytes()...)}
+	h.record.Store(record)
+	return record
+}
+
+func (h *header) load() *headerRecord {
+	record, _ := h.record.Load().(*headerRecord)
+	return record
+}
+
+// writeTo writes header record if the writer has no records yet
+func (h *header) writeTo(w *writer) error {
+	if w.headerWritten {
+		return nil
+	}
+	record := h.load()
+	if record == nil {
+		return nil
+	}
+	if _, err := w.Write(record.data); err != nil {
+		return err
+	}
+	w.headerWritten = true
+	return nil
+}
+
+func newHeader(cfg *config.Header) *header {
+	return &header{config: cfg}
+}
@@ -1,10 +1,15 @@
 package log
 
 import (
+	"context"
+	"github.com/pkg/errors"
 	"github.com/viant/afs"
 	"github.com/viant/tapper/config"
 	"github.com/viant/tapper/emitter"
 	"github.com/viant/tapper/msg"
+	"io"
+	"os"
+	"path"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -13,116 +18,228 @@ import (
 
 // Logger represents file system transaction logger
 type Logger struct {
-	ID      string
-	fs      afs.Service
-	count   int32
-	mux     *sync.Mutex
-	config  *config.Stream
-	index   uint64
-	writers []*writer
-	closed  int32
-	emitter *emitter.Service
+	ID         string
+	fs         afs.Service
+	config     *config.Stream
+	shards     []*shard
+	next       uint64
+	affinity   *sync.Pool
+	generation uint64
+	closed     int32
+	emitter    *emitter.Service
+	queue      *queue
+	partitions *partitions
+	syncStats  syncStats
+	tasks      *tasks
+	header     *header
+	clock      func() time.Time //time source of rotation and partition idle checks
 }
 
 func (l *Logger) monitorWriters() {
 	for atomic.LoadInt32(&l.closed) == 0 {
-		l.mux.Lock()
-		writer := l.getWriter()
-		if writer == nil {
-			continue
+		for _, shard := range l.shards {
+			shard.monitor(l.clock())
 		}
-		if writer.isExpired(time.Now().Add(-5 * time.Second)) {
-			if err := writer.Flush(); err == nil {
-				err = l.rotateIfNeeded(writer, time.Now())
-			}
+		if l.partitions != nil {
+			l.partitions.monitor(l.clock())
 		}
-		l.mux.Unlock()
 		time.Sleep(time.Second)
 	}
 }
 
-// Close closes logger
-func (l *Logger) Close() (err error) {
+// Close closes logger, queued asynchronous records are written before closing writers,
+// use Shutdown to also wait for background rotation work
+func (l *Logger) Close() error {
+	err := l.close(context.Background(), true)
+	l.emitter.Close()
+	return err
+}
+
+// close closes logger writers, when wait is false rotated files are finalised in background with supplied context
+func (l *Logger) close(ctx context.Context, wait bool) (err error) {
+	if l.queue != nil {
+		err = l.queue.close(ctx)
+	}
 	atomic.StoreInt32(&l.closed, 1)
-	for _, writer := range l.writers {
-		if writer == nil {
-			continue
+	for _, shard := range l.shards {
+		if e := shard.close(ctx, wait); e != nil {
+			err = e
 		}
-		writer.loggerClose = true
-		if e := writer.Close(); e != nil {
+	}
+	if l.partitions != nil {
+		if e := l.partitions.close(ctx, wait); e != nil {
 			err = e
 		}
 	}
-	l.emitter.Close()
 	return err
 }
 
-func (l *Logger) open(ts time.Time) (err error) {
-	var rotationURL string
-	if l.config.Rotation != nil {
-		rotationURL = l.config.Rotation.ExpandURL(ts, l.ID)
+// Log logs a message, in asynchronous mode message bytes are queued and written by a dedicated goroutine
+func (l *Logger) Log(message msg.Message) (err error) {
+	if l.partitions != nil {
+		return errors.New("partition was not specified, use LogTo with partitioned output")
+	}
+	if l.header != nil {
+		if err = l.header.check(message); err != nil {
+			return err
+		}
+	}
+	if l.queue != nil {
+		return l.queue.put(message)
+	}
+	return l.log(message)
+}
+
+// LogTo logs a message to the supplied partition, each partition writes and rotates its own file
+func (l *Logger) LogTo(partition Partition, message msg.Message) error {
+	if l.partitions == nil {
+		return errors.New("partitioned output was not configured")
+	}
+	if l.header != nil {
+		if err := l.header.check(message); err != nil {
+			return err
+		}
+	}
+	for {
+		shard, err := l.partitions.shard(l, partition)
+		if err != nil {
+			return err
+		}
+		if err = shard.log(message); err != errShardClosed {
+			return err
+		}
 	}
+}
 
-	index := atomic.AddUint64(&l.index, 1) % 2
-	l.writers[index], err = newWriter(l.config, l.fs, rotationURL, int(index), ts, l.emitter)
-	return err
+// Dropped returns number of records dropped by asynchronous logger full queue policy
+func (l *Logger) Dropped() uint64 {
+	if l.queue == nil {
+		return 0
+	}
+	return atomic.LoadUint64(&l.queue.dropped)
 }
 
-func (l *Logger) getWriter() *writer {
-	index := atomic.LoadUint64(&l.index) % 2
-	return l.writers[index]
+// Spilled returns number of records spilled to disk by asynchronous logger full queue policy
+func (l *Logger) Spilled() uint64 {
+	if l.queue == nil {
+		return 0
+	}
+	return atomic.LoadUint64(&l.queue.spilled)
 }
 
-// Log logs a message
-func (l *Logger) Log(message msg.Message) (err error) {
-	now := time.Now()
-	l.mux.Lock()
-	defer l.mux.Unlock()
-	writer := l.getWriter()
-	_, err = message.WriteTo(writer)
-	if err == nil {
-		count := writer.increment()
-		if l.config.FlushMod > 0 && count%l.config.FlushMod == 0 {
-			err = writer.Flush()
-		}
-		if err == nil {
-			err = l.rotateIfNeeded(writer, now)
+func (l *Logger) log(message io.WriterTo) (err error) {
+	return l.nextShard().log(message)
+}
+
+// nextShard returns a shard for the next message
+func (l *Logger) nextShard() *shard {
+	if len(l.shards) == 1 {
+		return l.shards[0]
+	}
+	if l.affinity != nil {
+		number := l.affinity.Get().(*int)
+		result := l.shards[*number]
+		l.affinity.Put(number)
+		return result
+	}
+	return l.shards[atomic.AddUint64(&l.next, 1)%uint64(len(l.shards))]
+}
+
+// Rotate forces rotation of the current writer, it synchronously compresses, transfers and emits rotated file
+// and returns its URL, empty URL is returned if there was nothing to rotate.
+// In sharded mode all shards are rotated and the first rotated URL is returned, use RotateShards to get all of them.
+func (l *Logger) Rotate(ctx context.Context) (string, error) {
+	URLs, err := l.RotateShards(ctx)
+	for _, URL := range URLs {
+		if URL != "" {
+			return URL, err
 		}
 	}
-	return err
+	return "", err
 }
 
-func (l *Logger) rotateIfNeeded(writer *writer, now time.Time) (err error) {
-	if writer.isMaxReached() || writer.isExpired(now) {
-		if err = writer.Close(); err == nil {
-			l.writers[writer.index] = nil
-			err = l.open(now)
+// RotateShards forces rotation of all shards or open partitions, it returns rotated URL per shard
+func (l *Logger) RotateShards(ctx context.Context) ([]string, error) {
+	if l.config.Rotation == nil {
+		return nil, errors.New("rotation was not configured")
+	}
+	if atomic.LoadInt32(&l.closed) == 1 {
+		return nil, errors.New("logger was closed")
+	}
+	shards := l.shards
+	if l.partitions != nil {
+		shards = l.partitions.shards()
+	}
+	var result = make([]string, len(shards))
+	for i, shard := range shards {
+		URL, err := shard.forceRotate(ctx)
+		if err != nil {
+			return result, err
 		}
+		result[i] = URL
 	}
-	return err
+	return result, nil
 }
 
 // New creates a transaction logger
 func New(config *config.Stream, ID string, fs afs.Service) (*Logger, error) {
-	config.Init()
+	return newLogger(config, ID, fs, time.Now)
+}
+
+// newLogger creates a transaction logger using supplied clock
+func newLogger(config *config.Stream, ID string, fs afs.Service, clock func() time.Time) (*Logger, error) {
+	if err := config.Init(); err != nil {
+		return nil, err
+	}
 	emitter, err := emitter.New(config)
 	if err != nil {
 		return nil, err
 	}
 	result := &Logger{
 		fs:      fs,
-		mux:     &sync.Mutex{},
 		config:  config,
 		ID:      strings.Replace(ID, ".", "_", len(ID)),
-		writers: make([]*writer, 2),
 		emitter: emitter,
+		tasks:   newTasks(),
+		clock:   clock,
 	}
-	err = result.open(time.Now())
-	if err != nil {
+	if config.Header != nil {
+		result.header = newHeader(config.Header)
+	}
+	if config.Partition != nil {
+		result.partitions = newPartitions(config.Partition)
+	} else {
+		result.shards = make([]*shard, config.Shards)
+	}
+	for i := range result.shards {
+		result.shards[i] = newShard(result, i)
+	}
+	if err = result.recover(context.Background()); err != nil {
 		return nil, err
 	}
-	if config.Rotation != nil {
+	now := clock()
+	for _, shard := range result.shards {
+		if err = shard.open(now); err != nil {
+			return nil, err
+		}
+	}
+	if config.HasAffinity() {
+		result.affinity = &sync.Pool{New: func() interface{} {
+			number := int(atomic.AddUint64(&result.next, 1) % uint64(len(result.shards)))
+			return &number
+		}}
+	}
+	if config.Async != nil {
+		if result.queue, err = newQueue(config.Async, path.Join(os.TempDir(), "tapper-"+result.ID+".spill")); err != nil {
+			return nil, err
+		}
+		go result.queue.run(result.log)
+	}
+	if config.Rotation != nil || config.Partition != nil {
 		go result.monitorWriters()
 	}
+	if durability := config.DurabilityPolicy(); durability.Interval > 0 {
+		go result.syncPeriodically(durability.Interval)
+	}
 	return result, err
 }
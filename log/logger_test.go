@@ -1,22 +1,32 @@
 package log_test
 
 import (
+	"bytes"
 	"compress/gzip"
+	"compress/zlib"
 	"context"
+	stdcsv "encoding/csv"
+	"github.com/hamba/avro/ocf"
 	"github.com/stretchr/testify/assert"
 	"github.com/viant/afs"
 	"github.com/viant/afs/file"
 	"github.com/viant/afs/url"
+	"github.com/viant/tapper/buffer"
 	"github.com/viant/tapper/config"
+	"github.com/viant/tapper/io/encoder"
 	"github.com/viant/tapper/log"
 	"github.com/viant/tapper/msg"
+	"github.com/viant/tapper/msg/avro"
 	"github.com/viant/tapper/msg/csv"
 	"github.com/viant/tapper/msg/json"
+	"github.com/viant/tapper/parquet"
 	"github.com/viant/toolbox"
+	"io"
 	"io/ioutil"
 	"math/rand"
 	"net/http"
 	"os"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -222,6 +232,736 @@ func TestLogger_Log(t *testing.T) {
 	}
 }
 
+func TestLogger_Log_RotationTriggers(t *testing.T) {
+
+	var useCases = []struct {
+		description string
+		codec       string
+		flushMod    int
+		rotation    *config.Rotation
+		batches     []int
+		pause       time.Duration
+		expect      []int
+	}{
+		{
+			description: "max entries fires first",
+			rotation:    &config.Rotation{EveryMs: 60000, MaxEntries: 10, MaxBytes: 1024 * 1024},
+			batches:     []int{100},
+			expect:      []int{10, 10, 10, 10, 10, 10, 10, 10, 10, 10},
+		},
+		{
+			description: "max bytes fires first",
+			rotation:    &config.Rotation{EveryMs: 60000, MaxEntries: 100, MaxBytes: 300},
+			batches:     []int{20},
+			expect:      []int{5, 5, 5, 5},
+		},
+		{
+			description: "time fires first",
+			rotation:    &config.Rotation{EveryMs: 50, MaxEntries: 100, MaxBytes: 1024 * 1024},
+			batches:     []int{10, 10},
+			pause:       100 * time.Millisecond,
+			expect:      []int{9, 11},
+		},
+		{
+			description: "max compressed bytes fires first",
+			codec:       "gzip",
+			flushMod:    10,
+			rotation:    &config.Rotation{EveryMs: 60000, MaxEntries: 100, MaxCompressedBytes: 20},
+			batches:     []int{30},
+			expect:      []int{10, 10, 10},
+		},
+		{
+			description: "max compressed bytes include buffered bytes",
+			rotation:    &config.Rotation{EveryMs: 60000, MaxEntries: 100, MaxCompressedBytes: 300},
+			batches:     []int{20},
+			expect:      []int{5, 5, 5, 5},
+		},
+		{
+			description: "max compressed bytes overshoot until codec flush",
+			codec:       "gzip",
+			rotation:    &config.Rotation{EveryMs: 60000, MaxEntries: 100, MaxCompressedBytes: 20},
+			batches:     []int{30},
+			expect:      []int{30},
+		},
+	}
+	fs := afs.New()
+	ctx := context.Background()
+	for i, useCase := range useCases {
+		baseURL := "/tmp/tapper-trigger/" + strconv.Itoa(i)
+		_ = fs.Delete(ctx, baseURL)
+		_ = fs.Create(ctx, baseURL, file.DefaultDirOsMode, true)
+		useCase.rotation.URL = baseURL + "/rotated-%v"
+		cfg := &config.Stream{
+			URL:      baseURL + "/log.json",
+			Codec:    useCase.codec,
+			FlushMod: useCase.flushMod,
+			Rotation: useCase.rotation,
+		}
+		clock := &testClock{now: time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC)}
+		logger, err := log.NewWithClock(cfg, "xx", fs, clock.Now)
+		if !assert.Nil(t, err, useCase.description) {
+			continue
+		}
+		provider := msg.NewProvider(128, 2, json.New)
+		for j, batch := range useCase.batches {
+			if j > 0 {
+				clock.Advance(useCase.pause)
+			}
+			for k := 0; k < batch; k++ {
+				message := provider.NewMessage()
+				message.PutString("k1", strings.Repeat("?", 50)) //60 bytes per line
+				assert.Nil(t, logger.Log(message), useCase.description)
+				message.Free()
+			}
+		}
+		assert.Nil(t, logger.Close(), useCase.description)
+		time.Sleep(200 * time.Millisecond)
+
+		objects, err := fs.List(ctx, baseURL)
+		if !assert.Nil(t, err, useCase.description) {
+			continue
+		}
+		var actual []int
+		for _, object := range objects {
+			if object.IsDir() || !strings.HasPrefix(object.Name(), "rotated-") {
+				continue
+			}
+			reader, err := fs.OpenURL(ctx, object.URL())
+			if !assert.Nil(t, err, useCase.description) {
+				continue
+			}
+			var source io.Reader = reader
+			if useCase.codec == "gzip" {
+				source, _ = gzip.NewReader(reader)
+			}
+			data, _ := ioutil.ReadAll(source)
+			_ = reader.Close()
+			actual = append(actual, strings.Count(string(data), "\n"))
+		}
+		sort.Ints(actual)
+		assert.EqualValues(t, useCase.expect, actual, useCase.description)
+	}
+}
+
+func TestLogger_Log_AlignedRotation(t *testing.T) {
+	fs := afs.New()
+	ctx := context.Background()
+	baseURL := "/tmp/tapper-aligned"
+	_ = fs.Delete(ctx, baseURL)
+	_ = fs.Create(ctx, baseURL, file.DefaultDirOsMode, true)
+	cfg := &config.Stream{
+		URL:      baseURL + "/log.json",
+		Rotation: &config.Rotation{AlignTo: config.AlignMinute, TimeZone: "UTC", URL: baseURL + "/rotated-[yyyyMMdd_HHmm]-%v"},
+	}
+	clock := &testClock{now: time.Date(2021, 3, 4, 5, 6, 50, 0, time.UTC)}
+	logger, err := log.NewWithClock(cfg, "xx", fs, clock.Now)
+	if !assert.Nil(t, err) {
+		return
+	}
+	provider := msg.NewProvider(128, 2, json.New)
+	for _, period := range []struct {
+		advance time.Duration
+		records int
+	}{
+		{records: 3},                            //05:06:50
+		{advance: 20 * time.Second, records: 2}, //05:07:10, first record after the boundary rotates the file
+		{advance: time.Minute, records: 1},      //05:08:10
+	} {
+		clock.Advance(period.advance)
+		minute := clock.Now().Format("04")
+		for k := 0; k < period.records; k++ {
+			message := provider.NewMessage()
+			message.PutString("minute", minute)
+			assert.Nil(t, logger.Log(message))
+			message.Free()
+		}
+	}
+	assert.Nil(t, logger.Close())
+	time.Sleep(200 * time.Millisecond)
+
+	objects, err := fs.List(ctx, baseURL)
+	if !assert.Nil(t, err) {
+		return
+	}
+	actual := map[string]string{}
+	for _, object := range objects {
+		if object.IsDir() || !strings.HasPrefix(object.Name(), "rotated-") {
+			continue
+		}
+		reader, err := fs.OpenURL(ctx, object.URL())
+		if !assert.Nil(t, err) {
+			continue
+		}
+		data, _ := ioutil.ReadAll(reader)
+		_ = reader.Close()
+		actual[object.Name()] = string(data)
+	}
+	assert.EqualValues(t, map[string]string{
+		"rotated-20210304_0506-xx-0": strings.Repeat(`{"minute":"06"}`+"\n", 3),
+		"rotated-20210304_0507-xx-0": strings.Repeat(`{"minute":"07"}`+"\n", 2),
+		"rotated-20210304_0508-xx-0": `{"minute":"08"}` + "\n",
+	}, actual, "each rotated file holds records of its period")
+}
+
+//testClock represents manually advanced logger clock
+type testClock struct {
+	mux sync.Mutex
+	now time.Time
+}
+
+//Now returns current test time
+func (c *testClock) Now() time.Time {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	return c.now
+}
+
+//Advance moves test time forward
+func (c *testClock) Advance(duration time.Duration) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	c.now = c.now.Add(duration)
+}
+
+func TestLogger_Rotate(t *testing.T) {
+	fs := afs.New()
+	ctx := context.Background()
+	baseURL := "/tmp/tapper-rotate"
+	_ = fs.Delete(ctx, baseURL)
+	_ = fs.Create(ctx, baseURL, file.DefaultDirOsMode, true)
+	cfg := &config.Stream{
+		URL: baseURL + "/log.json",
+		Rotation: &config.Rotation{
+			EveryMs: 60000,
+			URL:     baseURL + "/rotated-%v",
+			Codec:   "gzip",
+		},
+	}
+	logger, err := log.New(cfg, "xx", fs)
+	if !assert.Nil(t, err) {
+		return
+	}
+	defer logger.Close()
+	provider := msg.NewProvider(128, 2, json.New)
+	for i := 0; i < 10; i++ {
+		message := provider.NewMessage()
+		message.PutInt("id", i)
+		assert.Nil(t, logger.Log(message))
+		message.Free()
+	}
+	rotated, err := logger.Rotate(ctx)
+	assert.Nil(t, err)
+	assert.EqualValues(t, baseURL+"/rotated-xx-0.gz", rotated)
+	reader, err := fs.OpenURL(ctx, rotated)
+	if !assert.Nil(t, err) {
+		return
+	}
+	defer reader.Close()
+	gzReader, err := gzip.NewReader(reader)
+	if !assert.Nil(t, err) {
+		return
+	}
+	data, err := ioutil.ReadAll(gzReader)
+	assert.Nil(t, err)
+	assert.EqualValues(t, 10, strings.Count(string(data), "\n"))
+
+	rotated, err = logger.Rotate(ctx)
+	assert.Nil(t, err)
+	assert.EqualValues(t, "", rotated, "nothing to rotate")
+}
+
+func TestLogger_Log_Codec(t *testing.T) {
+	fs := afs.New()
+	ctx := context.Background()
+	baseURL := "/tmp/tapper-codec"
+	var useCases = []struct {
+		description string
+		config      *config.Stream
+		expectURL   string
+	}{
+		{
+			description: "streaming codec",
+			config:      &config.Stream{URL: baseURL + "/log.json", Codec: "zlib:9"},
+			expectURL:   baseURL + "/log.json.zz",
+		},
+		{
+			description: "rotation codec",
+			config: &config.Stream{
+				URL: baseURL + "/log.json",
+				Rotation: &config.Rotation{
+					EveryMs: 60000,
+					URL:     baseURL + "/rotated-%v",
+					Codec:   "zlib",
+				},
+			},
+			expectURL: baseURL + "/rotated-xx-0.zz",
+		},
+	}
+	for _, useCase := range useCases {
+		_ = fs.Delete(ctx, baseURL)
+		_ = fs.Create(ctx, baseURL, file.DefaultDirOsMode, true)
+		logger, err := log.New(useCase.config, "xx", fs)
+		if !assert.Nil(t, err, useCase.description) {
+			continue
+		}
+		provider := msg.NewProvider(128, 2, json.New)
+		for i := 0; i < 10; i++ {
+			message := provider.NewMessage()
+			message.PutInt("id", i)
+			assert.Nil(t, logger.Log(message), useCase.description)
+			message.Free()
+		}
+		assert.Nil(t, logger.Close(), useCase.description)
+		reader, err := fs.OpenURL(ctx, useCase.expectURL)
+		if !assert.Nil(t, err, useCase.description) {
+			continue
+		}
+		zReader, err := zlib.NewReader(reader)
+		if !assert.Nil(t, err, useCase.description) {
+			_ = reader.Close()
+			continue
+		}
+		data, err := ioutil.ReadAll(zReader)
+		assert.Nil(t, err, useCase.description)
+		assert.EqualValues(t, 10, strings.Count(string(data), "\n"), useCase.description)
+		_ = reader.Close()
+	}
+}
+
+func TestLogger_Log_Header(t *testing.T) {
+	fs := afs.New()
+	ctx := context.Background()
+	baseURL := "/tmp/tapper-header"
+	type Event struct {
+		ID   int
+		Name string
+	}
+	eventProvider, err := encoder.New(&Event{})
+	if !assert.Nil(t, err) {
+		return
+	}
+	var useCases = []struct {
+		description string
+		header      *config.Header
+		log         func(message msg.Message, i int)
+		expect      string
+	}{
+		{
+			description: "columns of the first message",
+			header:      &config.Header{},
+			log: func(message msg.Message, i int) {
+				message.PutInt("id", i)
+				message.PutString("name", "n,"+strconv.Itoa(i))
+			},
+			expect: "id,name\n",
+		},
+		{
+			description: "columns of encoder provider",
+			header:      &config.Header{Columns: eventProvider.Columns()},
+			log: func(message msg.Message, i int) {
+				eventProvider.New(&Event{ID: i, Name: "n"}).Encode(message)
+			},
+			expect: "ID,Name\n",
+		},
+	}
+	for _, useCase := range useCases {
+		_ = fs.Delete(ctx, baseURL)
+		_ = fs.Create(ctx, baseURL, file.DefaultDirOsMode, true)
+		cfg := &config.Stream{
+			URL:    baseURL + "/log.csv",
+			Header: useCase.header,
+			Rotation: &config.Rotation{
+				EveryMs:    60000,
+				MaxEntries: 2,
+				URL:        baseURL + "/rotated-%v.csv",
+			},
+		}
+		logger, err := log.New(cfg, "xx", fs)
+		if !assert.Nil(t, err, useCase.description) {
+			continue
+		}
+		provider := msg.NewProvider(128, 2, csv.New)
+		for i := 0; i < 5; i++ {
+			message := provider.NewMessage()
+			useCase.log(message, i)
+			assert.Nil(t, logger.Log(message), useCase.description)
+			message.Free()
+		}
+		message := provider.NewMessage()
+		message.PutInt("other", 1)
+		assert.NotNil(t, logger.Log(message), useCase.description+" inconsistent columns")
+		message.Free()
+		assert.Nil(t, logger.Shutdown(ctx), useCase.description)
+		for _, name := range []string{"rotated-xx-0.csv", "rotated-xx-1.csv", "rotated-xx-2.csv"} {
+			data, err := ioutil.ReadFile(baseURL + "/" + name)
+			if !assert.Nil(t, err, useCase.description) {
+				continue
+			}
+			lines := strings.Count(string(data), "\n")
+			assert.True(t, strings.HasPrefix(string(data), useCase.expect), useCase.description+" "+name)
+			assert.True(t, lines == 2 || lines == 3, useCase.description+" "+name)
+		}
+	}
+}
+
+func TestLogger_Log_Header_Explode(t *testing.T) {
+	fs := afs.New()
+	ctx := context.Background()
+	baseURL := "/tmp/tapper-header-explode"
+	type Item struct {
+		ID   int    `tapper:"id"`
+		Name string `tapper:"name"`
+	}
+	type Event struct {
+		ID    int    `tapper:"id"`
+		Items []Item `tapper:"items"`
+		Note  string `tapper:"note"`
+	}
+	eventProvider, err := encoder.New(&Event{})
+	if !assert.Nil(t, err) {
+		return
+	}
+	itemProvider, err := encoder.New(&Item{})
+	if !assert.Nil(t, err) {
+		return
+	}
+	_ = fs.Delete(ctx, baseURL)
+	_ = fs.Create(ctx, baseURL, file.DefaultDirOsMode, true)
+	cfg := &config.Stream{URL: baseURL + "/log.csv", Header: &config.Header{}}
+	logger, err := log.New(cfg, "xx", fs)
+	if !assert.Nil(t, err) {
+		return
+	}
+	provider := msg.NewProvider(128, 2, csv.NewWithOptions(csv.Options{Objects: csv.ObjectsExplode, ObjectColumns: map[string][]string{"items": itemProvider.Columns()}}))
+	for _, event := range []*Event{ //the first message has empty slice
+		{ID: 1, Note: "a"},
+		{ID: 2, Items: []Item{{ID: 10, Name: "x"}, {ID: 11}}, Note: "b"},
+	} {
+		message := provider.NewMessage()
+		eventProvider.New(event).Encode(message)
+		assert.Nil(t, logger.Log(message))
+		message.Free()
+	}
+	assert.Nil(t, logger.Close())
+	data, err := ioutil.ReadFile(baseURL + "/log.csv")
+	if !assert.Nil(t, err) {
+		return
+	}
+	records, err := stdcsv.NewReader(bytes.NewReader(data)).ReadAll()
+	assert.Nil(t, err, "records have the header width")
+	assert.EqualValues(t, [][]string{
+		{"id", "items.id", "items.name", "note"},
+		{"1", "", "", "a"},
+		{"2", "10:11", "x:", "b"},
+	}, records)
+}
+
+func TestLogger_Log_Avro(t *testing.T) {
+	fs := afs.New()
+	ctx := context.Background()
+	baseURL := "/tmp/tapper-avro"
+	type Event struct {
+		ID   int
+		Name string
+	}
+	eventProvider, err := encoder.New(&Event{})
+	if !assert.Nil(t, err) {
+		return
+	}
+	schema, err := avro.NewSchema(eventProvider)
+	if !assert.Nil(t, err) {
+		return
+	}
+	for _, codec := range []string{config.AvroCodecDeflate, config.AvroCodecNull} {
+		_ = fs.Delete(ctx, baseURL)
+		_ = fs.Create(ctx, baseURL, file.DefaultDirOsMode, true)
+		cfg := &config.Stream{
+			URL:  baseURL + "/log.avro",
+			Avro: &config.Avro{Schema: schema.String(), Codec: codec, BlockRecords: 2},
+			Rotation: &config.Rotation{
+				EveryMs:    60000,
+				MaxEntries: 5,
+				URL:        baseURL + "/rotated-%v.avro",
+			},
+		}
+		logger, err := log.New(cfg, "xx", fs)
+		if !assert.Nil(t, err, codec) {
+			continue
+		}
+		provider := msg.NewProvider(128, 2, avro.New(schema))
+		for i := 0; i < 12; i++ {
+			message := provider.NewMessage()
+			eventProvider.New(&Event{ID: i, Name: "n" + strconv.Itoa(i)}).Encode(message)
+			assert.Nil(t, logger.Log(message), codec)
+			message.Free()
+		}
+		message := provider.NewMessage()
+		message.PutString("other", "x")
+		assert.NotNil(t, logger.Log(message), codec+" invalid message")
+		message.Free()
+		assert.Nil(t, logger.Shutdown(ctx), codec)
+
+		ID := 0
+		for i, expect := range []int{5, 5, 2} {
+			reader, err := os.Open(baseURL + "/rotated-xx-" + strconv.Itoa(i) + ".avro")
+			if !assert.Nil(t, err, codec) {
+				continue
+			}
+			decoder, err := ocf.NewDecoder(reader)
+			if !assert.Nil(t, err, codec) {
+				_ = reader.Close()
+				continue
+			}
+			assert.EqualValues(t, codec, string(decoder.Metadata()["avro.codec"]), codec)
+			count := 0
+			for decoder.HasNext() {
+				var event struct {
+					ID   int64  `avro:"ID"`
+					Name string `avro:"Name"`
+				}
+				assert.Nil(t, decoder.Decode(&event), codec)
+				assert.EqualValues(t, ID, event.ID, codec)
+				assert.EqualValues(t, "n"+strconv.Itoa(ID), event.Name, codec)
+				ID++
+				count++
+			}
+			assert.Nil(t, decoder.Error(), codec)
+			assert.EqualValues(t, expect, count, codec)
+			_ = reader.Close()
+		}
+	}
+}
+
+func TestLogger_Log_Parquet(t *testing.T) {
+	fs := afs.New()
+	ctx := context.Background()
+	baseURL := "/tmp/tapper-parquet"
+	type Event struct {
+		ID   int
+		Name string
+		Tags []string
+	}
+	eventProvider, err := encoder.New(&Event{})
+	if !assert.Nil(t, err) {
+		return
+	}
+	var useCases = []struct {
+		description string
+		ext         string
+		header      *config.Header
+		parquet     *config.Parquet
+		newMessage  func(provider *msg.Provider, bytes *buffer.Bytes) msg.Message
+	}{
+		{
+			description: "inferred json",
+			ext:         ".json",
+			parquet:     &config.Parquet{RowGroupSize: 2},
+			newMessage:  json.New,
+		},
+		{
+			description: "csv with provider schema",
+			ext:         ".csv",
+			header:      &config.Header{},
+			parquet:     &config.Parquet{Columns: parquet.NewSchema(eventProvider), Format: parquet.FormatCSV, Compression: parquet.CompressionGzip},
+			newMessage:  csv.New,
+		},
+	}
+	for _, useCase := range useCases {
+		_ = fs.Delete(ctx, baseURL)
+		_ = fs.Create(ctx, baseURL, file.DefaultDirOsMode, true)
+		cfg := &config.Stream{
+			URL:    baseURL + "/log" + useCase.ext,
+			Header: useCase.header,
+			Rotation: &config.Rotation{
+				EveryMs:    60000,
+				MaxEntries: 5,
+				URL:        baseURL + "/rotated-%v" + useCase.ext,
+				Parquet:    useCase.parquet,
+			},
+		}
+		logger, err := log.New(cfg, "xx", fs)
+		if !assert.Nil(t, err, useCase.description) {
+			continue
+		}
+		provider := msg.NewProvider(128, 2, useCase.newMessage)
+		for i := 0; i < 12; i++ {
+			message := provider.NewMessage()
+			eventProvider.New(&Event{ID: i, Name: "n" + strconv.Itoa(i), Tags: []string{"a", "b"}}).Encode(message)
+			assert.Nil(t, logger.Log(message), useCase.description)
+			message.Free()
+		}
+		assert.Nil(t, logger.Shutdown(ctx), useCase.description)
+		for i := 0; i < 3; i++ {
+			location := baseURL + "/rotated-xx-" + strconv.Itoa(i) + useCase.ext
+			_, err := os.Stat(location)
+			assert.True(t, os.IsNotExist(err), useCase.description)
+			data, err := ioutil.ReadFile(location + config.ParquetSuffix)
+			if !assert.Nil(t, err, useCase.description) {
+				continue
+			}
+			assert.True(t, bytes.HasPrefix(data, []byte("PAR1")), useCase.description)
+			assert.True(t, bytes.HasSuffix(data, []byte("PAR1")), useCase.description)
+			for _, column := range eventProvider.Columns() {
+				assert.True(t, bytes.Contains(data, []byte(column)), useCase.description)
+			}
+		}
+	}
+}
+
+func TestLogger_Log_Async(t *testing.T) {
+	fs := afs.New()
+	ctx := context.Background()
+	cfg := &config.Stream{
+		URL:   "/tmp/tapper-async.json",
+		Async: &config.Async{QueueSize: 16},
+	}
+	_ = fs.Delete(ctx, cfg.URL)
+	logger, err := log.New(cfg, "xx", fs)
+	if !assert.Nil(t, err) {
+		return
+	}
+	provider := msg.NewProvider(128, 4, json.New)
+	waitGroup := sync.WaitGroup{}
+	for i := 0; i < 4; i++ {
+		waitGroup.Add(1)
+		go func() {
+			defer waitGroup.Done()
+			for j := 0; j < 250; j++ {
+				message := provider.NewMessage()
+				message.PutInt("id", j)
+				assert.Nil(t, logger.Log(message))
+				message.Free()
+			}
+		}()
+	}
+	waitGroup.Wait()
+	assert.Nil(t, logger.Close())
+	assert.EqualValues(t, 0, logger.Dropped())
+	data, err := ioutil.ReadFile(cfg.URL)
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.EqualValues(t, 1000, strings.Count(string(data), "\n"))
+}
+
+func TestLogger_Log_Sharded(t *testing.T) {
+	fs := afs.New()
+	ctx := context.Background()
+	baseURL := "/tmp/tapper-shard"
+	_ = fs.Delete(ctx, baseURL)
+	_ = fs.Create(ctx, baseURL, file.DefaultDirOsMode, true)
+	cfg := &config.Stream{
+		URL:    baseURL + "/log.json",
+		Shards: 4,
+		Rotation: &config.Rotation{
+			EveryMs:    60000,
+			MaxEntries: 10,
+			URL:        baseURL + "/rotated-%v.json",
+		},
+	}
+	logger, err := log.New(cfg, "xx", fs)
+	if !assert.Nil(t, err) {
+		return
+	}
+	provider := msg.NewProvider(128, 2, json.New)
+	for i := 0; i < 40; i++ {
+		message := provider.NewMessage()
+		message.PutInt("id", i)
+		assert.Nil(t, logger.Log(message))
+		message.Free()
+	}
+	assert.Nil(t, logger.Close())
+	time.Sleep(200 * time.Millisecond)
+
+	objects, err := fs.List(ctx, baseURL)
+	if !assert.Nil(t, err) {
+		return
+	}
+	var actual []int
+	for _, object := range objects {
+		if object.IsDir() {
+			continue
+		}
+		assert.True(t, strings.HasPrefix(object.Name(), "rotated-xx-"), object.Name())
+		data, err := ioutil.ReadFile(url.Path(object.URL()))
+		assert.Nil(t, err)
+		actual = append(actual, strings.Count(string(data), "\n"))
+	}
+	sort.Ints(actual)
+	//the first shard reaching MaxEntries forces all other shards to rotate on their next write
+	assert.EqualValues(t, []int{1, 1, 1, 9, 9, 9, 10}, actual)
+	for _, shard := range []string{"0", "1", "2", "3"} {
+		ok, _ := fs.Exists(ctx, baseURL+"/rotated-xx-"+shard+"-0.json")
+		assert.True(t, ok, shard)
+	}
+}
+
+func TestLogger_LogTo(t *testing.T) {
+	fs := afs.New()
+	ctx := context.Background()
+	baseURL := "/tmp/tapper-partition"
+	_ = fs.Delete(ctx, baseURL)
+	cfg := &config.Stream{
+		URL: baseURL + "/active/country={country}/event_type={event}/log.json",
+		Partition: &config.Partition{
+			Keys:    []string{"country", "event"},
+			MaxOpen: 2,
+		},
+		Rotation: &config.Rotation{
+			EveryMs:    60000,
+			MaxEntries: 4,
+			URL:        baseURL + "/data/country={country}/event_type={event}/log-%v.json",
+		},
+	}
+	logger, err := log.New(cfg, "xx", fs)
+	if !assert.Nil(t, err) {
+		return
+	}
+	partitions := []log.Partition{{"US", "click"}, {"US", "view"}, {"PL", "click"}}
+	provider := msg.NewProvider(128, 2, json.New)
+	for i := 0; i < 30; i++ {
+		partition := partitions[i%len(partitions)]
+		message := provider.NewMessage()
+		message.PutInt("id", i)
+		message.PutString("country", partition[0])
+		message.PutString("event", partition[1])
+		assert.Nil(t, logger.LogTo(partition, message))
+		message.Free()
+	}
+	message := provider.NewMessage()
+	assert.NotNil(t, logger.Log(message), "partition is required")
+	assert.NotNil(t, logger.LogTo(log.Partition{"US"}, message), "invalid partition")
+	for _, partition := range []log.Partition{{"US/click", ""}, {"..", "click"}, {"US", "a\\b"}} {
+		assert.NotNil(t, logger.LogTo(partition, message), "invalid partition value")
+	}
+	message.Free()
+	assert.Nil(t, logger.Close())
+	time.Sleep(200 * time.Millisecond)
+
+	for _, partition := range partitions {
+		parent := baseURL + "/data/country=" + partition[0] + "/event_type=" + partition[1]
+		objects, err := fs.List(ctx, parent)
+		if !assert.Nil(t, err, parent) {
+			continue
+		}
+		total := 0
+		for _, object := range objects {
+			if object.IsDir() {
+				continue
+			}
+			data, err := ioutil.ReadFile(url.Path(object.URL()))
+			assert.Nil(t, err)
+			lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+			assert.True(t, len(lines) <= 4, object.Name())
+			for _, line := range lines {
+				assert.Contains(t, line, `"country":"`+partition[0]+`","event":"`+partition[1]+`"`)
+			}
+			total += len(lines)
+		}
+		assert.EqualValues(t, 10, total, parent)
+	}
+}
+
 //Server represents consumer server
 type testServer struct {
 	*http.Server
@@ -315,6 +1055,52 @@ func BenchmarkLogger_Log_Rotation(b *testing.B) {
 	testRotationConcurrently(b, cfg)
 }
 
+//BenchmarkLogger_Log_Async    	 2332435	       638.7 ns/op	       1 B/op	       0 allocs/op
+func BenchmarkLogger_Log_Async(b *testing.B) {
+	toolbox.RemoveFileIfExist("/tmp/tapper_bench_async.log")
+	cfg := &config.Stream{
+		URL:   "/tmp/tapper_bench_async.log",
+		Async: &config.Async{QueueSize: 4096, Policy: config.PolicyDropOldest},
+	}
+	testRotationConcurrently(b, cfg)
+}
+
+//go test -bench Log_Shards -cpu 1,4,16 results on a single core machine, where shards can not write in parallel
+//BenchmarkLogger_Log_Shards/unsharded            	  200000	      2684 ns/op	       0 B/op	       0 allocs/op
+//BenchmarkLogger_Log_Shards/unsharded-4          	  200000	      2404 ns/op	       0 B/op	       0 allocs/op
+//BenchmarkLogger_Log_Shards/unsharded-16         	  200000	      2735 ns/op	       0 B/op	       0 allocs/op
+//BenchmarkLogger_Log_Shards/roundRobin/shards-4-4          	  200000	      2921 ns/op	       0 B/op	       0 allocs/op
+//BenchmarkLogger_Log_Shards/roundRobin/shards-16-16        	  200000	      2782 ns/op	       0 B/op	       0 allocs/op
+//BenchmarkLogger_Log_Shards/affinity/shards-4-4            	  200000	      2454 ns/op	       0 B/op	       0 allocs/op
+//BenchmarkLogger_Log_Shards/affinity/shards-16-16          	  200000	      2692 ns/op	       0 B/op	       0 allocs/op
+func BenchmarkLogger_Log_Shards(b *testing.B) {
+	var testCases = []struct {
+		name    string
+		shards  int
+		shardBy string
+	}{
+		{name: "unsharded"},
+		{name: "roundRobin/shards-4", shards: 4, shardBy: config.ShardByRoundRobin},
+		{name: "roundRobin/shards-16", shards: 16, shardBy: config.ShardByRoundRobin},
+		{name: "affinity/shards-4", shards: 4, shardBy: config.ShardByAffinity},
+		{name: "affinity/shards-16", shards: 16, shardBy: config.ShardByAffinity},
+	}
+	for _, testCase := range testCases {
+		testCase := testCase
+		b.Run(testCase.name, func(b *testing.B) {
+			baseURL := "/tmp/tapper_bench_shards/" + strings.Replace(testCase.name, "/", "-", 1)
+			_ = os.RemoveAll(baseURL)
+			_ = os.MkdirAll(baseURL, file.DefaultDirOsMode)
+			cfg := &config.Stream{
+				URL:     baseURL + "/log.json",
+				Shards:  testCase.shards,
+				ShardBy: testCase.shardBy,
+			}
+			testRotationConcurrently(b, cfg)
+		})
+	}
+}
+
 func testRotationConcurrently(b *testing.B, cfg *config.Stream) {
 	messages := msg.NewProvider(2048, 1024,json.New)
 	logger, err := log.New(cfg, "xx", afs.New())
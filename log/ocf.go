@@ -0,0 +1,129 @@
+package log
+
+import (
+	"bytes"
+	"compress/flate"
+	"crypto/rand"
+	"encoding/binary"
+	"github.com/pkg/errors"
+	"github.com/viant/tapper/config"
+	iow "github.com/viant/tapper/io"
+	"io"
+)
+
+var ocfMagic = []byte{'O', 'b', 'j', 1}
+
+// ocf represents Avro Object Container File writer, records are buffered into blocks,
+// every block is compressed with the configured codec and followed by the file sync marker
+type ocf struct {
+	writer     io.Writer
+	flusher    iow.Flusher
+	config     *config.Avro
+	sync       [16]byte
+	block      bytes.Buffer
+	records    int
+	deflate    *flate.Writer
+	compressed bytes.Buffer
+	long       [binary.MaxVarintLen64]byte
+}
+
+// writeHeader writes file header with schema, codec and sync marker
+func (o *ocf) writeHeader() error {
+	header := &bytes.Buffer{}
+	header.Write(ocfMagic)
+	o.appendLong(header, 2)
+	o.appendString(header, "avro.schema")
+	o.appendString(header, o.config.Schema)
+	o.appendString(header, "avro.codec")
+	o.appendString(header, o.config.Codec)
+	o.appendLong(header, 0)
+	header.Write(o.sync[:])
+	_, err := o.writer.Write(header.Bytes())
+	return err
+}
+
+func (o *ocf) appendLong(buffer *bytes.Buffer, value int64) {
+	n := binary.PutVarint(o.long[:], value) //zigzag encoded as avro long
+	buffer.Write(o.long[:n])
+}
+
+func (o *ocf) appendString(buffer *bytes.Buffer, value string) {
+	o.appendLong(buffer, int64(len(value)))
+	buffer.WriteString(value)
+}
+
+// Write appends record data to the current block
+func (o *ocf) Write(data []byte) (int, error) {
+	return o.block.Write(data)
+}
+
+// endRecord completes record, it writes the block once it reaches max records or size
+func (o *ocf) endRecord() error {
+	o.records++
+	if o.records >= o.config.BlockRecords || o.block.Len() >= o.config.BlockSize {
+		return o.writeBlock()
+	}
+	return nil
+}
+
+func (o *ocf) writeBlock() error {
+	if o.records == 0 {
+		return nil
+	}
+	data := o.block.Bytes()
+	if o.config.Codec == config.AvroCodecDeflate {
+		o.compressed.Reset()
+		if o.deflate == nil {
+			var err error
+			if o.deflate, err = flate.NewWriter(&o.compressed, flate.DefaultCompression); err != nil {
+				return err
+			}
+		} else {
+			o.deflate.Reset(&o.compressed)
+		}
+		if _, err := o.deflate.Write(data); err != nil {
+			return err
+		}
+		if err := o.deflate.Close(); err != nil {
+			return err
+		}
+		data = o.compressed.Bytes()
+	}
+	n := binary.PutVarint(o.long[:], int64(o.records))
+	if _, err := o.writer.Write(o.long[:n]); err != nil {
+		return err
+	}
+	n = binary.PutVarint(o.long[:], int64(len(data)))
+	if _, err := o.writer.Write(o.long[:n]); err != nil {
+		return err
+	}
+	if _, err := o.writer.Write(data); err != nil {
+		return err
+	}
+	if _, err := o.writer.Write(o.sync[:]); err != nil {
+		return err
+	}
+	o.block.Reset()
+	o.records = 0
+	return nil
+}
+
+// Flush writes pending block and flushes underlying writer
+func (o *ocf) Flush() error {
+	if err := o.writeBlock(); err != nil {
+		return err
+	}
+	return o.flusher.Flush()
+}
+
+// newOCF creates Avro Object Container File writer and writes the file header
+func newOCF(writer io.Writer, flusher iow.Flusher, cfg *config.Avro) (*ocf, error) {
+	result := &ocf{writer: writer, flusher: flusher, config: cfg}
+	if _, err := rand.Read(result.sync[:]); err != nil {
+		return nil, errors.Wrap(err, "failed to generate avro sync marker")
+	}
+	if err := result.writeHeader(); err != nil {
+		return nil, errors.Wrap(err, "failed to write avro header")
+	}
+	return result, nil
+}
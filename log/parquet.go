@@ -0,0 +1,51 @@
+package log
+
+import (
+	"bufio"
+	"context"
+	"github.com/pkg/errors"
+	"github.com/viant/afs/file"
+	"github.com/viant/tapper/parquet"
+)
+
+// convert replaces rotated NDJSON or CSV file with a parquet file, schema is inferred from the rotated records
+// unless parquet columns were configured
+func (w *writer) convert(ctx context.Context) error {
+	if w.rotationURL == "" {
+		return nil
+	}
+	conversion := w.config.Rotation.Parquet
+	columns := conversion.Columns
+	if len(columns) == 0 {
+		source, reader, err := w.sourceReader(ctx)
+		if err != nil {
+			return err
+		}
+		columns, err = parquet.Inspect(reader, conversion.Options())
+		_ = reader.Close()
+		if err != nil {
+			return errors.Wrapf(err, "failed to inspect: %v", source)
+		}
+	}
+	source, reader, err := w.sourceReader(ctx)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	w.rotationURL += w.config.Rotation.Suffix()
+	destWriter, err := w.fs.NewWriter(ctx, w.rotationURL, file.DefaultFileOsMode)
+	if err != nil {
+		return err
+	}
+	writer := bufio.NewWriter(destWriter)
+	if err = parquet.Convert(writer, reader, columns, conversion.Options()); err != nil {
+		_ = destWriter.Close()
+		return errors.Wrapf(err, "failed to convert %v to parquet", source)
+	}
+	if err = writer.Flush(); err == nil {
+		if err = destWriter.Close(); err == nil {
+			err = w.fs.Delete(ctx, source)
+		}
+	}
+	return err
+}
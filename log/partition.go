@@ -0,0 +1,177 @@
+package log
+
+import (
+	"container/list"
+	"context"
+	"github.com/pkg/errors"
+	"github.com/viant/afs/file"
+	"github.com/viant/afs/url"
+	"github.com/viant/tapper/config"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Partition represents partition values in config.Partition.Keys order
+type Partition []string
+
+// partitions represents a bounded registry of open partition shards, least recently used first to close
+type partitions struct {
+	config *config.Partition
+	mux    sync.Mutex
+	byKey  map[string]*list.Element
+	lru    *list.List
+	key    []byte
+	opened uint64
+}
+
+// shard returns open partition shard for supplied values, it opens a new one if needed
+func (p *partitions) shard(logger *Logger, values Partition) (*shard, error) {
+	if len(values) != len(p.config.Keys) {
+		return nil, errors.Errorf("invalid partition: expected %v values, but had %v", len(p.config.Keys), len(values))
+	}
+	for i, value := range values {
+		if !isValidPartitionValue(value) {
+			return nil, errors.Errorf("invalid partition %v value: %q", p.config.Keys[i], value)
+		}
+	}
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	p.key = p.key[:0]
+	for i, value := range values {
+		if i > 0 {
+			p.key = append(p.key, '/')
+		}
+		p.key = append(p.key, value...)
+	}
+	if element, ok := p.byKey[string(p.key)]; ok {
+		p.lru.MoveToFront(element)
+		return element.Value.(*shard), nil
+	}
+	for p.lru.Len() >= p.config.MaxOpen {
+		p.evict(p.lru.Back())
+	}
+	result, err := p.open(logger, values)
+	if err != nil {
+		return nil, err
+	}
+	result.partition = string(p.key)
+	p.byKey[result.partition] = p.lru.PushFront(result)
+	return result, nil
+}
+
+// isValidPartitionValue returns true if value can be used as a path fragment, path separators and dot segments are rejected,
+// so partition values can not escape partition directory nor collide in partition key
+func isValidPartitionValue(value string) bool {
+	if value == "" || value == "." || value == ".." {
+		return false
+	}
+	return !strings.ContainsAny(value, "/\\\x00")
+}
+
+func (p *partitions) open(logger *Logger, values Partition) (*shard, error) {
+	opened := atomic.AddUint64(&p.opened, 1)
+	result := &shard{
+		ID:          logger.ID + "-" + strconv.FormatUint(opened, 10),
+		destURL:     p.config.ExpandURL(logger.config.URL, values),
+		logger:      logger,
+		writers:     make([]*writer, 2),
+		partitioned: true,
+	}
+	var err error
+	if result.rotation, err = p.rotation(logger, values); err != nil {
+		return nil, err
+	}
+	if err := ensureParent(logger, result.destURL); err != nil {
+		return nil, err
+	}
+	return result, result.open(logger.clock())
+}
+
+// rotation returns partition rotation with expanded URL
+func (p *partitions) rotation(logger *Logger, values Partition) (*config.Rotation, error) {
+	rotation := logger.config.Rotation
+	if rotation == nil {
+		return nil, nil
+	}
+	partitionRotation := *rotation
+	partitionRotation.URL = p.config.ExpandURL(rotation.URL, values)
+	if err := partitionRotation.Init(); err != nil {
+		return nil, err
+	}
+	return &partitionRotation, ensureParent(logger, partitionRotation.URL)
+}
+
+// ensureParent creates local partition directory if needed
+func ensureParent(logger *Logger, URL string) error {
+	if url.Scheme(URL, file.Scheme) != file.Scheme {
+		return nil
+	}
+	parent, _ := url.Split(URL, file.Scheme)
+	if ok, _ := logger.fs.Exists(context.Background(), parent); ok {
+		return nil
+	}
+	return logger.fs.Create(context.Background(), parent, file.DefaultDirOsMode, true)
+}
+
+// evict closes partition shard, rotated file is finalised in the background
+func (p *partitions) evict(element *list.Element) {
+	shard := element.Value.(*shard)
+	p.lru.Remove(element)
+	delete(p.byKey, shard.partition)
+	_ = shard.close(context.Background(), false)
+}
+
+// monitor rotates expired partitions and closes idle ones
+func (p *partitions) monitor(now time.Time) {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	for element := p.lru.Front(); element != nil; element = element.Next() {
+		element.Value.(*shard).monitor(now)
+	}
+	if p.config.IdleMs == 0 {
+		return
+	}
+	idleTime := time.Duration(p.config.IdleMs) * time.Millisecond
+	for element := p.lru.Back(); element != nil; element = p.lru.Back() {
+		if now.Sub(element.Value.(*shard).lastUsed()) < idleTime {
+			break
+		}
+		p.evict(element)
+	}
+}
+
+// shards returns open partition shards
+func (p *partitions) shards() []*shard {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	var result = make([]*shard, 0, p.lru.Len())
+	for element := p.lru.Front(); element != nil; element = element.Next() {
+		result = append(result, element.Value.(*shard))
+	}
+	return result
+}
+
+// close closes all partition shards
+func (p *partitions) close(ctx context.Context, wait bool) (err error) {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	for element := p.lru.Front(); element != nil; element = element.Next() {
+		if e := element.Value.(*shard).close(ctx, wait); e != nil {
+			err = e
+		}
+	}
+	p.lru.Init()
+	p.byKey = map[string]*list.Element{}
+	return err
+}
+
+func newPartitions(cfg *config.Partition) *partitions {
+	return &partitions{
+		config: cfg,
+		byKey:  map[string]*list.Element{},
+		lru:    list.New(),
+	}
+}
@@ -0,0 +1,204 @@
+package log
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"github.com/viant/tapper/config"
+	"io"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+//queue represents a bounded ring of records written by a dedicated goroutine
+type queue struct {
+	policy   string
+	slots    []record
+	head     int
+	size     int
+	closed   bool
+	mux      sync.Mutex
+	notEmpty *sync.Cond
+	notFull  *sync.Cond
+	spill    *spill
+	dropped  uint64
+	spilled  uint64
+	done     chan bool
+	retries  int   //consecutive failed spill replays
+	backoff  bool  //spill replay is delayed after failure
+	aborted  int32 //close context was done before the queue was drained
+}
+
+var errAborted = errors.New("queue close was aborted")
+
+const (
+	minReplayBackoff = 100 * time.Millisecond
+	maxReplayBackoff = 30 * time.Second
+)
+
+//put copies message bytes into the queue applying full queue policy
+func (q *queue) put(message io.WriterTo) error {
+	q.mux.Lock()
+	for q.size == len(q.slots) && !q.closed {
+		switch q.policy {
+		case config.PolicyDropNewest:
+			q.mux.Unlock()
+			atomic.AddUint64(&q.dropped, 1)
+			return nil
+		case config.PolicyDropOldest:
+			q.head = (q.head + 1) % len(q.slots)
+			q.size--
+			atomic.AddUint64(&q.dropped, 1)
+		case config.PolicySpill:
+			q.mux.Unlock()
+			atomic.AddUint64(&q.spilled, 1)
+			return q.spill.write(message)
+		default:
+			q.notFull.Wait()
+		}
+	}
+	if q.closed {
+		q.mux.Unlock()
+		return errors.New("logger was closed")
+	}
+	slot := &q.slots[(q.head+q.size)%len(q.slots)]
+	slot.data = slot.data[:0]
+	_, err := message.WriteTo(slot)
+	if err == nil {
+		q.size++
+		q.notEmpty.Signal()
+	}
+	q.mux.Unlock()
+	return err
+}
+
+//run writes queued records with the supplied function until queue is closed and drained
+func (q *queue) run(write func(record io.WriterTo) error) {
+	defer close(q.done)
+	current := &record{}
+	for {
+		q.mux.Lock()
+		for q.size == 0 && !q.closed {
+			if q.spill != nil && !q.backoff && q.spill.isPending() {
+				q.mux.Unlock()
+				err := q.replay(write)
+				q.mux.Lock()
+				if err != nil {
+					q.delayReplay()
+				} else {
+					q.retries = 0
+				}
+				continue
+			}
+			q.notEmpty.Wait()
+		}
+		if q.size == 0 {
+			q.mux.Unlock()
+			break
+		}
+		slot := &q.slots[q.head]
+		current.data, slot.data = slot.data, current.data[:0]
+		q.head = (q.head + 1) % len(q.slots)
+		q.size--
+		q.notFull.Signal()
+		q.mux.Unlock()
+		if err := write(current); err != nil {
+			log.Print(err)
+		}
+	}
+	if q.spill != nil && atomic.LoadInt32(&q.aborted) == 0 {
+		_ = q.replay(func(record io.WriterTo) error {
+			if atomic.LoadInt32(&q.aborted) == 1 {
+				return errAborted
+			}
+			return write(record)
+		})
+	}
+}
+
+func (q *queue) replay(write func(record io.WriterTo) error) error {
+	err := q.spill.replay(write)
+	if err != nil && err != errAborted {
+		log.Print(err)
+	}
+	return err
+}
+
+//delayReplay postpones the next spill replay with exponential backoff, queue lock has to be held
+func (q *queue) delayReplay() {
+	delay := minReplayBackoff << uint(q.retries)
+	if delay > maxReplayBackoff || delay <= 0 {
+		delay = maxReplayBackoff
+	} else {
+		q.retries++
+	}
+	q.backoff = true
+	time.AfterFunc(delay, func() {
+		q.mux.Lock()
+		q.backoff = false
+		q.notEmpty.Broadcast()
+		q.mux.Unlock()
+	})
+}
+
+//close stops accepting new records and waits till queued records are written or context is done,
+//records left in the queue are then spilled with spill policy or dropped otherwise
+func (q *queue) close(ctx context.Context) error {
+	q.mux.Lock()
+	if q.closed {
+		q.mux.Unlock()
+		return nil
+	}
+	q.closed = true
+	q.notEmpty.Broadcast()
+	q.notFull.Broadcast()
+	q.mux.Unlock()
+	var err error
+	select {
+	case <-q.done:
+	case <-ctx.Done():
+		atomic.StoreInt32(&q.aborted, 1)
+		q.mux.Lock()
+		dropped := 0
+		for ; q.size > 0; q.size-- {
+			slot := &q.slots[q.head]
+			q.head = (q.head + 1) % len(q.slots)
+			if q.spill == nil || q.spill.write(slot) != nil {
+				dropped++
+			}
+		}
+		q.mux.Unlock()
+		<-q.done //the record being written is completed
+		if dropped > 0 {
+			atomic.AddUint64(&q.dropped, uint64(dropped))
+			err = errors.Errorf("failed to write %v queued records: %v", dropped, ctx.Err())
+		}
+	}
+	if q.spill != nil {
+		if e := q.spill.close(); e != nil {
+			err = e
+		}
+	}
+	return err
+}
+
+func newQueue(cfg *config.Async, spillPath string) (*queue, error) {
+	result := &queue{
+		policy: cfg.Policy,
+		slots:  make([]record, cfg.QueueSize),
+		done:   make(chan bool),
+	}
+	result.notEmpty = sync.NewCond(&result.mux)
+	result.notFull = sync.NewCond(&result.mux)
+	if cfg.Policy == config.PolicySpill {
+		if cfg.SpillPath != "" {
+			spillPath = cfg.SpillPath
+		}
+		var err error
+		if result.spill, err = newSpill(spillPath); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
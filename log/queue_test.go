@@ -0,0 +1,194 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/viant/tapper/config"
+	"io"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestQueue_Policy(t *testing.T) {
+
+	var useCases = []struct {
+		description   string
+		policy        string
+		expect        []string
+		expectDropped uint64
+		expectSpilled uint64
+	}{
+		{
+			description:   "drop newest",
+			policy:        config.PolicyDropNewest,
+			expect:        []string{"0", "1", "2", "3"},
+			expectDropped: 6,
+		},
+		{
+			description:   "drop oldest",
+			policy:        config.PolicyDropOldest,
+			expect:        []string{"6", "7", "8", "9"},
+			expectDropped: 6,
+		},
+		{
+			description:   "spill",
+			policy:        config.PolicySpill,
+			expect:        []string{"0", "1", "2", "3", "4", "5", "6", "7", "8", "9"},
+			expectSpilled: 6,
+		},
+		{
+			description: "block",
+			policy:      config.PolicyBlock,
+			expect:      []string{"0", "1", "2", "3", "4", "5", "6", "7", "8", "9"},
+		},
+	}
+
+	for _, useCase := range useCases {
+		cfg := &config.Async{QueueSize: 4, Policy: useCase.policy}
+		if !assert.Nil(t, cfg.Init(), useCase.description) {
+			continue
+		}
+		spillPath := path.Join(os.TempDir(), "tapper-queue-test.spill")
+		_ = os.Remove(spillPath)
+		q, err := newQueue(cfg, spillPath)
+		if !assert.Nil(t, err, useCase.description) {
+			continue
+		}
+		output := new(bytes.Buffer)
+		write := func(record io.WriterTo) error {
+			_, err := record.WriteTo(output)
+			return err
+		}
+		if useCase.policy == config.PolicyBlock {
+			go func() {
+				time.Sleep(100 * time.Millisecond)
+				q.run(write)
+			}()
+		}
+		for i := 0; i < 10; i++ {
+			assert.Nil(t, q.put(&record{data: []byte(strconv.Itoa(i) + "\n")}), useCase.description)
+		}
+		if useCase.policy != config.PolicyBlock {
+			go q.run(write)
+		}
+		assert.Nil(t, q.close(context.Background()), useCase.description)
+		actual := strings.Split(strings.TrimSpace(output.String()), "\n")
+		assert.EqualValues(t, useCase.expect, actual, useCase.description)
+		assert.EqualValues(t, useCase.expectDropped, q.dropped, useCase.description)
+		assert.EqualValues(t, useCase.expectSpilled, q.spilled, useCase.description)
+		_, err = os.Stat(spillPath)
+		assert.True(t, os.IsNotExist(err), useCase.description)
+	}
+}
+
+func TestQueue_Close_Context(t *testing.T) {
+	var useCases = []struct {
+		description   string
+		policy        string
+		expectErr     string
+		expectDropped uint64
+		expectSpill   string
+	}{
+		{
+			description:   "queued records are dropped",
+			policy:        config.PolicyBlock,
+			expectErr:     "failed to write 3 queued records: context canceled",
+			expectDropped: 3,
+		},
+		{
+			description: "queued records are spilled",
+			policy:      config.PolicySpill,
+			expectSpill: "1\n2\n3\n",
+		},
+	}
+	for _, useCase := range useCases {
+		cfg := &config.Async{QueueSize: 4, Policy: useCase.policy}
+		if !assert.Nil(t, cfg.Init(), useCase.description) {
+			continue
+		}
+		spillPath := path.Join(os.TempDir(), "tapper-queue-close-test.spill")
+		_ = os.Remove(spillPath)
+		q, err := newQueue(cfg, spillPath)
+		if !assert.Nil(t, err, useCase.description) {
+			continue
+		}
+		for i := 0; i < 4; i++ {
+			assert.Nil(t, q.put(&record{data: []byte(strconv.Itoa(i) + "\n")}), useCase.description)
+		}
+		output := new(bytes.Buffer)
+		started := make(chan bool, 4)
+		go q.run(func(record io.WriterTo) error {
+			started <- true
+			time.Sleep(50 * time.Millisecond)
+			_, err := record.WriteTo(output)
+			return err
+		})
+		<-started
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		err = q.close(ctx)
+		if useCase.expectErr == "" {
+			assert.Nil(t, err, useCase.description)
+		} else if assert.NotNil(t, err, useCase.description) {
+			assert.EqualValues(t, useCase.expectErr, err.Error(), useCase.description)
+		}
+		assert.EqualValues(t, "0\n", output.String(), "in-flight record is written")
+		assert.EqualValues(t, useCase.expectDropped, q.dropped, useCase.description)
+		if useCase.expectSpill == "" {
+			continue
+		}
+		spill, err := newSpill(spillPath)
+		if !assert.Nil(t, err, useCase.description) {
+			continue
+		}
+		spilled := new(bytes.Buffer)
+		assert.Nil(t, spill.replay(func(record io.WriterTo) error {
+			_, err := record.WriteTo(spilled)
+			return err
+		}), useCase.description)
+		assert.Nil(t, spill.close(), useCase.description)
+		assert.EqualValues(t, useCase.expectSpill, spilled.String(), useCase.description)
+	}
+}
+
+func TestSpill_Replay(t *testing.T) {
+	spillPath := path.Join(os.TempDir(), "tapper-spill-test.spill")
+	_ = os.Remove(spillPath)
+	s, err := newSpill(spillPath)
+	if !assert.Nil(t, err) {
+		return
+	}
+	for i := 0; i < 5; i++ {
+		assert.Nil(t, s.write(&record{data: []byte(strconv.Itoa(i) + "\n")}))
+	}
+	output := new(bytes.Buffer)
+	failAt := 3
+	write := func(record io.WriterTo) error {
+		if output.Len() == failAt*2 {
+			return io.ErrShortWrite
+		}
+		_, err := record.WriteTo(output)
+		return err
+	}
+	assert.NotNil(t, s.replay(write), "sink failure")
+	assert.True(t, s.isPending())
+	assert.Nil(t, s.write(&record{data: []byte("5\n")}), "spill while replay is pending")
+	assert.Nil(t, s.close(), "replayed records are compacted")
+
+	s, err = newSpill(spillPath)
+	if !assert.Nil(t, err) {
+		return
+	}
+	failAt = -1
+	assert.Nil(t, s.replay(write))
+	assert.False(t, s.isPending())
+	assert.EqualValues(t, "0\n1\n2\n3\n4\n5\n", output.String(), "records are neither lost nor duplicated")
+	assert.Nil(t, s.close())
+	_, err = os.Stat(spillPath)
+	assert.True(t, os.IsNotExist(err))
+}
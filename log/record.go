@@ -0,0 +1,22 @@
+package log
+
+import (
+	"io"
+)
+
+//record represents a reusable copy of message bytes
+type record struct {
+	data []byte
+}
+
+//Write appends data to the record
+func (r *record) Write(bs []byte) (int, error) {
+	r.data = append(r.data, bs...)
+	return len(bs), nil
+}
+
+//WriteTo writes record data to the writer
+func (r *record) WriteTo(w io.Writer) (int64, error) {
+	n, err := w.Write(r.data)
+	return int64(n), err
+}
@@ -0,0 +1,226 @@
+package log
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/pkg/errors"
+	"github.com/viant/afs/file"
+	"github.com/viant/afs/url"
+	"github.com/viant/tapper/emitter"
+	"io/ioutil"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	//stageRotated rotated file awaits compression, transfer and emit
+	stageRotated = "rotated"
+	//stageFinalised rotated file awaits emit
+	stageFinalised = "finalised"
+
+	markerSuffix  = ".tapper"
+	maxRecoverSeq = 10000
+)
+
+// marker represents rotated file finalisation progress, persisted next to the rotation path
+type marker struct {
+	ID           string //shard ID
+	Logger       string //logger ID, shard ID prefix is ambiguous, i.e. logger app shard app-2 and logger app-2
+	DestURL      string
+	RotationPath string
+	RotationURL  string
+	Transfer     bool
+	Created      time.Time
+	Stage        string
+}
+
+// markerPath returns hidden marker file path for supplied rotation path, emitter skips pending files with marker
+func markerPath(rotationPath string) string {
+	return emitter.MarkerPath(rotationPath)
+}
+
+func (w *writer) saveMarker(stage string) error {
+	data, err := json.Marshal(&marker{
+		ID:           w.ID,
+		Logger:       w.loggerID,
+		DestURL:      w.destURL,
+		RotationPath: w.rotationPath,
+		RotationURL:  w.rotationURL,
+		Transfer:     w.rotationTransfer,
+		Created:      w.created,
+		Stage:        stage,
+	})
+	if err != nil {
+		return err
+	}
+	location := markerPath(w.rotationPath)
+	if err = ioutil.WriteFile(location+".tmp", data, file.DefaultFileOsMode); err != nil {
+		return errors.Wrapf(err, "failed to save recovery marker: %v", location)
+	}
+	return os.Rename(location+".tmp", location)
+}
+
+func (w *writer) removeMarker() {
+	if w.rotationPath == "" {
+		return
+	}
+	_ = os.Remove(markerPath(w.rotationPath))
+}
+
+// recover finalises files left by a previous process of this logger: active files are rotated,
+// rotated files are compressed, transferred and emitted according to their markers;
+// it returns an error if active file can not be rotated, since opening logger would truncate it
+func (l *Logger) recover(ctx context.Context) error {
+	if l.config.Rotation == nil {
+		return nil
+	}
+	shards := l.shards
+	if l.partitions != nil {
+		shards = l.partitionShards()
+	}
+	for _, shard := range shards {
+		if err := l.recoverActive(ctx, shard); err != nil {
+			return err
+		}
+	}
+	for _, location := range l.recoveryLocations() {
+		infos, err := ioutil.ReadDir(location)
+		if err != nil {
+			continue
+		}
+		for _, info := range infos {
+			name := info.Name()
+			if info.IsDir() || !strings.HasPrefix(name, ".") || !strings.HasSuffix(name, markerSuffix) {
+				continue
+			}
+			if err = l.recoverMarker(ctx, path.Join(location, name)); err != nil {
+				log.Print(err)
+			}
+		}
+	}
+	return nil
+}
+
+// recoveryLocations returns local directories holding rotated files, partitioned logger returns directories of all partitions
+func (l *Logger) recoveryLocations() []string {
+	rotationURL := l.config.Rotation.URL
+	if url.Scheme(rotationURL, file.Scheme) != file.Scheme {
+		return []string{os.TempDir()}
+	}
+	parent, _ := url.Split(rotationURL, file.Scheme)
+	if l.partitions == nil {
+		return []string{url.Path(parent)}
+	}
+	locations, _ := filepath.Glob(url.Path(l.config.Partition.GlobURL(parent)))
+	return locations
+}
+
+// partitionShards returns unopened shards of active partition files left by a previous process
+func (l *Logger) partitionShards() []*shard {
+	destURL := l.config.URL
+	if url.Scheme(destURL, file.Scheme) != file.Scheme {
+		return nil
+	}
+	pattern := url.Path(destURL)
+	matches, _ := filepath.Glob(l.config.Partition.GlobURL(pattern))
+	var result []*shard
+	for _, match := range matches {
+		values, ok := l.config.Partition.MatchURL(pattern, match)
+		if !ok {
+			continue
+		}
+		rotation, err := l.partitions.rotation(l, values)
+		if err != nil {
+			log.Print(err)
+			continue
+		}
+		//partition open sequence starts with 1, so recovered file rotation URL does not collide with new partition files
+		result = append(result, &shard{ID: l.ID + "-0", destURL: match, logger: l, rotation: rotation})
+	}
+	return result
+}
+
+// recoverActive moves non empty active file left by a previous process to its rotation path
+func (l *Logger) recoverActive(ctx context.Context, shard *shard) error {
+	if url.Scheme(shard.destURL, file.Scheme) != file.Scheme {
+		return nil
+	}
+	info, err := os.Stat(url.Path(shard.destURL))
+	if err != nil || info.Size() == 0 {
+		return nil
+	}
+	created := shard.rotation.In(info.ModTime())
+	attempts := maxRecoverSeq
+	if !shard.rotation.HasSequence() { //the same rotation URL would be expanded on every attempt
+		attempts = 1
+	}
+	rotationURL, candidate := "", ""
+	for i := 0; i < attempts; i++ {
+		candidate = shard.rotation.ExpandURL(created, shard.ID)
+		if ok, _ := l.fs.Exists(ctx, candidate); ok {
+			continue
+		}
+		if suffix := shard.rotation.Suffix(); suffix != "" {
+			if ok, _ := l.fs.Exists(ctx, candidate+suffix); ok {
+				continue
+			}
+		}
+		rotationURL = candidate
+		break
+	}
+	if rotationURL == "" {
+		return errors.Errorf("failed to recover %v: unable to allocate rotation URL, %v already exists", shard.destURL, candidate)
+	}
+	recovered := &writer{ID: shard.ID, loggerID: l.ID, fs: l.fs, config: l.config, destURL: shard.destURL, rotationURL: rotationURL, created: created, count: 1}
+	initRotation(recovered, shard.rotation, created, l.emitter)
+	if _, err = recovered.detach(); err != nil {
+		return errors.Wrapf(err, "failed to recover %v", shard.destURL)
+	}
+	return nil
+}
+
+// recoverMarker finalises rotated file described by the marker if it belongs to this logger
+func (l *Logger) recoverMarker(ctx context.Context, location string) error {
+	data, err := ioutil.ReadFile(location)
+	if err != nil {
+		return err
+	}
+	state := &marker{}
+	if err = json.Unmarshal(data, state); err != nil {
+		return errors.Wrapf(err, "invalid recovery marker: %v", location)
+	}
+	if state.Logger != l.ID && (state.Logger != "" || state.ID != l.ID) { //markers without logger ID match unsharded logger only
+		return nil
+	}
+	recovered := &writer{
+		ID:               state.ID,
+		loggerID:         l.ID,
+		fs:               l.fs,
+		config:           l.config,
+		destURL:          state.DestURL,
+		rotationURL:      state.RotationURL,
+		rotationPath:     state.RotationPath,
+		rotationTransfer: state.Transfer,
+		created:          state.Created,
+		count:            1,
+		closed:           1,
+	}
+	if l.config.Rotation.Emit != nil {
+		recovered.emitter = l.emitter
+	}
+	stage := state.Stage
+	if _, err := os.Stat(state.RotationPath); stage == stageRotated && os.IsNotExist(err) {
+		//process stopped after rotated file was compressed or transferred, but before marker was updated
+		recovered.rotationURL += l.config.Rotation.Suffix()
+		if ok, _ := l.fs.Exists(ctx, recovered.rotationURL); !ok {
+			recovered.removeMarker()
+			return nil
+		}
+		stage = stageFinalised
+	}
+	return recovered.finalize(ctx, stage)
+}
@@ -0,0 +1,176 @@
+package log
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/viant/afs"
+	"github.com/viant/tapper/config"
+	"github.com/viant/tapper/emitter"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLogger_recover(t *testing.T) {
+	baseDir := path.Join(os.TempDir(), "tapper-recovery")
+	_ = os.RemoveAll(baseDir)
+	if !assert.Nil(t, os.MkdirAll(baseDir, 0755)) {
+		return
+	}
+	emitted := path.Join(baseDir, "emitted")
+	newConfig := func() *config.Stream {
+		return &config.Stream{
+			URL: path.Join(baseDir, "log.json"),
+			Rotation: &config.Rotation{
+				EveryMs: 60000,
+				URL:     path.Join(baseDir, "rotated-%v"),
+				Codec:   "gzip",
+				Emit:    &config.Event{Command: "sh", Args: []string{"-c", "echo $0 >> " + emitted, emitter.DestName}},
+			},
+		}
+	}
+	emittedNames := func() []string {
+		data, _ := ioutil.ReadFile(emitted)
+		result := strings.Fields(string(data))
+		sort.Strings(result)
+		return result
+	}
+	//active file left by crashed process
+	assert.Nil(t, ioutil.WriteFile(path.Join(baseDir, "log.json"), []byte("{\"id\":1}\n{\"id\":2}\n"), 0644))
+	//rotated file which was neither compressed nor emitted
+	rotationPath := path.Join(baseDir, "rotated-xx-100")
+	assert.Nil(t, ioutil.WriteFile(rotationPath, []byte("{\"id\":0}\n"), 0644))
+	state, _ := json.Marshal(&marker{ID: "xx", RotationPath: rotationPath, RotationURL: rotationPath, Created: time.Now(), Stage: stageRotated})
+	assert.Nil(t, ioutil.WriteFile(markerPath(rotationPath), state, 0644))
+	//markers of other loggers, including logger which ID starts with this logger ID
+	var otherPaths []string
+	for _, other := range []*marker{{ID: "yy"}, {ID: "xx-2", Logger: "xx-2"}, {ID: "xx-2"}} {
+		otherPath := path.Join(baseDir, "rotated-"+other.ID+"-"+strconv.Itoa(len(otherPaths)))
+		assert.Nil(t, ioutil.WriteFile(otherPath, []byte("{\"id\":0}\n"), 0644))
+		other.RotationPath, other.RotationURL, other.Created, other.Stage = otherPath, otherPath, time.Now(), stageRotated
+		state, _ = json.Marshal(other)
+		assert.Nil(t, ioutil.WriteFile(markerPath(otherPath), state, 0644))
+		otherPaths = append(otherPaths, otherPath)
+	}
+
+	logger, err := New(newConfig(), "xx", afs.New())
+	if !assert.Nil(t, err) {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	assert.Nil(t, logger.Shutdown(ctx))
+
+	assertLines := func(location string, expect int) {
+		reader, err := os.Open(location)
+		if !assert.Nil(t, err, location) {
+			return
+		}
+		defer reader.Close()
+		gzReader, err := gzip.NewReader(reader)
+		if !assert.Nil(t, err, location) {
+			return
+		}
+		data, _ := ioutil.ReadAll(gzReader)
+		assert.EqualValues(t, expect, bytes.Count(data, []byte("\n")), location)
+	}
+	assertLines(path.Join(baseDir, "rotated-xx-0.gz"), 2)
+	assertLines(rotationPath+".gz", 1)
+	for _, location := range []string{rotationPath, markerPath(rotationPath), markerPath(path.Join(baseDir, "rotated-xx-0"))} {
+		_, err := os.Stat(location)
+		assert.True(t, os.IsNotExist(err), location)
+	}
+	for _, otherPath := range otherPaths {
+		_, err = os.Stat(markerPath(otherPath))
+		assert.Nil(t, err, "other logger marker should be left intact")
+	}
+	assert.EqualValues(t, []string{"rotated-xx-0.gz", "rotated-xx-100.gz"}, emittedNames(), "recovered files should be emitted once")
+
+	//second start does not recover files again, rotated files left in place are pending emits
+	assert.Nil(t, os.Remove(emitted))
+	logger, err = New(newConfig(), "xx", afs.New())
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Nil(t, logger.Shutdown(ctx))
+	assert.EqualValues(t, []string{"rotated-xx-0.gz", "rotated-xx-100.gz"}, emittedNames(), "rotated files should be emitted on start")
+	_, err = os.Stat(path.Join(baseDir, "rotated-xx-1.gz"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestLogger_recover_Partition(t *testing.T) {
+	baseDir := path.Join(os.TempDir(), "tapper-recovery-partition")
+	_ = os.RemoveAll(baseDir)
+	activeDir := path.Join(baseDir, "active", "country=US")
+	rotatedDir := path.Join(baseDir, "data", "country=PL")
+	for _, location := range []string{activeDir, rotatedDir} {
+		if !assert.Nil(t, os.MkdirAll(location, 0755)) {
+			return
+		}
+	}
+	//active partition file left by crashed process
+	assert.Nil(t, ioutil.WriteFile(path.Join(activeDir, "log.json"), []byte("{\"id\":1}\n{\"id\":2}\n"), 0644))
+	//rotated partition file which was not compressed
+	rotationPath := path.Join(rotatedDir, "log-xx-3-0.json")
+	assert.Nil(t, ioutil.WriteFile(rotationPath, []byte("{\"id\":0}\n"), 0644))
+	state, _ := json.Marshal(&marker{ID: "xx-3", Logger: "xx", RotationPath: rotationPath, RotationURL: rotationPath, Created: time.Now(), Stage: stageRotated})
+	assert.Nil(t, ioutil.WriteFile(markerPath(rotationPath), state, 0644))
+
+	logger, err := New(&config.Stream{
+		URL:       path.Join(baseDir, "active", "country={country}", "log.json"),
+		Partition: &config.Partition{Keys: []string{"country"}},
+		Rotation: &config.Rotation{
+			EveryMs: 60000,
+			URL:     path.Join(baseDir, "data", "country={country}", "log-%v.json"),
+			Codec:   "gzip",
+		},
+	}, "xx", afs.New())
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Nil(t, logger.Close())
+
+	for location, expect := range map[string]int{path.Join(baseDir, "data", "country=US", "log-xx-0-0.json.gz"): 2, rotationPath + ".gz": 1} {
+		reader, err := os.Open(location)
+		if !assert.Nil(t, err, location) {
+			continue
+		}
+		gzReader, err := gzip.NewReader(reader)
+		if assert.Nil(t, err, location) {
+			data, _ := ioutil.ReadAll(gzReader)
+			assert.EqualValues(t, expect, bytes.Count(data, []byte("\n")), location)
+		}
+		_ = reader.Close()
+	}
+	for _, location := range []string{path.Join(activeDir, "log.json"), rotationPath, markerPath(rotationPath)} {
+		_, err := os.Stat(location)
+		assert.True(t, os.IsNotExist(err), location)
+	}
+}
+
+func TestLogger_recover_WithoutSequence(t *testing.T) {
+	baseDir := path.Join(os.TempDir(), "tapper-recovery-sequence")
+	_ = os.RemoveAll(baseDir)
+	if !assert.Nil(t, os.MkdirAll(baseDir, 0755)) {
+		return
+	}
+	activePath := path.Join(baseDir, "log.json")
+	assert.Nil(t, ioutil.WriteFile(activePath, []byte("{\"id\":1}\n"), 0644))
+	assert.Nil(t, ioutil.WriteFile(path.Join(baseDir, "rotated.json"), []byte("{\"id\":0}\n"), 0644))
+	_, err := New(&config.Stream{
+		URL:      activePath,
+		Rotation: &config.Rotation{EveryMs: 60000, URL: path.Join(baseDir, "rotated.json")},
+	}, "xx", afs.New())
+	assert.NotNil(t, err, "rotation URL without sequence is already taken")
+	data, err := ioutil.ReadFile(activePath)
+	assert.Nil(t, err)
+	assert.EqualValues(t, "{\"id\":1}\n", string(data), "active file should be left intact")
+}
@@ -0,0 +1,213 @@
+package log
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"github.com/viant/tapper/config"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// shard represents an independent pair of rotating writers
+type shard struct {
+	ID         string
+	destURL    string
+	logger     *Logger
+	rotation   *config.Rotation
+	mux        sync.Mutex
+	index      uint64
+	writers    []*writer
+	generation uint64
+	// partition fields, partition shards rotate independently
+	partition   string
+	partitioned bool
+	used        int64
+	closed      bool
+}
+
+func (s *shard) open(ts time.Time) (err error) {
+	var rotationURL string
+	if s.rotation != nil {
+		ts = s.rotation.In(ts)
+		rotationURL = s.rotation.ExpandURL(ts, s.ID)
+	}
+	index := atomic.AddUint64(&s.index, 1) % 2
+	s.writers[index], err = newWriter(s.logger.config, s.destURL, s.logger.fs, rotationURL, int(index), ts, s.logger.emitter)
+	if err == nil {
+		s.writers[index].ID = s.ID
+		s.writers[index].loggerID = s.logger.ID
+		s.writers[index].tasks = s.logger.tasks
+	}
+	return err
+}
+
+func (s *shard) getWriter() *writer {
+	index := atomic.LoadUint64(&s.index) % 2
+	return s.writers[index]
+}
+
+// isBehind returns true if any other shard has rotated since this shard writer was opened
+func (s *shard) isBehind() bool {
+	if s.partitioned {
+		return false
+	}
+	return s.generation != atomic.LoadUint64(&s.logger.generation)
+}
+
+func (s *shard) lastUsed() time.Time {
+	return time.Unix(0, atomic.LoadInt64(&s.used))
+}
+
+func (s *shard) log(message io.WriterTo) (err error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if s.closed {
+		return errShardClosed
+	}
+	now := s.logger.clock()
+	if s.partitioned {
+		atomic.StoreInt64(&s.used, now.UnixNano())
+	}
+	writer := s.getWriter()
+	if writer.isPeriodEnded(now) || (s.isBehind() && writer.count > 0) {
+		if err = s.rotate(writer, now); err != nil {
+			return err
+		}
+		writer = s.getWriter()
+	} else if s.isBehind() {
+		s.generation = atomic.LoadUint64(&s.logger.generation)
+	}
+	if header := s.logger.header; header != nil {
+		if err = header.writeTo(writer); err != nil {
+			return err
+		}
+	}
+	_, err = message.WriteTo(writer)
+	if err == nil {
+		err = writer.endRecord()
+	}
+	if err == nil {
+		writer.increment()
+		err = s.syncIfNeeded(writer, now)
+		if err == nil {
+			err = s.rotateIfNeeded(writer, now)
+		}
+	}
+	return err
+}
+
+func (s *shard) monitor(now time.Time) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	writer := s.getWriter()
+	if writer == nil || s.closed {
+		return
+	}
+	if writer.isExpired(now.Add(-5 * time.Second)) {
+		if err := writer.Flush(); err == nil {
+			_ = s.rotateIfNeeded(writer, now)
+		}
+		return
+	}
+	if s.isBehind() && writer.count > 0 {
+		_ = s.rotate(writer, now)
+	}
+}
+
+func (s *shard) rotateIfNeeded(writer *writer, now time.Time) (err error) {
+	if writer.isMaxReached() || writer.isExpired(now) {
+		if !s.partitioned {
+			atomic.AddUint64(&s.logger.generation, 1)
+		}
+		err = s.rotate(writer, now)
+	}
+	return err
+}
+
+func (s *shard) rotate(writer *writer, now time.Time) (err error) {
+	s.generation = atomic.LoadUint64(&s.logger.generation)
+	if err = writer.Close(); err == nil {
+		s.writers[writer.index] = nil
+		err = s.open(now)
+	}
+	return err
+}
+
+// forceRotate rotates current writer synchronously, it returns rotated URL or empty string if there was nothing to rotate
+func (s *shard) forceRotate(ctx context.Context) (string, error) {
+	s.mux.Lock()
+	if s.closed {
+		s.mux.Unlock()
+		return "", nil
+	}
+	writer := s.getWriter()
+	detached, err := writer.detach()
+	if detached && err == nil {
+		s.writers[writer.index] = nil
+		s.generation = atomic.LoadUint64(&s.logger.generation)
+		err = s.open(s.logger.clock())
+	}
+	s.mux.Unlock()
+	if !detached || err != nil {
+		return "", err
+	}
+	if writer.count == 0 {
+		return "", writer.closeQuietly(ctx)
+	}
+	if err = writer.closeQuietly(ctx); err != nil {
+		return "", err
+	}
+	return writer.rotationURL, nil
+}
+
+// close closes shard writers, if wait is false rotated file is finalised in the background
+func (s *shard) close(ctx context.Context, wait bool) (err error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.closed = true
+	for _, writer := range s.writers {
+		if writer == nil {
+			continue
+		}
+		writer.loggerClose = wait
+		if e := writer.close(ctx); e != nil {
+			err = e
+		}
+	}
+	return err
+}
+
+var errShardClosed = errors.New("shard was closed")
+
+// shardURL returns shard specific URL, shard number is inserted before the file extension
+func shardURL(URL string, number int) string {
+	parent, name := path.Split(URL)
+	suffix := "-" + strconv.Itoa(number)
+	if index := strings.Index(name, "."); index != -1 {
+		return parent + name[:index] + suffix + name[index:]
+	}
+	return URL + suffix
+}
+
+func newShard(logger *Logger, number int) *shard {
+	result := &shard{
+		ID:      logger.ID,
+		destURL: logger.config.URL,
+		logger:  logger,
+		writers: make([]*writer, 2),
+	}
+	if logger.config.Shards > 1 {
+		result.ID += "-" + strconv.Itoa(number)
+		result.destURL = shardURL(logger.config.URL, number)
+	}
+	if logger.config.Rotation != nil {
+		rotation := *logger.config.Rotation
+		result.rotation = &rotation
+	}
+	return result
+}
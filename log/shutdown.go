@@ -0,0 +1,98 @@
+package log
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+)
+
+const shutdownPollInterval = 10 * time.Millisecond
+
+// ShutdownError represents background work that was not completed by logger shutdown,
+// rotated files with unfinished work are finalised by the next logger start
+type ShutdownError struct {
+	Unfinished []string //rotated files still being compressed, transferred or emitted
+	Errors     []error  //background and emit errors
+}
+
+// Error returns error message
+func (e *ShutdownError) Error() string {
+	var messages = make([]string, 0, 1+len(e.Errors))
+	if len(e.Unfinished) > 0 {
+		messages = append(messages, "unfinished: "+strings.Join(e.Unfinished, ", "))
+	}
+	for _, err := range e.Errors {
+		messages = append(messages, err.Error())
+	}
+	return "shutdown: " + strings.Join(messages, "; ")
+}
+
+// tasks tracks rotated files finalised in background
+type tasks struct {
+	mux     sync.Mutex
+	running map[*writer]bool
+	errors  []error
+}
+
+func (t *tasks) start(w *writer) {
+	t.mux.Lock()
+	t.running[w] = true
+	t.mux.Unlock()
+}
+
+func (t *tasks) done(w *writer, err error) {
+	t.mux.Lock()
+	delete(t.running, w)
+	if err != nil {
+		t.errors = append(t.errors, err)
+	}
+	t.mux.Unlock()
+}
+
+// wait waits for all running tasks or context done, it returns unfinished rotation URLs and background errors
+func (t *tasks) wait(ctx context.Context) ([]string, []error) {
+	for {
+		t.mux.Lock()
+		count := len(t.running)
+		if count == 0 || ctx.Err() != nil {
+			var unfinished = make([]string, 0, count)
+			for w := range t.running {
+				unfinished = append(unfinished, w.rotationURL)
+			}
+			errs := t.errors
+			t.errors = nil
+			t.mux.Unlock()
+			return unfinished, errs
+		}
+		t.mux.Unlock()
+		select {
+		case <-ctx.Done():
+		case <-time.After(shutdownPollInterval):
+		}
+	}
+}
+
+func newTasks() *tasks {
+	return &tasks{running: make(map[*writer]bool)}
+}
+
+// Shutdown closes logger and waits for queued records and in-flight compression, transfer and emit of rotated files until context is done,
+// failed emits are retried a bounded number of times; it returns ShutdownError listing unfinished work and errors,
+// unfinished emits are retried on the next logger start
+func (l *Logger) Shutdown(ctx context.Context) error {
+	result := &ShutdownError{}
+	if err := l.close(ctx, false); err != nil {
+		result.Errors = append(result.Errors, err)
+	}
+	unfinished, errs := l.tasks.wait(ctx)
+	result.Unfinished = unfinished
+	result.Errors = append(result.Errors, errs...)
+	if err := l.emitter.Shutdown(ctx); err != nil {
+		result.Errors = append(result.Errors, err)
+	}
+	if len(result.Unfinished) == 0 && len(result.Errors) == 0 {
+		return nil
+	}
+	return result
+}
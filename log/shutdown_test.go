@@ -0,0 +1,137 @@
+package log
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/viant/afs"
+	"github.com/viant/tapper/config"
+	"github.com/viant/tapper/msg"
+	mjson "github.com/viant/tapper/msg/json"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLogger_Shutdown(t *testing.T) {
+	var useCases = []struct {
+		description      string
+		emit             *config.Event
+		timeout          time.Duration
+		messages         int
+		expectFiles      int
+		expectError      bool
+		expectMarkers    int
+		expectRecoveries int
+	}{
+		{
+			description: "all rotated files finalised",
+			timeout:     5 * time.Second,
+			messages:    23,
+			expectFiles: 5,
+		},
+		{
+			description:      "failed emit persisted for next start",
+			emit:             &config.Event{Command: "sh", Args: []string{"-c", "exit 1"}},
+			timeout:          300 * time.Millisecond,
+			messages:         6,
+			expectFiles:      2,
+			expectError:      true,
+			expectMarkers:    2,
+			expectRecoveries: 2,
+		},
+		{
+			description:      "failed emit without deadline is retried a bounded number of times",
+			emit:             &config.Event{Command: "sh", Args: []string{"-c", "exit 1"}},
+			messages:         6,
+			expectFiles:      2,
+			expectError:      true,
+			expectMarkers:    2,
+			expectRecoveries: 2,
+		},
+		{
+			description:      "emit dropped after max retries",
+			emit:             &config.Event{Command: "sh", Args: []string{"-c", "exit 1"}, MaxRetries: 1},
+			timeout:          5 * time.Second,
+			messages:         6,
+			expectFiles:      2,
+			expectError:      true,
+			expectMarkers:    2,
+			expectRecoveries: 2,
+		},
+	}
+
+	for _, useCase := range useCases {
+		baseDir := path.Join(os.TempDir(), "tapper-shutdown")
+		_ = os.RemoveAll(baseDir)
+		if !assert.Nil(t, os.MkdirAll(baseDir, 0755), useCase.description) {
+			continue
+		}
+		emitted := path.Join(baseDir, "emitted")
+		newConfig := func(emit *config.Event) *config.Stream {
+			return &config.Stream{
+				URL: path.Join(baseDir, "log.json"),
+				Rotation: &config.Rotation{
+					EveryMs:    60000,
+					MaxEntries: 5,
+					URL:        path.Join(baseDir, "rotated-%v"),
+					Codec:      "gzip",
+					Emit:       emit,
+				},
+			}
+		}
+		logger, err := New(newConfig(useCase.emit), "sd", afs.New())
+		if !assert.Nil(t, err, useCase.description) {
+			continue
+		}
+		provider := msg.NewProvider(1024, 1, mjson.New)
+		for i := 0; i < useCase.messages; i++ {
+			message := provider.NewMessage()
+			message.PutInt("id", i)
+			assert.Nil(t, logger.Log(message), useCase.description)
+			message.Free()
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		if useCase.timeout > 0 {
+			ctx, cancel = context.WithTimeout(context.Background(), useCase.timeout)
+		}
+		err = logger.Shutdown(ctx)
+		cancel()
+		if useCase.expectError {
+			assert.NotNil(t, err, useCase.description)
+			shutdownErr, ok := err.(*ShutdownError)
+			if assert.True(t, ok, useCase.description) && len(shutdownErr.Unfinished) == 0 {
+				assert.Contains(t, err.Error(), "failed to emit 2 events", useCase.description)
+			}
+		} else {
+			assert.Nil(t, err, useCase.description)
+		}
+		compressed, _ := filepath.Glob(path.Join(baseDir, "rotated-sd-*.gz"))
+		assert.EqualValues(t, useCase.expectFiles, len(compressed), useCase.description)
+		markers, _ := filepath.Glob(path.Join(baseDir, ".rotated-sd-*.tapper"))
+		assert.EqualValues(t, useCase.expectMarkers, len(markers), useCase.description)
+		for _, location := range markers {
+			data, err := ioutil.ReadFile(location)
+			assert.Nil(t, err, useCase.description)
+			state := &marker{}
+			assert.Nil(t, json.Unmarshal(data, state), useCase.description)
+			assert.EqualValues(t, stageFinalised, state.Stage, useCase.description)
+		}
+		if useCase.expectRecoveries == 0 {
+			continue
+		}
+		//next start emits persisted events
+		logger, err = New(newConfig(&config.Event{Command: "touch", Args: []string{emitted}}), "sd", afs.New())
+		if !assert.Nil(t, err, useCase.description) {
+			continue
+		}
+		assert.Nil(t, logger.Shutdown(context.Background()), useCase.description)
+		_, err = os.Stat(emitted)
+		assert.Nil(t, err, useCase.description)
+		markers, _ = filepath.Glob(path.Join(baseDir, ".rotated-sd-*.tapper"))
+		assert.EqualValues(t, 0, len(markers), useCase.description)
+	}
+}
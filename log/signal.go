@@ -0,0 +1,35 @@
+package log
+
+import (
+	"context"
+	"log"
+	"os"
+	"os/signal"
+)
+
+// RotateOnSignal rotates the logger whenever one of the supplied signals is received (SIGHUP and SIGUSR1 by default),
+// the returned function stops signal handling
+func (l *Logger) RotateOnSignal(signals ...os.Signal) (stop func()) {
+	if len(signals) == 0 {
+		signals = rotationSignals
+	}
+	notification := make(chan os.Signal, 1)
+	signal.Notify(notification, signals...)
+	done := make(chan bool)
+	go func() {
+		for {
+			select {
+			case <-notification:
+				if _, err := l.Rotate(context.Background()); err != nil {
+					log.Print(err)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() {
+		signal.Stop(notification)
+		close(done)
+	}
+}
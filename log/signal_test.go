@@ -0,0 +1,53 @@
+//go:build !windows
+// +build !windows
+
+package log_test
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/viant/afs"
+	"github.com/viant/afs/file"
+	"github.com/viant/tapper/config"
+	"github.com/viant/tapper/log"
+	"github.com/viant/tapper/msg"
+	"github.com/viant/tapper/msg/json"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestLogger_RotateOnSignal(t *testing.T) {
+	fs := afs.New()
+	ctx := context.Background()
+	baseURL := "/tmp/tapper-signal"
+	_ = fs.Delete(ctx, baseURL)
+	_ = fs.Create(ctx, baseURL, file.DefaultDirOsMode, true)
+	cfg := &config.Stream{
+		URL: baseURL + "/log.json",
+		Rotation: &config.Rotation{
+			EveryMs: 60000,
+			URL:     baseURL + "/rotated-%v",
+		},
+	}
+	logger, err := log.New(cfg, "xx", fs)
+	if !assert.Nil(t, err) {
+		return
+	}
+	defer logger.Close()
+	stop := logger.RotateOnSignal()
+	defer stop()
+	provider := msg.NewProvider(128, 2, json.New)
+	for _, sig := range []syscall.Signal{syscall.SIGHUP, syscall.SIGUSR1} {
+		message := provider.NewMessage()
+		message.PutString("signal", sig.String())
+		assert.Nil(t, logger.Log(message))
+		message.Free()
+		assert.Nil(t, syscall.Kill(syscall.Getpid(), sig))
+		time.Sleep(200 * time.Millisecond)
+	}
+	for _, rotated := range []string{"/rotated-xx-0", "/rotated-xx-1"} {
+		ok, _ := fs.Exists(ctx, baseURL+rotated)
+		assert.True(t, ok, rotated)
+	}
+}
@@ -0,0 +1,11 @@
+//go:build !windows
+// +build !windows
+
+package log
+
+import (
+	"os"
+	"syscall"
+)
+
+var rotationSignals = []os.Signal{syscall.SIGHUP, syscall.SIGUSR1}
@@ -0,0 +1,8 @@
+package log
+
+import (
+	"os"
+	"syscall"
+)
+
+var rotationSignals = []os.Signal{syscall.SIGHUP}
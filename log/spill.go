@@ -0,0 +1,159 @@
+package log
+
+import (
+	"bufio"
+	"encoding/binary"
+	"github.com/pkg/errors"
+	"io"
+	"os"
+	"sync"
+)
+
+//spill represents length prefixed records overflow file, replayed records are tracked with offset,
+//so a failed replay resumes with the first record that was not written
+type spill struct {
+	path     string
+	mux      sync.Mutex
+	file     *os.File
+	writer   *bufio.Writer
+	record   record
+	header   [4]byte
+	replayed record
+	offset   int64 //offset of the next record to replay
+	size     int64 //spill file size including buffered records
+	pending  bool
+}
+
+func (s *spill) isPending() bool {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return s.pending
+}
+
+//write appends message to the spill file
+func (s *spill) write(message io.WriterTo) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.record.data = s.record.data[:0]
+	if _, err := message.WriteTo(&s.record); err != nil {
+		return err
+	}
+	binary.BigEndian.PutUint32(s.header[:], uint32(len(s.record.data)))
+	if _, err := s.writer.Write(s.header[:]); err != nil {
+		return errors.Wrapf(err, "failed to spill record to %v", s.path)
+	}
+	if _, err := s.writer.Write(s.record.data); err != nil {
+		return errors.Wrapf(err, "failed to spill record to %v", s.path)
+	}
+	s.size += int64(len(s.header) + len(s.record.data))
+	s.pending = true
+	return nil
+}
+
+//replay writes spilled records with the supplied function and truncates the spill file once all records were written,
+//the lock is released while writing, so producers can keep spilling
+func (s *spill) replay(write func(record io.WriterTo) error) error {
+	for {
+		ok, err := s.next()
+		if !ok || err != nil {
+			return err
+		}
+		if err = write(&s.replayed); err != nil {
+			return err
+		}
+		s.mux.Lock()
+		s.offset += int64(len(s.header) + len(s.replayed.data))
+		s.mux.Unlock()
+	}
+}
+
+//next reads the next record to replay, it returns false and truncates the spill file if all records were replayed
+func (s *spill) next() (bool, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if !s.pending {
+		return false, nil
+	}
+	if s.offset >= s.size {
+		return false, s.truncate()
+	}
+	if err := s.writer.Flush(); err != nil {
+		return false, err
+	}
+	var header [4]byte
+	if _, err := s.file.ReadAt(header[:], s.offset); err != nil {
+		return false, errors.Wrapf(err, "corrupted spill file: %v", s.path)
+	}
+	size := int(binary.BigEndian.Uint32(header[:]))
+	if cap(s.replayed.data) < size {
+		s.replayed.data = make([]byte, size)
+	}
+	s.replayed.data = s.replayed.data[:size]
+	if _, err := s.file.ReadAt(s.replayed.data, s.offset+int64(len(header))); err != nil {
+		return false, errors.Wrapf(err, "corrupted spill file: %v", s.path)
+	}
+	return true, nil
+}
+
+func (s *spill) truncate() error {
+	s.pending = false
+	s.offset, s.size = 0, 0
+	if err := s.file.Truncate(0); err != nil {
+		return err
+	}
+	_, err := s.file.Seek(0, io.SeekStart)
+	s.writer.Reset(s.file)
+	return err
+}
+
+//compact removes replayed records, so they are not replayed again by the next process
+func (s *spill) compact() error {
+	if s.offset == 0 {
+		return nil
+	}
+	remaining := make([]byte, s.size-s.offset)
+	if _, err := s.file.ReadAt(remaining, s.offset); err != nil {
+		return err
+	}
+	if _, err := s.file.WriteAt(remaining, 0); err != nil {
+		return err
+	}
+	s.offset, s.size = 0, int64(len(remaining))
+	return s.file.Truncate(s.size)
+}
+
+func (s *spill) close() error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if err := s.writer.Flush(); err != nil {
+		return err
+	}
+	if s.pending {
+		if err := s.compact(); err != nil {
+			return errors.Wrapf(err, "failed to compact spill file: %v", s.path)
+		}
+	}
+	if err := s.file.Close(); err != nil {
+		return err
+	}
+	if s.pending {
+		return nil
+	}
+	return os.Remove(s.path)
+}
+
+//newSpill opens spill file, records left by previous process are replayed first
+func newSpill(path string) (*spill, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to open spill file: %v", path)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if _, err = file.Seek(0, io.SeekEnd); err != nil {
+		return nil, err
+	}
+	return &spill{path: path, file: file, writer: bufio.NewWriter(file), size: info.Size(), pending: info.Size() > 0}, nil
+}
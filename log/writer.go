@@ -2,7 +2,6 @@ package log
 
 import (
 	"bufio"
-	"compress/gzip"
 	"context"
 	"fmt"
 	"github.com/pkg/errors"
@@ -24,6 +23,8 @@ import (
 
 // writer represents an optimized writer
 type writer struct {
+	ID               string
+	loggerID         string
 	index            int
 	emitter          *emitter.Service
 	destURL          string
@@ -33,16 +34,27 @@ type writer struct {
 	codec            string
 	count            int32
 	max              int32
-	flushMod         int
+	size             int64
+	maxBytes         int64
+	maxCompressed    int64
+	counter          *counter
+	buffer           *bufio.Writer //uncompressed stream buffer, nil for stream codec
+	unsynced         int
+	synced           time.Time
 	closed           int32
 	created          time.Time
 	expiryTime       *time.Time
+	aligned          bool
 	closer           io.Closer
 	flusher          iow.Flusher
 	writer           io.Writer
 	config           *config.Stream
 	fs               afs.Service
 	loggerClose      bool
+	emitPending      bool
+	headerWritten    bool
+	tasks            *tasks
+	ocf              *ocf
 }
 
 func (w *writer) isClosed() bool {
@@ -51,10 +63,15 @@ func (w *writer) isClosed() bool {
 
 // Close closes this writer
 func (w *writer) Close() error {
-	if !atomic.CompareAndSwapInt32(&w.closed, 0, 1) {
-		return nil
-	}
+	return w.close(context.Background())
+}
+
+// close closes this writer, rotated file is finalised with supplied context, in background unless logger is closing
+func (w *writer) close(ctx context.Context) error {
 	if w.rotationURL == "" {
+		if !atomic.CompareAndSwapInt32(&w.closed, 0, 1) {
+			return nil
+		}
 		if w.count == 0 {
 			return nil
 		}
@@ -64,67 +81,110 @@ func (w *writer) Close() error {
 		}
 		return w.closer.Close()
 	}
-
-	if w.rotationPath != "" {
-		src := url.Path(w.destURL)
-		if err := os.Rename(src, w.rotationPath); err != nil {
-			return errors.Wrapf(err, "failed to rename: %v to %v", src, w.rotationPath)
-		}
+	if detached, err := w.detach(); !detached || err != nil {
+		return err
 	}
 	if w.loggerClose {
-		if err := w.closeQuietly(); err != nil {
+		if err := w.closeQuietly(ctx); err != nil {
 			log.Print(err)
 			return err
 		}
 		return nil
 	}
+	if w.tasks != nil {
+		w.tasks.start(w)
+	}
 	go func() {
-		if err := w.closeQuietly(); err != nil {
+		err := w.closeQuietly(ctx)
+		if err != nil {
 			log.Print(err)
 		}
+		if w.tasks != nil {
+			if w.emitPending { //emit was scheduled for retry
+				err = nil
+			}
+			w.tasks.done(w, err)
+		}
 	}()
 	return nil
 }
 
-func (w *writer) closeQuietly() error {
-	ctx := context.Background()
+// detach closes writer for further writes and moves active file to rotation path, it returns false if writer was already closed
+func (w *writer) detach() (bool, error) {
+	if !atomic.CompareAndSwapInt32(&w.closed, 0, 1) {
+		return false, nil
+	}
+	if w.rotationPath != "" {
+		if w.count > 0 {
+			if err := w.saveMarker(stageRotated); err != nil {
+				return true, err
+			}
+		}
+		src := url.Path(w.destURL)
+		if err := os.Rename(src, w.rotationPath); err != nil {
+			return true, errors.Wrapf(err, "failed to rename: %v to %v", src, w.rotationPath)
+		}
+	}
+	return true, nil
+}
+
+func (w *writer) closeQuietly(ctx context.Context) error {
 	err := w.Flush()
 
 	if writerCloser, ok := w.writer.(io.Closer); ok && err == nil {
 		err = writerCloser.Close()
 	}
 	if err == nil {
-		if w.count == 0 {
-			if w.rotationPath != "" {
-				w.fs.Delete(ctx, w.rotationPath)
-			}
-			return nil
+		err = w.closer.Close()
+	}
+	if err != nil {
+		return err
+	}
+	if w.count == 0 {
+		if w.rotationPath != "" {
+			w.fs.Delete(ctx, w.rotationPath)
 		}
-		if err = w.closer.Close(); err == nil {
-			if w.config.Rotation.IsGzip() {
-				if err = w.compress(ctx); err != nil {
-					return err
-				}
+		return nil
+	}
+	return w.finalize(ctx, stageRotated)
+}
+
+// finalize converts or compresses, transfers and emits rotated file, progress is recorded in the marker file
+func (w *writer) finalize(ctx context.Context, stage string) (err error) {
+	if stage == stageRotated {
+		if w.config.Rotation.Parquet != nil {
+			if err = w.convert(ctx); err != nil {
+				return err
+			}
+		} else if w.config.Rotation.IsCompressed() {
+			if err = w.compress(ctx); err != nil {
+				return err
 			}
 		}
-	}
-	if err == nil {
 		if err = w.transferToDestURL(ctx); err != nil {
 			return err
 		}
-	}
-	if err != nil {
-		return err
+		if w.rotationPath != "" && w.emitter != nil {
+			if err = w.saveMarker(stageFinalised); err != nil {
+				return err
+			}
+		}
 	}
 	if w.emitter != nil && w.count > 0 {
 		event := &emitter.Event{
-			Config:  w.config.Rotation.Emit,
-			Created: w.created,
-			URL:     w.rotationURL,
+			Config:    w.config.Rotation.Emit,
+			Created:   w.created,
+			URL:       w.rotationURL,
+			OnSuccess: w.removeMarker,
 		}
-		err = w.emitter.Emit(event)
+		if err = w.emitter.Emit(event); err != nil {
+			w.emitPending = true
+			return err
+		}
+		return nil
 	}
-	return err
+	w.removeMarker()
+	return nil
 }
 
 func (w *writer) transferToDestURL(ctx context.Context) error {
@@ -135,7 +195,7 @@ func (w *writer) transferToDestURL(ctx context.Context) error {
 	if rotation == nil {
 		return nil
 	}
-	if rotation.IsGzip() {
+	if rotation.IsCompressed() || rotation.Parquet != nil {
 		return nil
 	}
 	reader, err := w.fs.OpenURL(ctx, w.rotationPath)
@@ -150,14 +210,18 @@ func (w *writer) transferToDestURL(ctx context.Context) error {
 	if _, err = io.Copy(writer, reader); err != nil {
 		return err
 	}
-	return writer.Close()
+	if err = writer.Close(); err != nil {
+		return err
+	}
+	return w.fs.Delete(ctx, w.rotationPath)
 }
 
 func (w *writer) compress(ctx context.Context) (err error) {
 	if w.rotationURL == "" {
 		return
 	}
-	if !w.config.Rotation.IsGzip() {
+	compression := w.config.Rotation.Compression()
+	if !compression.IsCompressed() {
 		return
 	}
 	source, reader, err := w.sourceReader(ctx)
@@ -165,13 +229,16 @@ func (w *writer) compress(ctx context.Context) (err error) {
 		return err
 	}
 	defer reader.Close()
-	w.rotationURL += ".gz"
+	w.rotationURL += compression.Suffix()
 	destWriter, err := w.fs.NewWriter(ctx, w.rotationURL, file.DefaultFileOsMode)
 	if err != nil {
 		return err
 	}
 	defer destWriter.Close()
-	writer := gzip.NewWriter(destWriter)
+	writer, err := compression.NewWriter(destWriter)
+	if err != nil {
+		return err
+	}
 	if _, err = io.Copy(writer, reader); err == nil {
 		if err = writer.Flush(); err == nil {
 			if err = writer.Close(); err == nil {
@@ -206,12 +273,28 @@ func (w *writer) sourceReader(ctx context.Context) (string, io.ReadCloser, error
 	return source, reader, nil
 }
 
-// isMaxReached returns true if max records per writer are exceeded
+// isMaxReached returns true if max records or bytes per writer are exceeded, compressed bytes of uncompressed stream
+// include buffered bytes, while stream codec output is counted once the codec flushes it, so the bound is approximate
 func (w *writer) isMaxReached() bool {
-	if w.max == 0 || w.count == 0 {
+	if w.count == 0 {
 		return false
 	}
-	return w.count >= w.max
+	if w.max > 0 && w.count >= w.max {
+		return true
+	}
+	if w.maxBytes > 0 && atomic.LoadInt64(&w.size) >= w.maxBytes {
+		return true
+	}
+	return w.maxCompressed > 0 && w.compressedSize() >= w.maxCompressed
+}
+
+// compressedSize returns bytes written to the underlying file including bytes pending in the uncompressed stream buffer
+func (w *writer) compressedSize() int64 {
+	size := w.counter.Size()
+	if w.buffer != nil {
+		size += int64(w.buffer.Buffered())
+	}
+	return size
 }
 
 // isExpired returns last write exceeded writer rotation time
@@ -225,12 +308,29 @@ func (w *writer) isExpired(now time.Time) bool {
 	return w.expiryTime.Before(now)
 }
 
+// isPeriodEnded returns true if wall-clock aligned writer period has ended
+func (w *writer) isPeriodEnded(now time.Time) bool {
+	if !w.aligned || w.expiryTime == nil {
+		return false
+	}
+	return !now.Before(*w.expiryTime)
+}
+
 // Write writes data
 func (w *writer) Write(bs []byte) (n int, err error) {
 	n, err = w.writer.Write(bs)
+	atomic.AddInt64(&w.size, int64(n))
 	return n, err
 }
 
+// endRecord completes record written to the writer
+func (w *writer) endRecord() error {
+	if w.ocf == nil {
+		return nil
+	}
+	return w.ocf.endRecord()
+}
+
 func (w *writer) increment() int {
 	return int(atomic.AddInt32(&w.count, 1))
 
@@ -255,45 +355,63 @@ func (w *writer) initRotation(rotation *config.Rotation, created time.Time, emit
 		}
 	}
 	w.expiryTime = rotation.ExpiryTime(created)
+	w.aligned = rotation.IsAligned()
 	w.max = int32(rotation.MaxEntries)
+	w.maxBytes = rotation.MaxBytes
+	w.maxCompressed = rotation.MaxCompressedBytes
 	if rotation.Emit != nil {
 		w.emitter = emitter
 	}
 }
 
 // NewWriter creates a writer
-func newWriter(config *config.Stream, fs afs.Service, rotationURL string, index int, created time.Time, emitter *emitter.Service) (*writer, error) {
+func newWriter(config *config.Stream, destURL string, fs afs.Service, rotationURL string, index int, created time.Time, emitter *emitter.Service) (*writer, error) {
 
 	var options = make([]storage.Option, 0)
 	if config.StreamUpload {
 		options = append(options, option.NewSkipChecksum(true))
 	}
 
-	writerCloser, err := fs.NewWriter(context.Background(), config.URL, file.DefaultFileOsMode, options...)
+	writerCloser, err := fs.NewWriter(context.Background(), destURL, file.DefaultFileOsMode, options...)
 	if err != nil {
 		return nil, err
 	}
 	result := &writer{
 		fs:          fs,
 		index:       index,
-		destURL:     config.URL,
+		destURL:     destURL,
 		rotationURL: rotationURL,
 		closer:      writerCloser,
+		counter:     &counter{Writer: writerCloser},
 		created:     created,
+		synced:      created,
 	}
 	result.config = config
 
 	if rotation := config.Rotation; rotation != nil {
 		initRotation(result, rotation, created, emitter)
 	}
-	if config.IsGzip() {
-		gzWriter := gzip.NewWriter(writerCloser)
-		result.writer = gzWriter
-		result.flusher = gzWriter
+	if compression := config.Compression(); compression != nil {
+		codecWriter, err := compression.NewWriter(result.counter)
+		if err != nil {
+			_ = writerCloser.Close()
+			return nil, err
+		}
+		result.writer = codecWriter
+		result.flusher = codecWriter
 	} else {
-		writer := bufio.NewWriter(writerCloser)
+		writer := bufio.NewWriter(result.counter)
 		result.writer = writer
 		result.flusher = writer
+		result.buffer = writer
+	}
+	if config.Avro != nil {
+		if result.ocf, err = newOCF(result.writer, result.flusher, config.Avro); err != nil {
+			_ = writerCloser.Close()
+			return nil, err
+		}
+		result.writer = result.ocf
+		result.flusher = result.ocf
 	}
 	return result, nil
 }
@@ -309,7 +427,10 @@ func initRotation(result *writer, rotation *config.Rotation, created time.Time,
 		}
 	}
 	result.expiryTime = rotation.ExpiryTime(created)
+	result.aligned = rotation.IsAligned()
 	result.max = int32(rotation.MaxEntries)
+	result.maxBytes = rotation.MaxBytes
+	result.maxCompressed = rotation.MaxCompressedBytes
 	if rotation.Emit != nil {
 		result.emitter = emitter
 	}
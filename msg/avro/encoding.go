@@ -0,0 +1,172 @@
+package avro
+
+import (
+	"github.com/pkg/errors"
+	"github.com/viant/tapper/buffer"
+	"math"
+)
+
+//appendLong appends zigzag encoded variable length long
+func appendLong(bs *buffer.Bytes, value int64) {
+	v := uint64(value<<1) ^ uint64(value>>63)
+	for v >= 0x80 {
+		bs.AppendByte(byte(v) | 0x80)
+		v >>= 7
+	}
+	bs.AppendByte(byte(v))
+}
+
+func appendString(bs *buffer.Bytes, value string) {
+	appendLong(bs, int64(len(value)))
+	bs.AppendString(value)
+}
+
+func appendFloat(bs *buffer.Bytes, value float32) {
+	v := math.Float32bits(value)
+	for i := 0; i < 4; i++ {
+		bs.AppendByte(byte(v >> (8 * i)))
+	}
+}
+
+func appendDouble(bs *buffer.Bytes, value float64) {
+	v := math.Float64bits(value)
+	for i := 0; i < 8; i++ {
+		bs.AppendByte(byte(v >> (8 * i)))
+	}
+}
+
+func appendBoolean(bs *buffer.Bytes, value bool) {
+	if value {
+		bs.AppendByte(1)
+		return
+	}
+	bs.AppendByte(0)
+}
+
+//appendDefault appends JSON default value, union default uses the first union branch
+func appendDefault(bs *buffer.Bytes, schema *Schema, value interface{}) error {
+	switch schema.Type {
+	case TypeNull:
+		if value != nil {
+			return errors.Errorf("expected null, but had: %v", value)
+		}
+		return nil
+	case TypeBoolean:
+		actual, ok := value.(bool)
+		if !ok {
+			return errors.Errorf("expected boolean, but had: %v", value)
+		}
+		appendBoolean(bs, actual)
+		return nil
+	case TypeInt, TypeLong, TypeFloat, TypeDouble:
+		actual, ok := value.(float64)
+		if !ok {
+			return errors.Errorf("expected number, but had: %v", value)
+		}
+		switch schema.Type {
+		case TypeFloat:
+			appendFloat(bs, float32(actual))
+		case TypeDouble:
+			appendDouble(bs, actual)
+		default:
+			appendLong(bs, int64(actual))
+		}
+		return nil
+	case TypeString, TypeBytes:
+		actual, ok := value.(string)
+		if !ok {
+			return errors.Errorf("expected string, but had: %v", value)
+		}
+		appendString(bs, actual)
+		return nil
+	case TypeArray:
+		items, ok := value.([]interface{})
+		if !ok {
+			return errors.Errorf("expected array, but had: %v", value)
+		}
+		if len(items) > 0 {
+			appendLong(bs, int64(len(items)))
+			for _, item := range items {
+				if err := appendDefault(bs, schema.Items, item); err != nil {
+					return err
+				}
+			}
+		}
+		appendLong(bs, 0)
+		return nil
+	case TypeRecord:
+		fields, ok := value.(map[string]interface{})
+		if !ok {
+			return errors.Errorf("expected record, but had: %v", value)
+		}
+		for _, field := range schema.Fields {
+			item, ok := fields[field.Name]
+			if !ok {
+				if item, ok = field.Default, field.HasDefault; !ok {
+					return errors.Errorf("missing %v.%v default", schema.Name, field.Name)
+				}
+			}
+			if err := appendDefault(bs, field.Type, item); err != nil {
+				return err
+			}
+		}
+		return nil
+	case TypeUnion:
+		appendLong(bs, 0)
+		return appendDefault(bs, schema.Types[0], value)
+	}
+	return errors.Errorf("unsupported avro type: %v", schema.Type)
+}
+
+func isNull(schema *Schema) bool {
+	return schema.Type == TypeNull
+}
+
+//isTime returns true for string, long timestamp or int date schema
+func isTime(schema *Schema) bool {
+	return schema.Type == TypeString || schema.Type == TypeLong || (schema.Type == TypeInt && schema.LogicalType == "date")
+}
+
+func isRecord(schema *Schema) bool {
+	return schema.Type == TypeRecord
+}
+
+func isString(schema *Schema) bool {
+	return schema.Type == TypeString
+}
+
+func isBytes(schema *Schema) bool {
+	return schema.Type == TypeBytes
+}
+
+func isBoolean(schema *Schema) bool {
+	return schema.Type == TypeBoolean
+}
+
+func isFloat(schema *Schema) bool {
+	return schema.Type == TypeFloat || schema.Type == TypeDouble
+}
+
+func isNumeric(schema *Schema) bool {
+	return schema.Type == TypeInt || schema.Type == TypeLong || isFloat(schema)
+}
+
+func isRecordArray(schema *Schema) bool {
+	return schema.Type == TypeArray && isRecord(schema.Items)
+}
+
+func isStringArray(schema *Schema) bool {
+	return schema.Type == TypeArray && isString(schema.Items)
+}
+
+func isBooleanArray(schema *Schema) bool {
+	return schema.Type == TypeArray && isBoolean(schema.Items)
+}
+
+func isFloatArray(schema *Schema) bool {
+	return schema.Type == TypeArray && isFloat(schema.Items)
+}
+
+func isNumericArray(schema *Schema) bool {
+	return schema.Type == TypeArray && isNumeric(schema.Items)
+}
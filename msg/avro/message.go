@@ -0,0 +1,511 @@
+package avro
+
+import (
+	"github.com/pkg/errors"
+	"github.com/viant/tapper/buffer"
+	"github.com/viant/tapper/io"
+	"github.com/viant/tapper/msg"
+	iow "io"
+	"math"
+	"sync/atomic"
+	"time"
+)
+
+//Message represents Avro binary encoded record, Put calls are validated against the record schema,
+//fields can be put in any order, they are written in schema order once the message ends,
+//missing nullable fields are written as null, other missing fields use schema default
+type Message struct {
+	record
+	bs       *buffer.Bytes
+	provider *msg.Provider
+	borrowed int32
+	schema   *Schema
+	err      error
+	ended    bool
+}
+
+//record encodes record fields into scratch buffer, spans track encoded field values
+type record struct {
+	message  *Message
+	schema   *Schema
+	scratch  *buffer.Bytes
+	spans    []span
+	children []*record
+	current  int
+	start    int
+	text     []byte
+}
+
+type span struct {
+	start int
+	end   int
+	set   bool
+}
+
+func (r *record) reset(schema *Schema) {
+	r.schema = schema
+	if r.scratch == nil {
+		r.scratch = buffer.NewBytes(256)
+	}
+	r.scratch.Reset()
+	if cap(r.spans) < len(schema.Fields) {
+		r.spans = make([]span, len(schema.Fields))
+		r.children = make([]*record, len(schema.Fields))
+	}
+	r.spans = r.spans[:len(schema.Fields)]
+	for i := range r.spans {
+		r.spans[i] = span{}
+	}
+}
+
+func (r *record) child(index int) *record {
+	if r.children[index] == nil {
+		r.children[index] = &record{message: r.message}
+	}
+	return r.children[index]
+}
+
+func (r *record) fail(err error) {
+	if r.message.err == nil {
+		r.message.err = err
+	}
+}
+
+//open starts field value, it writes union branch index and returns value schema or nil if the field is invalid,
+//nil matcher opens raw field value including union branch index
+func (r *record) open(key string, matches func(schema *Schema) bool) *Schema {
+	if r.message.err != nil {
+		return nil
+	}
+	index := r.schema.fieldIndex(key)
+	if index == -1 {
+		r.fail(errors.Errorf("unknown avro field: %v.%v", r.schema.Name, key))
+		return nil
+	}
+	if r.spans[index].set {
+		r.fail(errors.Errorf("duplicate avro field: %v.%v", r.schema.Name, key))
+		return nil
+	}
+	r.current = index
+	r.start = r.scratch.Size()
+	fieldType := r.schema.Fields[index].Type
+	if matches == nil {
+		return fieldType
+	}
+	if fieldType.Type != TypeUnion {
+		if matches(fieldType) {
+			return fieldType
+		}
+	}
+	for i, branch := range fieldType.Types {
+		if matches(branch) {
+			appendLong(r.scratch, int64(i))
+			return branch
+		}
+	}
+	r.fail(errors.Errorf("incompatible avro field: %v.%v type", r.schema.Name, key))
+	return nil
+}
+
+//close ends field value opened with open
+func (r *record) close() {
+	r.spans[r.current] = span{start: r.start, end: r.scratch.Size(), set: true}
+}
+
+//assemble appends fields in schema order
+func (r *record) assemble(dst *buffer.Bytes) {
+	data := r.scratch.Bytes()
+	for i, field := range r.schema.Fields {
+		if item := r.spans[i]; item.set {
+			dst.AppendBytes(data[item.start:item.end])
+			continue
+		}
+		if field.Type.Type == TypeUnion {
+			if index := field.Type.nullIndex(); index != -1 {
+				appendLong(dst, int64(index))
+				continue
+			}
+		}
+		if !field.HasDefault {
+			r.fail(errors.Errorf("missing avro field: %v.%v", r.schema.Name, field.Name))
+			return
+		}
+		if err := appendDefault(dst, field.Type, field.Default); err != nil {
+			r.fail(errors.Wrapf(err, "invalid avro field %v.%v default", r.schema.Name, field.Name))
+			return
+		}
+	}
+}
+
+//Put raw bytes are not supported
+func (r *record) Put(bs []byte) {
+	r.fail(errors.New("raw bytes are not supported by avro message"))
+}
+
+//PutByte raw byte is not supported
+func (r *record) PutByte(b byte) {
+	r.fail(errors.New("raw bytes are not supported by avro message"))
+}
+
+//PutObject puts nested record
+func (r *record) PutObject(key string, object io.Encoder) {
+	schema := r.open(key, isRecord)
+	if schema == nil {
+		return
+	}
+	child := r.child(r.current)
+	child.reset(schema)
+	object.Encode(child)
+	child.assemble(r.scratch)
+	r.close()
+}
+
+//PutObjects puts array of records
+func (r *record) PutObjects(key string, objects []io.Encoder) {
+	schema := r.open(key, isRecordArray)
+	if schema == nil {
+		return
+	}
+	child := r.child(r.current)
+	if len(objects) > 0 {
+		appendLong(r.scratch, int64(len(objects)))
+		for _, object := range objects {
+			child.reset(schema.Items)
+			object.Encode(child)
+			child.assemble(r.scratch)
+		}
+	}
+	appendLong(r.scratch, 0)
+	r.close()
+}
+
+//PutString puts string value
+func (r *record) PutString(key, value string) {
+	if r.open(key, isString) == nil {
+		return
+	}
+	appendString(r.scratch, value)
+	r.close()
+}
+
+//PutNonEmptyString puts non empty string, empty string is written as null or field default
+func (r *record) PutNonEmptyString(key, value string) {
+	if len(value) == 0 {
+		return
+	}
+	r.PutString(key, value)
+}
+
+//PutB64EncodedBytes puts bytes value, avro bytes are binary, so value is not base64 encoded
+func (r *record) PutB64EncodedBytes(key string, bytes []byte) {
+	if r.open(key, isBytes) == nil {
+		return
+	}
+	appendLong(r.scratch, int64(len(bytes)))
+	r.scratch.AppendBytes(bytes)
+	r.close()
+}
+
+//PutStrings puts string array
+func (r *record) PutStrings(key string, values []string) {
+	if r.open(key, isStringArray) == nil {
+		return
+	}
+	if len(values) > 0 {
+		appendLong(r.scratch, int64(len(values)))
+		for _, value := range values {
+			appendString(r.scratch, value)
+		}
+	}
+	appendLong(r.scratch, 0)
+	r.close()
+}
+
+//PutInts puts numeric array
+func (r *record) PutInts(key string, values []int) {
+	schema := r.open(key, isNumericArray)
+	if schema == nil {
+		return
+	}
+	if len(values) > 0 {
+		appendLong(r.scratch, int64(len(values)))
+		for _, value := range values {
+			if !r.number(schema.Items, int64(value), float64(value), key) {
+				return
+			}
+		}
+	}
+	appendLong(r.scratch, 0)
+	r.close()
+}
+
+//PutUInts puts numeric array
+func (r *record) PutUInts(key string, values []uint64) {
+	schema := r.open(key, isNumericArray)
+	if schema == nil {
+		return
+	}
+	if len(values) > 0 {
+		appendLong(r.scratch, int64(len(values)))
+		for _, value := range values {
+			if value > math.MaxInt64 {
+				r.fail(errors.Errorf("avro field %v.%v value overflow: %v", r.schema.Name, key, value))
+				return
+			}
+			if !r.number(schema.Items, int64(value), float64(value), key) {
+				return
+			}
+		}
+	}
+	appendLong(r.scratch, 0)
+	r.close()
+}
+
+//PutInt puts numeric value
+func (r *record) PutInt(key string, value int) {
+	schema := r.open(key, isNumeric)
+	if schema == nil {
+		return
+	}
+	if r.number(schema, int64(value), float64(value), key) {
+		r.close()
+	}
+}
+
+//number appends integer or floating point value, it returns false if value overflows int schema
+func (r *record) number(schema *Schema, value int64, float float64, key string) bool {
+	switch schema.Type {
+	case TypeInt:
+		if value < math.MinInt32 || value > math.MaxInt32 {
+			r.fail(errors.Errorf("avro field %v.%v int value overflow: %v", r.schema.Name, key, value))
+			return false
+		}
+		appendLong(r.scratch, value)
+	case TypeLong:
+		appendLong(r.scratch, value)
+	case TypeFloat:
+		appendFloat(r.scratch, float32(float))
+	default:
+		appendDouble(r.scratch, float)
+	}
+	return true
+}
+
+//PutFloat puts float or double value
+func (r *record) PutFloat(key string, value float64) {
+	schema := r.open(key, isFloat)
+	if schema == nil {
+		return
+	}
+	r.number(schema, 0, value, key)
+	r.close()
+}
+
+//PutFloats puts float or double array
+func (r *record) PutFloats(key string, values []float64) {
+	schema := r.open(key, isFloatArray)
+	if schema == nil {
+		return
+	}
+	if len(values) > 0 {
+		appendLong(r.scratch, int64(len(values)))
+		for _, value := range values {
+			r.number(schema.Items, 0, value, key)
+		}
+	}
+	appendLong(r.scratch, 0)
+	r.close()
+}
+
+//PutBool puts boolean value
+func (r *record) PutBool(key string, value bool) {
+	if r.open(key, isBoolean) == nil {
+		return
+	}
+	appendBoolean(r.scratch, value)
+	r.close()
+}
+
+//PutBools puts boolean array
+func (r *record) PutBools(key string, values []bool) {
+	if r.open(key, isBooleanArray) == nil {
+		return
+	}
+	if len(values) > 0 {
+		appendLong(r.scratch, int64(len(values)))
+		for _, value := range values {
+			appendBoolean(r.scratch, value)
+		}
+	}
+	appendLong(r.scratch, 0)
+	r.close()
+}
+
+//PutInt64 puts numeric value
+func (r *record) PutInt64(key string, value int64) {
+	schema := r.open(key, isNumeric)
+	if schema == nil {
+		return
+	}
+	if r.number(schema, value, float64(value), key) {
+		r.close()
+	}
+}
+
+//PutUint64 puts numeric value
+func (r *record) PutUint64(key string, value uint64) {
+	schema := r.open(key, isNumeric)
+	if schema == nil {
+		return
+	}
+	if value > math.MaxInt64 {
+		r.fail(errors.Errorf("avro field %v.%v value overflow: %v", r.schema.Name, key, value))
+		return
+	}
+	if r.number(schema, int64(value), float64(value), key) {
+		r.close()
+	}
+}
+
+//PutFloat32 puts float or double value
+func (r *record) PutFloat32(key string, value float32) {
+	schema := r.open(key, isFloat)
+	if schema == nil {
+		return
+	}
+	r.number(schema, 0, float64(value), key)
+	r.close()
+}
+
+//PutTime puts time as string formatted with layout (RFC 3339 for empty layout), as long timestamp (milliseconds,
+//or microseconds/nanoseconds for timestamp-micros/timestamp-nanos logical type) or as int date (days since epoch)
+func (r *record) PutTime(key string, value time.Time, layout string) {
+	schema := r.open(key, isTime)
+	if schema == nil {
+		return
+	}
+	switch schema.Type {
+	case TypeString:
+		if layout == "" {
+			layout = time.RFC3339Nano
+		}
+		r.text = value.AppendFormat(r.text[:0], layout)
+		appendLong(r.scratch, int64(len(r.text)))
+		r.scratch.AppendBytes(r.text)
+	case TypeInt:
+		if !r.number(schema, floorDiv(value.Unix(), 86400), 0, key) {
+			return
+		}
+	default:
+		switch schema.LogicalType {
+		case "timestamp-micros", "local-timestamp-micros":
+			appendLong(r.scratch, floorDiv(value.UnixNano(), int64(time.Microsecond)))
+		case "timestamp-nanos", "local-timestamp-nanos":
+			appendLong(r.scratch, value.UnixNano())
+		default:
+			appendLong(r.scratch, floorDiv(value.UnixNano(), int64(time.Millisecond)))
+		}
+	}
+	r.close()
+}
+
+//floorDiv returns quotient rounded towards negative infinity, so that times before epoch are not rounded up
+func floorDiv(value, divisor int64) int64 {
+	result := value / divisor
+	if value < 0 && value%divisor != 0 {
+		result--
+	}
+	return result
+}
+
+//PutDuration puts duration as numeric nanoseconds
+func (r *record) PutDuration(key string, value time.Duration) {
+	r.PutInt64(key, int64(value))
+}
+
+//PutNull puts null value, field has to be nullable
+func (r *record) PutNull(key string) {
+	if r.open(key, isNull) == nil {
+		return
+	}
+	r.close()
+}
+
+//PutRaw puts Avro encoded field value, union value has to start with branch index
+func (r *record) PutRaw(key string, value []byte) {
+	if r.open(key, nil) == nil {
+		return
+	}
+	r.scratch.AppendBytes(value)
+	r.close()
+}
+
+//Begin begin message
+func (m *Message) Begin() {
+	m.err = nil
+	m.ended = false
+	m.record.reset(m.schema)
+}
+
+//End end message, it writes record fields in schema order
+func (m *Message) End() {
+	if m.ended {
+		return
+	}
+	m.ended = true
+	if m.err == nil {
+		m.record.assemble(m.bs)
+	}
+}
+
+//Err returns the first schema validation error
+func (m *Message) Err() error {
+	return m.err
+}
+
+//WriteTo writes avro binary encoded record to the writer, it returns schema validation error if any
+func (m *Message) WriteTo(w iow.Writer) (int64, error) {
+	m.End()
+	if m.err != nil {
+		return 0, m.err
+	}
+	return m.bs.WriteTo(w)
+}
+
+//Free returns bytes to the pool
+func (m *Message) Free() {
+	m.provider.Put(m)
+}
+
+func (m *Message) SetBorrowed() {
+	atomic.StoreInt32(&m.borrowed, 1)
+}
+
+func (m *Message) CompareAndSwap() bool {
+	return atomic.CompareAndSwapInt32(&m.borrowed, 1, 0)
+}
+
+func (m *Message) GetByteBuffer() *buffer.Bytes {
+	return m.bs
+}
+
+//SetSliceDelimiter not applicable to avro message
+func (m *Message) SetSliceDelimiter(delimiter string) {
+}
+
+//UseQuotes not applicable to avro message
+func (m *Message) UseQuotes(quote bool) {
+}
+
+//New returns message constructor for the record schema, use with msg.NewProvider(size, concurrency, avro.New(schema))
+func New(schema *Schema) func(provider *msg.Provider, bytes *buffer.Bytes) msg.Message {
+	return func(provider *msg.Provider, bytes *buffer.Bytes) msg.Message {
+		result := &Message{
+			bs:       bytes,
+			provider: provider,
+			schema:   schema,
+		}
+		result.record.message = result
+		return result
+	}
+}
@@ -0,0 +1,502 @@
+package avro
+
+import (
+	"bytes"
+	havro "github.com/hamba/avro"
+	"github.com/stretchr/testify/assert"
+	"github.com/viant/tapper/io"
+	"github.com/viant/tapper/io/encoder"
+	"github.com/viant/tapper/msg"
+	"math"
+	"net"
+	"testing"
+	"time"
+)
+
+const testSchema = `{
+	"type": "record",
+	"name": "Event",
+	"fields": [
+		{"name": "id", "type": "long"},
+		{"name": "count", "type": "int"},
+		{"name": "name", "type": "string", "default": "n/a"},
+		{"name": "note", "type": ["null", "string"]},
+		{"name": "score", "type": "double", "default": 0},
+		{"name": "ratio", "type": "float", "default": 0},
+		{"name": "active", "type": "boolean", "default": false},
+		{"name": "data", "type": "bytes", "default": ""},
+		{"name": "tags", "type": {"type": "array", "items": "string"}, "default": []},
+		{"name": "ids", "type": {"type": "array", "items": "long"}, "default": []},
+		{"name": "user", "type": ["null", {"type": "record", "name": "User", "fields": [
+			{"name": "id", "type": "long"},
+			{"name": "name", "type": "string", "default": ""}
+		]}]},
+		{"name": "users", "type": {"type": "array", "items": "User"}, "default": []}
+	]
+}`
+
+type testUser struct {
+	ID   int
+	Name string
+}
+
+func (u *testUser) Encode(stream io.Stream) {
+	stream.PutInt("id", u.ID)
+	stream.PutNonEmptyString("name", u.Name)
+}
+
+func TestMessage(t *testing.T) {
+	var useCases = []struct {
+		description string
+		put         func(message msg.Message)
+		expect      map[string]interface{}
+		expectError string
+	}{
+		{
+			description: "all fields in any order",
+			put: func(message msg.Message) {
+				message.PutStrings("tags", []string{"a", "b"})
+				message.PutInt("count", 3)
+				message.PutInt("id", -10)
+				message.PutString("name", "zażółć")
+				message.PutString("note", "x")
+				message.PutFloat("score", 1.5)
+				message.PutFloat("ratio", 0.25)
+				message.PutBool("active", true)
+				message.PutB64EncodedBytes("data", []byte{0, 1})
+				message.PutUInts("ids", []uint64{1, 1 << 40})
+				message.PutObject("user", &testUser{ID: 1, Name: "Bob"})
+				message.PutObjects("users", []io.Encoder{&testUser{ID: 2, Name: "a"}, &testUser{ID: 3}})
+			},
+			expect: map[string]interface{}{
+				"id":     int64(-10),
+				"count":  3,
+				"name":   "zażółć",
+				"note":   "x",
+				"score":  1.5,
+				"ratio":  float32(0.25),
+				"active": true,
+				"data":   []byte{0, 1},
+				"tags":   []interface{}{"a", "b"},
+				"ids":    []interface{}{int64(1), int64(1 << 40)},
+				"user":   map[string]interface{}{"User": map[string]interface{}{"id": int64(1), "name": "Bob"}}, //named union branch
+				"users": []interface{}{
+					map[string]interface{}{"id": int64(2), "name": "a"},
+					map[string]interface{}{"id": int64(3), "name": ""},
+				},
+			},
+		},
+		{
+			description: "defaults and nulls",
+			put: func(message msg.Message) {
+				message.PutInt("id", 1)
+				message.PutInt("count", 2)
+				message.PutNonEmptyString("name", "")
+			},
+			expect: map[string]interface{}{
+				"id":     int64(1),
+				"count":  2,
+				"name":   "n/a",
+				"note":   nil,
+				"score":  0.0,
+				"ratio":  float32(0),
+				"active": false,
+				"data":   []byte{},
+				"tags":   []interface{}{},
+				"ids":    []interface{}{},
+				"user":   nil,
+				"users":  []interface{}{},
+			},
+		},
+		{
+			description: "missing field without default",
+			put: func(message msg.Message) {
+				message.PutInt("id", 1)
+			},
+			expectError: "missing avro field: Event.count",
+		},
+		{
+			description: "unknown field",
+			put: func(message msg.Message) {
+				message.PutInt("id", 1)
+				message.PutInt("unknown", 1)
+			},
+			expectError: "unknown avro field: Event.unknown",
+		},
+		{
+			description: "incompatible type",
+			put: func(message msg.Message) {
+				message.PutString("id", "1")
+			},
+			expectError: "incompatible avro field: Event.id type",
+		},
+		{
+			description: "duplicate field",
+			put: func(message msg.Message) {
+				message.PutInt("id", 1)
+				message.PutInt("id", 2)
+			},
+			expectError: "duplicate avro field: Event.id",
+		},
+		{
+			description: "int overflow",
+			put: func(message msg.Message) {
+				message.PutInt("count", 1<<40)
+			},
+			expectError: "avro field Event.count int value overflow: 1099511627776",
+		},
+		{
+			description: "uint64 overflow",
+			put: func(message msg.Message) {
+				message.PutUint64("id", math.MaxUint64)
+			},
+			expectError: "avro field Event.id value overflow: 18446744073709551615",
+		},
+		{
+			description: "nested record error",
+			put: func(message msg.Message) {
+				message.PutObject("user", &testUser{Name: "x"})
+				message.PutFloat("user", 1)
+			},
+			expectError: "duplicate avro field: Event.user",
+		},
+		{
+			description: "raw bytes",
+			put: func(message msg.Message) {
+				message.Put([]byte("raw"))
+			},
+			expectError: "raw bytes are not supported by avro message",
+		},
+	}
+	schema, err := ParseSchema(testSchema)
+	if !assert.Nil(t, err) {
+		return
+	}
+	reference := havro.MustParse(testSchema)
+	provider := msg.NewProvider(16, 1, New(schema))
+	for _, useCase := range useCases {
+		message := provider.NewMessage()
+		useCase.put(message)
+		buffer := new(bytes.Buffer)
+		_, err := message.WriteTo(buffer)
+		message.Free()
+		if useCase.expectError != "" {
+			if assert.NotNil(t, err, useCase.description) {
+				assert.EqualValues(t, useCase.expectError, err.Error(), useCase.description)
+			}
+			assert.EqualValues(t, 0, buffer.Len(), useCase.description)
+			continue
+		}
+		if !assert.Nil(t, err, useCase.description) {
+			continue
+		}
+		var actual map[string]interface{}
+		assert.Nil(t, havro.Unmarshal(reference, buffer.Bytes(), &actual), useCase.description)
+		assert.EqualValues(t, useCase.expect, actual, useCase.description)
+	}
+}
+
+const testTypedSchema = `{
+	"type": "record",
+	"name": "Typed",
+	"fields": [
+		{"name": "id", "type": "long"},
+		{"name": "count", "type": "int"},
+		{"name": "size", "type": "long"},
+		{"name": "ratio", "type": "float"},
+		{"name": "score", "type": "double"},
+		{"name": "created", "type": {"type": "long", "logicalType": "timestamp-millis"}},
+		{"name": "updated", "type": {"type": "long", "logicalType": "timestamp-micros"}},
+		{"name": "day", "type": {"type": "int", "logicalType": "date"}},
+		{"name": "text", "type": "string"},
+		{"name": "elapsed", "type": "long"},
+		{"name": "note", "type": ["null", "string"]},
+		{"name": "raw", "type": "string"}
+	]
+}`
+
+func TestMessage_PutTyped(t *testing.T) {
+	schema, err := ParseSchema(testTypedSchema)
+	if !assert.Nil(t, err) {
+		return
+	}
+	at := time.Date(1969, 12, 31, 12, 0, 0, 123456000, time.UTC)
+	message := msg.NewProvider(16, 1, New(schema)).NewMessage()
+	message.PutInt64("id", -1)
+	message.PutInt64("count", 2)
+	message.PutUint64("size", math.MaxInt64)
+	message.PutFloat32("ratio", 0.5)
+	message.PutFloat32("score", 1.5)
+	message.PutTime("created", at, "")
+	message.PutTime("updated", at, "")
+	message.PutTime("day", at, "")
+	message.PutTime("text", at, time.RFC3339)
+	message.PutDuration("elapsed", time.Second)
+	message.PutNull("note")
+	message.PutRaw("raw", []byte{0x02, 'a'})
+	buffer := new(bytes.Buffer)
+	_, err = message.WriteTo(buffer)
+	if !assert.Nil(t, err) {
+		return
+	}
+	var actual map[string]interface{}
+	assert.Nil(t, havro.Unmarshal(havro.MustParse(testTypedSchema), buffer.Bytes(), &actual))
+	assert.EqualValues(t, map[string]interface{}{
+		"id":      int64(-1),
+		"count":   2,
+		"size":    int64(math.MaxInt64),
+		"ratio":   float32(0.5),
+		"score":   1.5,
+		"created": at.Truncate(time.Millisecond),
+		"updated": at,
+		"day":     time.Date(1969, 12, 31, 0, 0, 0, 0, time.UTC),
+		"text":    "1969-12-31T12:00:00Z",
+		"elapsed": int64(time.Second),
+		"note":    nil,
+		"raw":     "a",
+	}, actual)
+}
+
+type testEvent struct {
+	ID       int
+	Score    float64
+	Name     string
+	Active   bool
+	Expiry   *time.Time
+	Ratio    float32
+	Modified time.Time
+	IDs      []int
+	Tags     []string
+}
+
+func TestNewSchema(t *testing.T) {
+	provider, err := encoder.New(&testEvent{})
+	if !assert.Nil(t, err) {
+		return
+	}
+	schema, err := NewSchema(provider)
+	if !assert.Nil(t, err) {
+		return
+	}
+	reference, err := havro.Parse(schema.String())
+	if !assert.Nil(t, err, schema.String()) {
+		return
+	}
+	modified := time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC)
+	event := &testEvent{ID: 1, Score: 2.5, Active: true, Ratio: 0.5, Modified: modified, IDs: []int{1, 2}, Tags: []string{"a"}}
+	message := msg.NewProvider(16, 1, New(schema)).NewMessage()
+	provider.New(event).Encode(message)
+	buffer := new(bytes.Buffer)
+	_, err = message.WriteTo(buffer)
+	if !assert.Nil(t, err) {
+		return
+	}
+	var actual map[string]interface{}
+	assert.Nil(t, havro.Unmarshal(reference, buffer.Bytes(), &actual))
+	assert.EqualValues(t, map[string]interface{}{
+		"ID":       int64(1),
+		"Score":    2.5,
+		"Name":     "",
+		"Active":   true,
+		"Expiry":   nil,
+		"Ratio":    float32(0.5),
+		"Modified": modified.Format(time.RFC3339),
+		"IDs":      []interface{}{int64(1), int64(2)},
+		"Tags":     []interface{}{"a"},
+	}, actual)
+}
+
+func TestNewSchema_Tags(t *testing.T) {
+	type event struct {
+		ID      int       `tapper:"id"`
+		Count   int       `tapper:"count,omitempty"`
+		Code    int       `tapper:"code,string"`
+		Created time.Time `tapper:"created,format="`
+		Tags    []string  `tapper:"tags,omitempty"`
+	}
+	provider, err := encoder.New(&event{})
+	if !assert.Nil(t, err) {
+		return
+	}
+	schema, err := NewSchema(provider)
+	if !assert.Nil(t, err) {
+		return
+	}
+	reference, err := havro.Parse(schema.String())
+	if !assert.Nil(t, err, schema.String()) {
+		return
+	}
+	created := time.Date(2021, 1, 2, 3, 4, 5, 6000000, time.UTC)
+	message := msg.NewProvider(16, 1, New(schema)).NewMessage()
+	provider.New(&event{ID: 1, Code: 7, Created: created}).Encode(message)
+	buffer := new(bytes.Buffer)
+	_, err = message.WriteTo(buffer)
+	if !assert.Nil(t, err) {
+		return
+	}
+	var actual map[string]interface{}
+	assert.Nil(t, havro.Unmarshal(reference, buffer.Bytes(), &actual))
+	assert.EqualValues(t, map[string]interface{}{
+		"id":      int64(1),
+		"count":   int64(0),
+		"code":    "7",
+		"created": created,
+		"tags":    []interface{}{},
+	}, actual)
+}
+
+func TestNewSchema_Kinds(t *testing.T) {
+	type event struct {
+		Small  int16     `tapper:"small"`
+		Byte   uint8     `tapper:"byte"`
+		Count  uint32    `tapper:"count"`
+		Big    uint64    `tapper:"big"`
+		Scores []float32 `tapper:"scores"`
+		Flags  []bool    `tapper:"flags"`
+		IDs    []int32   `tapper:"ids"`
+		Data   []byte    `tapper:"data,omitempty"`
+		Addr   net.IP    `tapper:"addr"`
+	}
+	provider, err := encoder.New(&event{})
+	if !assert.Nil(t, err) {
+		return
+	}
+	schema, err := NewSchema(provider)
+	if !assert.Nil(t, err) {
+		return
+	}
+	reference, err := havro.Parse(schema.String())
+	if !assert.Nil(t, err, schema.String()) {
+		return
+	}
+	message := msg.NewProvider(16, 1, New(schema)).NewMessage()
+	provider.New(&event{Small: -2, Byte: 255, Count: 3, Big: 4, Scores: []float32{0.5}, Flags: []bool{true}, IDs: []int32{-1}, Data: []byte("hi"), Addr: net.IPv4(10, 0, 0, 1)}).Encode(message)
+	buffer := new(bytes.Buffer)
+	_, err = message.WriteTo(buffer)
+	if !assert.Nil(t, err) {
+		return
+	}
+	var actual map[string]interface{}
+	assert.Nil(t, havro.Unmarshal(reference, buffer.Bytes(), &actual))
+	assert.EqualValues(t, map[string]interface{}{
+		"small":  -2,
+		"byte":   255,
+		"count":  int64(3),
+		"big":    int64(4),
+		"scores": []interface{}{0.5},
+		"flags":  []interface{}{true},
+		"ids":    []interface{}{int64(-1)},
+		"data":   []byte("hi"),
+		"addr":   "10.0.0.1",
+	}, actual)
+
+	type unsupported struct {
+		Attrs map[string]string
+	}
+	provider, err = encoder.New(&unsupported{})
+	if !assert.Nil(t, err) {
+		return
+	}
+	_, err = NewSchema(provider)
+	assert.NotNil(t, err)
+}
+
+type testAddress struct {
+	City string `tapper:"city"`
+}
+
+type testNode struct {
+	Name string    `tapper:"name"`
+	Next *testNode `tapper:"next"`
+}
+
+func TestNewSchema_Nested(t *testing.T) {
+	type base struct {
+		ID int `tapper:"id"`
+	}
+	type order struct {
+		base
+		Home    testAddress    `tapper:"home"`
+		Billing *testAddress   `tapper:"billing"`
+		Items   []*testAddress `tapper:"items"`
+		Node    testNode       `tapper:"node"`
+	}
+	provider, err := encoder.New(&order{})
+	if !assert.Nil(t, err) {
+		return
+	}
+	schema, err := NewSchema(provider)
+	if !assert.Nil(t, err) {
+		return
+	}
+	reference, err := havro.Parse(schema.String())
+	if !assert.Nil(t, err, schema.String()) {
+		return
+	}
+	value := &order{base: base{ID: 1}, Home: testAddress{City: "NY"}, Items: []*testAddress{{City: "LA"}}, Node: testNode{Name: "a", Next: &testNode{Name: "b"}}}
+	message := msg.NewProvider(16, 1, New(schema)).NewMessage()
+	provider.New(value).Encode(message)
+	buffer := new(bytes.Buffer)
+	_, err = message.WriteTo(buffer)
+	if !assert.Nil(t, err) {
+		return
+	}
+	var actual map[string]interface{}
+	assert.Nil(t, havro.Unmarshal(reference, buffer.Bytes(), &actual))
+	assert.EqualValues(t, map[string]interface{}{
+		"id":      int64(1),
+		"home":    map[string]interface{}{"city": "NY"},
+		"billing": nil,
+		"items":   []interface{}{map[string]interface{}{"city": "LA"}},
+		"node": map[string]interface{}{
+			"name": "a",
+			"next": map[string]interface{}{"testNode": map[string]interface{}{"name": "b", "next": nil}},
+		},
+	}, actual)
+}
+
+func TestParseSchema(t *testing.T) {
+	var useCases = []struct {
+		description string
+		schema      string
+		hasError    bool
+	}{
+		{description: "valid", schema: testSchema},
+		{description: "logical type", schema: `{"type":"record","name":"A","fields":[{"name":"ts","type":{"type":"long","logicalType":"timestamp-millis"}}]}`},
+		{description: "invalid JSON", schema: `{`, hasError: true},
+		{description: "not a record", schema: `"string"`, hasError: true},
+		{description: "unsupported type", schema: `{"type":"record","name":"A","fields":[{"name":"a","type":"map"}]}`, hasError: true},
+		{description: "duplicate field", schema: `{"type":"record","name":"A","fields":[{"name":"a","type":"int"},{"name":"a","type":"int"}]}`, hasError: true},
+	}
+	for _, useCase := range useCases {
+		_, err := ParseSchema(useCase.schema)
+		assert.EqualValues(t, useCase.hasError, err != nil, useCase.description)
+	}
+}
+
+func TestMessage_Allocs(t *testing.T) {
+	schema, err := ParseSchema(testSchema)
+	if !assert.Nil(t, err) {
+		return
+	}
+	provider := msg.NewProvider(1024, 1, New(schema))
+	message := provider.NewMessage()
+	user := &testUser{ID: 1, Name: "a b"}
+	objects := []io.Encoder{user, user}
+	tags := []string{"a", "b"}
+	at := time.Now()
+	allocs := testing.AllocsPerRun(100, func() {
+		message.GetByteBuffer().Reset()
+		message.Begin()
+		message.PutInt("id", 1)
+		message.PutInt("count", 10)
+		message.PutString("note", "hello")
+		message.PutStrings("tags", tags)
+		message.PutObject("user", user)
+		message.PutObjects("users", objects)
+		message.PutTime("name", at, time.RFC3339Nano)
+		message.PutFloat32("ratio", 0.5)
+		message.End()
+	})
+	assert.EqualValues(t, 0, allocs)
+}
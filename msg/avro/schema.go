@@ -0,0 +1,347 @@
+package avro
+
+import (
+	"encoding/json"
+	"github.com/pkg/errors"
+	"github.com/viant/tapper/io/encoder"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+//Avro types
+const (
+	TypeNull    = "null"
+	TypeBoolean = "boolean"
+	TypeInt     = "int"
+	TypeLong    = "long"
+	TypeFloat   = "float"
+	TypeDouble  = "double"
+	TypeBytes   = "bytes"
+	TypeString  = "string"
+	TypeRecord  = "record"
+	TypeArray   = "array"
+	TypeUnion   = "union"
+)
+
+//Schema represents supported subset of Avro schema: primitives, records, arrays and unions
+type Schema struct {
+	Type        string
+	Name        string    //record name
+	LogicalType string    //optional logical type of primitive
+	Fields      []*Field  //record fields
+	Items       *Schema   //array items
+	Types       []*Schema //union branches
+	index       map[string]int
+	text        string
+}
+
+//Field represents record field
+type Field struct {
+	Name       string
+	Type       *Schema
+	Default    interface{}
+	HasDefault bool
+}
+
+//String returns schema JSON
+func (s *Schema) String() string {
+	return s.text
+}
+
+//fieldIndex returns record field index or -1
+func (s *Schema) fieldIndex(name string) int {
+	if index, ok := s.index[name]; ok {
+		return index
+	}
+	return -1
+}
+
+//nullIndex returns union null branch index or -1
+func (s *Schema) nullIndex() int {
+	for i, branch := range s.Types {
+		if branch.Type == TypeNull {
+			return i
+		}
+	}
+	return -1
+}
+
+//MarshalJSON returns schema JSON
+func (s *Schema) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.asMap(map[string]bool{}))
+}
+
+func (s *Schema) asMap(defined map[string]bool) interface{} {
+	switch s.Type {
+	case TypeRecord:
+		if defined[s.Name] {
+			return s.Name
+		}
+		defined[s.Name] = true
+		var fields = make([]interface{}, 0, len(s.Fields))
+		for _, field := range s.Fields {
+			item := map[string]interface{}{"name": field.Name, "type": field.Type.asMap(defined)}
+			if field.HasDefault {
+				item["default"] = field.Default
+			}
+			fields = append(fields, item)
+		}
+		return map[string]interface{}{"type": TypeRecord, "name": s.Name, "fields": fields}
+	case TypeArray:
+		return map[string]interface{}{"type": TypeArray, "items": s.Items.asMap(defined)}
+	case TypeUnion:
+		var types = make([]interface{}, 0, len(s.Types))
+		for _, branch := range s.Types {
+			types = append(types, branch.asMap(defined))
+		}
+		return types
+	}
+	if s.LogicalType != "" {
+		return map[string]interface{}{"type": s.Type, "logicalType": s.LogicalType}
+	}
+	return s.Type
+}
+
+func (s *Schema) init() error {
+	data, err := s.MarshalJSON()
+	if err != nil {
+		return err
+	}
+	s.text = string(data)
+	return nil
+}
+
+//ParseSchema parses Avro JSON schema, top level schema has to be a record
+func ParseSchema(text string) (*Schema, error) {
+	var value interface{}
+	if err := json.Unmarshal([]byte(text), &value); err != nil {
+		return nil, errors.Wrap(err, "invalid avro schema")
+	}
+	result, err := parse(value, map[string]*Schema{})
+	if err != nil {
+		return nil, err
+	}
+	if result.Type != TypeRecord {
+		return nil, errors.Errorf("expected avro record schema, but had: %v", result.Type)
+	}
+	result.text = text
+	return result, nil
+}
+
+func parse(value interface{}, named map[string]*Schema) (*Schema, error) {
+	switch actual := value.(type) {
+	case string:
+		switch actual {
+		case TypeNull, TypeBoolean, TypeInt, TypeLong, TypeFloat, TypeDouble, TypeBytes, TypeString:
+			return &Schema{Type: actual}, nil
+		}
+		if schema, ok := named[actual]; ok {
+			return schema, nil
+		}
+		return nil, errors.Errorf("unsupported avro type: %v", actual)
+	case []interface{}:
+		result := &Schema{Type: TypeUnion}
+		for _, item := range actual {
+			branch, err := parse(item, named)
+			if err != nil {
+				return nil, err
+			}
+			if branch.Type == TypeUnion {
+				return nil, errors.New("avro union can not contain union")
+			}
+			result.Types = append(result.Types, branch)
+		}
+		return result, nil
+	case map[string]interface{}:
+		typeName, _ := actual["type"].(string)
+		switch typeName {
+		case TypeRecord:
+			return parseRecord(actual, named)
+		case TypeArray:
+			items, err := parse(actual["items"], named)
+			if err != nil {
+				return nil, err
+			}
+			return &Schema{Type: TypeArray, Items: items}, nil
+		}
+		result, err := parse(actual["type"], named)
+		if err != nil {
+			return nil, err
+		}
+		if logicalType, ok := actual["logicalType"].(string); ok && result.Fields == nil && result.Type != TypeUnion {
+			copied := *result
+			copied.LogicalType = logicalType
+			result = &copied
+		}
+		return result, nil
+	}
+	return nil, errors.Errorf("invalid avro schema: %v", value)
+}
+
+func parseRecord(value map[string]interface{}, named map[string]*Schema) (*Schema, error) {
+	name, _ := value["name"].(string)
+	if name == "" {
+		return nil, errors.New("avro record name was empty")
+	}
+	fields, ok := value["fields"].([]interface{})
+	if !ok {
+		return nil, errors.Errorf("avro record %v fields were empty", name)
+	}
+	result := &Schema{Type: TypeRecord, Name: name, index: map[string]int{}}
+	named[name] = result
+	for _, item := range fields {
+		fieldValue, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, errors.Errorf("invalid avro record %v field: %v", name, item)
+		}
+		field := &Field{}
+		field.Name, _ = fieldValue["name"].(string)
+		if field.Name == "" {
+			return nil, errors.Errorf("avro record %v field name was empty", name)
+		}
+		var err error
+		if field.Type, err = parse(fieldValue["type"], named); err != nil {
+			return nil, errors.Wrapf(err, "invalid avro field %v.%v", name, field.Name)
+		}
+		field.Default, field.HasDefault = fieldValue["default"]
+		if err = result.add(field); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+func (s *Schema) add(field *Field) error {
+	if _, ok := s.index[field.Name]; ok {
+		return errors.Errorf("duplicate avro field %v.%v", s.Name, field.Name)
+	}
+	s.index[field.Name] = len(s.Fields)
+	s.Fields = append(s.Fields, field)
+	return nil
+}
+
+//NewSchema creates record schema for struct encoder provider fields, fields follow provider encoding order,
+//strings default to empty string and time pointers are nullable since empty and nil values are not encoded,
+//omitempty fields default to zero value, time fields with empty format are timestamp-millis longs,
+//nested structs are records, struct pointers nullable records and struct slices arrays of records, text marshaler and stringer fields are strings,
+//map and custom stream encoder fields are not supported
+func NewSchema(provider *encoder.Provider) (*Schema, error) {
+	result, err := newRecord(provider, map[*encoder.Provider]*Schema{}, map[string]bool{})
+	if err != nil {
+		return nil, err
+	}
+	return result, result.init()
+}
+
+//newRecord creates record schema, records are shared by providers so recursive types use named record reference
+func newRecord(provider *encoder.Provider, records map[*encoder.Provider]*Schema, names map[string]bool) (*Schema, error) {
+	if result, ok := records[provider]; ok {
+		return result, nil
+	}
+	name := provider.Type.Name()
+	if name == "" {
+		name = "Record"
+	}
+	for i, base := 1, name; names[name]; i++ { //different types with the same name
+		name = base + strconv.Itoa(i)
+	}
+	names[name] = true
+	result := &Schema{Type: TypeRecord, Name: name, index: map[string]int{}}
+	records[provider] = result
+	for _, structField := range provider.Fields {
+		field := &Field{Name: structField.Name}
+		var zero interface{}
+		switch structField.Marshaling {
+		case encoder.MarshalText, encoder.MarshalString:
+			field.Type = &Schema{Type: TypeString}
+			field.Default, field.HasDefault = "", true
+			if err := result.add(field); err != nil {
+				return nil, err
+			}
+			continue
+		case encoder.MarshalStream, encoder.MarshalObject:
+			return nil, errors.Errorf("unsupported avro field type: %v, custom encoder", structField.Type)
+		}
+		switch fieldType := structField.Type; fieldType.Kind() {
+		case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16:
+			field.Type, zero = &Schema{Type: TypeInt}, 0.0
+		case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint32, reflect.Uint64:
+			field.Type, zero = &Schema{Type: TypeLong}, 0.0
+		case reflect.Float64:
+			field.Type, zero = &Schema{Type: TypeDouble}, 0.0
+		case reflect.Float32:
+			field.Type, zero = &Schema{Type: TypeFloat}, 0.0
+		case reflect.String:
+			field.Type = &Schema{Type: TypeString}
+			field.Default, field.HasDefault = "", true
+		case reflect.Bool:
+			field.Type, zero = &Schema{Type: TypeBoolean}, false
+		case reflect.Slice:
+			field.Type, zero = &Schema{Type: TypeArray, Items: &Schema{Type: TypeLong}}, []interface{}{}
+			switch {
+			case structField.Nested != nil:
+				items, err := newRecord(structField.Nested, records, names)
+				if err != nil {
+					return nil, err
+				}
+				field.Type.Items = items
+			case fieldType.Elem().Kind() == reflect.String:
+				field.Type.Items = &Schema{Type: TypeString}
+			case fieldType.Elem().Kind() == reflect.Float64, fieldType.Elem().Kind() == reflect.Float32:
+				field.Type.Items = &Schema{Type: TypeDouble}
+			case fieldType.Elem().Kind() == reflect.Bool:
+				field.Type.Items = &Schema{Type: TypeBoolean}
+			case fieldType.Elem().Kind() == reflect.Uint8: //byte slice
+				field.Type, zero = &Schema{Type: TypeBytes}, ""
+			}
+		case reflect.Ptr:
+			branch := timeSchema(structField)
+			if structField.Nested != nil {
+				record, err := newRecord(structField.Nested, records, names)
+				if err != nil {
+					return nil, err
+				}
+				branch = record
+			}
+			field.Type = &Schema{Type: TypeUnion, Types: []*Schema{{Type: TypeNull}, branch}}
+			field.Default, field.HasDefault = nil, true
+		case reflect.Map:
+			return nil, errors.Errorf("unsupported avro field type: %v", fieldType)
+		default:
+			if structField.Nested != nil {
+				record, err := newRecord(structField.Nested, records, names)
+				if err != nil {
+					return nil, err
+				}
+				field.Type = record
+				break
+			}
+			if fieldType != reflect.TypeOf(time.Time{}) {
+				return nil, errors.Errorf("unsupported avro field type: %v", fieldType)
+			}
+			field.Type, zero = timeSchema(structField), 0.0
+			if field.Type.Type == TypeString {
+				zero = ""
+			}
+		}
+		if structField.AsString && field.Type.Type != TypeUnion && field.Type.Type != TypeRecord {
+			field.Type, zero = &Schema{Type: TypeString}, ""
+		}
+		if structField.OmitEmpty && !field.HasDefault && zero != nil {
+			field.Default, field.HasDefault = zero, true
+		}
+		if err := result.add(field); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+//timeSchema returns string schema for time field, or timestamp-millis long for empty format (message native time type)
+func timeSchema(field *encoder.Field) *Schema {
+	if field.Format == "" {
+		return &Schema{Type: TypeLong, LogicalType: "timestamp-millis"}
+	}
+	return &Schema{Type: TypeString}
+}
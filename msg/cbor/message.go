@@ -0,0 +1,343 @@
+package cbor
+
+import (
+	"github.com/viant/tapper/buffer"
+	"github.com/viant/tapper/io"
+	"github.com/viant/tapper/msg"
+	iow "io"
+	"math"
+	"sync/atomic"
+	"time"
+)
+
+//major types
+const (
+	majorUint   = 0
+	majorNegInt = 1
+	majorBytes  = 2
+	majorText   = 3
+	majorArray  = 4
+	majorTag    = 6
+)
+
+const (
+	indefiniteMap = 0xbf
+	breakCode     = 0xff
+	falseCode     = 0xf4
+	trueCode      = 0xf5
+	nullCode      = 0xf6
+	float32Code   = 0xfa
+	float64Code   = 0xfb
+
+	tagDateTime = 0 //RFC 3339 date/time text
+	tagEpoch    = 1 //epoch-based date/time number
+)
+
+//Message represents CBOR (RFC 8949) transaction message, each message is encoded as an indefinite-length map,
+//since the number of fields is unknown at Begin, consecutive messages form a CBOR sequence (RFC 8742)
+type Message struct {
+	bs       *buffer.Bytes
+	provider *msg.Provider
+	borrowed int32
+	depth    int
+	scratch  []byte
+}
+
+//Begin begin message
+func (m *Message) Begin() {
+	m.depth = 0
+	m.beginMap()
+}
+
+func (m *Message) beginMap() {
+	m.depth++
+	m.bs.AppendByte(indefiniteMap)
+}
+
+func (m *Message) endMap() {
+	m.depth--
+	m.bs.AppendByte(breakCode)
+}
+
+//End end message
+func (m *Message) End() {
+	for m.depth > 0 {
+		m.endMap()
+	}
+}
+
+//PutByte put raw byte
+func (m *Message) PutByte(b byte) {
+	m.bs.AppendByte(b)
+}
+
+//Put put raw bytes
+func (m *Message) Put(bs []byte) {
+	m.bs.AppendBytes(bs)
+}
+
+//head appends data item head with the smallest argument encoding
+func (m *Message) head(major byte, v uint64) {
+	major <<= 5
+	switch {
+	case v < 24:
+		m.bs.AppendByte(major | byte(v))
+	case v <= math.MaxUint8:
+		m.bs.AppendByte(major | 24)
+		m.bs.AppendByte(byte(v))
+	case v <= math.MaxUint16:
+		m.bs.AppendByte(major | 25)
+		m.uint16(v)
+	case v <= math.MaxUint32:
+		m.bs.AppendByte(major | 26)
+		m.uint32(v)
+	default:
+		m.bs.AppendByte(major | 27)
+		m.uint64(v)
+	}
+}
+
+func (m *Message) uint16(v uint64) {
+	m.bs.AppendByte(byte(v >> 8))
+	m.bs.AppendByte(byte(v))
+}
+
+func (m *Message) uint32(v uint64) {
+	m.bs.AppendByte(byte(v >> 24))
+	m.bs.AppendByte(byte(v >> 16))
+	m.uint16(v)
+}
+
+func (m *Message) uint64(v uint64) {
+	m.uint32(v >> 32)
+	m.uint32(v)
+}
+
+func (m *Message) text(value string) {
+	m.head(majorText, uint64(len(value)))
+	m.bs.AppendString(value)
+}
+
+func (m *Message) int(v int64) {
+	if v < 0 {
+		m.head(majorNegInt, uint64(-1-v))
+		return
+	}
+	m.head(majorUint, uint64(v))
+}
+
+func (m *Message) float(v float64) {
+	m.bs.AppendByte(float64Code)
+	m.uint64(math.Float64bits(v))
+}
+
+func (m *Message) bool(v bool) {
+	if v {
+		m.bs.AppendByte(trueCode)
+		return
+	}
+	m.bs.AppendByte(falseCode)
+}
+
+//PutB64EncodedBytes puts key and bytes as CBOR byte string, bytes are not base64 encoded
+func (m *Message) PutB64EncodedBytes(key string, bytes []byte) {
+	m.text(key)
+	m.head(majorBytes, uint64(len(bytes)))
+	m.bs.AppendBytes(bytes)
+}
+
+//PutObject puts key and object as nested indefinite-length map
+func (m *Message) PutObject(key string, object io.Encoder) {
+	m.text(key)
+	m.beginMap()
+	object.Encode(m)
+	m.endMap()
+}
+
+//PutObjects puts key and objects as array of indefinite-length maps
+func (m *Message) PutObjects(key string, objects []io.Encoder) {
+	m.text(key)
+	m.head(majorArray, uint64(len(objects)))
+	for _, object := range objects {
+		m.beginMap()
+		object.Encode(m)
+		m.endMap()
+	}
+}
+
+//PutNonEmptyString put key and non empty value
+func (m *Message) PutNonEmptyString(key, value string) {
+	if len(value) == 0 {
+		return
+	}
+	m.PutString(key, value)
+}
+
+//PutString put key and string value
+func (m *Message) PutString(key, value string) {
+	m.text(key)
+	m.text(value)
+}
+
+//PutStrings put key and string array
+func (m *Message) PutStrings(key string, values []string) {
+	m.text(key)
+	m.head(majorArray, uint64(len(values)))
+	for _, value := range values {
+		m.text(value)
+	}
+}
+
+//PutInts puts key and int array
+func (m *Message) PutInts(key string, values []int) {
+	m.text(key)
+	m.head(majorArray, uint64(len(values)))
+	for _, value := range values {
+		m.int(int64(value))
+	}
+}
+
+//PutUInts put key and uint array
+func (m *Message) PutUInts(key string, values []uint64) {
+	m.text(key)
+	m.head(majorArray, uint64(len(values)))
+	for _, value := range values {
+		m.head(majorUint, value)
+	}
+}
+
+//PutInt put key and int value
+func (m *Message) PutInt(key string, value int) {
+	m.text(key)
+	m.int(int64(value))
+}
+
+//PutFloat put key and float value
+func (m *Message) PutFloat(key string, value float64) {
+	m.text(key)
+	m.float(value)
+}
+
+//PutFloats put key and float array
+func (m *Message) PutFloats(key string, values []float64) {
+	m.text(key)
+	m.head(majorArray, uint64(len(values)))
+	for _, value := range values {
+		m.float(value)
+	}
+}
+
+//PutBool put key and bool value
+func (m *Message) PutBool(key string, value bool) {
+	m.text(key)
+	m.bool(value)
+}
+
+//PutBools put key and bool array
+func (m *Message) PutBools(key string, values []bool) {
+	m.text(key)
+	m.head(majorArray, uint64(len(values)))
+	for _, value := range values {
+		m.bool(value)
+	}
+}
+
+//PutInt64 put key and int64 value
+func (m *Message) PutInt64(key string, value int64) {
+	m.text(key)
+	m.int(value)
+}
+
+//PutUint64 put key and uint64 value
+func (m *Message) PutUint64(key string, value uint64) {
+	m.text(key)
+	m.head(majorUint, value)
+}
+
+//PutFloat32 put key and single precision float value
+func (m *Message) PutFloat32(key string, value float32) {
+	m.text(key)
+	m.bs.AppendByte(float32Code)
+	m.uint32(uint64(math.Float32bits(value)))
+}
+
+//PutTime put key and time formatted with layout, RFC 3339 layouts are tagged as standard date/time string (tag 0),
+//empty layout writes epoch-based date/time (tag 1) as integer or float seconds
+func (m *Message) PutTime(key string, value time.Time, layout string) {
+	m.text(key)
+	if layout == "" {
+		m.head(majorTag, tagEpoch)
+		if value.Nanosecond() == 0 {
+			m.int(value.Unix())
+			return
+		}
+		m.float(float64(value.UnixNano()) / float64(time.Second))
+		return
+	}
+	if layout == time.RFC3339 || layout == time.RFC3339Nano {
+		m.head(majorTag, tagDateTime)
+	}
+	m.scratch = value.AppendFormat(m.scratch[:0], layout)
+	m.head(majorText, uint64(len(m.scratch)))
+	m.bs.AppendBytes(m.scratch)
+}
+
+//PutDuration put key and duration as int64 nanoseconds
+func (m *Message) PutDuration(key string, value time.Duration) {
+	m.PutInt64(key, int64(value))
+}
+
+//PutNull put key and null value
+func (m *Message) PutNull(key string) {
+	m.text(key)
+	m.bs.AppendByte(nullCode)
+}
+
+//PutRaw put key and CBOR encoded data item, empty value is written as null
+func (m *Message) PutRaw(key string, value []byte) {
+	if len(value) == 0 {
+		m.PutNull(key)
+		return
+	}
+	m.text(key)
+	m.bs.AppendBytes(value)
+}
+
+//WriteTo writes message to the writer, messages are self delimiting, so no record separator is written
+func (m *Message) WriteTo(w iow.Writer) (int64, error) {
+	m.End()
+	return m.bs.WriteTo(w)
+}
+
+//Free returns bytes to the pool
+func (m *Message) Free() {
+	m.provider.Put(m)
+}
+
+func (m *Message) SetBorrowed() {
+	atomic.StoreInt32(&m.borrowed, 1)
+}
+
+func (m *Message) CompareAndSwap() bool {
+	return atomic.CompareAndSwapInt32(&m.borrowed, 1, 0)
+}
+
+func (m *Message) GetByteBuffer() *buffer.Bytes {
+	return m.bs
+}
+
+//SetSliceDelimiter not applicable to CBOR message
+func (m *Message) SetSliceDelimiter(delimiter string) {
+}
+
+//UseQuotes not applicable to CBOR message
+func (m *Message) UseQuotes(quote bool) {
+}
+
+//New creates CBOR message, use with msg.NewProvider(size, concurrency, cbor.New)
+func New(provider *msg.Provider, bytes *buffer.Bytes) msg.Message {
+	return &Message{
+		bs:       bytes,
+		provider: provider,
+	}
+}
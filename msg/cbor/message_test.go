@@ -0,0 +1,186 @@
+package cbor
+
+import (
+	"bytes"
+	"encoding/hex"
+	"github.com/fxamacker/cbor"
+	"github.com/stretchr/testify/assert"
+	"github.com/viant/tapper/io"
+	"github.com/viant/tapper/msg"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testUser struct {
+	ID   int
+	Name string
+}
+
+func (u *testUser) Encode(stream io.Stream) {
+	stream.PutInt("id", u.ID)
+	stream.PutString("name", u.Name)
+}
+
+//TestMessage checks encoding against RFC 8949 Appendix A examples
+func TestMessage(t *testing.T) {
+	var useCases = []struct {
+		description string
+		put         func(message msg.Message)
+		expect      string
+	}{
+		{
+			description: "empty",
+			put:         func(message msg.Message) {},
+			expect:      "bfff",
+		},
+		{
+			description: `{_ "Fun": true, "Amt": -2}`,
+			put: func(message msg.Message) {
+				message.PutBool("Fun", true)
+				message.PutInt("Amt", -2)
+			},
+			expect: "bf6346756ef563416d7421ff",
+		},
+		{
+			description: "integers",
+			put: func(message msg.Message) {
+				message.PutInts("i", []int{0, 23, 24, 1000, 1000000, -1, -1000})
+				message.PutUInts("u", []uint64{18446744073709551615})
+			},
+			expect: "bf6169" + "87" + "00" + "17" + "1818" + "1903e8" + "1a000f4240" + "20" + "3903e7" +
+				"6175" + "81" + "1bffffffffffffffff" + "ff",
+		},
+		{
+			description: "float, bool, text and bytes",
+			put: func(message msg.Message) {
+				message.PutFloats("f", []float64{1.1})
+				message.PutBools("b", []bool{false, true})
+				message.PutStrings("s", []string{"IETF", strings.Repeat("a", 24)})
+				message.PutNonEmptyString("e", "")
+				message.PutB64EncodedBytes("h", []byte{1, 2, 3, 4})
+			},
+			expect: "bf6166" + "81fb3ff199999999999a" + "6162" + "82f4f5" + "6173" + "82" + "6449455446" + "7818" + strings.Repeat("61", 24) +
+				"6168" + "4401020304" + "ff",
+		},
+		{
+			description: "typed primitives",
+			put: func(message msg.Message) {
+				message.PutInt64("a", -1)
+				message.PutUint64("b", math.MaxUint64)
+				message.PutFloat32("c", 1.5)
+				message.PutTime("d", time.Unix(1, 0), "")
+				message.PutNull("e")
+				message.PutRaw("f", []byte{0x01})
+				message.PutTime("g", time.Date(2013, 3, 21, 20, 4, 0, 0, time.UTC), time.RFC3339)
+			},
+			expect: "bf616120" + "61621bffffffffffffffff" + "6163fa3fc00000" + "6164c101" + "6165f6" + "616601" +
+				"6167c074" + "323031332d30332d32315432303a30343a30305a" + "ff",
+		},
+		{
+			description: "nested objects",
+			put: func(message msg.Message) {
+				message.PutObject("u", &testUser{ID: 1, Name: "a"})
+				message.PutObjects("l", []io.Encoder{&testUser{ID: 2}})
+			},
+			expect: "bf6175" + "bf626964" + "01" + "646e616d65" + "6161" + "ff" + "616c" + "81" + "bf626964" + "02" + "646e616d65" + "60" + "ff" + "ff",
+		},
+	}
+	provider := msg.NewProvider(16, 1, New)
+	for _, useCase := range useCases {
+		message := provider.NewMessage()
+		useCase.put(message)
+		buffer := new(bytes.Buffer)
+		_, err := message.WriteTo(buffer)
+		message.Free()
+		assert.Nil(t, err, useCase.description)
+		assert.EqualValues(t, useCase.expect, hex.EncodeToString(buffer.Bytes()), useCase.description)
+	}
+}
+
+func TestMessage_Decode(t *testing.T) {
+	provider := msg.NewProvider(16, 1, New)
+	buffer := new(bytes.Buffer)
+	for i := 0; i < 2; i++ {
+		message := provider.NewMessage()
+		message.PutString("s", "zażółć")
+		message.PutInt("i", -300-i)
+		message.PutUInts("u", []uint64{1 << 40})
+		message.PutFloats("f", []float64{0.5, -2})
+		message.PutB64EncodedBytes("b", []byte("bin"))
+		message.PutObject("u1", &testUser{ID: 1, Name: "a"})
+		message.PutObjects("u2", []io.Encoder{&testUser{ID: 2, Name: "b"}, &testUser{ID: 3, Name: "c"}})
+		_, err := message.WriteTo(buffer)
+		assert.Nil(t, err)
+		message.Free()
+	}
+	decoder := cbor.NewDecoder(buffer)
+	for i := 0; i < 2; i++ {
+		var record map[string]interface{}
+		assert.Nil(t, decoder.Decode(&record))
+		assert.EqualValues(t, map[string]interface{}{
+			"s":  "zażółć",
+			"i":  int64(-300 - i),
+			"u":  []interface{}{uint64(1 << 40)},
+			"f":  []interface{}{0.5, -2.0},
+			"b":  []byte("bin"),
+			"u1": map[interface{}]interface{}{"id": uint64(1), "name": "a"},
+			"u2": []interface{}{
+				map[interface{}]interface{}{"id": uint64(2), "name": "b"},
+				map[interface{}]interface{}{"id": uint64(3), "name": "c"},
+			},
+		}, record)
+	}
+	assert.EqualValues(t, 0, buffer.Len())
+}
+
+func TestMessage_PutTime(t *testing.T) {
+	var useCases = []struct {
+		description string
+		value       time.Time
+		layout      string
+	}{
+		{description: "epoch integer", value: time.Unix(1363896240, 0)},
+		{description: "epoch float", value: time.Unix(1363896240, 500000000)},
+		{description: "RFC 3339", value: time.Unix(1363896240, 0).UTC(), layout: time.RFC3339},
+		{description: "RFC 3339 nano", value: time.Unix(1363896240, 123456789).UTC(), layout: time.RFC3339Nano},
+	}
+	provider := msg.NewProvider(16, 1, New)
+	for _, useCase := range useCases {
+		message := provider.NewMessage()
+		message.PutTime("t", useCase.value, useCase.layout)
+		buffer := new(bytes.Buffer)
+		_, err := message.WriteTo(buffer)
+		message.Free()
+		assert.Nil(t, err, useCase.description)
+		var record struct {
+			T time.Time `cbor:"t"`
+		}
+		assert.Nil(t, cbor.Unmarshal(buffer.Bytes(), &record), useCase.description)
+		assert.True(t, useCase.value.Equal(record.T), useCase.description)
+	}
+}
+
+func TestMessage_Allocs(t *testing.T) {
+	provider := msg.NewProvider(1024, 1, New)
+	message := provider.NewMessage()
+	user := &testUser{ID: 1, Name: "a b"}
+	objects := []io.Encoder{user, user}
+	tags := []string{"a", "b"}
+	at := time.Now()
+	allocs := testing.AllocsPerRun(100, func() {
+		message.GetByteBuffer().Reset()
+		message.Begin()
+		message.PutString("msg", "hello")
+		message.PutInt("n", 10)
+		message.PutStrings("tags", tags)
+		message.PutObject("user", user)
+		message.PutObjects("users", objects)
+		message.PutUint64("u", math.MaxUint64)
+		message.PutTime("at", at, "")
+		message.PutTime("on", at, time.RFC3339Nano)
+		message.End()
+	})
+	assert.EqualValues(t, 0, allocs)
+}
@@ -2,30 +2,55 @@ package csv
 
 import (
 	"github.com/viant/tapper/buffer"
-	"github.com/viant/tapper/io"
 	"github.com/viant/tapper/msg"
-	"github.com/viant/toolbox"
 	iow "io"
-	"log"
+	"strings"
 	"sync/atomic"
+	"time"
 )
 
-//CsvMessage represents transaction CsvMessage
+//Message represents RFC 4180 CSV message, each message is a single record
 type Message struct {
 	bs             *buffer.Bytes
 	provider       *msg.Provider
 	borrowed       int32
+	fields         int
+	keys           []string
+	delimiter      byte
+	terminator     string
 	sliceDelimiter string
 	useQuote       bool
+	objects        string
+	prefix         string
+	joined         map[[2]string]string
+	json           msg.Message
+	exploder       *exploder
+	declared       map[string][]string
+	exploded       map[string][]string //declared columns joined with objects key
 }
 
 const (
 	defaultSliceDelimiter = ":"
+	defaultDelimiter      = ','
+	defaultTerminator     = "\n"
 )
 
+//Options represents CSV message options
+type Options struct {
+	Delimiter      byte   //field delimiter, comma by default, i.e. '\t' for TSV, '|'
+	Terminator     string //record terminator, "\n" by default, i.e. "\r\n"
+	SliceDelimiter string //slice item delimiter, ":" by default
+	UseQuotes      bool   //quote all text fields, otherwise only fields with delimiter, quote or new line are quoted
+	Objects        string //PutObjects strategy: json (default) or explode
+	//ObjectColumns declares exploded object fields by dotted objects key, i.e. {"items": {"id", "name"}}, field names are relative to object,
+	//i.e. encoder.Provider Columns() of the item type, explode strategy writes undeclared objects keys as JSON cell
+	ObjectColumns map[string][]string
+}
+
 //Begin begin CsvMessage
 func (m *Message) Begin() {
-	return
+	m.fields = 0
+	m.keys = m.keys[:0]
 }
 
 //PutByte put bytes
@@ -33,134 +58,243 @@ func (m *Message) PutByte(b byte) {
 	m.bs.AppendByte(b)
 }
 
-func (m *Message) quoted(key string) {
-	m.appendQuote()
-	m.bs.AppendString(key)
-	m.appendQuote()
+//field appends delimiter before every but first field
+func (m *Message) field(key string) {
+	m.column(m.join(m.prefix, key))
 }
 
-func (m *Message) next() {
-	m.bs.AppendString(",")
+//column appends delimiter before every but first field and records column name
+func (m *Message) column(name string) {
+	if m.fields > 0 {
+		m.bs.AppendByte(m.delimiter)
+	}
+	m.fields++
+	m.keys = append(m.keys, name)
 }
 
-//Put put bytes
-func (m *Message) Put(bs []byte) {
-	m.bs.AppendBytes(bs)
+//Columns returns keys of fields put to the message
+func (m *Message) Columns() []string {
+	return m.keys
 }
 
-//PutB64EncodedBytes puts ky and bas64 encoded values
-func (m *Message) PutB64EncodedBytes(key string, bytes []byte) {
-	log.Panic("PutB64EncodedBytes is not supported for CSV message")
+//AppendHeader appends header record with supplied columns
+func (m *Message) AppendHeader(bs *buffer.Bytes, columns []string) {
+	for i, column := range columns {
+		if i > 0 {
+			bs.AppendByte(m.delimiter)
+		}
+		m.appendText(bs, column, m.useQuote || m.needsQuotes(column))
+	}
+	bs.AppendString(m.terminator)
 }
 
-//PutObject not supported for csv message
-func (m *Message) PutObject(key string, object io.Encoder) {
-	log.Panic("PutObject is not supported for CSV message")
+func (m *Message) appendText(bs *buffer.Bytes, value string, quote bool) {
+	if !quote {
+		bs.AppendString(value)
+		return
+	}
+	bs.AppendByte('"')
+	escaped(bs, value)
+	bs.AppendByte('"')
 }
 
-//PutObjects put objects
-func (m *Message) PutObjects(key string, objects []io.Encoder) {
-	log.Panic("PutObjects is not supported for CSV message")
+//needsQuotes returns true if text value has to be quoted
+func (m *Message) needsQuotes(value string) bool {
+	for i := 0; i < len(value); i++ {
+		switch value[i] {
+		case '"', '\r', '\n', m.delimiter:
+			return true
+		}
+	}
+	return len(m.terminator) > 0 && strings.Contains(value, m.terminator)
 }
 
-//PutNonEmptyString put key and non empty value
-func (m *Message) PutNonEmptyString(key, value string) {
-	if len(value) == 0 {
-		return
+//escaped appends value with doubled quotes
+func escaped(bs *buffer.Bytes, value string) {
+	start := 0
+	for i := 0; i < len(value); i++ {
+		if value[i] == '"' {
+			bs.AppendString(value[start : i+1])
+			bs.AppendByte('"')
+			start = i + 1
+		}
 	}
+	bs.AppendString(value[start:])
+}
+
+//sliceNeedsQuotes returns true if slice delimiter collides with field delimiter
+func (m *Message) sliceNeedsQuotes() bool {
+	return m.needsQuotes(m.getSliceDelimiter())
+}
+
+//Put put bytes
+func (m *Message) Put(bs []byte) {
+	m.bs.AppendBytes(bs)
+}
+
+//PutNonEmptyString put key and non empty value, empty value is written as empty field to keep columns aligned
+func (m *Message) PutNonEmptyString(key, value string) {
 	m.PutString(key, value)
 }
 
 //PutString put key and string value
 func (m *Message) PutString(key, value string) {
-	m.quoted(value)
-	m.next()
-}
-
-func (m *Message) appendQuote() {
-	if m.useQuote {
-		m.bs.AppendByte('"')
-	}
+	m.field(key)
+	m.appendText(m.bs, value, m.useQuote || m.needsQuotes(value))
 }
 
 //PutStrings put key and string slice
 func (m *Message) PutStrings(key string, values []string) {
-	m.appendQuote()
+	m.field(key)
+	quote := m.useQuote || (len(values) > 1 && m.sliceNeedsQuotes())
+	for i := 0; i < len(values) && !quote; i++ {
+		quote = m.needsQuotes(values[i])
+	}
+	if quote {
+		m.bs.AppendByte('"')
+	}
 	for i, value := range values {
 		if i > 0 {
-			m.Put([]byte(value))
-			m.Put([]byte(m.getSliceDelimiter()))
+			m.bs.AppendString(m.getSliceDelimiter())
+		}
+		if quote {
+			escaped(m.bs, value)
+		} else {
+			m.bs.AppendString(value)
 		}
 	}
-	m.bs.Trim(m.getSliceDelimiter()[0])
-	m.appendQuote()
-	m.next()
+	if quote {
+		m.bs.AppendByte('"')
+	}
+}
+
+//beginSlice starts slice field, it returns true if field was quoted
+func (m *Message) beginSlice(key string, size int) bool {
+	m.field(key)
+	quote := size > 1 && m.sliceNeedsQuotes()
+	if quote {
+		m.bs.AppendByte('"')
+	}
+	return quote
+}
+
+func (m *Message) sliceItem(i int) {
+	if i > 0 {
+		m.bs.AppendString(m.getSliceDelimiter())
+	}
+}
+
+func (m *Message) endSlice(quoted bool) {
+	if quoted {
+		m.bs.AppendByte('"')
+	}
 }
 
 //PutInts puts key and int slice
 func (m *Message) PutInts(key string, values []int) {
+	quoted := m.beginSlice(key, len(values))
 	for i, value := range values {
-		if i > 0 {
-			m.Put([]byte(toolbox.AsString(value)))
-			m.Put([]byte(m.getSliceDelimiter()))
-		}
+		m.sliceItem(i)
+		m.bs.AppendInt(int64(value))
 	}
-	m.bs.Trim(m.getSliceDelimiter()[0])
-	m.next()
+	m.endSlice(quoted)
 }
 
 //PutUInts put key and uint slice
 func (m *Message) PutUInts(key string, values []uint64) {
+	quoted := m.beginSlice(key, len(values))
 	for i, value := range values {
-		if i > 0 {
-			m.Put([]byte(toolbox.AsString(value)))
-			m.Put([]byte(m.getSliceDelimiter()))
-		}
+		m.sliceItem(i)
+		m.bs.AppendUint(value)
 	}
-	m.bs.Trim(m.getSliceDelimiter()[0])
-	m.next()
+	m.endSlice(quoted)
 }
 
 //PutInt put key and int value
 func (m *Message) PutInt(key string, value int) {
+	m.field(key)
 	m.bs.AppendInt(int64(value))
-	m.next()
 }
 
 //PutFloat put key and float value
 func (m *Message) PutFloat(key string, value float64) {
+	m.field(key)
 	m.bs.AppendFloat(value, 64)
-	m.next()
-
 }
 
 //PutFloats put key and float slice
 func (m *Message) PutFloats(key string, values []float64) {
+	quoted := m.beginSlice(key, len(values))
 	for i, value := range values {
-		if i > 0 {
-			m.bs.AppendFloat(value, 64)
-			m.Put([]byte(m.getSliceDelimiter()))
-		}
+		m.sliceItem(i)
+		m.bs.AppendFloat(value, 64)
 	}
-	m.bs.Trim(m.getSliceDelimiter()[0])
-	m.next()
+	m.endSlice(quoted)
 }
 
 //PutBool put key and bool value
 func (m *Message) PutBool(key string, value bool) {
-	m.PutString(key, toolbox.AsString(value))
+	m.field(key)
+	m.bs.AppendBool(value)
 }
 
-//PutFloats put key and bool slice
+//PutBools put key and bool slice
 func (m *Message) PutBools(key string, values []bool) {
+	quoted := m.beginSlice(key, len(values))
 	for i, value := range values {
-		if i > 0 {
-			m.PutString(key, toolbox.AsString(value))
-			m.Put([]byte(m.getSliceDelimiter()))
-		}
+		m.sliceItem(i)
+		m.bs.AppendBool(value)
+	}
+	m.endSlice(quoted)
+}
+
+//PutInt64 put key and int64 value
+func (m *Message) PutInt64(key string, value int64) {
+	m.field(key)
+	m.bs.AppendInt(value)
+}
+
+//PutUint64 put key and uint64 value
+func (m *Message) PutUint64(key string, value uint64) {
+	m.field(key)
+	m.bs.AppendUint(value)
+}
+
+//PutFloat32 put key and float32 value
+func (m *Message) PutFloat32(key string, value float32) {
+	m.field(key)
+	m.bs.AppendFloat(float64(value), 32)
+}
+
+//PutTime put key and time formatted with layout (RFC 3339 with nanoseconds for empty layout), value is quoted if layout produces delimiter, quote or new line
+func (m *Message) PutTime(key string, value time.Time, layout string) {
+	m.field(key)
+	if layout == "" {
+		layout = time.RFC3339Nano
+	}
+	start := m.bs.Size()
+	m.bs.AppendTime(value, layout)
+	if formatted := m.bs.Bytes()[start:]; m.useQuote || m.needsQuotes(asString(formatted)) {
+		text := string(formatted)
+		m.bs.Truncate(start)
+		m.appendText(m.bs, text, true)
 	}
-	m.bs.Trim(m.getSliceDelimiter()[0])
-	m.next()
+}
+
+//PutDuration put key and duration as int64 nanoseconds
+func (m *Message) PutDuration(key string, value time.Duration) {
+	m.PutInt64(key, int64(value))
+}
+
+//PutNull put key and empty field
+func (m *Message) PutNull(key string) {
+	m.field(key)
+}
+
+//PutRaw put key and field written as is, value has to be already quoted if needed
+func (m *Message) PutRaw(key string, value []byte) {
+	m.field(key)
+	m.bs.AppendBytes(value)
 }
 
 //WriteTo writes CsvMessage to the writer
@@ -171,12 +305,11 @@ func (m *Message) WriteTo(w iow.Writer) (int64, error) {
 
 //End end CsvMessage
 func (m *Message) End() {
-	m.bs.Trim(',')
 }
 
 func (m *Message) end() {
 	m.End()
-	m.bs.AppendBytes([]byte("\n"))
+	m.bs.AppendString(m.terminator)
 }
 
 //Free returns bytes to the pool
@@ -208,14 +341,33 @@ func (m *Message) getSliceDelimiter() string {
 	return m.sliceDelimiter
 }
 
-//UseQuotes applicable to only csv
+//UseQuotes quotes all text fields when true, otherwise only fields that require quoting
 func (m *Message) UseQuotes(quote bool) {
 	m.useQuote = quote
 }
 
 func New(provider *msg.Provider, bytes *buffer.Bytes) msg.Message {
-	return &Message{
-		bs:       bytes,
-		provider: provider,
+	return NewWithOptions(Options{})(provider, bytes)
+}
+
+//NewWithOptions returns message constructor with supplied options, i.e. NewWithOptions(Options{Delimiter: '\t'}) for TSV
+func NewWithOptions(options Options) func(provider *msg.Provider, bytes *buffer.Bytes) msg.Message {
+	if options.Delimiter == 0 {
+		options.Delimiter = defaultDelimiter
+	}
+	if options.Terminator == "" {
+		options.Terminator = defaultTerminator
+	}
+	return func(provider *msg.Provider, bytes *buffer.Bytes) msg.Message {
+		return &Message{
+			bs:             bytes,
+			provider:       provider,
+			delimiter:      options.Delimiter,
+			terminator:     options.Terminator,
+			sliceDelimiter: options.SliceDelimiter,
+			useQuote:       options.UseQuotes,
+			objects:        options.Objects,
+			declared:       options.ObjectColumns,
+		}
 	}
 }
@@ -0,0 +1,413 @@
+package csv
+
+import (
+	"bytes"
+	stdcsv "encoding/csv"
+	"github.com/stretchr/testify/assert"
+	"github.com/viant/tapper/buffer"
+	"github.com/viant/tapper/io"
+	"github.com/viant/tapper/msg"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestMessage(t *testing.T) {
+	var useCases = []struct {
+		description string
+		options     Options
+		put         func(message msg.Message)
+		expect      string
+		expectCSV   []string //optional fields decoded by encoding/csv
+	}{
+		{
+			description: "Put raw bytes",
+			put: func(message msg.Message) {
+				message.Put([]byte("raw"))
+			},
+			expect: "raw\n",
+		},
+		{
+			description: "PutByte",
+			put: func(message msg.Message) {
+				message.PutByte('x')
+			},
+			expect: "x\n",
+		},
+		{
+			description: "PutString plain",
+			put: func(message msg.Message) {
+				message.PutString("k1", "abc")
+				message.PutString("k2", "def")
+			},
+			expect:    "abc,def\n",
+			expectCSV: []string{"abc", "def"},
+		},
+		{
+			description: "PutString escaping",
+			put: func(message msg.Message) {
+				message.PutString("k1", `say "hi"`)
+				message.PutString("k2", "a,b")
+				message.PutString("k3", "line1\nline2")
+				message.PutString("k4", "cr\r")
+				message.PutString("k5", "")
+			},
+			expect:    "\"say \"\"hi\"\"\",\"a,b\",\"line1\nline2\",\"cr\r\",\n",
+			expectCSV: []string{`say "hi"`, "a,b", "line1\nline2", "cr\r", ""},
+		},
+		{
+			description: "PutString always quoted",
+			options:     Options{UseQuotes: true},
+			put: func(message msg.Message) {
+				message.PutString("k1", "abc")
+				message.PutString("k2", `x"y`)
+				message.PutInt("k3", 1)
+			},
+			expect:    "\"abc\",\"x\"\"y\",1\n",
+			expectCSV: []string{"abc", `x"y`, "1"},
+		},
+		{
+			description: "PutNonEmptyString keeps column",
+			put: func(message msg.Message) {
+				message.PutNonEmptyString("k1", "")
+				message.PutNonEmptyString("k2", "v")
+			},
+			expect:    ",v\n",
+			expectCSV: []string{"", "v"},
+		},
+		{
+			description: "PutStrings",
+			put: func(message msg.Message) {
+				message.PutStrings("k1", []string{"a", "b", "c"})
+				message.PutStrings("k2", []string{"x,y", `"z"`})
+				message.PutStrings("k3", nil)
+			},
+			expect:    "a:b:c,\"x,y:\"\"z\"\"\",\n",
+			expectCSV: []string{"a:b:c", `x,y:"z"`, ""},
+		},
+		{
+			description: "PutInts",
+			put: func(message msg.Message) {
+				message.PutInts("k1", []int{1, -2, 3})
+				message.PutInts("k2", []int{4})
+			},
+			expect:    "1:-2:3,4\n",
+			expectCSV: []string{"1:-2:3", "4"},
+		},
+		{
+			description: "PutUInts",
+			put: func(message msg.Message) {
+				message.PutUInts("k1", []uint64{1, 18446744073709551615})
+			},
+			expect:    "1:18446744073709551615\n",
+			expectCSV: []string{"1:18446744073709551615"},
+		},
+		{
+			description: "PutInt",
+			put: func(message msg.Message) {
+				message.PutInt("k1", 10)
+				message.PutInt("k2", -3)
+			},
+			expect:    "10,-3\n",
+			expectCSV: []string{"10", "-3"},
+		},
+		{
+			description: "PutFloat",
+			put: func(message msg.Message) {
+				message.PutFloat("k1", 1.25)
+			},
+			expect:    "1.25\n",
+			expectCSV: []string{"1.25"},
+		},
+		{
+			description: "PutFloats",
+			put: func(message msg.Message) {
+				message.PutFloats("k1", []float64{1.5, 2})
+			},
+			expect:    "1.5:2\n",
+			expectCSV: []string{"1.5:2"},
+		},
+		{
+			description: "PutBool",
+			put: func(message msg.Message) {
+				message.PutBool("k1", true)
+				message.PutBool("k2", false)
+			},
+			expect:    "true,false\n",
+			expectCSV: []string{"true", "false"},
+		},
+		{
+			description: "PutBools",
+			put: func(message msg.Message) {
+				message.PutBools("k1", []bool{true, false, true})
+				message.PutString("k2", "x")
+			},
+			expect:    "true:false:true,x\n",
+			expectCSV: []string{"true:false:true", "x"},
+		},
+		{
+			description: "TSV delimiter",
+			options:     Options{Delimiter: '\t'},
+			put: func(message msg.Message) {
+				message.PutString("k1", "a,b")
+				message.PutString("k2", "c\td")
+				message.PutInt("k3", 1)
+			},
+			expect: "a,b\t\"c\td\"\t1\n",
+		},
+		{
+			description: "pipe delimiter with CRLF terminator",
+			options:     Options{Delimiter: '|', Terminator: "\r\n"},
+			put: func(message msg.Message) {
+				message.PutString("k1", "a|b")
+				message.PutInts("k2", []int{1, 2})
+			},
+			expect: "\"a|b\"|1:2\r\n",
+		},
+		{
+			description: "slice delimiter colliding with field delimiter",
+			options:     Options{SliceDelimiter: ","},
+			put: func(message msg.Message) {
+				message.PutInts("k1", []int{1, 2})
+				message.PutStrings("k2", []string{"a", "b"})
+				message.PutBools("k3", []bool{true})
+			},
+			expect:    "\"1,2\",\"a,b\",true\n",
+			expectCSV: []string{"1,2", "a,b", "true"},
+		},
+		{
+			description: "typed primitives",
+			put: func(message msg.Message) {
+				message.PutInt64("k1", math.MinInt64)
+				message.PutUint64("k2", math.MaxUint64)
+				message.PutFloat32("k3", 0.1)
+				message.PutTime("k4", time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC), time.RFC3339)
+				message.PutTime("k5", time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC), "Jan 2, 2006")
+				message.PutDuration("k6", time.Second)
+				message.PutNull("k7")
+				message.PutRaw("k8", []byte(`"a,b"`))
+			},
+			expect:    "-9223372036854775808,18446744073709551615,0.1,2021-03-04T05:06:07Z,\"Mar 4, 2021\",1000000000,,\"a,b\"\n",
+			expectCSV: []string{"-9223372036854775808", "18446744073709551615", "0.1", "2021-03-04T05:06:07Z", "Mar 4, 2021", "1000000000", "", "a,b"},
+		},
+	}
+
+	for _, useCase := range useCases {
+		provider := msg.NewProvider(16, 1, NewWithOptions(useCase.options))
+		message := provider.NewMessage()
+		useCase.put(message)
+		buffer := new(bytes.Buffer)
+		_, err := message.WriteTo(buffer)
+		message.Free()
+		assert.Nil(t, err, useCase.description)
+		assert.EqualValues(t, useCase.expect, buffer.String(), useCase.description)
+		if useCase.expectCSV == nil {
+			continue
+		}
+		reader := stdcsv.NewReader(buffer)
+		record, err := reader.Read()
+		if !assert.Nil(t, err, useCase.description) {
+			continue
+		}
+		assert.EqualValues(t, useCase.expectCSV, record, useCase.description)
+	}
+}
+
+func TestMessage_Reuse(t *testing.T) {
+	provider := msg.NewProvider(16, 1, New)
+	for i := 0; i < 2; i++ {
+		message := provider.NewMessage()
+		message.PutInt("k1", i)
+		message.PutString("k2", "v")
+		buffer := new(bytes.Buffer)
+		_, err := message.WriteTo(buffer)
+		message.Free()
+		assert.Nil(t, err)
+		assert.EqualValues(t, []string{"0,v\n", "1,v\n"}[i], buffer.String())
+	}
+}
+
+type testUser struct {
+	ID   int
+	Name string
+	Tags []string
+}
+
+func (u *testUser) Encode(stream io.Stream) {
+	stream.PutInt("id", u.ID)
+	stream.PutString("name", u.Name)
+	stream.PutStrings("tags", u.Tags)
+}
+
+type testOrder struct {
+	ID    int
+	User  *testUser
+	Items []io.Encoder
+}
+
+func (o *testOrder) Encode(stream io.Stream) {
+	stream.PutInt("id", o.ID)
+	stream.PutObject("user", o.User)
+	stream.PutObjects("items", o.Items)
+}
+
+func TestMessage_Objects(t *testing.T) {
+	order := &testOrder{
+		ID:   1,
+		User: &testUser{ID: 10, Name: "Bob", Tags: []string{"a", "b"}},
+		Items: []io.Encoder{
+			&testUser{ID: 100, Name: "x,y"},
+			&testUser{ID: 200, Name: "z", Tags: []string{"t"}},
+		},
+	}
+	var useCases = []struct {
+		description   string
+		options       Options
+		put           func(message msg.Message)
+		expect        string
+		expectColumns []string
+	}{
+		{
+			description: "nested object as dotted columns",
+			put: func(message msg.Message) {
+				message.PutObject("user", order.User)
+				message.PutInt("n", 1)
+			},
+			expect:        "10,Bob,a:b,1\n",
+			expectColumns: []string{"user.id", "user.name", "user.tags", "n"},
+		},
+		{
+			description: "objects as JSON cell",
+			put: func(message msg.Message) {
+				message.PutObject("order", order)
+			},
+			expect:        "1,10,Bob,a:b,\"[{\"\"id\"\":100,\"\"name\"\":\"\"x,y\"\",\"\"tags\"\":[]},{\"\"id\"\":200,\"\"name\"\":\"\"z\"\",\"\"tags\"\":[\"\"t\"\"]}]\"\n",
+			expectColumns: []string{"order.id", "order.user.id", "order.user.name", "order.user.tags", "order.items"},
+		},
+		{
+			description: "exploded objects",
+			options:     Options{Objects: ObjectsExplode, ObjectColumns: map[string][]string{"order.items": {"id", "name", "tags"}}},
+			put: func(message msg.Message) {
+				message.PutObject("order", order)
+			},
+			expect:        "1,10,Bob,a:b,100:200,\"x,y:z\",:t\n",
+			expectColumns: []string{"order.id", "order.user.id", "order.user.name", "order.user.tags", "order.items.id", "order.items.name", "order.items.tags"},
+		},
+		{
+			description: "base64 bytes",
+			put: func(message msg.Message) {
+				message.PutB64EncodedBytes("data", []byte("hello"))
+				message.PutB64EncodedBytes("empty", nil)
+			},
+			expect:        "aGVsbG8=,\n",
+			expectColumns: []string{"data", "empty"},
+		},
+	}
+	for _, useCase := range useCases {
+		provider := msg.NewProvider(16, 1, NewWithOptions(useCase.options))
+		for i := 0; i < 2; i++ { //second iteration reuses message
+			message := provider.NewMessage()
+			useCase.put(message)
+			assert.EqualValues(t, useCase.expectColumns, message.(msg.Header).Columns(), useCase.description)
+			buffer := new(bytes.Buffer)
+			_, err := message.WriteTo(buffer)
+			message.Free()
+			assert.Nil(t, err, useCase.description)
+			assert.EqualValues(t, useCase.expect, buffer.String(), useCase.description)
+			_, err = stdcsv.NewReader(buffer).Read()
+			assert.Nil(t, err, useCase.description)
+		}
+	}
+}
+
+type testItem struct {
+	A string
+	B string
+	C string
+}
+
+func (i *testItem) Encode(stream io.Stream) {
+	if i.A != "" {
+		stream.PutString("a", i.A)
+	}
+	if i.B != "" {
+		stream.PutString("b", i.B)
+	}
+	if i.C != "" {
+		stream.PutString("c", i.C)
+	}
+}
+
+func TestMessage_Objects_Explode(t *testing.T) {
+	var testCases = []struct {
+		description   string
+		items         []io.Encoder
+		expect        string
+		expectColumns []string
+	}{
+		{
+			description:   "empty slice",
+			expect:        ",,1\n",
+			expectColumns: []string{"items.a", "items.b", "n"},
+		},
+		{
+			description:   "item without first field",
+			items:         []io.Encoder{&testItem{A: "A0", B: "B0"}, &testItem{B: "B1"}},
+			expect:        "A0:,B0:B1,1\n",
+			expectColumns: []string{"items.a", "items.b", "n"},
+		},
+		{
+			description:   "item without last field",
+			items:         []io.Encoder{&testItem{A: "A0", B: "B0"}, &testItem{A: "A1"}, &testItem{B: "B2"}},
+			expect:        "A0:A1:,B0::B2,1\n",
+			expectColumns: []string{"items.a", "items.b", "n"},
+		},
+		{
+			description:   "first item without fields",
+			items:         []io.Encoder{&testItem{}, &testItem{B: "B1"}},
+			expect:        ":,:B1,1\n",
+			expectColumns: []string{"items.a", "items.b", "n"},
+		},
+		{
+			description:   "undeclared field",
+			items:         []io.Encoder{&testItem{A: "A0", C: "C0"}},
+			expect:        "A0,,1\n",
+			expectColumns: []string{"items.a", "items.b", "n"},
+		},
+	}
+	provider := msg.NewProvider(16, 1, NewWithOptions(Options{Objects: ObjectsExplode, ObjectColumns: map[string][]string{"items": {"a", "b"}}}))
+	for _, testCase := range testCases {
+		message := provider.NewMessage()
+		message.PutObjects("items", testCase.items)
+		message.PutInt("n", 1)
+		assert.EqualValues(t, testCase.expectColumns, message.(msg.Header).Columns(), testCase.description)
+		buffer := new(bytes.Buffer)
+		_, err := message.WriteTo(buffer)
+		message.Free()
+		assert.Nil(t, err, testCase.description)
+		assert.EqualValues(t, testCase.expect, buffer.String(), testCase.description)
+	}
+	message := provider.NewMessage()
+	message.PutObjects("other", []io.Encoder{&testItem{A: "A0"}})
+	assert.EqualValues(t, []string{"other"}, message.(msg.Header).Columns(), "undeclared key is written as JSON cell")
+	message.Free()
+}
+
+func TestMessage_AppendHeader(t *testing.T) {
+	provider := msg.NewProvider(16, 1, NewWithOptions(Options{Delimiter: '|'}))
+	message := provider.NewMessage()
+	message.PutInt("id", 1)
+	message.PutStrings("a|b", []string{"x"})
+	message.PutBool("flag", true)
+	source, ok := message.(msg.Header)
+	if !assert.True(t, ok) {
+		return
+	}
+	assert.EqualValues(t, []string{"id", "a|b", "flag"}, source.Columns())
+	bs := buffer.NewBytes(16)
+	source.AppendHeader(bs, source.Columns())
+	assert.EqualValues(t, "id|\"a|b\"|flag\n", string(bs.Bytes()))
+	message.Free()
+	message = provider.NewMessage()
+	assert.EqualValues(t, 0, len(message.(msg.Header).Columns()), "columns are reset on reuse")
+}
@@ -0,0 +1,313 @@
+package csv
+
+import (
+	"github.com/viant/tapper/buffer"
+	"github.com/viant/tapper/io"
+	"github.com/viant/tapper/msg/json"
+	"github.com/viant/xunsafe"
+	"time"
+	"unsafe"
+)
+
+const (
+	//ObjectsJSON writes PutObjects slice as a single JSON array cell
+	ObjectsJSON = "json"
+	//ObjectsExplode writes every declared object field as a dotted column, values of consecutive objects are joined with slice delimiter,
+	//columns come from Options.ObjectColumns, so every message writes the same columns regardless of slice content;
+	//objects without a declared field leave an empty item, undeclared fields are skipped and undeclared keys are written as JSON cell
+	ObjectsExplode = "explode"
+)
+
+//PutObject puts object fields as dotted columns, i.e. user.id
+func (m *Message) PutObject(key string, object io.Encoder) {
+	prefix := m.prefix
+	m.prefix = m.join(prefix, key)
+	object.Encode(m)
+	m.prefix = prefix
+}
+
+//PutObjects puts objects as JSON cell or exploded columns, depending on the message objects strategy
+func (m *Message) PutObjects(key string, objects []io.Encoder) {
+	name := m.join(m.prefix, key)
+	columns := m.objectColumns(name)
+	if columns == nil {
+		m.field(key)
+		data := m.encodeJSON(objects)
+		m.appendText(m.bs, asString(data), true)
+		return
+	}
+	exploder := m.getExploder()
+	exploder.explode(name, columns, objects)
+	for i, column := range columns {
+		m.column(column)
+		value := asString(exploder.values[i].Bytes())
+		m.appendText(m.bs, value, m.useQuote || m.needsQuotes(value))
+	}
+}
+
+//objectColumns returns declared exploded dotted columns of the objects key or nil if objects are written as JSON cell
+func (m *Message) objectColumns(key string) []string {
+	if m.objects != ObjectsExplode {
+		return nil
+	}
+	result, ok := m.exploded[key]
+	if ok {
+		return result
+	}
+	if fields, declared := m.declared[key]; declared {
+		result = make([]string, len(fields))
+		for i, field := range fields {
+			result[i] = m.join(key, field)
+		}
+	}
+	if m.exploded == nil {
+		m.exploded = map[string][]string{}
+	}
+	m.exploded[key] = result
+	return result
+}
+
+//PutB64EncodedBytes puts base64 encoded bytes cell
+func (m *Message) PutB64EncodedBytes(key string, bytes []byte) {
+	m.field(key)
+	m.bs.AppendBase64(bytes)
+}
+
+//join returns dotted column name, joined names are cached to avoid allocation
+func (m *Message) join(prefix, key string) string {
+	if prefix == "" {
+		return key
+	}
+	if m.joined == nil {
+		m.joined = map[[2]string]string{}
+	}
+	pair := [2]string{prefix, key}
+	result, ok := m.joined[pair]
+	if !ok {
+		result = prefix + "." + key
+		m.joined[pair] = result
+	}
+	return result
+}
+
+//encodeJSON returns objects encoded as JSON array, returned bytes are valid till the next call
+func (m *Message) encodeJSON(objects []io.Encoder) []byte {
+	if m.json == nil {
+		m.json = json.New(nil, buffer.NewBytes(256))
+	}
+	bs := m.json.GetByteBuffer()
+	bs.Reset()
+	bs.AppendByte('[')
+	for i, object := range objects {
+		if i > 0 {
+			bs.AppendByte(',')
+		}
+		m.json.Begin()
+		object.Encode(m.json)
+		m.json.End()
+	}
+	bs.AppendByte(']')
+	return bs.Bytes()
+}
+
+func (m *Message) getExploder() *exploder {
+	if m.exploder == nil {
+		m.exploder = &exploder{message: m}
+	}
+	return m.exploder
+}
+
+//exploder collects declared object fields as columns, values of consecutive objects are joined with slice delimiter
+type exploder struct {
+	message *Message
+	prefix  string
+	object  int
+	current *buffer.Bytes //value of the last put field
+	columns []string
+	values  []*buffer.Bytes
+	items   []int //number of slice delimiters in column value
+	discard *buffer.Bytes
+}
+
+func (e *exploder) explode(key string, columns []string, objects []io.Encoder) {
+	e.columns = columns
+	for len(e.values) < len(columns) {
+		e.values = append(e.values, buffer.NewBytes(64))
+		e.items = append(e.items, 0)
+	}
+	for i := range columns {
+		e.values[i].Reset()
+		e.items[i] = 0
+	}
+	for i, object := range objects {
+		e.prefix = key
+		e.object = i
+		object.Encode(e)
+	}
+	for i := range columns { //column missing in trailing objects
+		e.pad(i, len(objects)-1)
+	}
+}
+
+//value returns buffer of the column value of the current object, undeclared column value is discarded
+func (e *exploder) value(key string) *buffer.Bytes {
+	name := e.message.join(e.prefix, key)
+	for i, column := range e.columns {
+		if column == name {
+			e.pad(i, e.object)
+			e.current = e.values[i]
+			return e.current
+		}
+	}
+	if e.discard == nil {
+		e.discard = buffer.NewBytes(64)
+	}
+	e.discard.Reset()
+	e.current = e.discard
+	return e.current
+}
+
+//pad appends slice delimiters to the column value till it holds the object item, missing items are left empty
+func (e *exploder) pad(column, object int) {
+	for ; e.items[column] < object; e.items[column]++ {
+		e.values[column].AppendString(e.message.getSliceDelimiter())
+	}
+}
+
+//Put appends raw bytes to the last column value
+func (e *exploder) Put(bs []byte) {
+	if e.current != nil {
+		e.current.AppendBytes(bs)
+	}
+}
+
+//PutByte appends raw byte to the last column value
+func (e *exploder) PutByte(b byte) {
+	if e.current != nil {
+		e.current.AppendByte(b)
+	}
+}
+
+//PutObject puts nested object fields as dotted columns
+func (e *exploder) PutObject(key string, object io.Encoder) {
+	prefix := e.prefix
+	e.prefix = e.message.join(prefix, key)
+	object.Encode(e)
+	e.prefix = prefix
+}
+
+//PutObjects puts nested objects as JSON array
+func (e *exploder) PutObjects(key string, objects []io.Encoder) {
+	e.value(key).AppendBytes(e.message.encodeJSON(objects))
+}
+
+func (e *exploder) PutString(key, value string) {
+	e.value(key).AppendString(value)
+}
+
+func (e *exploder) PutNonEmptyString(key, value string) {
+	e.PutString(key, value)
+}
+
+func (e *exploder) PutB64EncodedBytes(key string, bytes []byte) {
+	e.value(key).AppendBase64(bytes)
+}
+
+func (e *exploder) PutStrings(key string, values []string) {
+	value := e.value(key)
+	for i, item := range values {
+		if i > 0 {
+			value.AppendString(e.message.getSliceDelimiter())
+		}
+		value.AppendString(item)
+	}
+}
+
+func (e *exploder) PutInts(key string, values []int) {
+	value := e.value(key)
+	for i, item := range values {
+		if i > 0 {
+			value.AppendString(e.message.getSliceDelimiter())
+		}
+		value.AppendInt(int64(item))
+	}
+}
+
+func (e *exploder) PutUInts(key string, values []uint64) {
+	value := e.value(key)
+	for i, item := range values {
+		if i > 0 {
+			value.AppendString(e.message.getSliceDelimiter())
+		}
+		value.AppendUint(item)
+	}
+}
+
+func (e *exploder) PutInt(key string, value int) {
+	e.value(key).AppendInt(int64(value))
+}
+
+func (e *exploder) PutFloat(key string, value float64) {
+	e.value(key).AppendFloat(value, 64)
+}
+
+func (e *exploder) PutFloats(key string, values []float64) {
+	value := e.value(key)
+	for i, item := range values {
+		if i > 0 {
+			value.AppendString(e.message.getSliceDelimiter())
+		}
+		value.AppendFloat(item, 64)
+	}
+}
+
+func (e *exploder) PutBool(key string, value bool) {
+	e.value(key).AppendBool(value)
+}
+
+func (e *exploder) PutBools(key string, values []bool) {
+	value := e.value(key)
+	for i, item := range values {
+		if i > 0 {
+			value.AppendString(e.message.getSliceDelimiter())
+		}
+		value.AppendBool(item)
+	}
+}
+
+func (e *exploder) PutInt64(key string, value int64) {
+	e.value(key).AppendInt(value)
+}
+
+func (e *exploder) PutUint64(key string, value uint64) {
+	e.value(key).AppendUint(value)
+}
+
+func (e *exploder) PutFloat32(key string, value float32) {
+	e.value(key).AppendFloat(float64(value), 32)
+}
+
+func (e *exploder) PutTime(key string, value time.Time, layout string) {
+	if layout == "" {
+		layout = time.RFC3339Nano
+	}
+	e.value(key).AppendTime(value, layout)
+}
+
+func (e *exploder) PutDuration(key string, value time.Duration) {
+	e.value(key).AppendInt(int64(value))
+}
+
+//PutNull puts empty column value
+func (e *exploder) PutNull(key string) {
+	e.value(key)
+}
+
+func (e *exploder) PutRaw(key string, value []byte) {
+	e.value(key).AppendBytes(value)
+}
+
+//asString returns string sharing bytes memory, it must not outlive the bytes
+func asString(bs []byte) string {
+	return xunsafe.AsString(unsafe.Pointer(&bs))
+}
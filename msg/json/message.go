@@ -5,16 +5,18 @@ import (
 	"github.com/viant/tapper/buffer"
 	"github.com/viant/tapper/io"
 	"github.com/viant/tapper/msg"
-	"github.com/viant/toolbox"
 	iow "io"
+	"math"
 	"sync/atomic"
+	"time"
 )
 
 //Message represents transaction message
 type Message struct {
-	bs       *buffer.Bytes
-	provider *msg.Provider
-	borrowed int32
+	bs         *buffer.Bytes
+	provider   *msg.Provider
+	borrowed   int32
+	escapeHTML bool
 }
 
 //Begin begin message
@@ -27,10 +29,16 @@ func (m *Message) PutByte(b byte) {
 	m.bs.AppendByte(b)
 }
 
-func (m *Message) quoted(key string) {
-	m.bs.AppendByte('"')
-	m.bs.AppendString(key)
-	m.bs.AppendByte('"')
+func (m *Message) quoted(value string) {
+	m.bs.AppendJSONString(value, m.escapeHTML)
+}
+
+func (m *Message) float(value float64) {
+	if math.IsNaN(value) || math.IsInf(value, 0) { //not representable in JSON
+		m.bs.AppendString("null")
+		return
+	}
+	m.bs.AppendFloat(value, 64)
 }
 
 func (m *Message) next() {
@@ -117,7 +125,7 @@ func (m *Message) PutInts(key string, values []int) {
 		if i > 0 {
 			m.next()
 		}
-		m.Put([]byte(toolbox.AsString(value)))
+		m.bs.AppendInt(int64(value))
 	}
 	m.Put([]byte("]"))
 	m.next()
@@ -131,7 +139,7 @@ func (m *Message) PutUInts(key string, values []uint64) {
 		if i > 0 {
 			m.next()
 		}
-		m.Put([]byte(toolbox.AsString(value)))
+		m.bs.AppendUint(value)
 	}
 	m.Put([]byte("]"))
 	m.next()
@@ -147,7 +155,7 @@ func (m *Message) PutInt(key string, value int) {
 //PutFloat put key and float value
 func (m *Message) PutFloat(key string, value float64) {
 	m.key(key)
-	m.bs.AppendFloat(value, 64)
+	m.float(value)
 	m.next()
 
 }
@@ -160,7 +168,7 @@ func (m *Message) PutFloats(key string, values []float64) {
 		if i > 0 {
 			m.next()
 		}
-		m.bs.AppendFloat(value, 64)
+		m.float(value)
 	}
 	m.Put([]byte("]"))
 	m.next()
@@ -187,6 +195,66 @@ func (m *Message) PutBools(key string, values []bool) {
 	m.next()
 }
 
+//PutInt64 put key and int64 value
+func (m *Message) PutInt64(key string, value int64) {
+	m.key(key)
+	m.bs.AppendInt(value)
+	m.next()
+}
+
+//PutUint64 put key and uint64 value
+func (m *Message) PutUint64(key string, value uint64) {
+	m.key(key)
+	m.bs.AppendUint(value)
+	m.next()
+}
+
+//PutFloat32 put key and float32 value
+func (m *Message) PutFloat32(key string, value float32) {
+	m.key(key)
+	if math.IsNaN(float64(value)) || math.IsInf(float64(value), 0) {
+		m.bs.AppendString("null")
+	} else {
+		m.bs.AppendFloat(float64(value), 32)
+	}
+	m.next()
+}
+
+//PutTime put key and time formatted with layout (RFC 3339 with nanoseconds for empty layout) as string
+func (m *Message) PutTime(key string, value time.Time, layout string) {
+	m.key(key)
+	if layout == "" {
+		layout = time.RFC3339Nano
+	}
+	m.bs.AppendByte('"')
+	m.bs.AppendTime(value, layout)
+	m.bs.AppendByte('"')
+	m.next()
+}
+
+//PutDuration put key and duration as int64 nanoseconds
+func (m *Message) PutDuration(key string, value time.Duration) {
+	m.PutInt64(key, int64(value))
+}
+
+//PutNull put key and null value
+func (m *Message) PutNull(key string) {
+	m.key(key)
+	m.bs.AppendString("null")
+	m.next()
+}
+
+//PutRaw put key and JSON encoded value, empty value is written as null
+func (m *Message) PutRaw(key string, value []byte) {
+	if len(value) == 0 {
+		m.PutNull(key)
+		return
+	}
+	m.key(key)
+	m.bs.AppendBytes(value)
+	m.next()
+}
+
 //WriteTo writes message to the writer
 func (m *Message) WriteTo(w iow.Writer) (int64, error) {
 	m.end()
@@ -236,3 +304,12 @@ func New(provider *msg.Provider, bytes *buffer.Bytes) msg.Message {
 		provider: provider,
 	}
 }
+
+//NewHTMLSafe creates a message escaping <, > and & in strings, so that it can be safely embedded in HTML
+func NewHTMLSafe(provider *msg.Provider, bytes *buffer.Bytes) msg.Message {
+	return &Message{
+		bs:         bytes,
+		provider:   provider,
+		escapeHTML: true,
+	}
+}
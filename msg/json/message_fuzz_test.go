@@ -0,0 +1,72 @@
+//go:build go1.18
+// +build go1.18
+
+package json
+
+import (
+	"encoding/base64"
+	"github.com/stretchr/testify/assert"
+	"github.com/viant/tapper/buffer"
+	"github.com/viant/tapper/io"
+	"github.com/viant/tapper/msg"
+	"math"
+	"testing"
+)
+
+func FuzzMessage(f *testing.F) {
+	f.Add("key", "value", int64(1), 1.5, true)
+	f.Add("k\"ey", "a\nb\"c\\", int64(-100), -0.25, false)
+	f.Add("\xff", "\x00\x1f\u2028<&>", int64(math.MaxInt32), 1e21, true)
+	f.Fuzz(func(t *testing.T, key, value string, number int64, real float64, flag bool) {
+		if math.IsNaN(real) || math.IsInf(real, 0) {
+			real = 0
+		}
+		for _, newMessage := range []func(provider *msg.Provider, bytes *buffer.Bytes) msg.Message{New, NewHTMLSafe} {
+			provider := msg.NewProvider(32, 1, newMessage)
+			message := provider.NewMessage()
+			message.PutString("string", value)
+			message.PutNonEmptyString("nonEmpty", value)
+			message.PutStrings("strings", []string{value, key})
+			message.PutB64EncodedBytes("b64", []byte(value))
+			message.PutInt("int", int(number))
+			message.PutInts("ints", []int{int(number), 0})
+			message.PutUInts("uints", []uint64{uint64(number), 0})
+			message.PutFloat("float", real)
+			message.PutFloats("floats", []float64{real, 0})
+			message.PutBool("bool", flag)
+			message.PutBools("bools", []bool{flag, !flag})
+			message.PutObject("object", &testObject{ID: int(number), Name: value})
+			message.PutObjects("objects", []io.Encoder{&testObject{ID: 1, Name: key}, &testObject{ID: 2, Name: value}})
+			actual := decode(t, message)
+			message.Free()
+
+			expect := map[string]interface{}{
+				"string":  normalize(value),
+				"strings": []interface{}{normalize(value), normalize(key)},
+				"b64":     base64.StdEncoding.EncodeToString([]byte(value)),
+				"int":     float64(int(number)),
+				"ints":    []interface{}{float64(int(number)), float64(0)},
+				"uints":   []interface{}{float64(uint64(number)), float64(0)},
+				"float":   real,
+				"floats":  []interface{}{real, float64(0)},
+				"bool":    flag,
+				"bools":   []interface{}{flag, !flag},
+				"object":  map[string]interface{}{"id": float64(int(number)), "name": normalize(value)},
+				"objects": []interface{}{
+					map[string]interface{}{"id": float64(1), "name": normalize(key)},
+					map[string]interface{}{"id": float64(2), "name": normalize(value)},
+				},
+			}
+			if value != "" {
+				expect["nonEmpty"] = normalize(value)
+			}
+			assert.EqualValues(t, expect, actual)
+
+			message = provider.NewMessage()
+			message.PutString(key, value)
+			actual = decode(t, message)
+			message.Free()
+			assert.EqualValues(t, map[string]interface{}{normalize(key): normalize(value)}, actual)
+		}
+	})
+}
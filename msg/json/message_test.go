@@ -0,0 +1,135 @@
+package json
+
+import (
+	"bytes"
+	stdjson "encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/viant/tapper/io"
+	"github.com/viant/tapper/msg"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testObject struct {
+	ID   int
+	Name string
+}
+
+func (o *testObject) Encode(stream io.Stream) {
+	stream.PutInt("id", o.ID)
+	stream.PutString("name", o.Name)
+}
+
+//normalize returns value as decoded by encoding/json, invalid UTF-8 is replaced the same way
+func normalize(value string) string {
+	data, _ := stdjson.Marshal(value)
+	result := ""
+	_ = stdjson.Unmarshal(data, &result)
+	return result
+}
+
+func decode(t *testing.T, message msg.Message) map[string]interface{} {
+	buffer := new(bytes.Buffer)
+	_, err := message.WriteTo(buffer)
+	assert.Nil(t, err)
+	assert.True(t, stdjson.Valid(buffer.Bytes()), buffer.String())
+	assert.EqualValues(t, 1, bytes.Count(buffer.Bytes(), []byte("\n")), "one line per message")
+	var result map[string]interface{}
+	assert.Nil(t, stdjson.Unmarshal(buffer.Bytes(), &result), buffer.String())
+	return result
+}
+
+func TestMessage_PutString(t *testing.T) {
+	var useCases = []struct {
+		description string
+		key         string
+		value       string
+	}{
+		{description: "plain", key: "k", value: "abc"},
+		{description: "quote and backslash", key: `k"\`, value: `say "hi" \ bye`},
+		{description: "new lines", key: "k\n", value: "line1\nline2\r\n\tend"},
+		{description: "control characters", key: "k\x00", value: "\x00\x01\x08\x0c\x1f\x7f"},
+		{description: "unicode", key: "klucz", value: "zażółć gęślą jaźń 日本 🙂"},
+		{description: "invalid UTF-8", key: "k\xff", value: "a\xffb\xc3\x28c\xed\xa0\x80"},
+		{description: "line separators", key: "k", value: "a\u2028b\u2029c"},
+		{description: "html", key: "<k>", value: "<script>a && b</script>"},
+	}
+	for _, useCase := range useCases {
+		provider := msg.NewProvider(16, 1, New)
+		message := provider.NewMessage()
+		message.PutString(useCase.key, useCase.value)
+		actual := decode(t, message)
+		assert.EqualValues(t, map[string]interface{}{normalize(useCase.key): normalize(useCase.value)}, actual, useCase.description)
+		message.Free()
+
+		htmlProvider := msg.NewProvider(16, 1, NewHTMLSafe)
+		message = htmlProvider.NewMessage()
+		message.PutString(useCase.key, useCase.value)
+		text := string(message.GetByteBuffer().Bytes())
+		assert.False(t, strings.ContainsAny(text, "<>&"), useCase.description)
+		actual = decode(t, message)
+		assert.EqualValues(t, map[string]interface{}{normalize(useCase.key): normalize(useCase.value)}, actual, useCase.description)
+		message.Free()
+	}
+}
+
+func TestMessage_PutString_Allocs(t *testing.T) {
+	provider := msg.NewProvider(1024, 1, New)
+	message := provider.NewMessage()
+	allocs := testing.AllocsPerRun(100, func() {
+		message.GetByteBuffer().Reset()
+		message.PutString("k\"ey", "value \"with\" escapes\n\x01\xff <html> \u2028")
+	})
+	assert.EqualValues(t, 0, allocs)
+}
+
+func TestMessage_PutFloat(t *testing.T) {
+	provider := msg.NewProvider(1024, 1, New)
+	message := provider.NewMessage()
+	message.PutFloat("nan", math.NaN())
+	message.PutFloat("inf", math.Inf(1))
+	message.PutFloats("values", []float64{1.5, math.Inf(-1)})
+	actual := decode(t, message)
+	assert.EqualValues(t, map[string]interface{}{"nan": nil, "inf": nil, "values": []interface{}{1.5, nil}}, actual)
+}
+
+func TestMessage_PutTyped(t *testing.T) {
+	provider := msg.NewProvider(1024, 1, New)
+	message := provider.NewMessage()
+	at := time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC)
+	message.PutInt64("int64", math.MinInt64)
+	message.PutUint64("uint64", math.MaxUint64)
+	message.PutFloat32("float32", 1.25)
+	message.PutFloat32("nan", float32(math.NaN()))
+	message.PutTime("time", at, time.RFC3339)
+	message.PutTime("nano", at.Add(5), "")
+	message.PutDuration("duration", 1500*time.Millisecond)
+	message.PutNull("null")
+	message.PutRaw("raw", []byte(`{"a":[1,2]}`))
+	message.PutRaw("empty", nil)
+	buffer := new(bytes.Buffer)
+	_, err := message.WriteTo(buffer)
+	assert.Nil(t, err)
+	assert.EqualValues(t, `{"int64":-9223372036854775808,"uint64":18446744073709551615,"float32":1.25,"nan":null,"time":"2021-03-04T05:06:07Z","nano":"2021-03-04T05:06:07.000000005Z","duration":1500000000,"null":null,"raw":{"a":[1,2]},"empty":null}`+"\n", buffer.String())
+	assert.True(t, stdjson.Valid(buffer.Bytes()))
+}
+
+func TestMessage_PutTyped_Allocs(t *testing.T) {
+	provider := msg.NewProvider(1024, 1, New)
+	message := provider.NewMessage()
+	at := time.Now()
+	raw := []byte(`[1,2]`)
+	allocs := testing.AllocsPerRun(100, func() {
+		message.GetByteBuffer().Reset()
+		message.PutInt64("int64", -1)
+		message.PutUint64("uint64", math.MaxUint64)
+		message.PutFloat32("float32", 1.5)
+		message.PutTime("time", at, time.RFC3339Nano)
+		message.PutDuration("duration", time.Second)
+		message.PutNull("null")
+		message.PutRaw("raw", raw)
+	})
+	assert.EqualValues(t, 0, allocs)
+}
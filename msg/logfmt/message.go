@@ -0,0 +1,388 @@
+package logfmt
+
+import (
+	"github.com/viant/tapper/buffer"
+	"github.com/viant/tapper/io"
+	"github.com/viant/tapper/msg"
+	"github.com/viant/xunsafe"
+	iow "io"
+	"strings"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+	"unsafe"
+)
+
+const defaultSliceDelimiter = ","
+
+//Message represents logfmt transaction message, each message is a single line of space separated key=value pairs
+type Message struct {
+	bs             *buffer.Bytes
+	provider       *msg.Provider
+	borrowed       int32
+	fields         int
+	path           []segment
+	sliceDelimiter string
+	useQuote       bool
+}
+
+//segment represents nested object key prefix, index is used for PutObjects items
+type segment struct {
+	key   string
+	index int
+}
+
+//Begin begin message
+func (m *Message) Begin() {
+	m.fields = 0
+	m.path = m.path[:0]
+}
+
+//PutByte put bytes
+func (m *Message) PutByte(b byte) {
+	m.bs.AppendByte(b)
+}
+
+//Put put bytes
+func (m *Message) Put(bs []byte) {
+	m.bs.AppendBytes(bs)
+}
+
+//key appends space separated key with nested object prefix and equal sign
+func (m *Message) key(key string) {
+	if m.fields > 0 {
+		m.bs.AppendByte(' ')
+	}
+	m.fields++
+	for _, item := range m.path {
+		m.appendKey(item.key)
+		m.bs.AppendByte('.')
+		if item.index >= 0 {
+			m.bs.AppendInt(int64(item.index))
+			m.bs.AppendByte('.')
+		}
+	}
+	m.appendKey(key)
+	m.bs.AppendByte('=')
+}
+
+//appendKey appends key replacing characters not allowed in logfmt key (space, equal sign, quote, control or invalid UTF-8) with underscore
+func (m *Message) appendKey(key string) {
+	valid := utf8.ValidString(key)
+	start := 0
+	for i := 0; i < len(key); i++ {
+		if c := key[i]; c <= ' ' || c == '=' || c == '"' || c == 0x7f || (!valid && c >= utf8.RuneSelf) {
+			m.bs.AppendString(key[start:i])
+			m.bs.AppendByte('_')
+			start = i + 1
+		}
+	}
+	m.bs.AppendString(key[start:])
+}
+
+//needsQuotes returns true if value contains space, equal sign, quote, backslash, control or invalid UTF-8 characters
+func needsQuotes(value string) bool {
+	ascii := true
+	for i := 0; i < len(value); i++ {
+		switch c := value[i]; {
+		case c <= ' ', c == '=', c == '"', c == '\\', c == 0x7f:
+			return true
+		case c >= utf8.RuneSelf:
+			ascii = false
+		}
+	}
+	return !ascii && !utf8.ValidString(value)
+}
+
+func (m *Message) value(value string) {
+	if m.useQuote || needsQuotes(value) {
+		m.bs.AppendJSONString(value, false)
+		return
+	}
+	m.bs.AppendString(value)
+}
+
+//PutB64EncodedBytes puts key and base64 encoded value
+func (m *Message) PutB64EncodedBytes(key string, bytes []byte) {
+	m.key(key)
+	m.bs.AppendByte('"') //base64 padding uses equal sign
+	m.bs.AppendBase64(bytes)
+	m.bs.AppendByte('"')
+}
+
+//PutObject puts object fields with parent key prefix, i.e. parent.child=
+func (m *Message) PutObject(key string, object io.Encoder) {
+	m.path = append(m.path, segment{key: key, index: -1})
+	object.Encode(m)
+	m.path = m.path[:len(m.path)-1]
+}
+
+//PutObjects puts object fields with parent key and item index prefix, i.e. parent.0.child=
+func (m *Message) PutObjects(key string, objects []io.Encoder) {
+	m.path = append(m.path, segment{key: key})
+	last := len(m.path) - 1
+	for i, object := range objects {
+		m.path[last].index = i
+		object.Encode(m)
+	}
+	m.path = m.path[:last]
+}
+
+//PutNonEmptyString put key and non empty value
+func (m *Message) PutNonEmptyString(key, value string) {
+	if len(value) == 0 {
+		return
+	}
+	m.PutString(key, value)
+}
+
+//PutString put key and string value
+func (m *Message) PutString(key, value string) {
+	m.key(key)
+	m.value(value)
+}
+
+//PutStrings put key and string slice joined with slice delimiter, if any item contains the delimiter,
+//the value is quoted and item delimiters and backslashes are escaped with a backslash
+func (m *Message) PutStrings(key string, values []string) {
+	m.key(key)
+	delimiter := m.getSliceDelimiter()
+	escape := false
+	for i := 0; i < len(values) && !escape; i++ {
+		escape = delimiter != "" && strings.Contains(values[i], delimiter)
+	}
+	quote := escape || m.useQuote || (len(values) > 1 && needsQuotes(delimiter))
+	for i := 0; i < len(values) && !quote; i++ {
+		quote = needsQuotes(values[i])
+	}
+	if !quote {
+		for i, value := range values {
+			if i > 0 {
+				m.bs.AppendString(delimiter)
+			}
+			m.bs.AppendString(value)
+		}
+		return
+	}
+	m.bs.AppendByte('"')
+	for i, value := range values {
+		if i > 0 {
+			m.bs.AppendJSONEscaped(delimiter, false)
+		}
+		if escape {
+			m.appendEscapedItem(value, delimiter)
+			continue
+		}
+		m.bs.AppendJSONEscaped(value, false)
+	}
+	m.bs.AppendByte('"')
+}
+
+//appendEscapedItem appends quoted slice item with delimiter and backslash prefixed by a backslash
+func (m *Message) appendEscapedItem(item, delimiter string) {
+	for len(item) > 0 {
+		index, size := strings.IndexByte(item, '\\'), 1
+		if delimiterIndex := strings.Index(item, delimiter); delimiterIndex != -1 && (index == -1 || delimiterIndex < index) {
+			index, size = delimiterIndex, len(delimiter)
+		}
+		if index == -1 {
+			break
+		}
+		m.bs.AppendJSONEscaped(item[:index], false)
+		m.bs.AppendJSONEscaped("\\", false)
+		m.bs.AppendJSONEscaped(item[index:index+size], false)
+		item = item[index+size:]
+	}
+	m.bs.AppendJSONEscaped(item, false)
+}
+
+//beginSlice starts slice value, it returns true if value was quoted
+func (m *Message) beginSlice(key string, size int) bool {
+	m.key(key)
+	quote := size > 1 && needsQuotes(m.getSliceDelimiter())
+	if quote {
+		m.bs.AppendByte('"')
+	}
+	return quote
+}
+
+func (m *Message) sliceItem(i int) {
+	if i > 0 {
+		m.bs.AppendJSONEscaped(m.getSliceDelimiter(), false)
+	}
+}
+
+func (m *Message) endSlice(quoted bool) {
+	if quoted {
+		m.bs.AppendByte('"')
+	}
+}
+
+//PutInts puts key and int slice
+func (m *Message) PutInts(key string, values []int) {
+	quoted := m.beginSlice(key, len(values))
+	for i, value := range values {
+		m.sliceItem(i)
+		m.bs.AppendInt(int64(value))
+	}
+	m.endSlice(quoted)
+}
+
+//PutUInts put key and uint slice
+func (m *Message) PutUInts(key string, values []uint64) {
+	quoted := m.beginSlice(key, len(values))
+	for i, value := range values {
+		m.sliceItem(i)
+		m.bs.AppendUint(value)
+	}
+	m.endSlice(quoted)
+}
+
+//PutInt put key and int value
+func (m *Message) PutInt(key string, value int) {
+	m.key(key)
+	m.bs.AppendInt(int64(value))
+}
+
+//PutFloat put key and float value
+func (m *Message) PutFloat(key string, value float64) {
+	m.key(key)
+	m.bs.AppendFloat(value, 64)
+}
+
+//PutFloats put key and float slice
+func (m *Message) PutFloats(key string, values []float64) {
+	quoted := m.beginSlice(key, len(values))
+	for i, value := range values {
+		m.sliceItem(i)
+		m.bs.AppendFloat(value, 64)
+	}
+	m.endSlice(quoted)
+}
+
+//PutBool put key and bool value
+func (m *Message) PutBool(key string, value bool) {
+	m.key(key)
+	m.bs.AppendBool(value)
+}
+
+//PutBools put key and bool slice
+func (m *Message) PutBools(key string, values []bool) {
+	quoted := m.beginSlice(key, len(values))
+	for i, value := range values {
+		m.sliceItem(i)
+		m.bs.AppendBool(value)
+	}
+	m.endSlice(quoted)
+}
+
+//PutInt64 put key and int64 value
+func (m *Message) PutInt64(key string, value int64) {
+	m.key(key)
+	m.bs.AppendInt(value)
+}
+
+//PutUint64 put key and uint64 value
+func (m *Message) PutUint64(key string, value uint64) {
+	m.key(key)
+	m.bs.AppendUint(value)
+}
+
+//PutFloat32 put key and float32 value
+func (m *Message) PutFloat32(key string, value float32) {
+	m.key(key)
+	m.bs.AppendFloat(float64(value), 32)
+}
+
+//PutTime put key and time formatted with layout (RFC 3339 with nanoseconds for empty layout), value is quoted if layout produces space, equal sign or quote
+func (m *Message) PutTime(key string, value time.Time, layout string) {
+	m.key(key)
+	if layout == "" {
+		layout = time.RFC3339Nano
+	}
+	start := m.bs.Size()
+	m.bs.AppendTime(value, layout)
+	if formatted := m.bs.Bytes()[start:]; m.useQuote || needsQuotes(asString(formatted)) {
+		text := string(formatted)
+		m.bs.Truncate(start)
+		m.bs.AppendJSONString(text, false)
+	}
+}
+
+//asString returns string sharing bytes memory, it must not outlive the bytes
+func asString(bs []byte) string {
+	return xunsafe.AsString(unsafe.Pointer(&bs))
+}
+
+//PutDuration put key and duration as int64 nanoseconds
+func (m *Message) PutDuration(key string, value time.Duration) {
+	m.PutInt64(key, int64(value))
+}
+
+//PutNull put key with empty value, i.e. key=
+func (m *Message) PutNull(key string) {
+	m.key(key)
+}
+
+//PutRaw put key and value written as is, value has to be already quoted if needed
+func (m *Message) PutRaw(key string, value []byte) {
+	m.key(key)
+	m.bs.AppendBytes(value)
+}
+
+//WriteTo writes message to the writer
+func (m *Message) WriteTo(w iow.Writer) (int64, error) {
+	m.end()
+	return m.bs.WriteTo(w)
+}
+
+//End end message
+func (m *Message) End() {
+}
+
+func (m *Message) end() {
+	m.End()
+	m.bs.AppendByte('\n')
+}
+
+//Free returns bytes to the pool
+func (m *Message) Free() {
+	m.provider.Put(m)
+}
+
+func (m *Message) SetBorrowed() {
+	atomic.StoreInt32(&m.borrowed, 1)
+}
+
+func (m *Message) CompareAndSwap() bool {
+	return atomic.CompareAndSwapInt32(&m.borrowed, 1, 0)
+}
+
+func (m *Message) GetByteBuffer() *buffer.Bytes {
+	return m.bs
+}
+
+//SetSliceDelimiter sets slice items delimiter, comma by default
+func (m *Message) SetSliceDelimiter(delimiter string) {
+	m.sliceDelimiter = delimiter
+}
+
+func (m *Message) getSliceDelimiter() string {
+	if m.sliceDelimiter == "" {
+		return defaultSliceDelimiter
+	}
+	return m.sliceDelimiter
+}
+
+//UseQuotes quotes all string values when true, otherwise only values that require quoting
+func (m *Message) UseQuotes(quote bool) {
+	m.useQuote = quote
+}
+
+//New creates logfmt message, use with msg.NewProvider(size, concurrency, logfmt.New)
+func New(provider *msg.Provider, bytes *buffer.Bytes) msg.Message {
+	return &Message{
+		bs:       bytes,
+		provider: provider,
+	}
+}
@@ -0,0 +1,182 @@
+package logfmt
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"github.com/viant/tapper/io"
+	"github.com/viant/tapper/msg"
+	"math"
+	"testing"
+	"time"
+)
+
+type testUser struct {
+	ID   int
+	Name string
+}
+
+func (u *testUser) Encode(stream io.Stream) {
+	stream.PutInt("id", u.ID)
+	stream.PutString("name", u.Name)
+}
+
+func TestMessage(t *testing.T) {
+	var useCases = []struct {
+		description string
+		put         func(message msg.Message)
+		expect      string
+	}{
+		{
+			description: "Put raw bytes",
+			put: func(message msg.Message) {
+				message.Put([]byte("raw"))
+				message.PutByte('!')
+			},
+			expect: "raw!\n",
+		},
+		{
+			description: "PutString",
+			put: func(message msg.Message) {
+				message.PutString("level", "info")
+				message.PutString("msg", "hello world")
+				message.PutString("expr", "a=b")
+				message.PutString("quote", `say "hi"`)
+				message.PutString("empty", "")
+				message.PutString("unicode", "zażółć")
+				message.PutString("invalid", "a\xffb")
+				message.PutString("line", "a\nb")
+			},
+			expect: `level=info msg="hello world" expr="a=b" quote="say \"hi\"" empty= unicode=zażółć invalid="a\ufffdb" line="a\nb"` + "\n",
+		},
+		{
+			description: "keys",
+			put: func(message msg.Message) {
+				message.PutInt("request id", 1)
+				message.PutInt("a=b", 2)
+				message.PutInt("k\"", 3)
+			},
+			expect: "request_id=1 a_b=2 k_=3\n",
+		},
+		{
+			description: "PutNonEmptyString",
+			put: func(message msg.Message) {
+				message.PutNonEmptyString("a", "")
+				message.PutNonEmptyString("b", "v")
+			},
+			expect: "b=v\n",
+		},
+		{
+			description: "PutB64EncodedBytes",
+			put: func(message msg.Message) {
+				message.PutB64EncodedBytes("data", []byte("hi"))
+			},
+			expect: "data=\"aGk=\"\n",
+		},
+		{
+			description: "PutStrings",
+			put: func(message msg.Message) {
+				message.PutStrings("tags", []string{"a", "b"})
+				message.PutStrings("words", []string{"a b", "c"})
+				message.PutStrings("none", nil)
+				message.PutStrings("items", []string{"a,b", "c"})
+				message.PutStrings("item", []string{"a,b"})
+				message.PutStrings("paths", []string{`a\`, `b,\`, "c"})
+			},
+			expect: "tags=a,b words=\"a b,c\" none= items=\"a\\\\,b,c\" item=\"a\\\\,b\" paths=\"a\\\\\\\\,b\\\\,\\\\\\\\,c\"\n",
+		},
+		{
+			description: "numeric and bool",
+			put: func(message msg.Message) {
+				message.PutInt("int", -1)
+				message.PutInts("ints", []int{1, 2})
+				message.PutUInts("uints", []uint64{3})
+				message.PutFloat("float", 1.5)
+				message.PutFloats("floats", []float64{0.5, 2})
+				message.PutBool("bool", true)
+				message.PutBools("bools", []bool{true, false})
+			},
+			expect: "int=-1 ints=1,2 uints=3 float=1.5 floats=0.5,2 bool=true bools=true,false\n",
+		},
+		{
+			description: "slice delimiter requiring quotes",
+			put: func(message msg.Message) {
+				message.SetSliceDelimiter(" ")
+				message.PutInts("ints", []int{1, 2})
+				message.PutStrings("strings", []string{"a", "b"})
+				message.SetSliceDelimiter("")
+			},
+			expect: "ints=\"1 2\" strings=\"a b\"\n",
+		},
+		{
+			description: "UseQuotes",
+			put: func(message msg.Message) {
+				message.UseQuotes(true)
+				message.PutString("a", "v")
+				message.PutInt("b", 1)
+				message.UseQuotes(false)
+			},
+			expect: "a=\"v\" b=1\n",
+		},
+		{
+			description: "PutObject",
+			put: func(message msg.Message) {
+				message.PutObject("user", &testUser{ID: 1, Name: "Bob"})
+				message.PutInt("n", 2)
+			},
+			expect: "user.id=1 user.name=Bob n=2\n",
+		},
+		{
+			description: "typed primitives",
+			put: func(message msg.Message) {
+				message.PutInt64("i", math.MinInt64)
+				message.PutUint64("u", math.MaxUint64)
+				message.PutFloat32("f", 0.1)
+				message.PutTime("ts", time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC), time.RFC3339)
+				message.PutTime("day", time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC), "Jan 2 2006")
+				message.PutDuration("took", time.Millisecond)
+				message.PutNull("none")
+				message.PutRaw("raw", []byte(`"a b"`))
+			},
+			expect: `i=-9223372036854775808 u=18446744073709551615 f=0.1 ts=2021-03-04T05:06:07Z day="Mar 4 2021" took=1000000 none= raw="a b"` + "\n",
+		},
+		{
+			description: "PutObjects",
+			put: func(message msg.Message) {
+				message.PutObjects("users", []io.Encoder{&testUser{ID: 1, Name: "a"}, &testUser{ID: 2, Name: "b c"}})
+			},
+			expect: "users.0.id=1 users.0.name=a users.1.id=2 users.1.name=\"b c\"\n",
+		},
+	}
+	provider := msg.NewProvider(16, 1, New)
+	for _, useCase := range useCases {
+		message := provider.NewMessage()
+		useCase.put(message)
+		buffer := new(bytes.Buffer)
+		_, err := message.WriteTo(buffer)
+		message.Free()
+		assert.Nil(t, err, useCase.description)
+		assert.EqualValues(t, useCase.expect, buffer.String(), useCase.description)
+	}
+}
+
+func TestMessage_Allocs(t *testing.T) {
+	provider := msg.NewProvider(1024, 1, New)
+	message := provider.NewMessage()
+	user := &testUser{ID: 1, Name: "a b"}
+	objects := []io.Encoder{user, user}
+	tags := []string{"a", "b"}
+	at := time.Now()
+	allocs := testing.AllocsPerRun(100, func() {
+		message.GetByteBuffer().Reset()
+		message.Begin()
+		message.PutString("msg", "hello \"world\"")
+		message.PutInt("n", 10)
+		message.PutStrings("tags", tags)
+		message.PutObject("user", user)
+		message.PutObjects("users", objects)
+		message.PutUint64("u", math.MaxUint64)
+		message.PutTime("at", at, time.RFC3339Nano)
+		message.PutTime("range", at, time.RFC3339Nano+"/"+time.RFC3339Nano)
+	})
+	assert.EqualValues(t, 0, allocs)
+}
@@ -18,3 +18,11 @@ type Message interface {
 	Free()
 	UseQuotes(quote bool)
 }
+
+//Header represents message with named columns, used to write header record i.e. for CSV
+type Header interface {
+	//Columns returns keys of fields put to the message
+	Columns() []string
+	//AppendHeader appends header record with supplied columns
+	AppendHeader(bs *buffer.Bytes, columns []string)
+}
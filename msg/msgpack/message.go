@@ -0,0 +1,430 @@
+package msgpack
+
+import (
+	"github.com/viant/tapper/buffer"
+	"github.com/viant/tapper/io"
+	"github.com/viant/tapper/msg"
+	iow "io"
+	"math"
+	"sync/atomic"
+	"time"
+)
+
+//mapReserved number of bytes reserved for map header (map16) till the number of fields is known
+const mapReserved = 3
+
+//Message represents MessagePack transaction message, each message is encoded as a map,
+//map header is reserved at Begin and back-patched with the number of fields at End
+type Message struct {
+	bs       *buffer.Bytes
+	provider *msg.Provider
+	borrowed int32
+	maps     []mapFrame
+	scratch  []byte
+}
+
+//mapFrame represents open map
+type mapFrame struct {
+	offset int
+	fields int
+}
+
+//Begin begin message
+func (m *Message) Begin() {
+	m.maps = m.maps[:0]
+	m.beginMap()
+}
+
+func (m *Message) beginMap() {
+	m.maps = append(m.maps, mapFrame{offset: m.bs.Size()})
+	m.bs.AppendByte(0xde)
+	m.bs.AppendByte(0)
+	m.bs.AppendByte(0)
+}
+
+//endMap back-patches map header with the number of fields, using the smallest header
+func (m *Message) endMap() {
+	last := len(m.maps) - 1
+	frame := m.maps[last]
+	m.maps = m.maps[:last]
+	var header [5]byte
+	size := 0
+	switch n := frame.fields; {
+	case n < 16:
+		header[0] = 0x80 | byte(n)
+		size = 1
+	case n <= math.MaxUint16:
+		header[0], header[1], header[2] = 0xde, byte(n>>8), byte(n)
+		size = 3
+	default:
+		header[0], header[1], header[2], header[3], header[4] = 0xdf, byte(n>>24), byte(n>>16), byte(n>>8), byte(n)
+		size = 5
+	}
+	end := m.bs.Size()
+	body := frame.offset + mapReserved
+	switch {
+	case size < mapReserved:
+		data := m.bs.Bytes()
+		copy(data[frame.offset+size:], data[body:end])
+		m.bs.Truncate(end - mapReserved + size)
+	case size > mapReserved:
+		m.bs.AppendBytes(header[:size-mapReserved])
+		data := m.bs.Bytes()
+		copy(data[frame.offset+size:], data[body:end])
+	}
+	copy(m.bs.Bytes()[frame.offset:], header[:size])
+}
+
+//End end message
+func (m *Message) End() {
+	for len(m.maps) > 0 {
+		m.endMap()
+	}
+}
+
+//PutByte put raw byte, it is not counted as a map field
+func (m *Message) PutByte(b byte) {
+	m.bs.AppendByte(b)
+}
+
+//Put put raw bytes, it is not counted as a map field
+func (m *Message) Put(bs []byte) {
+	m.bs.AppendBytes(bs)
+}
+
+func (m *Message) key(key string) {
+	if len(m.maps) > 0 {
+		m.maps[len(m.maps)-1].fields++
+	}
+	m.str(key)
+}
+
+func (m *Message) uint16(v uint64) {
+	m.bs.AppendByte(byte(v >> 8))
+	m.bs.AppendByte(byte(v))
+}
+
+func (m *Message) uint32(v uint64) {
+	m.bs.AppendByte(byte(v >> 24))
+	m.bs.AppendByte(byte(v >> 16))
+	m.uint16(v)
+}
+
+func (m *Message) uint64(v uint64) {
+	m.uint32(v >> 32)
+	m.uint32(v)
+}
+
+func (m *Message) str(value string) {
+	m.strHeader(len(value))
+	m.bs.AppendString(value)
+}
+
+func (m *Message) strHeader(size int) {
+	switch n := uint64(size); {
+	case n < 32:
+		m.bs.AppendByte(0xa0 | byte(n))
+	case n <= math.MaxUint8:
+		m.bs.AppendByte(0xd9)
+		m.bs.AppendByte(byte(n))
+	case n <= math.MaxUint16:
+		m.bs.AppendByte(0xda)
+		m.uint16(n)
+	default:
+		m.bs.AppendByte(0xdb)
+		m.uint32(n)
+	}
+}
+
+func (m *Message) bin(value []byte) {
+	switch n := uint64(len(value)); {
+	case n <= math.MaxUint8:
+		m.bs.AppendByte(0xc4)
+		m.bs.AppendByte(byte(n))
+	case n <= math.MaxUint16:
+		m.bs.AppendByte(0xc5)
+		m.uint16(n)
+	default:
+		m.bs.AppendByte(0xc6)
+		m.uint32(n)
+	}
+	m.bs.AppendBytes(value)
+}
+
+func (m *Message) array(size int) {
+	switch n := uint64(size); {
+	case n < 16:
+		m.bs.AppendByte(0x90 | byte(n))
+	case n <= math.MaxUint16:
+		m.bs.AppendByte(0xdc)
+		m.uint16(n)
+	default:
+		m.bs.AppendByte(0xdd)
+		m.uint32(n)
+	}
+}
+
+//int appends the smallest integer representation
+func (m *Message) int(v int64) {
+	if v >= 0 {
+		m.uint(uint64(v))
+		return
+	}
+	switch {
+	case v >= -32:
+		m.bs.AppendByte(byte(v))
+	case v >= math.MinInt8:
+		m.bs.AppendByte(0xd0)
+		m.bs.AppendByte(byte(v))
+	case v >= math.MinInt16:
+		m.bs.AppendByte(0xd1)
+		m.uint16(uint64(v))
+	case v >= math.MinInt32:
+		m.bs.AppendByte(0xd2)
+		m.uint32(uint64(v))
+	default:
+		m.bs.AppendByte(0xd3)
+		m.uint64(uint64(v))
+	}
+}
+
+func (m *Message) uint(v uint64) {
+	switch {
+	case v <= math.MaxInt8:
+		m.bs.AppendByte(byte(v))
+	case v <= math.MaxUint8:
+		m.bs.AppendByte(0xcc)
+		m.bs.AppendByte(byte(v))
+	case v <= math.MaxUint16:
+		m.bs.AppendByte(0xcd)
+		m.uint16(v)
+	case v <= math.MaxUint32:
+		m.bs.AppendByte(0xce)
+		m.uint32(v)
+	default:
+		m.bs.AppendByte(0xcf)
+		m.uint64(v)
+	}
+}
+
+func (m *Message) float(v float64) {
+	m.bs.AppendByte(0xcb)
+	m.uint64(math.Float64bits(v))
+}
+
+func (m *Message) bool(v bool) {
+	if v {
+		m.bs.AppendByte(0xc3)
+		return
+	}
+	m.bs.AppendByte(0xc2)
+}
+
+//PutB64EncodedBytes puts key and bytes as MessagePack bin, bytes are not base64 encoded
+func (m *Message) PutB64EncodedBytes(key string, bytes []byte) {
+	m.key(key)
+	m.bin(bytes)
+}
+
+//PutObject puts key and object as nested map
+func (m *Message) PutObject(key string, object io.Encoder) {
+	m.key(key)
+	m.beginMap()
+	object.Encode(m)
+	m.endMap()
+}
+
+//PutObjects puts key and objects as array of maps
+func (m *Message) PutObjects(key string, objects []io.Encoder) {
+	m.key(key)
+	m.array(len(objects))
+	for _, object := range objects {
+		m.beginMap()
+		object.Encode(m)
+		m.endMap()
+	}
+}
+
+//PutNonEmptyString put key and non empty value
+func (m *Message) PutNonEmptyString(key, value string) {
+	if len(value) == 0 {
+		return
+	}
+	m.PutString(key, value)
+}
+
+//PutString put key and string value
+func (m *Message) PutString(key, value string) {
+	m.key(key)
+	m.str(value)
+}
+
+//PutStrings put key and string array
+func (m *Message) PutStrings(key string, values []string) {
+	m.key(key)
+	m.array(len(values))
+	for _, value := range values {
+		m.str(value)
+	}
+}
+
+//PutInts puts key and int array
+func (m *Message) PutInts(key string, values []int) {
+	m.key(key)
+	m.array(len(values))
+	for _, value := range values {
+		m.int(int64(value))
+	}
+}
+
+//PutUInts put key and uint array
+func (m *Message) PutUInts(key string, values []uint64) {
+	m.key(key)
+	m.array(len(values))
+	for _, value := range values {
+		m.uint(value)
+	}
+}
+
+//PutInt put key and int value
+func (m *Message) PutInt(key string, value int) {
+	m.key(key)
+	m.int(int64(value))
+}
+
+//PutFloat put key and float value
+func (m *Message) PutFloat(key string, value float64) {
+	m.key(key)
+	m.float(value)
+}
+
+//PutFloats put key and float array
+func (m *Message) PutFloats(key string, values []float64) {
+	m.key(key)
+	m.array(len(values))
+	for _, value := range values {
+		m.float(value)
+	}
+}
+
+//PutBool put key and bool value
+func (m *Message) PutBool(key string, value bool) {
+	m.key(key)
+	m.bool(value)
+}
+
+//PutBools put key and bool array
+func (m *Message) PutBools(key string, values []bool) {
+	m.key(key)
+	m.array(len(values))
+	for _, value := range values {
+		m.bool(value)
+	}
+}
+
+//PutInt64 put key and int64 value
+func (m *Message) PutInt64(key string, value int64) {
+	m.key(key)
+	m.int(value)
+}
+
+//PutUint64 put key and uint64 value
+func (m *Message) PutUint64(key string, value uint64) {
+	m.key(key)
+	m.uint(value)
+}
+
+//PutFloat32 put key and float 32 value
+func (m *Message) PutFloat32(key string, value float32) {
+	m.key(key)
+	m.bs.AppendByte(0xca)
+	m.uint32(uint64(math.Float32bits(value)))
+}
+
+//PutTime put key and time formatted with layout, empty layout writes timestamp extension type (-1)
+func (m *Message) PutTime(key string, value time.Time, layout string) {
+	m.key(key)
+	if layout != "" {
+		m.scratch = value.AppendFormat(m.scratch[:0], layout)
+		m.strHeader(len(m.scratch))
+		m.bs.AppendBytes(m.scratch)
+		return
+	}
+	seconds, nanos := value.Unix(), uint64(value.Nanosecond())
+	switch {
+	case nanos == 0 && seconds >= 0 && seconds <= math.MaxUint32: //timestamp 32
+		m.bs.AppendByte(0xd6)
+		m.bs.AppendByte(0xff)
+		m.uint32(uint64(seconds))
+	case seconds >= 0 && seconds>>34 == 0: //timestamp 64
+		m.bs.AppendByte(0xd7)
+		m.bs.AppendByte(0xff)
+		m.uint64(nanos<<34 | uint64(seconds))
+	default: //timestamp 96
+		m.bs.AppendByte(0xc7)
+		m.bs.AppendByte(12)
+		m.bs.AppendByte(0xff)
+		m.uint32(nanos)
+		m.uint64(uint64(seconds))
+	}
+}
+
+//PutDuration put key and duration as int64 nanoseconds
+func (m *Message) PutDuration(key string, value time.Duration) {
+	m.PutInt64(key, int64(value))
+}
+
+//PutNull put key and nil value
+func (m *Message) PutNull(key string) {
+	m.key(key)
+	m.bs.AppendByte(0xc0)
+}
+
+//PutRaw put key and MessagePack encoded value, empty value is written as nil
+func (m *Message) PutRaw(key string, value []byte) {
+	if len(value) == 0 {
+		m.PutNull(key)
+		return
+	}
+	m.key(key)
+	m.bs.AppendBytes(value)
+}
+
+//WriteTo writes message to the writer, messages are self delimiting, so no record separator is written
+func (m *Message) WriteTo(w iow.Writer) (int64, error) {
+	m.End()
+	return m.bs.WriteTo(w)
+}
+
+//Free returns bytes to the pool
+func (m *Message) Free() {
+	m.provider.Put(m)
+}
+
+func (m *Message) SetBorrowed() {
+	atomic.StoreInt32(&m.borrowed, 1)
+}
+
+func (m *Message) CompareAndSwap() bool {
+	return atomic.CompareAndSwapInt32(&m.borrowed, 1, 0)
+}
+
+func (m *Message) GetByteBuffer() *buffer.Bytes {
+	return m.bs
+}
+
+//SetSliceDelimiter not applicable to MessagePack message
+func (m *Message) SetSliceDelimiter(delimiter string) {
+}
+
+//UseQuotes not applicable to MessagePack message
+func (m *Message) UseQuotes(quote bool) {
+}
+
+//New creates MessagePack message, use with msg.NewProvider(size, concurrency, msgpack.New)
+func New(provider *msg.Provider, bytes *buffer.Bytes) msg.Message {
+	return &Message{
+		bs:       bytes,
+		provider: provider,
+	}
+}
@@ -0,0 +1,211 @@
+package msgpack
+
+import (
+	"bytes"
+	"encoding/hex"
+	"github.com/stretchr/testify/assert"
+	"github.com/viant/tapper/io"
+	"github.com/viant/tapper/msg"
+	"github.com/vmihailenco/msgpack/v5"
+	"math"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testUser struct {
+	ID   int
+	Name string
+}
+
+func (u *testUser) Encode(stream io.Stream) {
+	stream.PutInt("id", u.ID)
+	stream.PutString("name", u.Name)
+}
+
+//TestMessage checks encoding against MessagePack specification (https://github.com/msgpack/msgpack/blob/master/spec.md)
+func TestMessage(t *testing.T) {
+	var useCases = []struct {
+		description string
+		put         func(message msg.Message)
+		expect      string
+	}{
+		{
+			description: "empty",
+			put:         func(message msg.Message) {},
+			expect:      "80",
+		},
+		{
+			description: "msgpack.org example",
+			put: func(message msg.Message) {
+				message.PutBool("compact", true)
+				message.PutInt("schema", 0)
+			},
+			expect: "82a7636f6d70616374c3a6736368656d6100",
+		},
+		{
+			description: "int formats",
+			put: func(message msg.Message) {
+				message.PutInts("i", []int{127, 128, 256, 65536, 1 << 32, -32, -33, -129, -32769, -(1 << 31) - 1})
+			},
+			expect: "81a1699a7fcc80cd0100ce00010000cf0000000100000000e0d0dfd1ff7fd2ffff7fffd3ffffffff7fffffff",
+		},
+		{
+			description: "uint, float and bool",
+			put: func(message msg.Message) {
+				message.PutUInts("u", []uint64{1, 255})
+				message.PutFloat("f", 1.5)
+				message.PutBools("b", []bool{true, false})
+			},
+			expect: "83a1759201ccffa166cb3ff8000000000000a16292c3c2",
+		},
+		{
+			description: "string and bin",
+			put: func(message msg.Message) {
+				message.PutString("s", strings.Repeat("a", 32))
+				message.PutNonEmptyString("e", "")
+				message.PutB64EncodedBytes("b", []byte{1, 2})
+				message.PutStrings("l", []string{"x"})
+			},
+			expect: "83a173d920" + strings.Repeat("61", 32) + "a162c4020102a16c91a178",
+		},
+		{
+			description: "typed primitives",
+			put: func(message msg.Message) {
+				message.PutInt64("a", -1)
+				message.PutUint64("b", math.MaxUint64)
+				message.PutFloat32("c", 1.5)
+				message.PutTime("d", time.Unix(1, 0), "")
+				message.PutNull("e")
+				message.PutRaw("f", []byte{0x01})
+			},
+			expect: "86a161ff" + "a162cfffffffffffffffff" + "a163ca3fc00000" + "a164d6ff00000001" + "a165c0" + "a16601",
+		},
+		{
+			description: "map16",
+			put: func(message msg.Message) {
+				for i := 0; i < 16; i++ {
+					message.PutBool("k", true)
+				}
+			},
+			expect: "de0010" + strings.Repeat("a16bc3", 16),
+		},
+		{
+			description: "nested objects",
+			put: func(message msg.Message) {
+				message.PutObject("u", &testUser{ID: 1, Name: "a"})
+				message.PutObjects("l", []io.Encoder{&testUser{ID: 2}})
+			},
+			expect: "82a17582a26964" + "01a46e616d65a161" + "a16c9182a26964" + "02a46e616d65a0",
+		},
+	}
+	provider := msg.NewProvider(16, 1, New)
+	for _, useCase := range useCases {
+		message := provider.NewMessage()
+		useCase.put(message)
+		buffer := new(bytes.Buffer)
+		_, err := message.WriteTo(buffer)
+		message.Free()
+		assert.Nil(t, err, useCase.description)
+		assert.EqualValues(t, useCase.expect, hex.EncodeToString(buffer.Bytes()), useCase.description)
+	}
+}
+
+func TestMessage_Decode(t *testing.T) {
+	provider := msg.NewProvider(16, 1, New)
+	buffer := new(bytes.Buffer)
+	message := provider.NewMessage()
+	message.PutString("s", "zażółć")
+	message.PutInt("i", -300)
+	message.PutUInts("u", []uint64{1 << 40})
+	message.PutFloats("f", []float64{0.5, -2})
+	message.PutB64EncodedBytes("b", []byte("bin"))
+	message.PutObject("u1", &testUser{ID: 1, Name: "a"})
+	message.PutObjects("u2", []io.Encoder{&testUser{ID: 2, Name: "b"}, &testUser{ID: 3, Name: "c"}})
+	_, err := message.WriteTo(buffer)
+	assert.Nil(t, err)
+	message.Free()
+
+	wide := provider.NewMessage()
+	for i := 0; i < 70000; i++ {
+		wide.PutInt("k"+strconv.Itoa(i), i)
+	}
+	_, err = wide.WriteTo(buffer)
+	assert.Nil(t, err)
+	wide.Free()
+
+	decoder := msgpack.NewDecoder(buffer)
+	decoder.UseLooseInterfaceDecoding(true)
+	var record map[string]interface{}
+	assert.Nil(t, decoder.Decode(&record))
+	assert.EqualValues(t, map[string]interface{}{
+		"s":  "zażółć",
+		"i":  int64(-300),
+		"u":  []interface{}{uint64(1 << 40)},
+		"f":  []interface{}{0.5, -2.0},
+		"b":  "bin", //bin family is decoded as string into interface{}
+		"u1": map[string]interface{}{"id": int64(1), "name": "a"},
+		"u2": []interface{}{
+			map[string]interface{}{"id": int64(2), "name": "b"},
+			map[string]interface{}{"id": int64(3), "name": "c"},
+		},
+	}, record)
+
+	record = nil
+	assert.Nil(t, decoder.Decode(&record), "map32")
+	assert.EqualValues(t, 70000, len(record))
+	assert.EqualValues(t, 69999, record["k69999"])
+	assert.EqualValues(t, 0, buffer.Len())
+}
+
+func TestMessage_PutTime(t *testing.T) {
+	var useCases = []struct {
+		description string
+		value       time.Time
+	}{
+		{description: "timestamp 32", value: time.Unix(1600000000, 0)},
+		{description: "timestamp 64", value: time.Unix(1600000000, 123456789)},
+		{description: "timestamp 96", value: time.Unix(-1, 5)},
+	}
+	provider := msg.NewProvider(16, 1, New)
+	for _, useCase := range useCases {
+		message := provider.NewMessage()
+		message.PutTime("t", useCase.value, "")
+		message.PutTime("s", useCase.value, time.RFC3339)
+		buffer := new(bytes.Buffer)
+		_, err := message.WriteTo(buffer)
+		message.Free()
+		assert.Nil(t, err, useCase.description)
+		var record struct {
+			T time.Time `msgpack:"t"`
+			S string    `msgpack:"s"`
+		}
+		assert.Nil(t, msgpack.Unmarshal(buffer.Bytes(), &record), useCase.description)
+		assert.True(t, useCase.value.Equal(record.T), useCase.description)
+		assert.EqualValues(t, useCase.value.Format(time.RFC3339), record.S, useCase.description)
+	}
+}
+
+func TestMessage_Allocs(t *testing.T) {
+	provider := msg.NewProvider(1024, 1, New)
+	message := provider.NewMessage()
+	user := &testUser{ID: 1, Name: "a b"}
+	objects := []io.Encoder{user, user}
+	tags := []string{"a", "b"}
+	at := time.Now()
+	allocs := testing.AllocsPerRun(100, func() {
+		message.GetByteBuffer().Reset()
+		message.Begin()
+		message.PutString("msg", "hello")
+		message.PutInt("n", 10)
+		message.PutStrings("tags", tags)
+		message.PutObject("user", user)
+		message.PutObjects("users", objects)
+		message.PutUint64("u", math.MaxUint64)
+		message.PutTime("at", at, "")
+		message.PutTime("on", at, time.RFC3339Nano)
+		message.End()
+	})
+	assert.EqualValues(t, 0, allocs)
+}
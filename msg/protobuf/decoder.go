@@ -0,0 +1,139 @@
+package protobuf
+
+import (
+	"bufio"
+	"encoding/binary"
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/encoding/protowire"
+	"io"
+	"math"
+)
+
+//Decoder reads varint length prefixed records written by Message
+type Decoder struct {
+	reader *bufio.Reader
+	schema *Schema
+	data   []byte
+}
+
+//Decode decodes the next record into key value map, it returns io.EOF when there are no more records
+func (d *Decoder) Decode() (map[string]interface{}, error) {
+	size, err := binary.ReadUvarint(d.reader)
+	if err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return nil, errors.Wrap(err, "failed to read record size")
+		}
+		return nil, err
+	}
+	if uint64(cap(d.data)) < size {
+		d.data = make([]byte, size)
+	}
+	d.data = d.data[:size]
+	if _, err = io.ReadFull(d.reader, d.data); err != nil {
+		return nil, errors.Wrapf(err, "failed to read record of size: %v", size)
+	}
+	return Unmarshal(d.data, d.schema)
+}
+
+//Unmarshal decodes protobuf wire format message into key value map, unknown fields are skipped,
+//repeated fields are decoded as []interface{} from both packed and unpacked encoding
+func Unmarshal(data []byte, schema *Schema) (map[string]interface{}, error) {
+	result := map[string]interface{}{}
+	for len(data) > 0 {
+		number, wireType, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return nil, errors.Wrap(protowire.ParseError(n), "failed to decode tag")
+		}
+		data = data[n:]
+		field := schema.byNumber[number]
+		if field == nil {
+			if n = protowire.ConsumeFieldValue(number, wireType, data); n < 0 {
+				return nil, errors.Wrapf(protowire.ParseError(n), "failed to skip field: %v", number)
+			}
+			data = data[n:]
+			continue
+		}
+		var err error
+		if n, err = decodeField(field, wireType, data, result); err != nil {
+			return nil, err
+		}
+		data = data[n:]
+	}
+	return result, nil
+}
+
+func decodeField(field *Field, wireType protowire.Type, data []byte, result map[string]interface{}) (int, error) {
+	if wireType == protowire.BytesType && field.wireType() != protowire.BytesType { //packed repeated
+		packed, n := protowire.ConsumeBytes(data)
+		if n < 0 {
+			return n, errors.Wrapf(protowire.ParseError(n), "failed to decode packed field: %v", field.Key)
+		}
+		values, _ := result[field.Key].([]interface{})
+		for len(packed) > 0 {
+			value, m, err := decodeValue(field, field.wireType(), packed)
+			if err != nil {
+				return m, err
+			}
+			values = append(values, value)
+			packed = packed[m:]
+		}
+		result[field.Key] = values
+		return n, nil
+	}
+	if wireType != field.wireType() {
+		return 0, errors.Errorf("invalid wire type: %v for field: %v", wireType, field.Key)
+	}
+	value, n, err := decodeValue(field, wireType, data)
+	if err != nil {
+		return n, err
+	}
+	if field.Repeated {
+		values, _ := result[field.Key].([]interface{})
+		result[field.Key] = append(values, value)
+		return n, nil
+	}
+	result[field.Key] = value
+	return n, nil
+}
+
+func decodeValue(field *Field, wireType protowire.Type, data []byte) (interface{}, int, error) {
+	switch wireType {
+	case protowire.VarintType:
+		v, n := protowire.ConsumeVarint(data)
+		if n < 0 {
+			return nil, n, errors.Wrapf(protowire.ParseError(n), "failed to decode field: %v", field.Key)
+		}
+		switch field.Kind {
+		case KindSint:
+			return protowire.DecodeZigZag(v), n, nil
+		case KindUint:
+			return v, n, nil
+		case KindBool:
+			return protowire.DecodeBool(v), n, nil
+		}
+		return int64(v), n, nil
+	case protowire.Fixed64Type:
+		v, n := protowire.ConsumeFixed64(data)
+		if n < 0 {
+			return nil, n, errors.Wrapf(protowire.ParseError(n), "failed to decode field: %v", field.Key)
+		}
+		return math.Float64frombits(v), n, nil
+	}
+	v, n := protowire.ConsumeBytes(data)
+	if n < 0 {
+		return nil, n, errors.Wrapf(protowire.ParseError(n), "failed to decode field: %v", field.Key)
+	}
+	switch field.Kind {
+	case KindString:
+		return string(v), n, nil
+	case KindMessage:
+		value, err := Unmarshal(v, field.Schema)
+		return value, n, err
+	}
+	return append([]byte{}, v...), n, nil
+}
+
+//NewDecoder creates decoder for the schema
+func NewDecoder(reader io.Reader, schema *Schema) *Decoder {
+	return &Decoder{reader: bufio.NewReader(reader), schema: schema}
+}
@@ -0,0 +1,414 @@
+package protobuf
+
+import (
+	"encoding/binary"
+	"github.com/viant/tapper/buffer"
+	"github.com/viant/tapper/io"
+	"github.com/viant/tapper/msg"
+	"google.golang.org/protobuf/encoding/protowire"
+	iow "io"
+	"math"
+	"sync/atomic"
+	"time"
+)
+
+//Message represents protobuf wire format transaction message, each record is prefixed with varint length,
+//keys are mapped to field numbers by the schema, keys without schema field are skipped
+type Message struct {
+	encoder
+	provider *msg.Provider
+	borrowed int32
+	prefix   [binary.MaxVarintLen64]byte
+}
+
+//encoder writes fields of a message or embedded message
+type encoder struct {
+	bs      *buffer.Bytes
+	schema  *Schema
+	child   *encoder
+	scratch []byte
+}
+
+func (e *encoder) varint(v uint64) {
+	for v >= 0x80 {
+		e.bs.AppendByte(byte(v) | 0x80)
+		v >>= 7
+	}
+	e.bs.AppendByte(byte(v))
+}
+
+func (e *encoder) fixed64(v uint64) {
+	for i := 0; i < 8; i++ {
+		e.bs.AppendByte(byte(v >> (8 * i)))
+	}
+}
+
+func (e *encoder) tag(field *Field, wireType protowire.Type) {
+	e.varint(protowire.EncodeTag(protowire.Number(field.Number), wireType))
+}
+
+//int appends integer value without tag according to field kind
+func (e *encoder) int(field *Field, v int64) {
+	switch field.Kind {
+	case KindSint:
+		e.varint(protowire.EncodeZigZag(v))
+	case KindDouble:
+		e.fixed64(math.Float64bits(float64(v)))
+	case KindBool:
+		e.varint(protowire.EncodeBool(v != 0))
+	default:
+		e.varint(uint64(v))
+	}
+}
+
+//intSize returns size of integer value without tag
+func intSize(field *Field, v int64) int {
+	switch field.Kind {
+	case KindSint:
+		return protowire.SizeVarint(protowire.EncodeZigZag(v))
+	case KindDouble:
+		return 8
+	case KindBool:
+		return 1
+	}
+	return protowire.SizeVarint(uint64(v))
+}
+
+func isNumeric(field *Field) bool {
+	switch field.Kind {
+	case KindInt, KindSint, KindUint, KindBool, KindDouble:
+		return true
+	}
+	return false
+}
+
+func (e *encoder) field(key string) *Field {
+	if e.schema == nil {
+		return nil
+	}
+	return e.schema.byKey[key]
+}
+
+//Put put raw bytes
+func (e *encoder) Put(bs []byte) {
+	e.bs.AppendBytes(bs)
+}
+
+//PutByte put raw byte
+func (e *encoder) PutByte(b byte) {
+	e.bs.AppendByte(b)
+}
+
+//PutObject puts embedded message
+func (e *encoder) PutObject(key string, object io.Encoder) {
+	field := e.field(key)
+	if field == nil || field.Kind != KindMessage {
+		return
+	}
+	e.embedded(field, object)
+}
+
+func (e *encoder) embedded(field *Field, object io.Encoder) {
+	if e.child == nil {
+		e.child = &encoder{bs: buffer.NewBytes(256)}
+	}
+	child := e.child
+	child.bs.Reset()
+	child.schema = field.Schema
+	object.Encode(child)
+	e.tag(field, protowire.BytesType)
+	e.varint(uint64(child.bs.Size()))
+	e.bs.AppendBytes(child.bs.Bytes())
+}
+
+//PutObjects puts repeated embedded messages
+func (e *encoder) PutObjects(key string, objects []io.Encoder) {
+	field := e.field(key)
+	if field == nil || field.Kind != KindMessage {
+		return
+	}
+	for _, object := range objects {
+		e.embedded(field, object)
+	}
+}
+
+//PutString puts string value
+func (e *encoder) PutString(key, value string) {
+	field := e.field(key)
+	if field == nil || field.wireType() != protowire.BytesType || field.Kind == KindMessage {
+		return
+	}
+	e.tag(field, protowire.BytesType)
+	e.varint(uint64(len(value)))
+	e.bs.AppendString(value)
+}
+
+//PutNonEmptyString puts non empty string value
+func (e *encoder) PutNonEmptyString(key, value string) {
+	if len(value) == 0 {
+		return
+	}
+	e.PutString(key, value)
+}
+
+//PutB64EncodedBytes puts bytes value, protobuf bytes are binary, so value is not base64 encoded
+func (e *encoder) PutB64EncodedBytes(key string, bytes []byte) {
+	field := e.field(key)
+	if field == nil || field.wireType() != protowire.BytesType || field.Kind == KindMessage {
+		return
+	}
+	e.tag(field, protowire.BytesType)
+	e.varint(uint64(len(bytes)))
+	e.bs.AppendBytes(bytes)
+}
+
+//PutStrings puts repeated string value
+func (e *encoder) PutStrings(key string, values []string) {
+	for _, value := range values {
+		e.PutString(key, value)
+	}
+}
+
+//PutInts puts packed repeated integer value
+func (e *encoder) PutInts(key string, values []int) {
+	field := e.field(key)
+	if field == nil || !isNumeric(field) || len(values) == 0 {
+		return
+	}
+	size := 0
+	for _, value := range values {
+		size += intSize(field, int64(value))
+	}
+	e.tag(field, protowire.BytesType)
+	e.varint(uint64(size))
+	for _, value := range values {
+		e.int(field, int64(value))
+	}
+}
+
+//PutUInts puts packed repeated unsigned integer value
+func (e *encoder) PutUInts(key string, values []uint64) {
+	field := e.field(key)
+	if field == nil || !isNumeric(field) || len(values) == 0 {
+		return
+	}
+	size := 0
+	for _, value := range values {
+		size += intSize(field, int64(value))
+	}
+	e.tag(field, protowire.BytesType)
+	e.varint(uint64(size))
+	for _, value := range values {
+		e.int(field, int64(value))
+	}
+}
+
+//PutInt puts integer value
+func (e *encoder) PutInt(key string, value int) {
+	field := e.field(key)
+	if field == nil || !isNumeric(field) {
+		return
+	}
+	e.tag(field, field.wireType())
+	e.int(field, int64(value))
+}
+
+//PutFloat puts double value
+func (e *encoder) PutFloat(key string, value float64) {
+	field := e.field(key)
+	if field == nil || field.Kind != KindDouble {
+		return
+	}
+	e.tag(field, protowire.Fixed64Type)
+	e.fixed64(math.Float64bits(value))
+}
+
+//PutFloats puts packed repeated double value
+func (e *encoder) PutFloats(key string, values []float64) {
+	field := e.field(key)
+	if field == nil || field.Kind != KindDouble || len(values) == 0 {
+		return
+	}
+	e.tag(field, protowire.BytesType)
+	e.varint(uint64(8 * len(values)))
+	for _, value := range values {
+		e.fixed64(math.Float64bits(value))
+	}
+}
+
+//PutBool puts bool value
+func (e *encoder) PutBool(key string, value bool) {
+	field := e.field(key)
+	if field == nil || field.Kind != KindBool {
+		return
+	}
+	e.tag(field, protowire.VarintType)
+	e.varint(protowire.EncodeBool(value))
+}
+
+//PutBools puts packed repeated bool value
+func (e *encoder) PutBools(key string, values []bool) {
+	field := e.field(key)
+	if field == nil || field.Kind != KindBool || len(values) == 0 {
+		return
+	}
+	e.tag(field, protowire.BytesType)
+	e.varint(uint64(len(values)))
+	for _, value := range values {
+		e.varint(protowire.EncodeBool(value))
+	}
+}
+
+//PutInt64 puts integer value
+func (e *encoder) PutInt64(key string, value int64) {
+	field := e.field(key)
+	if field == nil || !isNumeric(field) {
+		return
+	}
+	e.tag(field, field.wireType())
+	e.int(field, value)
+}
+
+//PutUint64 puts unsigned integer value
+func (e *encoder) PutUint64(key string, value uint64) {
+	field := e.field(key)
+	if field == nil || !isNumeric(field) {
+		return
+	}
+	e.tag(field, field.wireType())
+	if field.Kind == KindDouble {
+		e.fixed64(math.Float64bits(float64(value)))
+		return
+	}
+	e.int(field, int64(value))
+}
+
+//PutFloat32 puts double value
+func (e *encoder) PutFloat32(key string, value float32) {
+	e.PutFloat(key, float64(value))
+}
+
+//PutTime puts time as formatted string (string, bytes; RFC 3339 with nanoseconds for empty layout), google.protobuf.Timestamp (message) or unix nanoseconds (numeric kinds)
+func (e *encoder) PutTime(key string, value time.Time, layout string) {
+	field := e.field(key)
+	if field == nil {
+		return
+	}
+	switch field.Kind {
+	case KindString, KindBytes:
+		if layout == "" {
+			layout = time.RFC3339Nano
+		}
+		e.scratch = value.AppendFormat(e.scratch[:0], layout)
+		e.tag(field, protowire.BytesType)
+		e.varint(uint64(len(e.scratch)))
+		e.bs.AppendBytes(e.scratch)
+	case KindMessage:
+		e.secondsNanos(field, value.Unix(), int32(value.Nanosecond()))
+	default:
+		e.PutInt64(key, value.UnixNano())
+	}
+}
+
+//PutDuration puts duration as google.protobuf.Duration (message) or nanoseconds (numeric kinds)
+func (e *encoder) PutDuration(key string, value time.Duration) {
+	field := e.field(key)
+	if field == nil {
+		return
+	}
+	if field.Kind == KindMessage {
+		e.secondsNanos(field, int64(value/time.Second), int32(value%time.Second))
+		return
+	}
+	e.PutInt64(key, int64(value))
+}
+
+//secondsNanos puts embedded message with seconds (1) and nanos (2) fields, shared by google.protobuf.Timestamp and Duration
+func (e *encoder) secondsNanos(field *Field, seconds int64, nanos int32) {
+	size := 0
+	if seconds != 0 {
+		size += 1 + protowire.SizeVarint(uint64(seconds))
+	}
+	if nanos != 0 {
+		size += 1 + protowire.SizeVarint(uint64(int64(nanos)))
+	}
+	e.tag(field, protowire.BytesType)
+	e.varint(uint64(size))
+	if seconds != 0 {
+		e.varint(protowire.EncodeTag(1, protowire.VarintType))
+		e.varint(uint64(seconds))
+	}
+	if nanos != 0 {
+		e.varint(protowire.EncodeTag(2, protowire.VarintType))
+		e.varint(uint64(int64(nanos)))
+	}
+}
+
+//PutNull skips the field, absent field represents null in protobuf
+func (e *encoder) PutNull(key string) {
+}
+
+//PutRaw puts length delimited value, i.e. already encoded embedded message
+func (e *encoder) PutRaw(key string, value []byte) {
+	field := e.field(key)
+	if field == nil || field.wireType() != protowire.BytesType {
+		return
+	}
+	e.tag(field, protowire.BytesType)
+	e.varint(uint64(len(value)))
+	e.bs.AppendBytes(value)
+}
+
+//Begin begin message
+func (m *Message) Begin() {
+}
+
+//End end message
+func (m *Message) End() {
+}
+
+//WriteTo writes varint length prefixed record to the writer
+func (m *Message) WriteTo(w iow.Writer) (int64, error) {
+	size := binary.PutUvarint(m.prefix[:], uint64(m.bs.Size()))
+	n, err := w.Write(m.prefix[:size])
+	if err != nil {
+		return int64(n), err
+	}
+	written, err := m.bs.WriteTo(w)
+	return int64(n) + written, err
+}
+
+//Free returns bytes to the pool
+func (m *Message) Free() {
+	m.provider.Put(m)
+}
+
+func (m *Message) SetBorrowed() {
+	atomic.StoreInt32(&m.borrowed, 1)
+}
+
+func (m *Message) CompareAndSwap() bool {
+	return atomic.CompareAndSwapInt32(&m.borrowed, 1, 0)
+}
+
+func (m *Message) GetByteBuffer() *buffer.Bytes {
+	return m.bs
+}
+
+//SetSliceDelimiter not applicable to protobuf message
+func (m *Message) SetSliceDelimiter(delimiter string) {
+}
+
+//UseQuotes not applicable to protobuf message
+func (m *Message) UseQuotes(quote bool) {
+}
+
+//New returns message constructor for the schema, use with msg.NewProvider(size, concurrency, protobuf.New(schema))
+func New(schema *Schema) func(provider *msg.Provider, bytes *buffer.Bytes) msg.Message {
+	return func(provider *msg.Provider, bytes *buffer.Bytes) msg.Message {
+		return &Message{
+			encoder:  encoder{bs: bytes, schema: schema},
+			provider: provider,
+		}
+	}
+}
@@ -0,0 +1,350 @@
+package protobuf
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"github.com/viant/tapper/io"
+	"github.com/viant/tapper/msg"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+	"math"
+	"testing"
+	"time"
+)
+
+type testUser struct {
+	ID   int
+	Name string
+}
+
+func (u *testUser) Encode(stream io.Stream) {
+	stream.PutInt("id", u.ID)
+	stream.PutString("name", u.Name)
+}
+
+type testGroup struct {
+	Name  string
+	Users []io.Encoder
+}
+
+func (g *testGroup) Encode(stream io.Stream) {
+	stream.PutString("name", g.Name)
+	stream.PutObjects("users", g.Users)
+}
+
+func testSchema(t *testing.T) *Schema {
+	user, err := NewSchema(
+		&Field{Key: "id", Number: 1, Kind: KindInt},
+		&Field{Key: "name", Number: 2, Kind: KindString},
+	)
+	assert.Nil(t, err)
+	group, err := NewSchema(
+		&Field{Key: "name", Number: 1, Kind: KindString},
+		&Field{Key: "users", Number: 2, Kind: KindMessage, Repeated: true, Schema: user},
+	)
+	assert.Nil(t, err)
+	schema, err := NewSchema(
+		&Field{Key: "int", Number: 1, Kind: KindInt},
+		&Field{Key: "sint", Number: 2, Kind: KindSint},
+		&Field{Key: "uint", Number: 3, Kind: KindUint},
+		&Field{Key: "bool", Number: 4, Kind: KindBool},
+		&Field{Key: "double", Number: 5, Kind: KindDouble},
+		&Field{Key: "string", Number: 6, Kind: KindString},
+		&Field{Key: "bytes", Number: 7, Kind: KindBytes},
+		&Field{Key: "ints", Number: 8, Kind: KindInt, Repeated: true},
+		&Field{Key: "sints", Number: 9, Kind: KindSint, Repeated: true},
+		&Field{Key: "uints", Number: 10, Kind: KindUint, Repeated: true},
+		&Field{Key: "bools", Number: 11, Kind: KindBool, Repeated: true},
+		&Field{Key: "doubles", Number: 12, Kind: KindDouble, Repeated: true},
+		&Field{Key: "strings", Number: 13, Kind: KindString, Repeated: true},
+		&Field{Key: "user", Number: 14, Kind: KindMessage, Schema: user},
+		&Field{Key: "users", Number: 15, Kind: KindMessage, Repeated: true, Schema: user},
+		&Field{Key: "group", Number: 16, Kind: KindMessage, Schema: group},
+	)
+	assert.Nil(t, err)
+	return schema
+}
+
+func TestMessage(t *testing.T) {
+	var useCases = []struct {
+		description string
+		put         func(message msg.Message)
+		expect      map[string]interface{}
+	}{
+		{
+			description: "scalars",
+			put: func(message msg.Message) {
+				message.PutInt("int", -1)
+				message.PutInt("sint", -2)
+				message.PutInt("uint", 3)
+				message.PutBool("bool", true)
+				message.PutFloat("double", 1.5)
+				message.PutString("string", "zażółć")
+				message.PutB64EncodedBytes("bytes", []byte{0, 1, 2})
+			},
+			expect: map[string]interface{}{
+				"int":    int64(-1),
+				"sint":   int64(-2),
+				"uint":   uint64(3),
+				"bool":   true,
+				"double": 1.5,
+				"string": "zażółć",
+				"bytes":  []byte{0, 1, 2},
+			},
+		},
+		{
+			description: "unknown keys and mismatched kinds are skipped",
+			put: func(message msg.Message) {
+				message.PutString("unknown", "x")
+				message.PutString("int", "x")
+				message.PutFloat("string", 1)
+				message.PutNonEmptyString("string", "")
+				message.PutInt("int", 1)
+			},
+			expect: map[string]interface{}{
+				"int": int64(1),
+			},
+		},
+		{
+			description: "repeated",
+			put: func(message msg.Message) {
+				message.PutInts("ints", []int{1, -1, 300})
+				message.PutInts("sints", []int{-1, 2})
+				message.PutUInts("uints", []uint64{1 << 40})
+				message.PutBools("bools", []bool{true, false})
+				message.PutFloats("doubles", []float64{0.5, 2})
+				message.PutStrings("strings", []string{"a", ""})
+				message.PutInts("ints", nil)
+			},
+			expect: map[string]interface{}{
+				"ints":    []interface{}{int64(1), int64(-1), int64(300)},
+				"sints":   []interface{}{int64(-1), int64(2)},
+				"uints":   []interface{}{uint64(1 << 40)},
+				"bools":   []interface{}{true, false},
+				"doubles": []interface{}{0.5, 2.0},
+				"strings": []interface{}{"a", ""},
+			},
+		},
+		{
+			description: "typed primitives",
+			put: func(message msg.Message) {
+				message.PutInt64("int", math.MinInt64)
+				message.PutUint64("uint", math.MaxUint64)
+				message.PutFloat32("double", 1.5)
+				message.PutTime("string", time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC), time.RFC3339)
+				message.PutDuration("sint", -time.Second)
+				message.PutNull("bool")
+				message.PutRaw("bytes", []byte{1, 2})
+			},
+			expect: map[string]interface{}{
+				"int":    int64(math.MinInt64),
+				"uint":   uint64(math.MaxUint64),
+				"double": 1.5,
+				"string": "2021-03-04T05:06:07Z",
+				"sint":   int64(-time.Second),
+				"bytes":  []byte{1, 2},
+			},
+		},
+		{
+			description: "embedded messages",
+			put: func(message msg.Message) {
+				message.PutObject("user", &testUser{ID: 1, Name: "Bob"})
+				message.PutObjects("users", []io.Encoder{&testUser{ID: 2, Name: "a"}, &testUser{ID: 3}})
+				message.PutObject("group", &testGroup{Name: "g", Users: []io.Encoder{&testUser{ID: 4, Name: "b"}}})
+			},
+			expect: map[string]interface{}{
+				"user": map[string]interface{}{"id": int64(1), "name": "Bob"},
+				"users": []interface{}{
+					map[string]interface{}{"id": int64(2), "name": "a"},
+					map[string]interface{}{"id": int64(3), "name": ""},
+				},
+				"group": map[string]interface{}{
+					"name":  "g",
+					"users": []interface{}{map[string]interface{}{"id": int64(4), "name": "b"}},
+				},
+			},
+		},
+	}
+	schema := testSchema(t)
+	provider := msg.NewProvider(16, 1, New(schema))
+	for _, useCase := range useCases {
+		message := provider.NewMessage()
+		message.Begin()
+		useCase.put(message)
+		message.End()
+		buffer := new(bytes.Buffer)
+		_, err := message.WriteTo(buffer)
+		message.Free()
+		if !assert.Nil(t, err, useCase.description) {
+			continue
+		}
+		decoder := NewDecoder(buffer, schema)
+		actual, err := decoder.Decode()
+		assert.Nil(t, err, useCase.description)
+		assert.EqualValues(t, useCase.expect, actual, useCase.description)
+	}
+}
+
+func TestMessage_PutTime(t *testing.T) {
+	secondsNanos, err := NewSchema( //google.protobuf.Timestamp and google.protobuf.Duration layout
+		&Field{Key: "seconds", Number: 1, Kind: KindInt},
+		&Field{Key: "nanos", Number: 2, Kind: KindInt},
+	)
+	assert.Nil(t, err)
+	schema, err := NewSchema(
+		&Field{Key: "created", Number: 1, Kind: KindMessage, Schema: secondsNanos},
+		&Field{Key: "ttl", Number: 2, Kind: KindMessage, Schema: secondsNanos},
+		&Field{Key: "unixNano", Number: 3, Kind: KindInt},
+		&Field{Key: "elapsed", Number: 4, Kind: KindInt},
+	)
+	assert.Nil(t, err)
+	provider := msg.NewProvider(16, 1, New(schema))
+	message := provider.NewMessage()
+	at := time.Unix(1600000000, 123456789)
+	message.Begin()
+	message.PutTime("created", at, time.RFC3339)
+	message.PutDuration("ttl", 1500*time.Millisecond)
+	message.PutTime("unixNano", at, "")
+	message.PutDuration("elapsed", time.Minute)
+	message.End()
+	buffer := new(bytes.Buffer)
+	_, err = message.WriteTo(buffer)
+	message.Free()
+	assert.Nil(t, err)
+	actual, err := NewDecoder(buffer, schema).Decode()
+	assert.Nil(t, err)
+	assert.EqualValues(t, map[string]interface{}{
+		"created":  map[string]interface{}{"seconds": int64(1600000000), "nanos": int64(123456789)},
+		"ttl":      map[string]interface{}{"seconds": int64(1), "nanos": int64(500000000)},
+		"unixNano": at.UnixNano(),
+		"elapsed":  int64(time.Minute),
+	}, actual)
+}
+
+func TestDecoder_Decode(t *testing.T) {
+	schema := testSchema(t)
+	provider := msg.NewProvider(16, 1, New(schema))
+	buffer := new(bytes.Buffer)
+	for i := 0; i < 3; i++ {
+		message := provider.NewMessage()
+		message.PutInt("int", i)
+		_, err := message.WriteTo(buffer)
+		assert.Nil(t, err)
+		message.Free()
+	}
+	decoder := NewDecoder(buffer, schema)
+	for i := 0; i < 3; i++ {
+		record, err := decoder.Decode()
+		assert.Nil(t, err)
+		assert.EqualValues(t, map[string]interface{}{"int": int64(i)}, record)
+	}
+	_, err := decoder.Decode()
+	assert.Equal(t, "EOF", err.Error())
+
+	_, err = NewDecoder(bytes.NewReader([]byte{5, 8}), schema).Decode()
+	assert.NotNil(t, err, "truncated record")
+}
+
+//TestMessage_Unmarshal verifies records with protobuf runtime using dynamic message descriptor
+func TestMessage_Unmarshal(t *testing.T) {
+	label := descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum()
+	repeated := descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum()
+	fileProto := &descriptorpb.FileDescriptorProto{
+		Name:   proto.String("test.proto"),
+		Syntax: proto.String("proto3"),
+		MessageType: []*descriptorpb.DescriptorProto{
+			{
+				Name: proto.String("User"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					{Name: proto.String("id"), Number: proto.Int32(1), Label: label, Type: descriptorpb.FieldDescriptorProto_TYPE_INT64.Enum()},
+					{Name: proto.String("name"), Number: proto.Int32(2), Label: label, Type: descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum()},
+				},
+			},
+			{
+				Name: proto.String("Record"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					{Name: proto.String("sint"), Number: proto.Int32(2), Label: label, Type: descriptorpb.FieldDescriptorProto_TYPE_SINT64.Enum()},
+					{Name: proto.String("double"), Number: proto.Int32(5), Label: label, Type: descriptorpb.FieldDescriptorProto_TYPE_DOUBLE.Enum()},
+					{Name: proto.String("ints"), Number: proto.Int32(8), Label: repeated, Type: descriptorpb.FieldDescriptorProto_TYPE_INT64.Enum()},
+					{Name: proto.String("strings"), Number: proto.Int32(13), Label: repeated, Type: descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum()},
+					{Name: proto.String("users"), Number: proto.Int32(15), Label: repeated, Type: descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum(), TypeName: proto.String(".User")},
+				},
+			},
+		},
+	}
+	file, err := protodesc.NewFile(fileProto, nil)
+	if !assert.Nil(t, err) {
+		return
+	}
+	schema := testSchema(t)
+	message := msg.NewProvider(16, 1, New(schema)).NewMessage()
+	message.PutInt("sint", -5)
+	message.PutFloat("double", 2.5)
+	message.PutInts("ints", []int{1, 2})
+	message.PutStrings("strings", []string{"a", "b"})
+	message.PutObjects("users", []io.Encoder{&testUser{ID: 7, Name: "x"}})
+	buffer := new(bytes.Buffer)
+	_, err = message.WriteTo(buffer)
+	assert.Nil(t, err)
+
+	data := buffer.Bytes()
+	assert.EqualValues(t, len(data)-1, data[0], "length prefix")
+	record := dynamicpb.NewMessage(file.Messages().ByName("Record"))
+	if !assert.Nil(t, proto.Unmarshal(data[1:], record)) {
+		return
+	}
+	fields := record.Descriptor().Fields()
+	assert.EqualValues(t, -5, record.Get(fields.ByName("sint")).Int())
+	assert.EqualValues(t, 2.5, record.Get(fields.ByName("double")).Float())
+	ints := record.Get(fields.ByName("ints")).List()
+	assert.EqualValues(t, 2, ints.Len())
+	assert.EqualValues(t, 2, ints.Get(1).Int())
+	assert.EqualValues(t, "b", record.Get(fields.ByName("strings")).List().Get(1).String())
+	user := record.Get(fields.ByName("users")).List().Get(0).Message()
+	assert.EqualValues(t, 7, user.Get(user.Descriptor().Fields().ByName("id")).Int())
+	assert.EqualValues(t, "x", user.Get(user.Descriptor().Fields().ByName("name")).String())
+}
+
+func TestNewSchema(t *testing.T) {
+	var useCases = []struct {
+		description string
+		fields      []*Field
+		hasError    bool
+	}{
+		{description: "valid", fields: []*Field{{Key: "a", Number: 1, Kind: KindInt}}},
+		{description: "invalid number", fields: []*Field{{Key: "a", Number: 0, Kind: KindInt}}, hasError: true},
+		{description: "invalid kind", fields: []*Field{{Key: "a", Number: 1}}, hasError: true},
+		{description: "message without schema", fields: []*Field{{Key: "a", Number: 1, Kind: KindMessage}}, hasError: true},
+		{description: "duplicate key", fields: []*Field{{Key: "a", Number: 1, Kind: KindInt}, {Key: "a", Number: 2, Kind: KindInt}}, hasError: true},
+		{description: "duplicate number", fields: []*Field{{Key: "a", Number: 1, Kind: KindInt}, {Key: "b", Number: 1, Kind: KindInt}}, hasError: true},
+	}
+	for _, useCase := range useCases {
+		_, err := NewSchema(useCase.fields...)
+		assert.EqualValues(t, useCase.hasError, err != nil, useCase.description)
+	}
+}
+
+func TestMessage_Allocs(t *testing.T) {
+	provider := msg.NewProvider(1024, 1, New(testSchema(t)))
+	message := provider.NewMessage()
+	user := &testUser{ID: 1, Name: "a b"}
+	objects := []io.Encoder{user, user}
+	ints := []int{1, 2, 3}
+	at := time.Now()
+	message.PutObject("user", user)
+	allocs := testing.AllocsPerRun(100, func() {
+		message.GetByteBuffer().Reset()
+		message.Begin()
+		message.PutString("string", "hello")
+		message.PutInt("int", 10)
+		message.PutInts("ints", ints)
+		message.PutObject("user", user)
+		message.PutObjects("users", objects)
+		message.PutUint64("uint", math.MaxUint64)
+		message.PutTime("string", at, time.RFC3339Nano)
+		message.End()
+	})
+	assert.EqualValues(t, 0, allocs)
+}
@@ -0,0 +1,92 @@
+package protobuf
+
+import (
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+//Kind represents protobuf field type
+type Kind int
+
+const (
+	//KindInt int64 varint
+	KindInt = Kind(iota + 1)
+	//KindSint zigzag encoded sint64 varint
+	KindSint
+	//KindUint uint64 varint
+	KindUint
+	//KindBool bool varint
+	KindBool
+	//KindDouble fixed64 double
+	KindDouble
+	//KindString length delimited UTF-8 string
+	KindString
+	//KindBytes length delimited bytes
+	KindBytes
+	//KindMessage length delimited embedded message
+	KindMessage
+)
+
+//Field represents schema field
+type Field struct {
+	Key      string  //stream key
+	Number   int     //protobuf field number
+	Kind     Kind    //field type
+	Repeated bool    //repeated field, numeric repeated fields are packed
+	Schema   *Schema //embedded message schema
+}
+
+func (f *Field) wireType() protowire.Type {
+	switch f.Kind {
+	case KindDouble:
+		return protowire.Fixed64Type
+	case KindString, KindBytes, KindMessage:
+		return protowire.BytesType
+	}
+	return protowire.VarintType
+}
+
+//Schema represents mapping of stream keys to protobuf fields
+type Schema struct {
+	byKey    map[string]*Field
+	byNumber map[protowire.Number]*Field
+}
+
+//Field returns field for the key
+func (s *Schema) Field(key string) *Field {
+	return s.byKey[key]
+}
+
+//Register registers field
+func (s *Schema) Register(field *Field) error {
+	number := protowire.Number(field.Number)
+	if !number.IsValid() {
+		return errors.Errorf("invalid field number: %v for %v", field.Number, field.Key)
+	}
+	if field.Kind < KindInt || field.Kind > KindMessage {
+		return errors.Errorf("invalid field kind: %v for %v", field.Kind, field.Key)
+	}
+	if field.Kind == KindMessage && field.Schema == nil {
+		return errors.Errorf("schema was empty for message field: %v", field.Key)
+	}
+	if _, ok := s.byKey[field.Key]; ok {
+		return errors.Errorf("duplicate field key: %v", field.Key)
+	}
+	if _, ok := s.byNumber[number]; ok {
+		return errors.Errorf("duplicate field number: %v", field.Number)
+	}
+	s.byKey[field.Key] = field
+	s.byNumber[number] = field
+	return nil
+}
+
+//NewSchema creates schema with supplied fields
+func NewSchema(fields ...*Field) (*Schema, error) {
+	result := &Schema{byKey: map[string]*Field{}, byNumber: map[protowire.Number]*Field{}}
+	for _, field := range fields {
+		if err := result.Register(field); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,52 @@
+package parquet
+
+import (
+	"io"
+)
+
+//Inspect reads NDJSON or CSV records and returns inferred columns in the order of the first occurrence,
+//int64 columns holding fractions are widened to double, other type conflicts fall back to string
+func Inspect(source io.Reader, options *Options) ([]*Column, error) {
+	opts := Options{}
+	if options != nil {
+		opts = *options
+	}
+	if err := opts.Init(); err != nil {
+		return nil, err
+	}
+	inspector := newInspector()
+	records := newReader(source, &opts, nil)
+	for {
+		entries, err := records.read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		inspector.inspect(entries)
+	}
+	return inspector.schema(), nil
+}
+
+//Convert reads NDJSON or CSV records and writes them as parquet file with supplied columns
+func Convert(dest io.Writer, source io.Reader, columns []*Column, options *Options) error {
+	writer, err := NewWriter(dest, columns, options)
+	if err != nil {
+		return err
+	}
+	records := newReader(source, &writer.options, columns)
+	for {
+		entries, err := records.read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if err = writer.writeEntries(entries); err != nil {
+			return err
+		}
+	}
+	return writer.Close()
+}
@@ -0,0 +1,105 @@
+package parquet
+
+import (
+	"encoding/json"
+	"strconv"
+)
+
+//inspector infers columns from records, columns are ordered by the first occurrence
+type inspector struct {
+	columns []*Column
+	index   map[string]*Column
+}
+
+func (i *inspector) inspect(entries []entry) {
+	for _, entry := range entries {
+		kind, repeated := inferType(entry.value)
+		column, ok := i.index[entry.key]
+		if !ok {
+			column = &Column{Name: entry.key, Type: kind, Repeated: repeated}
+			i.index[entry.key] = column
+			i.columns = append(i.columns, column)
+			continue
+		}
+		if kind == "" && !repeated {
+			continue
+		}
+		if (column.Type != "" || column.Repeated) && column.Repeated != repeated {
+			column.Type, column.Repeated = TypeString, false
+			continue
+		}
+		column.Type = mergeTypes(column.Type, kind)
+		column.Repeated = repeated
+	}
+}
+
+//schema returns inferred columns, columns holding only nulls are strings
+func (i *inspector) schema() []*Column {
+	for _, column := range i.columns {
+		if column.Type == "" {
+			column.Type = TypeString
+		}
+	}
+	return i.columns
+}
+
+//inferType returns value column type, empty type for nulls and empty slices
+func inferType(value interface{}) (string, bool) {
+	switch actual := value.(type) {
+	case nil:
+		return "", false
+	case bool:
+		return TypeBoolean, false
+	case json.Number:
+		if _, err := actual.Int64(); err == nil {
+			return TypeInt64, false
+		}
+		return TypeDouble, false
+	case string:
+		return TypeString, false
+	case text:
+		return inferText(string(actual)), false
+	case []interface{}:
+		result := ""
+		for _, item := range actual {
+			kind, _ := inferType(item)
+			result = mergeTypes(result, kind)
+		}
+		return result, true
+	}
+	return TypeString, false
+}
+
+//inferText returns CSV cell type
+func inferText(value string) string {
+	if value == "" {
+		return ""
+	}
+	if value == "true" || value == "false" {
+		return TypeBoolean
+	}
+	if _, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return TypeInt64
+	}
+	if _, err := strconv.ParseFloat(value, 64); err == nil {
+		return TypeDouble
+	}
+	return TypeString
+}
+
+//mergeTypes returns type compatible with both types, int64 widens to double, other conflicts fall back to string
+func mergeTypes(current, kind string) string {
+	switch {
+	case current == "" || current == kind:
+		return kind
+	case kind == "":
+		return current
+	case (current == TypeInt64 && kind == TypeDouble) || (current == TypeDouble && kind == TypeInt64):
+		return TypeDouble
+	}
+	return TypeString
+}
+
+func newInspector() *inspector {
+	return &inspector{index: map[string]*Column{}}
+}
@@ -0,0 +1,140 @@
+package parquet
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"github.com/pkg/errors"
+	"io"
+)
+
+//entry represents flattened record field in the record order
+type entry struct {
+	key   string
+	value interface{}
+}
+
+//text represents untyped CSV cell value
+type text string
+
+//reader represents flattened record reader, it returns io.EOF once all records were read
+type reader interface {
+	read() ([]entry, error)
+}
+
+//jsonReader reads NDJSON records, nested objects are flattened to dotted keys
+type jsonReader struct {
+	decoder *json.Decoder
+	entries []entry
+}
+
+func (r *jsonReader) read() ([]entry, error) {
+	var raw json.RawMessage
+	if err := r.decoder.Decode(&raw); err != nil {
+		return nil, err
+	}
+	r.entries = r.entries[:0]
+	if len(raw) == 0 || raw[0] != '{' {
+		return nil, errors.Errorf("invalid JSON record: %s", raw)
+	}
+	err := r.flatten("", raw)
+	return r.entries, err
+}
+
+//flatten appends object fields in the document order
+func (r *jsonReader) flatten(prefix string, raw json.RawMessage) error {
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	if _, err := decoder.Token(); err != nil {
+		return err
+	}
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+		key, _ := token.(string)
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+		var value json.RawMessage
+		if err = decoder.Decode(&value); err != nil {
+			return err
+		}
+		if len(value) > 0 && value[0] == '{' {
+			if err = r.flatten(key, value); err != nil {
+				return err
+			}
+			continue
+		}
+		item, err := jsonValue(value)
+		if err != nil {
+			return errors.Wrapf(err, "invalid JSON field %v", key)
+		}
+		r.entries = append(r.entries, entry{key: key, value: item})
+	}
+	return nil
+}
+
+//jsonValue returns scalar or slice of scalars value, nested arrays and arrays of objects are returned as JSON text
+func jsonValue(raw json.RawMessage) (interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	var result interface{}
+	if err := decoder.Decode(&result); err != nil {
+		return nil, err
+	}
+	if items, ok := result.([]interface{}); ok {
+		for _, item := range items {
+			switch item.(type) {
+			case map[string]interface{}, []interface{}:
+				return string(raw), nil
+			}
+		}
+	}
+	return result, nil
+}
+
+//csvReader reads CSV records, columns are defined by the header record or by the schema
+type csvReader struct {
+	reader  *csv.Reader
+	keys    []string
+	header  bool
+	entries []entry
+}
+
+func (r *csvReader) read() ([]entry, error) {
+	record, err := r.reader.Read()
+	if err != nil {
+		return nil, err
+	}
+	if r.header {
+		r.header = false
+		r.keys = record
+		return r.read()
+	}
+	if len(r.keys) == 0 {
+		return nil, errors.New("csv columns were not defined: use header record or parquet schema")
+	}
+	r.entries = r.entries[:0]
+	for i, value := range record {
+		if i >= len(r.keys) {
+			return nil, errors.Errorf("csv record has more fields than columns: %v", len(r.keys))
+		}
+		r.entries = append(r.entries, entry{key: r.keys[i], value: text(value)})
+	}
+	return r.entries, nil
+}
+
+func newReader(source io.Reader, options *Options, columns []*Column) reader {
+	if options.Format == FormatCSV {
+		result := &csvReader{reader: csv.NewReader(bufio.NewReader(source)), header: options.Header}
+		result.reader.Comma = rune(options.Delimiter[0])
+		result.reader.FieldsPerRecord = -1
+		for _, column := range columns {
+			result.keys = append(result.keys, column.Name)
+		}
+		return result
+	}
+	return &jsonReader{decoder: json.NewDecoder(bufio.NewReader(source))}
+}
@@ -0,0 +1,121 @@
+package parquet
+
+import (
+	"github.com/pkg/errors"
+	"github.com/viant/tapper/io/encoder"
+	"reflect"
+)
+
+const (
+	//TypeBoolean boolean column type
+	TypeBoolean = "boolean"
+	//TypeInt64 64 bit signed integer column type
+	TypeInt64 = "int64"
+	//TypeDouble double precision float column type
+	TypeDouble = "double"
+	//TypeString UTF-8 string column type
+	TypeString = "string"
+)
+
+//physical types
+const (
+	physicalBoolean   = 0
+	physicalInt64     = 2
+	physicalDouble    = 5
+	physicalByteArray = 6
+)
+
+//Column represents parquet column, non repeated columns are optional, missing values are written as nulls
+type Column struct {
+	Name     string //column name, nested object fields use dotted names, i.e. user.id
+	Type     string //boolean, int64, double or string
+	Repeated bool   //repeated column holds slice values
+}
+
+func (c *Column) physicalType() int32 {
+	switch c.Type {
+	case TypeBoolean:
+		return physicalBoolean
+	case TypeInt64:
+		return physicalInt64
+	case TypeDouble:
+		return physicalDouble
+	}
+	return physicalByteArray
+}
+
+//validateSchema returns an error if columns are empty, duplicated or of unsupported type
+func validateSchema(columns []*Column) error {
+	if len(columns) == 0 {
+		return errors.New("parquet schema was empty")
+	}
+	names := make(map[string]bool, len(columns))
+	for _, column := range columns {
+		if column.Name == "" {
+			return errors.New("parquet column name was empty")
+		}
+		if names[column.Name] {
+			return errors.Errorf("duplicate parquet column: %v", column.Name)
+		}
+		names[column.Name] = true
+		switch column.Type {
+		case TypeBoolean, TypeInt64, TypeDouble, TypeString:
+		default:
+			return errors.Errorf("unsupported parquet column %v type: %v", column.Name, column.Type)
+		}
+	}
+	return nil
+}
+
+//NewSchema returns parquet columns for struct encoder provider fields in encoding order, nested struct fields use dotted names,
+//struct slices are JSON text columns, text marshaler and stringer fields are string columns,
+//recursive struct, map and custom stream encoder fields are skipped
+func NewSchema(provider *encoder.Provider) []*Column {
+	return appendColumns(make([]*Column, 0, len(provider.Fields)), "", provider, map[*encoder.Provider]bool{})
+}
+
+func appendColumns(result []*Column, prefix string, provider *encoder.Provider, visited map[*encoder.Provider]bool) []*Column {
+	visited[provider] = true
+	for _, field := range provider.Fields {
+		column := &Column{Name: prefix + field.Name, Type: TypeString}
+		kind := field.Kind()
+		switch field.Marshaling {
+		case encoder.MarshalText, encoder.MarshalString:
+			result = append(result, column)
+			continue
+		case encoder.MarshalStream, encoder.MarshalObject: //encoded value type is not known upfront
+			continue
+		}
+		if kind == reflect.Map { //map keys are not known upfront
+			continue
+		}
+		if field.Nested != nil {
+			if kind != reflect.Slice {
+				if !visited[field.Nested] {
+					result = appendColumns(result, column.Name+".", field.Nested, visited)
+				}
+				continue
+			}
+			kind = reflect.String
+		}
+		if kind == reflect.Slice && field.Elem().Kind() != reflect.Uint8 { //byte slice is base64 text
+			column.Repeated = true
+			kind = field.Elem().Kind()
+		}
+		switch kind {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			column.Type = TypeInt64
+		case reflect.Float64, reflect.Float32:
+			column.Type = TypeDouble
+		case reflect.Bool:
+			column.Type = TypeBoolean
+		}
+		if field.AsString {
+			column.Type = TypeString
+		}
+		result = append(result, column)
+	}
+	delete(visited, provider)
+	return result
+}
@@ -0,0 +1,97 @@
+package parquet
+
+//thrift compact protocol field types
+const (
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+//compact represents minimal thrift compact protocol encoder used for page headers and file metadata
+type compact struct {
+	data  []byte
+	last  int16
+	stack []int16
+}
+
+func (c *compact) reset() {
+	c.data = c.data[:0]
+	c.last = 0
+	c.stack = c.stack[:0]
+}
+
+func (c *compact) uvarint(value uint64) {
+	for value >= 0x80 {
+		c.data = append(c.data, byte(value)|0x80)
+		value >>= 7
+	}
+	c.data = append(c.data, byte(value))
+}
+
+func (c *compact) varint(value int64) {
+	c.uvarint(uint64((value << 1) ^ (value >> 63)))
+}
+
+//field writes field header, short form encodes field id delta in the type byte
+func (c *compact) field(id int16, kind byte) {
+	if delta := id - c.last; delta > 0 && delta <= 15 {
+		c.data = append(c.data, byte(delta<<4)|kind)
+	} else {
+		c.data = append(c.data, kind)
+		c.varint(int64(id))
+	}
+	c.last = id
+}
+
+func (c *compact) i32(id int16, value int32) {
+	c.field(id, thriftI32)
+	c.varint(int64(value))
+}
+
+func (c *compact) i64(id int16, value int64) {
+	c.field(id, thriftI64)
+	c.varint(value)
+}
+
+func (c *compact) binary(id int16, value string) {
+	c.field(id, thriftBinary)
+	c.string(value)
+}
+
+func (c *compact) string(value string) {
+	c.uvarint(uint64(len(value)))
+	c.data = append(c.data, value...)
+}
+
+func (c *compact) list(id int16, kind byte, size int) {
+	c.field(id, thriftList)
+	if size < 15 {
+		c.data = append(c.data, byte(size<<4)|kind)
+		return
+	}
+	c.data = append(c.data, 0xf0|kind)
+	c.uvarint(uint64(size))
+}
+
+//beginStruct writes struct field header and opens nested field id scope
+func (c *compact) beginStruct(id int16) {
+	c.field(id, thriftStruct)
+	c.beginElement()
+}
+
+//beginElement opens struct list element field id scope
+func (c *compact) beginElement() {
+	c.stack = append(c.stack, c.last)
+	c.last = 0
+}
+
+//end writes struct stop marker and restores enclosing field id scope
+func (c *compact) end() {
+	c.data = append(c.data, 0)
+	if n := len(c.stack); n > 0 {
+		c.last = c.stack[n-1]
+		c.stack = c.stack[:n-1]
+	}
+}
@@ -0,0 +1,152 @@
+package parquet
+
+import (
+	"encoding/json"
+	"github.com/pkg/errors"
+	"strconv"
+	"strings"
+)
+
+//isNull returns true for nil and empty CSV cell of non string column
+func isNull(value interface{}, kind string) bool {
+	switch actual := value.(type) {
+	case nil:
+		return true
+	case text:
+		return actual == "" && kind != TypeString
+	}
+	return false
+}
+
+//asSlice returns repeated column items, CSV cell is split with slice delimiter
+func (w *Writer) asSlice(value interface{}) []interface{} {
+	switch actual := value.(type) {
+	case nil:
+		return nil
+	case []interface{}:
+		return actual
+	case text:
+		if actual == "" {
+			return nil
+		}
+		items := strings.Split(string(actual), w.options.SliceDelimiter)
+		result := make([]interface{}, len(items))
+		for i, item := range items {
+			result[i] = text(item)
+		}
+		return result
+	case []string:
+		result := make([]interface{}, len(actual))
+		for i, item := range actual {
+			result[i] = item
+		}
+		return result
+	case []int:
+		result := make([]interface{}, len(actual))
+		for i, item := range actual {
+			result[i] = item
+		}
+		return result
+	case []int64:
+		result := make([]interface{}, len(actual))
+		for i, item := range actual {
+			result[i] = item
+		}
+		return result
+	case []float64:
+		result := make([]interface{}, len(actual))
+		for i, item := range actual {
+			result[i] = item
+		}
+		return result
+	case []bool:
+		result := make([]interface{}, len(actual))
+		for i, item := range actual {
+			result[i] = item
+		}
+		return result
+	}
+	return []interface{}{value}
+}
+
+func asBool(value interface{}) (bool, error) {
+	switch actual := value.(type) {
+	case bool:
+		return actual, nil
+	case string:
+		return strconv.ParseBool(actual)
+	case text:
+		return strconv.ParseBool(string(actual))
+	}
+	return false, errors.Errorf("expected boolean, but had: %T", value)
+}
+
+func asInt64(value interface{}) (int64, error) {
+	switch actual := value.(type) {
+	case int:
+		return int64(actual), nil
+	case int64:
+		return actual, nil
+	case int32:
+		return int64(actual), nil
+	case uint:
+		return int64(actual), nil
+	case uint64:
+		return int64(actual), nil
+	case uint32:
+		return int64(actual), nil
+	case float64:
+		if actual == float64(int64(actual)) {
+			return int64(actual), nil
+		}
+	case json.Number:
+		return strconv.ParseInt(string(actual), 10, 64)
+	case string:
+		return strconv.ParseInt(actual, 10, 64)
+	case text:
+		return strconv.ParseInt(string(actual), 10, 64)
+	}
+	return 0, errors.Errorf("expected int64, but had: %T(%v)", value, value)
+}
+
+func asFloat64(value interface{}) (float64, error) {
+	switch actual := value.(type) {
+	case float64:
+		return actual, nil
+	case float32:
+		return float64(actual), nil
+	case int:
+		return float64(actual), nil
+	case int64:
+		return float64(actual), nil
+	case json.Number:
+		return actual.Float64()
+	case string:
+		return strconv.ParseFloat(actual, 64)
+	case text:
+		return strconv.ParseFloat(string(actual), 64)
+	}
+	return 0, errors.Errorf("expected double, but had: %T", value)
+}
+
+//asString returns string value, slices and maps are returned as JSON
+func asString(value interface{}) (string, error) {
+	switch actual := value.(type) {
+	case string:
+		return actual, nil
+	case text:
+		return string(actual), nil
+	case json.Number:
+		return string(actual), nil
+	case bool:
+		return strconv.FormatBool(actual), nil
+	case int:
+		return strconv.Itoa(actual), nil
+	case int64:
+		return strconv.FormatInt(actual, 10), nil
+	case float64:
+		return strconv.FormatFloat(actual, 'g', -1, 64), nil
+	}
+	data, err := json.Marshal(value)
+	return string(data), err
+}
@@ -0,0 +1,470 @@
+package parquet
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"github.com/golang/snappy"
+	"github.com/pkg/errors"
+	"io"
+	"math"
+)
+
+const (
+	//FormatJSON newline delimited JSON records
+	FormatJSON = "json"
+	//FormatCSV CSV records
+	FormatCSV = "csv"
+
+	//CompressionSnappy compresses pages with snappy
+	CompressionSnappy = "snappy"
+	//CompressionGzip compresses pages with gzip
+	CompressionGzip = "gzip"
+	//CompressionNone writes uncompressed pages
+	CompressionNone = "none"
+
+	//DefaultRowGroupSize default max number of rows per row group
+	DefaultRowGroupSize = 64 * 1024
+
+	defaultDelimiter      = ","
+	defaultSliceDelimiter = ":"
+)
+
+var magic = []byte("PAR1")
+
+//encodings, page and codec identifiers
+const (
+	encodingPlain = 0
+	encodingRLE   = 3
+
+	repetitionOptional = 1
+	repetitionRepeated = 2
+
+	convertedUTF8 = 0
+
+	codecUncompressed = 0
+	codecSnappy       = 1
+	codecGzip         = 2
+)
+
+//Options represents conversion options
+type Options struct {
+	Format         string //source record format: json (default) or csv
+	Delimiter      string //CSV field delimiter, "," by default
+	SliceDelimiter string //CSV repeated value delimiter, ":" by default
+	Header         bool   //CSV source starts with header record
+	RowGroupSize   int    //max number of rows per row group
+	Compression    string //page compression: snappy (default), gzip or none
+}
+
+//Init initialises default options
+func (o *Options) Init() error {
+	if o.Format == "" {
+		o.Format = FormatJSON
+	}
+	if o.Format != FormatJSON && o.Format != FormatCSV {
+		return errors.Errorf("unsupported parquet source format: %v", o.Format)
+	}
+	if o.Delimiter == "" {
+		o.Delimiter = defaultDelimiter
+	}
+	if o.SliceDelimiter == "" {
+		o.SliceDelimiter = defaultSliceDelimiter
+	}
+	if o.RowGroupSize == 0 {
+		o.RowGroupSize = DefaultRowGroupSize
+	}
+	if o.RowGroupSize < 0 {
+		return errors.Errorf("invalid parquet row group size: %v", o.RowGroupSize)
+	}
+	if o.Compression == "" {
+		o.Compression = CompressionSnappy
+	}
+	switch o.Compression {
+	case CompressionSnappy, CompressionGzip, CompressionNone:
+	default:
+		return errors.Errorf("unsupported parquet compression: %v", o.Compression)
+	}
+	return nil
+}
+
+func (o *Options) codec() int32 {
+	switch o.Compression {
+	case CompressionSnappy:
+		return codecSnappy
+	case CompressionGzip:
+		return codecGzip
+	}
+	return codecUncompressed
+}
+
+//chunk represents buffered column values of the current row group
+type chunk struct {
+	column      *Column
+	values      bytes.Buffer
+	booleans    []bool
+	definitions []byte
+	repetitions []byte
+}
+
+func (c *chunk) reset() {
+	c.values.Reset()
+	c.booleans = c.booleans[:0]
+	c.definitions = c.definitions[:0]
+	c.repetitions = c.repetitions[:0]
+}
+
+//columnChunk represents written column chunk metadata
+type columnChunk struct {
+	offset       int64
+	values       int64
+	uncompressed int64
+	compressed   int64
+}
+
+//rowGroup represents written row group metadata
+type rowGroup struct {
+	chunks []columnChunk
+	size   int64
+	rows   int64
+}
+
+//Writer represents parquet file writer, every column is written as a single PLAIN encoded data page per row group,
+//rows are buffered in memory until row group size is reached
+type Writer struct {
+	writer     io.Writer
+	offset     int64
+	options    Options
+	columns    []*Column
+	index      map[string]int
+	chunks     []*chunk
+	values     []interface{}
+	rows       int
+	groups     []rowGroup
+	thrift     compact
+	page       bytes.Buffer
+	compressed bytes.Buffer
+	snappy     []byte
+	gzip       *gzip.Writer
+	number     [8]byte
+}
+
+//Write appends a record, values of missing columns are written as nulls
+func (w *Writer) Write(record map[string]interface{}) error {
+	for i, column := range w.columns {
+		w.values[i] = record[column.Name]
+	}
+	return w.writeRow()
+}
+
+//writeEntries appends flattened record, entries without a column are skipped
+func (w *Writer) writeEntries(entries []entry) error {
+	for i := range w.values {
+		w.values[i] = nil
+	}
+	for _, entry := range entries {
+		if i, ok := w.index[entry.key]; ok {
+			w.values[i] = entry.value
+		}
+	}
+	return w.writeRow()
+}
+
+func (w *Writer) writeRow() error {
+	for i, chunk := range w.chunks {
+		if err := w.append(chunk, w.values[i]); err != nil {
+			return err
+		}
+	}
+	w.rows++
+	if w.rows >= w.options.RowGroupSize {
+		return w.flush()
+	}
+	return nil
+}
+
+func (w *Writer) append(chunk *chunk, value interface{}) error {
+	if !chunk.column.Repeated {
+		if isNull(value, chunk.column.Type) {
+			chunk.definitions = append(chunk.definitions, 0)
+			return nil
+		}
+		chunk.definitions = append(chunk.definitions, 1)
+		return w.appendValue(chunk, value)
+	}
+	items := w.asSlice(value)
+	if len(items) == 0 {
+		chunk.repetitions = append(chunk.repetitions, 0)
+		chunk.definitions = append(chunk.definitions, 0)
+		return nil
+	}
+	for i, item := range items {
+		if item == nil {
+			return errors.Errorf("null item in parquet repeated column: %v", chunk.column.Name)
+		}
+		repetition := byte(1)
+		if i == 0 {
+			repetition = 0
+		}
+		chunk.repetitions = append(chunk.repetitions, repetition)
+		chunk.definitions = append(chunk.definitions, 1)
+		if err := w.appendValue(chunk, item); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//appendValue appends PLAIN encoded value
+func (w *Writer) appendValue(chunk *chunk, value interface{}) error {
+	switch chunk.column.Type {
+	case TypeBoolean:
+		converted, err := asBool(value)
+		if err != nil {
+			return errors.Wrapf(err, "invalid parquet column %v value", chunk.column.Name)
+		}
+		chunk.booleans = append(chunk.booleans, converted)
+	case TypeInt64:
+		converted, err := asInt64(value)
+		if err != nil {
+			return errors.Wrapf(err, "invalid parquet column %v value", chunk.column.Name)
+		}
+		binary.LittleEndian.PutUint64(w.number[:], uint64(converted))
+		chunk.values.Write(w.number[:])
+	case TypeDouble:
+		converted, err := asFloat64(value)
+		if err != nil {
+			return errors.Wrapf(err, "invalid parquet column %v value", chunk.column.Name)
+		}
+		binary.LittleEndian.PutUint64(w.number[:], math.Float64bits(converted))
+		chunk.values.Write(w.number[:])
+	default:
+		converted, err := asString(value)
+		if err != nil {
+			return errors.Wrapf(err, "invalid parquet column %v value", chunk.column.Name)
+		}
+		binary.LittleEndian.PutUint32(w.number[:4], uint32(len(converted)))
+		chunk.values.Write(w.number[:4])
+		chunk.values.WriteString(converted)
+	}
+	return nil
+}
+
+//flush writes buffered rows as a row group
+func (w *Writer) flush() error {
+	if w.rows == 0 {
+		return nil
+	}
+	group := rowGroup{rows: int64(w.rows), chunks: make([]columnChunk, len(w.chunks))}
+	for i, chunk := range w.chunks {
+		if err := w.writeChunk(chunk, &group.chunks[i]); err != nil {
+			return err
+		}
+		group.size += group.chunks[i].uncompressed
+		chunk.reset()
+	}
+	w.groups = append(w.groups, group)
+	w.rows = 0
+	return nil
+}
+
+//writeChunk writes column chunk as a single data page: repetition levels, definition levels and values
+func (w *Writer) writeChunk(chunk *chunk, meta *columnChunk) error {
+	w.page.Reset()
+	if chunk.column.Repeated {
+		appendLevels(&w.page, chunk.repetitions)
+	}
+	appendLevels(&w.page, chunk.definitions)
+	if chunk.column.Type == TypeBoolean {
+		appendBooleans(&w.page, chunk.booleans)
+	} else {
+		w.page.Write(chunk.values.Bytes())
+	}
+	data, err := w.compress(w.page.Bytes())
+	if err != nil {
+		return err
+	}
+	w.thrift.reset()
+	w.thrift.i32(1, 0) //data page
+	w.thrift.i32(2, int32(w.page.Len()))
+	w.thrift.i32(3, int32(len(data)))
+	w.thrift.beginStruct(5)
+	w.thrift.i32(1, int32(len(chunk.definitions)))
+	w.thrift.i32(2, encodingPlain)
+	w.thrift.i32(3, encodingRLE)
+	w.thrift.i32(4, encodingRLE)
+	w.thrift.end()
+	w.thrift.end()
+	meta.offset = w.offset
+	meta.values = int64(len(chunk.definitions))
+	meta.uncompressed = int64(len(w.thrift.data) + w.page.Len())
+	meta.compressed = int64(len(w.thrift.data) + len(data))
+	if err = w.write(w.thrift.data); err == nil {
+		err = w.write(data)
+	}
+	return err
+}
+
+func (w *Writer) compress(data []byte) ([]byte, error) {
+	switch w.options.Compression {
+	case CompressionSnappy:
+		w.snappy = snappy.Encode(w.snappy[:cap(w.snappy)], data)
+		return w.snappy, nil
+	case CompressionGzip:
+		w.compressed.Reset()
+		if w.gzip == nil {
+			w.gzip = gzip.NewWriter(&w.compressed)
+		} else {
+			w.gzip.Reset(&w.compressed)
+		}
+		if _, err := w.gzip.Write(data); err != nil {
+			return nil, err
+		}
+		if err := w.gzip.Close(); err != nil {
+			return nil, err
+		}
+		return w.compressed.Bytes(), nil
+	}
+	return data, nil
+}
+
+func (w *Writer) write(data []byte) error {
+	n, err := w.writer.Write(data)
+	w.offset += int64(n)
+	return err
+}
+
+//Close writes pending row group and file footer, it does not close underlying writer
+func (w *Writer) Close() error {
+	if err := w.flush(); err != nil {
+		return err
+	}
+	metadata := w.metadata()
+	binary.LittleEndian.PutUint32(w.number[:4], uint32(len(metadata)))
+	if err := w.write(metadata); err != nil {
+		return err
+	}
+	if err := w.write(w.number[:4]); err != nil {
+		return err
+	}
+	return w.write(magic)
+}
+
+//metadata returns thrift encoded file metadata
+func (w *Writer) metadata() []byte {
+	m := &w.thrift
+	m.reset()
+	m.i32(1, 1) //version
+	m.list(2, thriftStruct, len(w.columns)+1)
+	m.beginElement()
+	m.binary(4, "schema")
+	m.i32(5, int32(len(w.columns)))
+	m.end()
+	for _, column := range w.columns {
+		m.beginElement()
+		m.i32(1, column.physicalType())
+		repetition := int32(repetitionOptional)
+		if column.Repeated {
+			repetition = repetitionRepeated
+		}
+		m.i32(3, repetition)
+		m.binary(4, column.Name)
+		if column.Type == TypeString {
+			m.i32(6, convertedUTF8)
+		}
+		m.end()
+	}
+	rows := int64(0)
+	for _, group := range w.groups {
+		rows += group.rows
+	}
+	m.i64(3, rows)
+	m.list(4, thriftStruct, len(w.groups))
+	for _, group := range w.groups {
+		m.beginElement()
+		m.list(1, thriftStruct, len(group.chunks))
+		for i, chunk := range group.chunks {
+			column := w.columns[i]
+			m.beginElement()
+			m.i64(2, chunk.offset)
+			m.beginStruct(3)
+			m.i32(1, column.physicalType())
+			m.list(2, thriftI32, 2)
+			m.varint(encodingPlain)
+			m.varint(encodingRLE)
+			m.list(3, thriftBinary, 1)
+			m.string(column.Name)
+			m.i32(4, w.options.codec())
+			m.i64(5, chunk.values)
+			m.i64(6, chunk.uncompressed)
+			m.i64(7, chunk.compressed)
+			m.i64(9, chunk.offset)
+			m.end()
+			m.end()
+		}
+		m.i64(2, group.size)
+		m.i64(3, group.rows)
+		m.end()
+	}
+	m.binary(6, "tapper")
+	m.end()
+	return m.data
+}
+
+//appendLevels appends length prefixed levels encoded as RLE runs of bit width 1
+func appendLevels(buffer *bytes.Buffer, levels []byte) {
+	var runs compact
+	for i := 0; i < len(levels); {
+		j := i + 1
+		for j < len(levels) && levels[j] == levels[i] {
+			j++
+		}
+		runs.uvarint(uint64(j-i) << 1)
+		runs.data = append(runs.data, levels[i])
+		i = j
+	}
+	var size [4]byte
+	binary.LittleEndian.PutUint32(size[:], uint32(len(runs.data)))
+	buffer.Write(size[:])
+	buffer.Write(runs.data)
+}
+
+//appendBooleans appends PLAIN encoded booleans, bit packed starting from the least significant bit
+func appendBooleans(buffer *bytes.Buffer, values []bool) {
+	var packed byte
+	for i, value := range values {
+		if value {
+			packed |= 1 << uint(i%8)
+		}
+		if i%8 == 7 {
+			buffer.WriteByte(packed)
+			packed = 0
+		}
+	}
+	if len(values)%8 != 0 {
+		buffer.WriteByte(packed)
+	}
+}
+
+//NewWriter creates parquet writer and writes file magic
+func NewWriter(writer io.Writer, columns []*Column, options *Options) (*Writer, error) {
+	if err := validateSchema(columns); err != nil {
+		return nil, err
+	}
+	result := &Writer{writer: writer, columns: columns, index: make(map[string]int, len(columns))}
+	if options != nil {
+		result.options = *options
+	}
+	if err := result.options.Init(); err != nil {
+		return nil, err
+	}
+	result.values = make([]interface{}, len(columns))
+	for i, column := range columns {
+		result.index[column.Name] = i
+		result.chunks = append(result.chunks, &chunk{column: column})
+	}
+	if err := result.write(magic); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
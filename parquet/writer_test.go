@@ -0,0 +1,559 @@
+package parquet
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"github.com/golang/snappy"
+	"github.com/stretchr/testify/assert"
+	"github.com/viant/tapper/io/encoder"
+	"io/ioutil"
+	"math"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+//thrift compact protocol and parquet.thrift identifiers, test reader declares them independently of the writer,
+//so identifier mistakes are not shared between the writer and the reader
+const (
+	specI32    = 5
+	specI64    = 6
+	specBinary = 8
+	specList   = 9
+	specStruct = 12
+
+	specBoolean   = 0
+	specInt64     = 2
+	specDouble    = 5
+	specByteArray = 6
+
+	specOptional = 1
+	specRepeated = 2
+
+	specUTF8 = 0
+
+	specUncompressed = 0
+	specSnappy       = 1
+	specGzip         = 2
+
+	specPlain    = 0
+	specRLE      = 3
+	specDataPage = 0
+)
+
+//testFile represents decoded parquet file
+type testFile struct {
+	columns []*Column
+	codec   int64
+	groups  []int64
+	rows    []map[string]interface{}
+}
+
+//thriftReader represents minimal thrift compact protocol decoder
+type thriftReader struct {
+	data []byte
+	pos  int
+}
+
+func (r *thriftReader) uvarint() uint64 {
+	value, n := binary.Uvarint(r.data[r.pos:])
+	r.pos += n
+	return value
+}
+
+func (r *thriftReader) varint() int64 {
+	value := r.uvarint()
+	return int64(value>>1) ^ -int64(value&1)
+}
+
+func (r *thriftReader) readStruct() map[int16]interface{} {
+	result := map[int16]interface{}{}
+	last := int16(0)
+	for {
+		header := r.data[r.pos]
+		r.pos++
+		if header == 0 {
+			return result
+		}
+		id := last + int16(header>>4)
+		if header>>4 == 0 {
+			id = int16(r.varint())
+		}
+		last = id
+		result[id] = r.readValue(header & 0x0f)
+	}
+}
+
+func (r *thriftReader) readValue(kind byte) interface{} {
+	switch kind {
+	case specI32, specI64:
+		return r.varint()
+	case specBinary:
+		size := int(r.uvarint())
+		r.pos += size
+		return string(r.data[r.pos-size : r.pos])
+	case specList:
+		header := r.data[r.pos]
+		r.pos++
+		size := int(header >> 4)
+		if size == 15 {
+			size = int(r.uvarint())
+		}
+		var result []interface{}
+		for i := 0; i < size; i++ {
+			result = append(result, r.readValue(header&0x0f))
+		}
+		return result
+	case specStruct:
+		return r.readStruct()
+	}
+	panic("unsupported thrift type")
+}
+
+//readLevels decodes length prefixed RLE levels
+func readLevels(data []byte, count int) ([]byte, []byte) {
+	size := int(binary.LittleEndian.Uint32(data))
+	reader := &thriftReader{data: data[4 : 4+size]}
+	var result []byte
+	for reader.pos < size {
+		header := reader.uvarint()
+		if header&1 == 1 {
+			panic("unexpected bit packed run")
+		}
+		value := reader.data[reader.pos]
+		reader.pos++
+		for i := 0; i < int(header>>1); i++ {
+			result = append(result, value)
+		}
+	}
+	if len(result) != count {
+		panic("invalid levels count")
+	}
+	return result, data[4+size:]
+}
+
+func readValue(column *Column, data []byte, index int) (interface{}, []byte) {
+	switch column.Type {
+	case TypeBoolean:
+		return data[index/8]&(1<<uint(index%8)) != 0, data
+	case TypeInt64:
+		return int64(binary.LittleEndian.Uint64(data)), data[8:]
+	case TypeDouble:
+		return math.Float64frombits(binary.LittleEndian.Uint64(data)), data[8:]
+	}
+	size := int(binary.LittleEndian.Uint32(data))
+	return string(data[4 : 4+size]), data[4+size:]
+}
+
+func readFile(t *testing.T, data []byte) *testFile {
+	assert.EqualValues(t, "PAR1", data[:4])
+	assert.EqualValues(t, "PAR1", data[len(data)-4:])
+	size := int(binary.LittleEndian.Uint32(data[len(data)-8:]))
+	footer := &thriftReader{data: data[len(data)-8-size : len(data)-8]}
+	metadata := footer.readStruct()
+	assert.EqualValues(t, size, footer.pos)
+	result := &testFile{}
+	for _, item := range metadata[2].([]interface{})[1:] {
+		element := item.(map[int16]interface{})
+		column := &Column{Name: element[4].(string), Repeated: element[3].(int64) == specRepeated}
+		switch element[1].(int64) {
+		case specBoolean:
+			column.Type = TypeBoolean
+		case specInt64:
+			column.Type = TypeInt64
+		case specDouble:
+			column.Type = TypeDouble
+		case specByteArray:
+			column.Type = TypeString
+			assert.EqualValues(t, specUTF8, element[6])
+		}
+		result.columns = append(result.columns, column)
+	}
+	for _, item := range metadata[4].([]interface{}) {
+		group := item.(map[int16]interface{})
+		rows := group[3].(int64)
+		result.groups = append(result.groups, rows)
+		offset := len(result.rows)
+		for i := int64(0); i < rows; i++ {
+			result.rows = append(result.rows, map[string]interface{}{})
+		}
+		for i, chunkItem := range group[1].([]interface{}) {
+			column := result.columns[i]
+			meta := chunkItem.(map[int16]interface{})[3].(map[int16]interface{})
+			result.codec = meta[4].(int64)
+			reader := &thriftReader{data: data[meta[9].(int64):]}
+			header := reader.readStruct()
+			assert.EqualValues(t, meta[7], int64(reader.pos)+header[3].(int64))
+			page := reader.data[reader.pos : reader.pos+int(header[3].(int64))]
+			switch result.codec {
+			case specSnappy:
+				page, _ = snappy.Decode(nil, page)
+			case specGzip:
+				gzipReader, _ := gzip.NewReader(bytes.NewReader(page))
+				page, _ = ioutil.ReadAll(gzipReader)
+			}
+			assert.EqualValues(t, header[2], len(page))
+			count := int(header[5].(map[int16]interface{})[1].(int64))
+			assert.EqualValues(t, meta[5], count)
+			var repetitions, definitions []byte
+			if column.Repeated {
+				repetitions, page = readLevels(page, count)
+			}
+			definitions, page = readLevels(page, count)
+			row, index := offset-1, 0
+			for j := 0; j < count; j++ {
+				if !column.Repeated || repetitions[j] == 0 {
+					row++
+					result.rows[row][column.Name] = nil
+				}
+				if definitions[j] == 0 {
+					continue
+				}
+				var value interface{}
+				value, page = readValue(column, page, index)
+				index++
+				if !column.Repeated {
+					result.rows[row][column.Name] = value
+					continue
+				}
+				items, _ := result.rows[row][column.Name].([]interface{})
+				result.rows[row][column.Name] = append(items, value)
+			}
+		}
+	}
+	return result
+}
+
+func TestWriter_Write(t *testing.T) {
+	columns := []*Column{
+		{Name: "id", Type: TypeInt64},
+		{Name: "name", Type: TypeString},
+		{Name: "score", Type: TypeDouble},
+		{Name: "active", Type: TypeBoolean},
+		{Name: "ids", Type: TypeInt64, Repeated: true},
+		{Name: "tags", Type: TypeString, Repeated: true},
+	}
+	records := []map[string]interface{}{
+		{"id": 1, "name": "a", "score": 1.5, "active": true, "ids": []int{1, 2}, "tags": []string{"x"}},
+		{"id": int64(-2), "active": false, "ids": []interface{}{}, "other": 1},
+		{"name": "zażółć", "score": 3, "active": true, "tags": []string{"y", "z", "w"}},
+		{"id": 4, "name": "", "active": true, "ids": []int64{1 << 40}},
+		{"id": 5, "active": true},
+		{"active": false},
+		{"active": true},
+		{"active": true},
+		{"active": true, "id": 10},
+	}
+	expect := []map[string]interface{}{
+		{"id": int64(1), "name": "a", "score": 1.5, "active": true, "ids": []interface{}{int64(1), int64(2)}, "tags": []interface{}{"x"}},
+		{"id": int64(-2), "name": nil, "score": nil, "active": false, "ids": nil, "tags": nil},
+		{"id": nil, "name": "zażółć", "score": 3.0, "active": true, "ids": nil, "tags": []interface{}{"y", "z", "w"}},
+		{"id": int64(4), "name": "", "score": nil, "active": true, "ids": []interface{}{int64(1 << 40)}, "tags": nil},
+		{"id": int64(5), "name": nil, "score": nil, "active": true, "ids": nil, "tags": nil},
+		{"id": nil, "name": nil, "score": nil, "active": false, "ids": nil, "tags": nil},
+		{"id": nil, "name": nil, "score": nil, "active": true, "ids": nil, "tags": nil},
+		{"id": nil, "name": nil, "score": nil, "active": true, "ids": nil, "tags": nil},
+		{"id": int64(10), "name": nil, "score": nil, "active": true, "ids": nil, "tags": nil},
+	}
+	var useCases = []struct {
+		description  string
+		options      *Options
+		expectCodec  int64
+		expectGroups []int64
+	}{
+		{description: "default options", expectCodec: specSnappy, expectGroups: []int64{9}},
+		{description: "gzip", options: &Options{Compression: CompressionGzip, RowGroupSize: 4}, expectCodec: specGzip, expectGroups: []int64{4, 4, 1}},
+		{description: "uncompressed", options: &Options{Compression: CompressionNone, RowGroupSize: 3}, expectCodec: specUncompressed, expectGroups: []int64{3, 3, 3}},
+	}
+	for _, useCase := range useCases {
+		buffer := new(bytes.Buffer)
+		writer, err := NewWriter(buffer, columns, useCase.options)
+		if !assert.Nil(t, err, useCase.description) {
+			continue
+		}
+		for _, record := range records {
+			assert.Nil(t, writer.Write(record), useCase.description)
+		}
+		assert.Nil(t, writer.Close(), useCase.description)
+		actual := readFile(t, buffer.Bytes())
+		assert.EqualValues(t, columns, actual.columns, useCase.description)
+		assert.EqualValues(t, useCase.expectCodec, actual.codec, useCase.description)
+		assert.EqualValues(t, useCase.expectGroups, actual.groups, useCase.description)
+		assert.EqualValues(t, expect, actual.rows, useCase.description)
+	}
+}
+
+func TestWriter_Write_Metadata(t *testing.T) {
+	columns := []*Column{
+		{Name: "id", Type: TypeInt64},
+		{Name: "user.name", Type: TypeString},
+		{Name: "tags", Type: TypeString, Repeated: true},
+	}
+	buffer := new(bytes.Buffer)
+	writer, err := NewWriter(buffer, columns, &Options{Compression: CompressionGzip, RowGroupSize: 2})
+	if !assert.Nil(t, err) {
+		return
+	}
+	for _, record := range []map[string]interface{}{{"id": 1, "user.name": "a", "tags": []string{"x", "y"}}, {"id": 2}, {"tags": []string{"z"}}} {
+		assert.Nil(t, writer.Write(record))
+	}
+	assert.Nil(t, writer.Close())
+	data := buffer.Bytes()
+	size := int(binary.LittleEndian.Uint32(data[len(data)-8:]))
+	metadata := (&thriftReader{data: data[len(data)-8-size : len(data)-8]}).readStruct()
+	for _, id := range []int16{1, 2, 3, 4} { //FileMetaData required fields: version, schema, num_rows, row_groups
+		assert.Contains(t, metadata, id, "file metadata field")
+	}
+	assert.EqualValues(t, 3, metadata[3])
+	schema := metadata[2].([]interface{})
+	root := schema[0].(map[int16]interface{})
+	assert.EqualValues(t, len(columns), root[5], "root num_children")
+	for i, column := range columns {
+		element := schema[i+1].(map[int16]interface{})
+		assert.EqualValues(t, column.Name, element[4], "schema element name")
+		repetition := int64(specOptional)
+		if column.Repeated {
+			repetition = specRepeated
+		}
+		assert.EqualValues(t, repetition, element[3], column.Name)
+	}
+	offset := int64(len("PAR1"))
+	groups := metadata[4].([]interface{})
+	assert.EqualValues(t, 2, len(groups))
+	for _, item := range groups {
+		group := item.(map[int16]interface{})
+		chunks := group[1].([]interface{})
+		assert.EqualValues(t, len(columns), len(chunks))
+		var uncompressed int64
+		for i, chunkItem := range chunks {
+			chunk := chunkItem.(map[int16]interface{})
+			meta := chunk[3].(map[int16]interface{})
+			for _, id := range []int16{1, 2, 3, 4, 5, 6, 7, 9} { //ColumnMetaData required fields
+				assert.Contains(t, meta, id, "column metadata field")
+			}
+			assert.EqualValues(t, []interface{}{int64(specPlain), int64(specRLE)}, meta[2], "column encodings")
+			assert.EqualValues(t, []interface{}{columns[i].Name}, meta[3], "path in schema")
+			assert.EqualValues(t, specGzip, meta[4])
+			assert.EqualValues(t, offset, chunk[2], "chunks are contiguous after magic")
+			assert.EqualValues(t, offset, meta[9], "data page follows chunk offset")
+			reader := &thriftReader{data: data[offset:]}
+			header := reader.readStruct()
+			assert.EqualValues(t, specDataPage, header[1], "page type")
+			assert.EqualValues(t, int64(reader.pos)+header[2].(int64), meta[6], "total uncompressed size includes page header")
+			assert.EqualValues(t, int64(reader.pos)+header[3].(int64), meta[7], "total compressed size includes page header")
+			page := header[5].(map[int16]interface{})
+			assert.EqualValues(t, meta[5], page[1], "page num_values")
+			assert.EqualValues(t, []interface{}{int64(specPlain), int64(specRLE), int64(specRLE)}, []interface{}{page[2], page[3], page[4]}, "page encodings")
+			offset += meta[7].(int64)
+			uncompressed += meta[6].(int64)
+		}
+		assert.EqualValues(t, uncompressed, group[2], "row group total_byte_size")
+	}
+	assert.EqualValues(t, len(data)-8-size, offset, "footer follows last chunk")
+}
+
+func TestConvert(t *testing.T) {
+	var useCases = []struct {
+		description   string
+		source        string
+		options       *Options
+		columns       []*Column
+		expectColumns []*Column
+		expect        []map[string]interface{}
+	}{
+		{
+			description: "inferred json",
+			source: `{"id":1,"user":{"name":"a","age":3},"score":1,"tags":["x","y"],"objects":[{"a":1}],"mixed":1}
+{"id":2,"user":{"name":"b"},"score":2.5,"tags":[],"mixed":"x","note":null}
+{"id":3,"active":true,"tags":null}
+`,
+			expectColumns: []*Column{
+				{Name: "id", Type: TypeInt64},
+				{Name: "user.name", Type: TypeString},
+				{Name: "user.age", Type: TypeInt64},
+				{Name: "score", Type: TypeDouble},
+				{Name: "tags", Type: TypeString, Repeated: true},
+				{Name: "objects", Type: TypeString},
+				{Name: "mixed", Type: TypeString},
+				{Name: "note", Type: TypeString},
+				{Name: "active", Type: TypeBoolean},
+			},
+			expect: []map[string]interface{}{
+				{"id": int64(1), "user.name": "a", "user.age": int64(3), "score": 1.0, "tags": []interface{}{"x", "y"}, "objects": `[{"a":1}]`, "mixed": "1", "note": nil, "active": nil},
+				{"id": int64(2), "user.name": "b", "user.age": nil, "score": 2.5, "tags": nil, "objects": nil, "mixed": "x", "note": nil, "active": nil},
+				{"id": int64(3), "user.name": nil, "user.age": nil, "score": nil, "tags": nil, "objects": nil, "mixed": nil, "note": nil, "active": true},
+			},
+		},
+		{
+			description: "json with schema",
+			source: `{"id":1,"name":"a","skipped":true}
+{"id":"2","ids":[1,2]}
+`,
+			columns: []*Column{{Name: "id", Type: TypeInt64}, {Name: "name", Type: TypeString}, {Name: "ids", Type: TypeInt64, Repeated: true}},
+			expect: []map[string]interface{}{
+				{"id": int64(1), "name": "a", "ids": nil},
+				{"id": int64(2), "name": nil, "ids": []interface{}{int64(1), int64(2)}},
+			},
+		},
+		{
+			description: "inferred csv with header",
+			source:      "id,name,score,active\n1,\"a,b\",1,true\n2,,2.5,false\n,c,,\n",
+			options:     &Options{Format: FormatCSV, Header: true},
+			expectColumns: []*Column{
+				{Name: "id", Type: TypeInt64},
+				{Name: "name", Type: TypeString},
+				{Name: "score", Type: TypeDouble},
+				{Name: "active", Type: TypeBoolean},
+			},
+			expect: []map[string]interface{}{
+				{"id": int64(1), "name": "a,b", "score": 1.0, "active": true},
+				{"id": int64(2), "name": "", "score": 2.5, "active": false},
+				{"id": nil, "name": "c", "score": nil, "active": nil},
+			},
+		},
+		{
+			description: "tsv with schema",
+			source:      "1\tx:y\t2021-01-02T03:04:05Z\n2\t\t\n",
+			options:     &Options{Format: FormatCSV, Delimiter: "\t"},
+			columns:     []*Column{{Name: "id", Type: TypeInt64}, {Name: "tags", Type: TypeString, Repeated: true}, {Name: "ts", Type: TypeString}},
+			expect: []map[string]interface{}{
+				{"id": int64(1), "tags": []interface{}{"x", "y"}, "ts": "2021-01-02T03:04:05Z"},
+				{"id": int64(2), "tags": nil, "ts": ""},
+			},
+		},
+	}
+	for _, useCase := range useCases {
+		columns := useCase.columns
+		if columns == nil {
+			var err error
+			columns, err = Inspect(strings.NewReader(useCase.source), useCase.options)
+			if !assert.Nil(t, err, useCase.description) {
+				continue
+			}
+			assert.EqualValues(t, useCase.expectColumns, columns, useCase.description)
+		}
+		buffer := new(bytes.Buffer)
+		err := Convert(buffer, strings.NewReader(useCase.source), columns, useCase.options)
+		if !assert.Nil(t, err, useCase.description) {
+			continue
+		}
+		actual := readFile(t, buffer.Bytes())
+		assert.EqualValues(t, useCase.expect, actual.rows, useCase.description)
+	}
+}
+
+func TestConvert_Error(t *testing.T) {
+	var useCases = []struct {
+		description string
+		source      string
+		columns     []*Column
+		options     *Options
+		expectError string
+	}{
+		{
+			description: "invalid value",
+			source:      `{"id":"x"}`,
+			columns:     []*Column{{Name: "id", Type: TypeInt64}},
+			expectError: `invalid parquet column id value: strconv.ParseInt: parsing "x": invalid syntax`,
+		},
+		{
+			description: "null item",
+			source:      `{"ids":[1,null]}`,
+			columns:     []*Column{{Name: "ids", Type: TypeInt64, Repeated: true}},
+			expectError: "null item in parquet repeated column: ids",
+		},
+		{
+			description: "not an object",
+			source:      `[1]`,
+			columns:     []*Column{{Name: "id", Type: TypeInt64}},
+			expectError: "invalid JSON record: [1]",
+		},
+		{
+			description: "empty schema",
+			source:      `{}`,
+			expectError: "parquet schema was empty",
+		},
+		{
+			description: "duplicate column",
+			columns:     []*Column{{Name: "id", Type: TypeInt64}, {Name: "id", Type: TypeString}},
+			expectError: "duplicate parquet column: id",
+		},
+		{
+			description: "unsupported type",
+			columns:     []*Column{{Name: "id", Type: "int96"}},
+			expectError: "unsupported parquet column id type: int96",
+		},
+		{
+			description: "unsupported compression",
+			columns:     []*Column{{Name: "id", Type: TypeInt64}},
+			options:     &Options{Compression: "lzo"},
+			expectError: "unsupported parquet compression: lzo",
+		},
+		{
+			description: "csv without columns",
+			source:      "1,2\n",
+			columns:     []*Column{},
+			options:     &Options{Format: FormatCSV},
+			expectError: "parquet schema was empty",
+		},
+	}
+	for _, useCase := range useCases {
+		err := Convert(new(bytes.Buffer), strings.NewReader(useCase.source), useCase.columns, useCase.options)
+		if assert.NotNil(t, err, useCase.description) {
+			assert.EqualValues(t, useCase.expectError, err.Error(), useCase.description)
+		}
+	}
+}
+
+func TestNewSchema(t *testing.T) {
+	type event struct {
+		ID       int
+		Score    float64
+		Name     string
+		Active   bool
+		Expiry   *time.Time
+		Ratio    float32
+		Modified time.Time
+		IDs      []int
+		Tags     []string
+		Code     int  `tapper:"code,string"`
+		Secret   bool `tapper:"-"`
+		User     *struct {
+			ID   int    `tapper:"id"`
+			Name string `tapper:"name"`
+		} `tapper:"user"`
+		Items []struct {
+			ID int `tapper:"id"`
+		} `tapper:"items"`
+		Small int16             `tapper:"small"`
+		Data  []byte            `tapper:"data"`
+		Flags []bool            `tapper:"flags"`
+		Attrs map[string]string `tapper:"attrs"`
+		Addr  net.IP            `tapper:"addr"`
+	}
+	provider, err := encoder.New(&event{})
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.EqualValues(t, []*Column{
+		{Name: "ID", Type: TypeInt64},
+		{Name: "Score", Type: TypeDouble},
+		{Name: "Name", Type: TypeString},
+		{Name: "Active", Type: TypeBoolean},
+		{Name: "Expiry", Type: TypeString},
+		{Name: "Ratio", Type: TypeDouble},
+		{Name: "Modified", Type: TypeString},
+		{Name: "IDs", Type: TypeInt64, Repeated: true},
+		{Name: "Tags", Type: TypeString, Repeated: true},
+		{Name: "code", Type: TypeString},
+		{Name: "user.id", Type: TypeInt64},
+		{Name: "user.name", Type: TypeString},
+		{Name: "items", Type: TypeString},
+		{Name: "small", Type: TypeInt64},
+		{Name: "data", Type: TypeString},
+		{Name: "flags", Type: TypeBoolean, Repeated: true},
+		{Name: "addr", Type: TypeString},
+	}, NewSchema(provider))
+}